@@ -23,14 +23,7 @@ func main() {
 
 	log.Info("starting service")
 
-	application := app.New(
-		log,
-		cfg.Grpc.Port,
-		cfg.Rest.Port,
-		cfg.StoragePath,
-		cfg.TokenTTL,
-		cfg.Secret,
-	)
+	application := app.New(log, cfg)
 
 	// go application.GRPCSrv.MustRun()
 	go application.RestSrv.MustRun()
@@ -39,7 +32,7 @@ func main() {
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	sign := <-stop
 	log.Info("shutting down", slog.String("signal", sign.String()))
-	application.RestSrv.Stop()
+	application.Stop()
 }
 
 func setupLogger(env string) *slog.Logger {
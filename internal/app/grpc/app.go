@@ -6,6 +6,7 @@ import (
 	"net"
 
 	authgrpc "github.com/babs-corp/babs-maps-auth/internal/grpc/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
 	"google.golang.org/grpc"
 )
 
@@ -20,7 +21,9 @@ func New(
 	authService authgrpc.Auth,
 	port int,
 ) *App {
-	gRPCServer := grpc.NewServer()
+	gRPCServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(clientinfo.UnaryServerInterceptor),
+	)
 	authgrpc.Register(gRPCServer, authService)
 
 	return &App{
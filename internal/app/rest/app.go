@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
@@ -21,11 +22,14 @@ type App struct {
 func New(
 	log *slog.Logger,
 	auth rest.Auth,
+	webhooks rest.Webhooks,
+	quotas rest.Quotas,
 	port int,
+	trustedProxies []netip.Prefix,
 ) *App {
 	router := chi.NewRouter()
 
-	rest.InitRoutes(router, auth)
+	rest.InitRoutes(router, log, auth, webhooks, quotas, trustedProxies)
 	server := &http.Server{
 		Addr:    restPort(port),
 		Handler: router,
@@ -8,13 +8,28 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+// Config of the service. Tokens are signed with HS256 and Secret, which
+// resource servers need to verify them and so can forge tokens too, or,
+// if SigningKey is set, with EdDSA and the Ed25519 private key in PEM at
+// that path; resource servers then verify them with the public key.
 type Config struct {
-	Env         string        `yaml:"env" env-default:"local"`
-	StoragePath string        `yaml:"storage_path" env-required:"true"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
-	Grpc        GrpcConfig    `yaml:"grpc"`
-	Rest        RestConfig    `yaml:"rest"`
-	Secret      string        `yaml:"secret" env-required:"true"`
+	Env         string          `yaml:"env" env-default:"local"`
+	StoragePath string          `yaml:"storage_path" env-required:"true"`
+	AutoMigrate bool            `yaml:"auto_migrate" env-default:"false"`
+	TokenTTL    time.Duration   `yaml:"token_ttl" env-required:"true"`
+	Grpc        GrpcConfig      `yaml:"grpc"`
+	Rest        RestConfig      `yaml:"rest"`
+	Secret      string          `yaml:"secret"`
+	SigningKey  string          `yaml:"signing_key"`
+	Lockout     LockoutConfig   `yaml:"lockout"`
+	Password    PasswordConfig  `yaml:"password"`
+	UserCache   UserCacheConfig `yaml:"user_cache"`
+	Audit       AuditConfig     `yaml:"audit"`
+	Events      EventsConfig    `yaml:"events"`
+	Webhooks    WebhooksConfig  `yaml:"webhooks"`
+	Browser     BrowserConfig   `yaml:"browser_sessions"`
+	Apps        AppsConfig      `yaml:"apps"`
+	Quotas      QuotasConfig    `yaml:"quotas"`
 }
 
 type GrpcConfig struct {
@@ -22,9 +37,131 @@ type GrpcConfig struct {
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+// RestConfig configures the REST server. Client IPs, used by lockout,
+// audit and sessions, are read from X-Forwarded-For and X-Real-IP only
+// for requests coming from TrustedProxies, addresses or CIDR prefixes of
+// load balancers in front of the server.
 type RestConfig struct {
-	Port    int           `yaml:"port"`
-	Timeout time.Duration `yaml:"timeout"`
+	Port           int           `yaml:"port"`
+	Timeout        time.Duration `yaml:"timeout"`
+	TrustedProxies []string      `yaml:"trusted_proxies"`
+}
+
+// LockoutConfig configures brute-force protection of login.
+// Store is either "database" (shared between instances) or "memory".
+type LockoutConfig struct {
+	Store         string        `yaml:"store" env-default:"database"`
+	MaxAttempts   int           `yaml:"max_attempts" env-default:"5"`
+	IPMaxAttempts int           `yaml:"ip_max_attempts" env-default:"50"`
+	BaseDelay     time.Duration `yaml:"base_delay" env-default:"30s"`
+	MaxDelay      time.Duration `yaml:"max_delay" env-default:"1h"`
+	Window        time.Duration `yaml:"window" env-default:"24h"`
+}
+
+// PasswordConfig configures password hashing. Algorithm is used for new
+// hashes, hashes of other algorithms are upgraded on successful login.
+type PasswordConfig struct {
+	Algorithm  string         `yaml:"algorithm" env-default:"argon2id"`
+	BcryptCost int            `yaml:"bcrypt_cost" env-default:"10"`
+	Argon2     Argon2idConfig `yaml:"argon2"`
+	Policy     PolicyConfig   `yaml:"policy"`
+}
+
+// PolicyConfig configures rules for new passwords. BreachedPath is an
+// optional file with SHA-1 hashes of breached passwords, one per line.
+type PolicyConfig struct {
+	MinLength     int    `yaml:"min_length" env-default:"8"`
+	MaxBytes      int    `yaml:"max_bytes" env-default:"72"`
+	RequireUpper  bool   `yaml:"require_upper" env-default:"false"`
+	RequireLower  bool   `yaml:"require_lower" env-default:"false"`
+	RequireDigit  bool   `yaml:"require_digit" env-default:"false"`
+	RequireSymbol bool   `yaml:"require_symbol" env-default:"false"`
+	ForbidEmail   bool   `yaml:"forbid_email" env-default:"true"`
+	BreachedPath  string `yaml:"breached_path"`
+}
+
+// UserCacheConfig configures in-process cache of users looked up by id.
+// With Notify caches of all instances are invalidated through Postgres
+// LISTEN/NOTIFY, otherwise changes made elsewhere are seen after TTL.
+// Hit, miss and eviction counters are served to admins at /debug/vars.
+type UserCacheConfig struct {
+	Enabled bool          `yaml:"enabled" env-default:"false"`
+	Size    int           `yaml:"size" env-default:"10000"`
+	TTL     time.Duration `yaml:"ttl" env-default:"1m"`
+	Notify  bool          `yaml:"notify" env-default:"false"`
+}
+
+// AuditConfig configures audit log of security-relevant events. Events
+// are stored in the database; with FilePath they are also appended to
+// the file as JSON lines.
+type AuditConfig struct {
+	Enabled  bool   `yaml:"enabled" env-default:"true"`
+	FilePath string `yaml:"file_path"`
+}
+
+// EventsConfig configures publishing of user lifecycle events through the
+// transactional outbox. Publisher is "file", "webhook", "nats" or "kafka".
+// URL is the webhook URL, NATS server or Kafka REST Proxy; Topic is the
+// NATS subject prefix or Kafka topic. The relay settings also apply when
+// only webhooks are enabled.
+type EventsConfig struct {
+	Enabled      bool          `yaml:"enabled" env-default:"false"`
+	Publisher    string        `yaml:"publisher" env-default:"file"`
+	FilePath     string        `yaml:"file_path" env-default:"./storage/events.jsonl"`
+	URL          string        `yaml:"url"`
+	Topic        string        `yaml:"topic" env-default:"babs.auth"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	Lease        time.Duration `yaml:"lease" env-default:"5m"`
+	BaseDelay    time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay     time.Duration `yaml:"max_delay" env-default:"10m"`
+	Retention    time.Duration `yaml:"retention" env-default:"168h"`
+}
+
+// WebhooksConfig configures signed webhooks to partner endpoints. A
+// delivery failing MaxAttempts times is dead until redelivered.
+type WebhooksConfig struct {
+	Enabled      bool          `yaml:"enabled" env-default:"false"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+	MaxAttempts  int           `yaml:"max_attempts" env-default:"8"`
+	BaseDelay    time.Duration `yaml:"base_delay" env-default:"30s"`
+	MaxDelay     time.Duration `yaml:"max_delay" env-default:"6h"`
+	BatchSize    int           `yaml:"batch_size" env-default:"50"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	Lease        time.Duration `yaml:"lease" env-default:"5m"`
+}
+
+// BrowserConfig configures single sign-on sessions kept in browser
+// cookies. A session ends after IdleTimeout without requests and
+// AbsoluteTimeout after login, whichever is first.
+type BrowserConfig struct {
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env-default:"30m"`
+	AbsoluteTimeout time.Duration `yaml:"absolute_timeout" env-default:"12h"`
+}
+
+// AppsConfig configures registration of apps. With RegistrationToken
+// set, apps can register themselves with dynamic registration of RFC 7591
+// presenting it as the initial access token; admins can always register
+// apps through the API.
+type AppsConfig struct {
+	RegistrationToken string `yaml:"registration_token"`
+}
+
+// QuotasConfig configures request budgets map services check at
+// /quota/check. Budgets are cached for CacheTTL, so changed budgets apply
+// to running instances with that delay.
+type QuotasConfig struct {
+	Enabled  bool          `yaml:"enabled" env-default:"false"`
+	CacheTTL time.Duration `yaml:"cache_ttl" env-default:"30s"`
+}
+
+type Argon2idConfig struct {
+	Memory      uint32 `yaml:"memory" env-default:"65536"`
+	Iterations  uint32 `yaml:"iterations" env-default:"3"`
+	Parallelism uint8  `yaml:"parallelism" env-default:"2"`
+	SaltLength  uint32 `yaml:"salt_length" env-default:"16"`
+	KeyLength   uint32 `yaml:"key_length" env-default:"32"`
 }
 
 func MustLoad() *Config {
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey lets programs call map services without a login. Prefix is the
+// visible start of the key used to find it; only SHA-256 of the whole key
+// is kept. Keys of an organization have OrgID set and are managed by
+// every member of it; UserID is the member who created the key.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	Prefix     string     `json:"prefix"`
+	Hash       []byte     `json:"-"`
+	Name       string     `json:"name"`
+	UserID     uuid.UUID  `json:"user_id"`
+	OrgID      *uuid.UUID `json:"org_id,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key is accepted at now
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && now.Before(k.ExpiresAt)
+}
@@ -1,7 +1,50 @@
 package models
 
+import "time"
+
+// Types of apps. Confidential apps keep their secret on a server, public
+// ones (single-page and mobile apps) cannot keep a secret and have none.
+const (
+	AppTypeConfidential = "confidential"
+	AppTypePublic       = "public"
+)
+
+// Grant types apps may be allowed. Password is the login operation,
+// authorization code is sign-in through hosted pages that send the user
+// back to a redirect URI.
+const (
+	GrantTypePassword          = "password"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// App is a client application of the service. Display name, logo and
+// colors brand hosted login pages, empty values fall back to defaults.
+// Users are not asked to consent to first-party apps. Tokens of the app
+// may carry only its Scopes and live for TokenTTL, if it is set.
+//
+// Secret is the SHA-256 hash of the secret of the app, the secret itself
+// is shown only when it is generated.
 type App struct {
-	ID int
-	Name string
-	Secret string
-}
\ No newline at end of file
+	ID              int           `db:"id"`
+	Name            string        `db:"name"`
+	Secret          string        `db:"secret"`
+	Type            string        `db:"type"`
+	FirstParty      bool          `db:"first_party"`
+	RedirectURIs    []string      `db:"redirect_uris"`
+	GrantTypes      []string      `db:"grant_types"`
+	Scopes          []string      `db:"scopes"`
+	TokenTTL        time.Duration `db:"token_ttl"`
+	DisplayName     string        `db:"display_name"`
+	LogoURL         string        `db:"logo_url"`
+	PrimaryColor    string        `db:"primary_color"`
+	BackgroundColor string        `db:"background_color"`
+}
+
+// Branding is the look of hosted pages shown for an app
+type Branding struct {
+	Name            string
+	LogoURL         string
+	PrimaryColor    string
+	BackgroundColor string
+}
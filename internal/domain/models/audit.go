@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of audit events
+const (
+	AuditLogin           = "login"
+	AuditLogout          = "logout"
+	AuditRegister        = "register"
+	AuditPasswordChange  = "password.change"
+	AuditPasswordReset   = "password.reset"
+	AuditUserUnlock      = "user.unlock"
+	AuditSessionRevoke   = "session.revoke"
+	AuditGrantCreate     = "grant.create"
+	AuditGrantRevoke     = "grant.revoke"
+	AuditAppCreate       = "app.create"
+	AuditAppUpdate       = "app.update"
+	AuditAppDelete       = "app.delete"
+	AuditAppSecretRotate = "app.secret_rotate"
+	AuditAPIKeyCreate    = "api_key.create"
+	AuditAPIKeyRevoke    = "api_key.revoke"
+)
+
+// Outcomes of audited actions
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent is a record of a security-relevant action. Actor is who
+// performed the action, Subject is the user it was performed on; they are
+// the same user e.g. for login and differ for admin actions. Email keeps
+// the email of the subject as entered, so failed logins of unknown users
+// are traceable too.
+type AuditEvent struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Type      string     `json:"type" db:"type"`
+	Outcome   string     `json:"outcome" db:"outcome"`
+	Reason    string     `json:"reason,omitempty" db:"reason"`
+	ActorID   *uuid.UUID `json:"actor_id,omitempty" db:"actor_id"`
+	SubjectID *uuid.UUID `json:"subject_id,omitempty" db:"subject_id"`
+	Email     string     `json:"email,omitempty" db:"email"`
+	IP        string     `json:"ip,omitempty" db:"ip"`
+	UserAgent string     `json:"user_agent,omitempty" db:"user_agent"`
+	AppID     int        `json:"app_id,omitempty" db:"app_id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter selects audit events. Zero values of fields mean "any".
+type AuditFilter struct {
+	Type      string
+	Outcome   string
+	ActorID   *uuid.UUID
+	SubjectID *uuid.UUID
+	IP        string
+	From      time.Time
+	To        time.Time
+}
+
+// AuditCursor points at the last event of the previous page
+type AuditCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// AuditQuery is a request for one page of audit events, newest first
+type AuditQuery struct {
+	Filter AuditFilter
+	Limit  uint
+	// After is nil for the first page
+	After *AuditCursor
+}
+
+// AuditPage is one page of audit events. NextCursor is empty on the last page.
+type AuditPage struct {
+	Events     []AuditEvent
+	NextCursor string
+}
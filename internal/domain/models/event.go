@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of user lifecycle events published to other services
+const (
+	EventUserCreated = "user.created"
+)
+
+// Event is a domain event published to other services. SubjectID is the
+// user the event is about; publishers use it as the message key, so that
+// events of one user land in one partition.
+type Event struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	SubjectID uuid.UUID       `json:"subject_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// OutboxEvent is an Event kept in the outbox until it is published
+type OutboxEvent struct {
+	Event
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	PublishedAt   *time.Time
+}
+
+// UserEventPayload is the payload of user lifecycle events. AppID is the
+// app the user came through, 0 if unknown.
+type UserEventPayload struct {
+	ID       uuid.UUID  `json:"id"`
+	Email    string     `json:"email"`
+	OrgID    *uuid.UUID `json:"org_id,omitempty"`
+	AppID    int        `json:"app_id,omitempty"`
+	Verified bool       `json:"verified"`
+	Disabled bool       `json:"disabled"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Grant is the consent of a user to an app. Scopes accumulate over
+// consents, so the user is asked again only for scopes not granted yet.
+type Grant struct {
+	UserID    uuid.UUID `json:"user_id"`
+	AppID     int       `json:"app_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Covers reports whether every scope of scopes is granted
+func (g Grant) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(g.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LoginAttempts is a counter of failed logins for a single key
+// (e.g. an account email or a client IP).
+type LoginAttempts struct {
+	Key          string    `db:"key"`
+	Failures     int       `db:"failures"`
+	LastFailedAt time.Time `db:"last_failed_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "github.com/google/uuid"
+
+// Principal is an authenticated caller of the API
+type Principal struct {
+	UserID uuid.UUID
+	// SessionID is the session of the token, uuid.Nil for tokens issued
+	// without one
+	SessionID uuid.UUID
+	// AppID is the app the token was issued to, 0 for tokens issued
+	// without one
+	AppID int
+	// OrgID is the organization of the user or of the API key
+	OrgID *uuid.UUID
+	// IsAdmin allows operations of the admin API. Tokens of third-party
+	// apps never get it.
+	IsAdmin bool
+	// ThirdParty is set for tokens of apps which are not first-party:
+	// they act on behalf of the user only within Scopes
+	ThirdParty bool
+	// APIKeyID is the key the caller authenticated with, uuid.Nil for
+	// tokens
+	APIKeyID uuid.UUID
+	// Scopes are granted to the app the token was issued to or to the key
+	Scopes []string
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// Types of subjects quotas are defined for
+const (
+	QuotaSubjectApp    = "app"
+	QuotaSubjectOrg    = "org"
+	QuotaSubjectAPIKey = "api_key"
+)
+
+// Periods of quota budgets
+const (
+	QuotaPeriodMinute = "minute"
+	QuotaPeriodDay    = "day"
+	QuotaPeriodMonth  = "month"
+)
+
+// QuotaSubject is what requests are counted against. ID is the app id,
+// organization id or API key id in text form.
+type QuotaSubject struct {
+	Type string `json:"subject_type"`
+	ID   string `json:"subject_id"`
+}
+
+// Quota is the request budget of a subject. The minute budget is a token
+// bucket refilled evenly over a minute; day and month budgets are
+// calendar periods in UTC. Zero limits are unlimited.
+type Quota struct {
+	QuotaSubject
+	PerMinute int64     `json:"per_minute"`
+	PerDay    int64     `json:"per_day"`
+	PerMonth  int64     `json:"per_month"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QuotaBucket is the state of the minute budget of a subject
+type QuotaBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// QuotaUsage is the count of requests of a subject in the day or month
+// starting at Start
+type QuotaUsage struct {
+	QuotaSubject
+	Period string    `json:"period"`
+	Start  time.Time `json:"start"`
+	Used   int64     `json:"used"`
+}
+
+// UsageFilter selects usage of a period. Zero values of other fields mean
+// "any"; From and To bound starts of periods.
+type UsageFilter struct {
+	Period  string
+	Subject *QuotaSubject
+	From    time.Time
+	To      time.Time
+}
+
+// QuotaBudget is the remaining budget of a subject in a period. ResetAt
+// is when the budget allows a request of the same cost again for the
+// minute budget and the start of the next period otherwise.
+type QuotaBudget struct {
+	QuotaSubject
+	Period    string    `json:"period"`
+	Limit     int64     `json:"limit"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// QuotaDecision is the outcome of a quota check. Budget is the exhausted
+// budget of a denied request and the tightest budget of an allowed one;
+// it is nil if no quota applies.
+type QuotaDecision struct {
+	Allowed bool
+	Budget  *QuotaBudget
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login of a user on a device. Its ID identifies the token
+// family: every token issued for the login carries it in the "sid" claim
+// and is rejected once the session is revoked.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	AppID      int        `json:"app_id,omitempty"`
+	Device     string     `json:"device"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// CookieHash is SHA-256 of the cookie of a browser session, nil for
+	// sessions of tokens
+	CookieHash []byte `json:"-"`
+}
+
+// IsBrowser reports whether the session is kept in a browser cookie
+func (s Session) IsBrowser() bool {
+	return s.CookieHash != nil
+}
+
+// Active reports whether tokens of the session are accepted at now
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
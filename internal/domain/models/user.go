@@ -6,10 +6,29 @@ import (
 	"github.com/google/uuid"
 )
 
+// User is a stored user. It must never be serialized to clients directly,
+// use UserViewFor instead.
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	PassHash  []byte    `json:"password_hash" db:"pass_hash"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID        uuid.UUID  `json:"-" db:"id"`
+	Username  string     `json:"-" db:"username"`
+	Email     string     `json:"-" db:"email"`
+	PassHash  []byte     `json:"-" db:"pass_hash"`
+	IsAdmin   bool       `json:"-" db:"is_admin"`
+	Verified  bool       `json:"-" db:"verified"`
+	Disabled  bool       `json:"-" db:"disabled"`
+	OrgID     *uuid.UUID `json:"-" db:"org_id"`
+	CreatedAt time.Time  `json:"-" db:"created_at"`
+}
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Role returns name of the user's role
+func (u User) Role() string {
+	if u.IsAdmin {
+		return RoleAdmin
+	}
+	return RoleUser
 }
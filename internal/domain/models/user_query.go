@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserSortCreatedAt = "created_at"
+	UserSortEmail     = "email"
+)
+
+// UserFilter selects users. Zero values of fields mean "any".
+type UserFilter struct {
+	EmailPrefix   string
+	Role          string
+	Verified      *bool
+	Disabled      *bool
+	OrgID         *uuid.UUID
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// UserCursor points at the last user of the previous page
+type UserCursor struct {
+	Sort      string    `json:"s"`
+	Desc      bool      `json:"d,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Email     string    `json:"e,omitempty"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// UserQuery is a request for one page of users
+type UserQuery struct {
+	Filter UserFilter
+	// Sort is UserSortCreatedAt or UserSortEmail
+	Sort  string
+	Desc  bool
+	Limit uint
+	// After is nil for the first page
+	After *UserCursor
+}
+
+// UserPage is one page of users. NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []User
+	NextCursor string
+	Total      int
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserView is a user as seen by a particular caller. Fields which
+// the caller may not see are left empty and omitted from responses.
+type UserView struct {
+	ID        uuid.UUID  `json:"id" doc:"user uid"`
+	Username  string     `json:"username,omitempty" doc:"user name"`
+	Email     string     `json:"email,omitempty" doc:"visible to the user and admins"`
+	CreatedAt *time.Time `json:"created_at,omitempty" doc:"visible to the user and admins"`
+	IsAdmin   *bool      `json:"is_admin,omitempty" doc:"visible to admins"`
+}
+
+// PublicUserView returns fields of the user visible to anyone
+func PublicUserView(u User) UserView {
+	return UserView{
+		ID:       u.ID,
+		Username: u.Username,
+	}
+}
+
+// SelfUserView returns fields of the user visible to the user itself
+func SelfUserView(u User) UserView {
+	v := PublicUserView(u)
+	v.Email = u.Email
+	createdAt := u.CreatedAt
+	v.CreatedAt = &createdAt
+
+	return v
+}
+
+// AdminUserView returns every field of the user except secrets
+func AdminUserView(u User) UserView {
+	v := SelfUserView(u)
+	isAdmin := u.IsAdmin
+	v.IsAdmin = &isAdmin
+
+	return v
+}
+
+// UserViewFor returns the widest view of the user allowed for viewer
+func UserViewFor(viewer Principal, u User) UserView {
+	switch {
+	case viewer.IsAdmin:
+		return AdminUserView(u)
+	case viewer.UserID == u.ID:
+		return SelfUserView(u)
+	default:
+		return PublicUserView(u)
+	}
+}
+
+// UserViewsFor applies UserViewFor to every user
+func UserViewsFor(viewer Principal, users []User) []UserView {
+	views := make([]UserView, 0, len(users))
+	for _, u := range users {
+		views = append(views, UserViewFor(viewer, u))
+	}
+
+	return views
+}
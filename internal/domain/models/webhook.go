@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of webhook deliveries. Deliveries which failed MaxAttempts
+// times are dead and are only sent again by an explicit redelivery.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookDead      = "dead"
+)
+
+// WebhookSubscription is an HTTP endpoint of a partner receiving user
+// lifecycle events about members of the organization OrgID and about
+// users of the app AppID: those who came through the app or consented to
+// it. At least one of them is set. Empty EventTypes subscribes to all
+// events.
+type WebhookSubscription struct {
+	ID         uuid.UUID  `json:"id"`
+	AppID      *int       `json:"app_id,omitempty"`
+	OrgID      *uuid.UUID `json:"org_id,omitempty"`
+	URL        string     `json:"url"`
+	Secret     string     `json:"-"`
+	EventTypes []string   `json:"event_types"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Matches reports whether event of type typ about a user of orgID and
+// of apps appIDs must be sent to the subscription. Subscriptions with
+// neither organization nor app match nothing.
+func (s WebhookSubscription) Matches(typ string, orgID *uuid.UUID, appIDs []int) bool {
+	if len(s.EventTypes) > 0 && !slices.Contains(s.EventTypes, typ) {
+		return false
+	}
+	if s.OrgID != nil && orgID != nil && *orgID == *s.OrgID {
+		return true
+	}
+
+	return s.AppID != nil && slices.Contains(appIDs, *s.AppID)
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a subscription.
+// Payload is the request body; deliveries make the delivery log.
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	SubscriptionID uuid.UUID       `json:"subscription_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"-"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatus     int             `json:"last_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+type WebhookDeliveryFilter struct {
+	SubscriptionID *uuid.UUID
+	EventID        *uuid.UUID
+	Status         string
+}
+
+// WebhookDeliveryCursor is the position after the last delivery of a page
+type WebhookDeliveryCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+type WebhookDeliveryQuery struct {
+	Filter WebhookDeliveryFilter
+	Limit  uint
+	After  *WebhookDeliveryCursor
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []WebhookDelivery
+	NextCursor string
+}
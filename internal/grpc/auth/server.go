@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"github.com/babs-corp/babs-maps-auth/internal/lib/password"
 	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
 	ssov1 "github.com/babs-corp/babs-maps-protos/gen/go/sso"
 	"google.golang.org/grpc"
@@ -50,6 +51,12 @@ func (s *serverAPI) Login(
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "invalid credentials")
 		}
+		if errors.Is(err, auth.ErrAccountLocked) || errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many login attempts, try again later")
+		}
+		if errors.Is(err, auth.ErrUserDisabled) {
+			return nil, status.Error(codes.PermissionDenied, "user is disabled")
+		}
 
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -64,13 +71,17 @@ func (s *serverAPI) Register(
 	req *ssov1.RegisterRequest,
 ) (*ssov1.RegisterResponse, error) {
 	if err := validateRegister(req); err != nil {
-		if errors.Is(err, auth.ErrUserExists) {
-			return nil, status.Error(codes.AlreadyExists, "user already exists")
-		}
 		return nil, err
 	}
 	userID, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword())
 	if err != nil {
+		var policyErr *password.PolicyError
+		if errors.As(err, &policyErr) {
+			return nil, status.Error(codes.InvalidArgument, policyErr.Error())
+		}
+		if errors.Is(err, auth.ErrUserExists) {
+			return nil, status.Error(codes.AlreadyExists, "user already exists")
+		}
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 	return &ssov1.RegisterResponse{
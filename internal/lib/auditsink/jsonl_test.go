@@ -0,0 +1,48 @@
+package auditsink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	// events are appended to the existing file
+	for i := 0; i < 2; i++ {
+		sink, err := NewJSONLines(path)
+		require.NoError(t, err)
+
+		require.NoError(t, sink.WriteAuditEvent(context.Background(), models.AuditEvent{
+			ID:        uuid.New(),
+			Type:      models.AuditLogin,
+			Outcome:   models.AuditSuccess,
+			CreatedAt: time.Now().UTC(),
+		}))
+		require.NoError(t, sink.Close())
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var lines int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event models.AuditEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		assert.Equal(t, models.AuditLogin, event.Type)
+		lines++
+	}
+	assert.Equal(t, 2, lines)
+}
@@ -0,0 +1,148 @@
+package clientinfo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Info describes the client that issued the current request.
+type Info struct {
+	IP        string
+	UserAgent string
+	// AppID is the app the client signs in to, 0 if unknown
+	AppID int
+}
+
+type ctxKey struct{}
+
+// WithInfo returns a copy of ctx carrying client info
+func WithInfo(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// FromContext returns client info stored in ctx, or empty Info if there is none
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(ctxKey{}).(Info)
+	return info
+}
+
+// Middleware returns middleware storing client info of every http
+// request in its context.
+//
+// The client IP is the address of the peer. Only peers in trustedProxies
+// are believed about the client: for them the rightmost address of
+// X-Forwarded-For which is not a trusted proxy is used, or X-Real-IP if
+// there is no X-Forwarded-For. Anyone else could rotate these headers to
+// evade per-IP limits.
+func Middleware(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := Info{
+				IP:        clientIP(r, trustedProxies),
+				UserAgent: r.UserAgent(),
+			}
+			next.ServeHTTP(w, r.WithContext(WithInfo(r.Context(), info)))
+		})
+	}
+}
+
+// ParsePrefixes parses addresses and CIDR prefixes of trusted proxies
+func ParsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	peer := hostOnly(r.RemoteAddr)
+	if !trusted(peer, trustedProxies) {
+		return peer
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			addr, err := netip.ParseAddr(hop)
+			if err != nil {
+				// proxies append valid addresses, so the header is forged
+				return peer
+			}
+			if !trusted(hop, trustedProxies) {
+				return addr.Unmap().String()
+			}
+		}
+		return peer
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+
+	return peer
+}
+
+func trusted(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor stores client info of every gRPC call in its context
+func UnaryServerInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	var info Info
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		info.IP = hostOnly(p.Addr.String())
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			info.UserAgent = ua[0]
+		}
+	}
+
+	return handler(WithInfo(ctx, info), req)
+}
+
+func hostOnly(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
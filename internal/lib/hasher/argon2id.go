@@ -0,0 +1,158 @@
+package hasher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const AlgorithmArgon2id = "argon2id"
+
+var _ Algorithm = Argon2id{}
+
+var argon2idPrefix = []byte("$argon2id$")
+
+var ErrInvalidArgon2id = errors.New("invalid argon2id parameters")
+
+const (
+	// minimal salt and key lengths allowed by RFC 9106
+	argon2idMinSalt = 8
+	argon2idMinKey  = 4
+)
+
+// Argon2id hashes passwords with argon2id and encodes them in PHC string
+// format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type Argon2id struct {
+	// Memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// Validate checks parameters, argon2.IDKey panics on zero iterations or
+// parallelism and silently raises memory below 8 KiB per lane.
+func (a Argon2id) Validate() error {
+	if err := validateArgon2id(a.Memory, a.Iterations, a.Parallelism); err != nil {
+		return err
+	}
+	if a.SaltLength < argon2idMinSalt {
+		return fmt.Errorf("%w: salt length must be at least %d", ErrInvalidArgon2id, argon2idMinSalt)
+	}
+	if a.KeyLength < argon2idMinKey {
+		return fmt.Errorf("%w: key length must be at least %d", ErrInvalidArgon2id, argon2idMinKey)
+	}
+
+	return nil
+}
+
+func (a Argon2id) Name() string {
+	return AlgorithmArgon2id
+}
+
+func (a Argon2id) Identify(hash []byte) bool {
+	return bytes.HasPrefix(hash, argon2idPrefix)
+}
+
+func (a Argon2id) Hash(password []byte) ([]byte, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("cannot generate salt: %w", err)
+	}
+
+	key := argon2.IDKey(password, salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	encoded := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+
+	return []byte(encoded), nil
+}
+
+func (a Argon2id) Compare(hash []byte, password []byte) error {
+	h, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	key := argon2.IDKey(password, h.salt, h.iterations, h.memory, h.parallelism, uint32(len(h.key)))
+	if subtle.ConstantTimeCompare(key, h.key) != 1 {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+func (a Argon2id) Outdated(hash []byte) bool {
+	h, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return h.version != argon2.Version ||
+		h.memory < a.Memory ||
+		h.iterations < a.Iterations ||
+		h.parallelism < a.Parallelism ||
+		uint32(len(h.salt)) < a.SaltLength ||
+		uint32(len(h.key)) < a.KeyLength
+}
+
+func parseArgon2id(hash []byte) (argon2idHash, error) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=2", salt, key
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return argon2idHash{}, ErrMalformedHash
+	}
+
+	var h argon2idHash
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &h.version); err != nil {
+		return argon2idHash{}, ErrMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.iterations, &h.parallelism); err != nil {
+		return argon2idHash{}, ErrMalformedHash
+	}
+	if validateArgon2id(h.memory, h.iterations, h.parallelism) != nil {
+		return argon2idHash{}, ErrMalformedHash
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argon2idHash{}, ErrMalformedHash
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.key) == 0 {
+		return argon2idHash{}, ErrMalformedHash
+	}
+
+	return h, nil
+}
+
+func validateArgon2id(memory uint32, iterations uint32, parallelism uint8) error {
+	switch {
+	case iterations < 1:
+		return fmt.Errorf("%w: iterations must be at least 1", ErrInvalidArgon2id)
+	case parallelism < 1:
+		return fmt.Errorf("%w: parallelism must be at least 1", ErrInvalidArgon2id)
+	case uint64(memory) < 8*uint64(parallelism):
+		return fmt.Errorf("%w: memory must be at least 8 KiB per lane", ErrInvalidArgon2id)
+	}
+
+	return nil
+}
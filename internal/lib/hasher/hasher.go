@@ -0,0 +1,87 @@
+// Package hasher hashes and verifies passwords with several algorithms.
+//
+// Hashes are self-describing: every algorithm writes its own prefix
+// ("$2a$" for bcrypt, "$argon2id$" for argon2id), so a hash produced by
+// any supported algorithm can be verified regardless of the current default.
+package hasher
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMismatch         = errors.New("password does not match hash")
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+)
+
+// Algorithm is a single password hashing algorithm
+type Algorithm interface {
+	// Name returns algorithm name used in config
+	Name() string
+	// Identify reports whether hash was produced by the algorithm
+	Identify(hash []byte) bool
+	Hash(password []byte) ([]byte, error)
+	// Compare returns ErrMismatch if password does not match hash
+	Compare(hash []byte, password []byte) error
+	// Outdated reports whether hash uses weaker parameters than configured
+	Outdated(hash []byte) bool
+}
+
+// Hasher produces hashes with the default algorithm and verifies hashes
+// of any known algorithm.
+type Hasher struct {
+	def   Algorithm
+	algos []Algorithm
+}
+
+// New returns Hasher which uses def for new hashes. Hashes of def and
+// of every algorithm from others can be verified.
+func New(def Algorithm, others ...Algorithm) *Hasher {
+	return &Hasher{
+		def:   def,
+		algos: append([]Algorithm{def}, others...),
+	}
+}
+
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	return h.def.Hash([]byte(password))
+}
+
+func (h *Hasher) Compare(hash []byte, password string) error {
+	algo, err := h.identify(hash)
+	if err != nil {
+		return err
+	}
+
+	return algo.Compare(hash, []byte(password))
+}
+
+// NeedsRehash reports whether hash should be replaced with a hash
+// produced by the default algorithm with current parameters
+func (h *Hasher) NeedsRehash(hash []byte) bool {
+	if !h.def.Identify(hash) {
+		return true
+	}
+
+	return h.def.Outdated(hash)
+}
+
+func (h *Hasher) identify(hash []byte) (Algorithm, error) {
+	for _, algo := range h.algos {
+		if algo.Identify(hash) {
+			return algo, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, prefix(hash))
+}
+
+func prefix(hash []byte) string {
+	const maxLen = 10
+	if len(hash) > maxLen {
+		return string(hash[:maxLen])
+	}
+	return string(hash)
+}
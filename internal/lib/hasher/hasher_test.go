@@ -0,0 +1,121 @@
+package hasher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testBcrypt   = Bcrypt{Cost: 4}
+	testArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+)
+
+func TestAlgorithm_RoundTrip(t *testing.T) {
+	for _, algo := range []Algorithm{testBcrypt, testArgon2id} {
+		t.Run(algo.Name(), func(t *testing.T) {
+			hash, err := algo.Hash([]byte("correct horse"))
+			require.NoError(t, err)
+
+			assert.True(t, algo.Identify(hash))
+			assert.NoError(t, algo.Compare(hash, []byte("correct horse")))
+			assert.ErrorIs(t, algo.Compare(hash, []byte("wrong horse")), ErrMismatch)
+			assert.False(t, algo.Outdated(hash))
+
+			// salted, so the same password gives different hashes
+			again, err := algo.Hash([]byte("correct horse"))
+			require.NoError(t, err)
+			assert.NotEqual(t, hash, again)
+		})
+	}
+}
+
+func TestAlgorithm_Outdated(t *testing.T) {
+	bcryptHash, err := testBcrypt.Hash([]byte("pass"))
+	require.NoError(t, err)
+	assert.True(t, Bcrypt{Cost: 5}.Outdated(bcryptHash))
+	assert.False(t, Bcrypt{Cost: 4}.Outdated(bcryptHash))
+
+	argonHash, err := testArgon2id.Hash([]byte("pass"))
+	require.NoError(t, err)
+
+	stronger := []Argon2id{
+		{Memory: 128, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		{Memory: 64, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		{Memory: 64, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32},
+		{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 32, KeyLength: 32},
+		{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 64},
+	}
+	for _, a := range stronger {
+		assert.True(t, a.Outdated(argonHash), "%+v", a)
+	}
+
+	assert.True(t, testBcrypt.Outdated([]byte("garbage")))
+	assert.True(t, testArgon2id.Outdated([]byte("garbage")))
+}
+
+func TestArgon2id_MalformedHash(t *testing.T) {
+	valid, err := testArgon2id.Hash([]byte("pass"))
+	require.NoError(t, err)
+	require.NoError(t, testArgon2id.Compare(valid, []byte("pass")))
+
+	hashes := map[string]string{
+		"empty":             "",
+		"too few parts":     "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ",
+		"too many parts":    "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5$x",
+		"other algorithm":   "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5",
+		"bad version":       "$argon2id$v=x$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5",
+		"bad params":        "$argon2id$v=19$m=64,t=1$c2FsdHNhbHQ$a2V5a2V5",
+		"zero iterations":   "$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$a2V5a2V5",
+		"zero parallelism":  "$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHQ$a2V5a2V5",
+		"memory below lane": "$argon2id$v=19$m=15,t=1,p=2$c2FsdHNhbHQ$a2V5a2V5",
+		"bad salt":          "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5a2V5",
+		"bad key":           "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$!!!",
+		"empty key":         "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$",
+	}
+	for name, hash := range hashes {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, testArgon2id.Compare([]byte(hash), []byte("pass")), ErrMalformedHash)
+		})
+	}
+}
+
+func TestArgon2id_Validate(t *testing.T) {
+	require.NoError(t, testArgon2id.Validate())
+
+	invalid := map[string]Argon2id{
+		"zero iterations":   {Memory: 64, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		"zero parallelism":  {Memory: 64, Iterations: 1, Parallelism: 0, SaltLength: 16, KeyLength: 32},
+		"memory below lane": {Memory: 15, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32},
+		"short salt":        {Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 7, KeyLength: 32},
+		"short key":         {Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 3},
+	}
+	for name, a := range invalid {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, a.Validate(), ErrInvalidArgon2id)
+		})
+	}
+}
+
+func TestHasher(t *testing.T) {
+	old := New(testBcrypt)
+	h := New(testArgon2id, testBcrypt)
+
+	bcryptHash, err := old.Hash("pass")
+	require.NoError(t, err)
+
+	// hashes of other known algorithms verify and are upgraded
+	assert.NoError(t, h.Compare(bcryptHash, "pass"))
+	assert.ErrorIs(t, h.Compare(bcryptHash, "other"), ErrMismatch)
+	assert.True(t, h.NeedsRehash(bcryptHash))
+
+	argonHash, err := h.Hash("pass")
+	require.NoError(t, err)
+	assert.NoError(t, h.Compare(argonHash, "pass"))
+	assert.False(t, h.NeedsRehash(argonHash))
+
+	// the old hasher does not know argon2id
+	assert.ErrorIs(t, old.Compare(argonHash, "pass"), ErrUnknownAlgorithm)
+	assert.ErrorIs(t, h.Compare([]byte("plaintext"), "plaintext"), ErrUnknownAlgorithm)
+}
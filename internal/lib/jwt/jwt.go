@@ -1,21 +1,88 @@
 package jwt
 
 import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-func NewToken(users models.User, secret string, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+// SigningKey signs tokens. With Private set tokens are signed with EdDSA
+// and resource servers verify them with the public key. Otherwise they
+// are signed with HS256 and Secret, which resource servers must share
+// and so can issue tokens themselves.
+type SigningKey struct {
+	Secret  string
+	Private ed25519.PrivateKey
+}
+
+// Validate checks token signed with the key, see authz.Validate
+func (k SigningKey) Validate(token string) (authz.Claims, error) {
+	if k.Private != nil {
+		return authz.ValidateWithKey(token, k.Private.Public())
+	}
+
+	return authz.Validate(token, k.Secret)
+}
+
+// LoadPrivateKey reads PEM encoded Ed25519 private key in PKCS #8 form, as
+// made by "openssl genpkey -algorithm ed25519"
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	const op = "jwt.LoadPrivateKey"
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := jwt.ParseEdPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key.(ed25519.PrivateKey), nil
+}
+
+// NewToken issues token of the user. Token of a session carries sessionID
+// in the "sid" claim, uuid.Nil means that the token is not bound to one.
+// Token of an app carries appID in the "app" claim. Scopes granted to the app are put in the "scope" claim, see authz.
+func NewToken(
+	users models.User,
+	sessionID uuid.UUID,
+	appID int,
+	scopes []string,
+	key SigningKey,
+	duration time.Duration,
+) (string, error) {
+	var (
+		method  jwt.SigningMethod = jwt.SigningMethodHS256
+		signKey any               = []byte(key.Secret)
+	)
+	if key.Private != nil {
+		method, signKey = jwt.SigningMethodEdDSA, key.Private
+	}
+	token := jwt.New(method)
 
 	claims := token.Claims.(jwt.MapClaims)
-	claims["uid"] = users.ID.String()
-	claims["email"] = users.Email
-	claims["exp"] = time.Now().Add(duration).Unix()
+	claims[authz.ClaimUserID] = users.ID.String()
+	claims[authz.ClaimEmail] = users.Email
+	claims[authz.ClaimExpires] = time.Now().Add(duration).Unix()
+	if sessionID != uuid.Nil {
+		claims[authz.ClaimSessionID] = sessionID.String()
+	}
+	if appID != 0 {
+		claims[authz.ClaimAppID] = appID
+	}
+	if len(scopes) > 0 {
+		claims[authz.ClaimScope] = authz.FormatScope(scopes)
+	}
 
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := token.SignedString(signKey)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,32 @@
+// Package paging encodes positions of keyset pagination into opaque
+// cursors clients pass back to get the next page.
+package paging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// EncodeCursor returns c as an opaque string. T must marshal to JSON without
+// errors, e.g. a struct of times, ids and strings.
+func EncodeCursor[T any](c T) string {
+	// marshaling of cursor structs cannot fail
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses cursor returned by EncodeCursor
+func DecodeCursor[T any](cursor string) (T, error) {
+	var c T
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,30 @@
+package paging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type position struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	want := position{CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 5, time.UTC), ID: uuid.New()}
+
+	got, err := DecodeCursor[position](EncodeCursor(want))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestCursor_Invalid(t *testing.T) {
+	for _, c := range []string{"!!!", EncodeCursor("not an object"), EncodeCursor(map[string]string{"t": "yesterday"})} {
+		_, err := DecodeCursor[position](c)
+		assert.Error(t, err, c)
+	}
+}
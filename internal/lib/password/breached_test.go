@@ -0,0 +1,51 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadBreached(t *testing.T) {
+	// SHA-1 of "password" and "123456", as in Have I Been Pwned dumps
+	path := writeFile(t, `# breached passwords
+5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824
+
+7c4a8d09ca3762af61e59520943dc26494f8941b
+`)
+
+	list, err := LoadBreached(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, list.Len())
+	assert.True(t, list.Contains("password"))
+	assert.True(t, list.Contains("123456"))
+	assert.False(t, list.Contains("Password"))
+}
+
+func TestLoadBreached_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"short hash": "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd\n",
+		"not hex":    "# ok\nzzaa61e4c9b93f3f0682250b6cf8331b7ee68fd8\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadBreached(writeFile(t, content))
+			assert.ErrorContains(t, err, "invalid hash on line")
+		})
+	}
+
+	_, err := LoadBreached(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,147 @@
+// Package password validates passwords against a configurable policy.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrPolicyViolation = errors.New("password violates policy")
+
+// Rule names reported in Violation.Rule
+const (
+	RuleMinLength     = "min_length"
+	RuleMaxLength     = "max_length"
+	RuleUppercase     = "uppercase"
+	RuleLowercase     = "lowercase"
+	RuleDigit         = "digit"
+	RuleSymbol        = "symbol"
+	RuleContainsEmail = "contains_email"
+	RuleBreached      = "breached"
+)
+
+// minEmailPartLen is the shortest part of email which is not allowed
+// to appear in password
+const minEmailPartLen = 3
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule the password breaks
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrPolicyViolation, strings.Join(msgs, "; "))
+}
+
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+type Policy struct {
+	MinLength int
+	// MaxBytes limits length of password in bytes. Bcrypt ignores
+	// everything after 72 bytes, so longer passwords give a false sense
+	// of security.
+	MaxBytes      int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// ForbidEmail rejects passwords containing the user's email or its parts
+	ForbidEmail bool
+	// Breached is an optional list of known breached passwords
+	Breached *BreachedList
+}
+
+// Validate returns *PolicyError if password breaks any rule
+func (p Policy) Validate(password string, email string) error {
+	var violations []Violation
+	add := func(rule, msg string) {
+		violations = append(violations, Violation{Rule: rule, Message: msg})
+	}
+
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		add(RuleMinLength, fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		add(RuleMaxLength, fmt.Sprintf("password must be at most %d bytes long", p.MaxBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add(RuleUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		add(RuleLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(RuleDigit, "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(RuleSymbol, "password must contain a symbol")
+	}
+
+	if p.ForbidEmail && containsEmail(password, email) {
+		add(RuleContainsEmail, "password must not contain email")
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		add(RuleBreached, "password has appeared in a data breach")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func containsEmail(password string, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+
+	if strings.Contains(password, email) {
+		return true
+	}
+
+	local, _, _ := strings.Cut(email, "@")
+	parts := strings.FieldsFunc(local, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	parts = append(parts, local)
+
+	for _, part := range parts {
+		if len(part) >= minEmailPartLen && strings.Contains(password, part) {
+			return true
+		}
+	}
+
+	return false
+}
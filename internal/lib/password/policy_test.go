@@ -0,0 +1,132 @@
+package password
+
+import (
+	"crypto/sha1"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rules(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	var policyErr *PolicyError
+	require.ErrorAs(t, err, &policyErr)
+	require.ErrorIs(t, err, ErrPolicyViolation)
+
+	var names []string
+	for _, v := range policyErr.Violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	strict := Policy{
+		MinLength:     8,
+		MaxBytes:      72,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		ForbidEmail:   true,
+	}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		email    string
+		want     []string
+	}{
+		{name: "strong", policy: strict, password: "Tr0ub4dor&3x", email: "alice@example.com"},
+		{name: "empty policy", password: ""},
+		{name: "too short", policy: strict, password: "Ab1!", want: []string{RuleMinLength}},
+		{
+			// runes are counted, not bytes
+			name:     "multibyte length",
+			policy:   Policy{MinLength: 4},
+			password: "пар",
+			want:     []string{RuleMinLength},
+		},
+		{
+			name:     "too long",
+			policy:   strict,
+			password: "Aa1!" + strings.Repeat("x", 69),
+			want:     []string{RuleMaxLength},
+		},
+		{
+			name:     "character classes",
+			policy:   strict,
+			password: "                ",
+			want:     []string{RuleUppercase, RuleLowercase, RuleDigit},
+		},
+		{name: "no symbol", policy: strict, password: "Abcdefg1", want: []string{RuleSymbol}},
+		{name: "unicode letters", policy: strict, password: "Ёжик-в-тумане1"},
+		{
+			name:     "whole email",
+			policy:   strict,
+			password: "x1!ALICE@EXAMPLE.COM",
+			email:    "alice@example.com",
+			want:     []string{RuleContainsEmail},
+		},
+		{
+			name:     "email part",
+			policy:   strict,
+			password: "Smith-2024!x",
+			email:    "john.smith@example.com",
+			want:     []string{RuleContainsEmail},
+		},
+		{
+			name:     "short email part",
+			policy:   strict,
+			password: "Jo-is-2024!x",
+			email:    "jo.smith@example.com",
+		},
+		{
+			name:     "email allowed",
+			policy:   Policy{},
+			password: "alice@example.com",
+			email:    "alice@example.com",
+		},
+		{
+			name:     "every violation",
+			policy:   strict,
+			password: "bob",
+			email:    "bob@example.com",
+			want:     []string{RuleMinLength, RuleUppercase, RuleDigit, RuleSymbol, RuleContainsEmail},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password, tt.email)
+			assert.Equal(t, tt.want, rules(t, err))
+		})
+	}
+}
+
+func TestPolicy_Breached(t *testing.T) {
+	list := &BreachedList{hashes: map[[sha1.Size]byte]struct{}{
+		sha1.Sum([]byte("password")): {},
+	}}
+
+	p := Policy{Breached: list}
+	assert.Equal(t, []string{RuleBreached}, rules(t, p.Validate("password", "")))
+	assert.NoError(t, p.Validate("Password", ""))
+}
+
+func TestPolicyError(t *testing.T) {
+	err := error(&PolicyError{Violations: []Violation{
+		{Rule: RuleMinLength, Message: "too short"},
+		{Rule: RuleDigit, Message: "no digit"},
+	}})
+
+	assert.Equal(t, "password violates policy: too short; no digit", err.Error())
+	assert.True(t, errors.Is(err, ErrPolicyViolation))
+}
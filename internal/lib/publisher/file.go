@@ -0,0 +1,53 @@
+// Package publisher implements outbox.EventPublisher for brokers and
+// receivers of domain events.
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+// File appends events to a file, one JSON object per line. It is meant
+// for local runs where no broker is at hand.
+type File struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFile opens path for appending, creating it if needed
+func NewFile(path string) (*File, error) {
+	const op = "publisher.NewFile"
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &File{
+		file: file,
+		enc:  json.NewEncoder(file),
+	}, nil
+}
+
+func (p *File) Publish(_ context.Context, event models.Event) error {
+	const op = "publisher.File.Publish"
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.enc.Encode(event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *File) Close() error {
+	return p.file.Close()
+}
@@ -0,0 +1,86 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+const (
+	kafkaContentType = "application/vnd.kafka.json.v2+json"
+	kafkaAccept      = "application/vnd.kafka.v2+json"
+)
+
+// Kafka produces events to a topic through the v2 API of Kafka REST
+// Proxy, as served by Confluent REST Proxy and Redpanda. Event subject is
+// the record key, so events of one user keep their order within a
+// partition. An event is acknowledged once the proxy reports its offset.
+type Kafka struct {
+	url    string
+	client *http.Client
+}
+
+type kafkaRecord struct {
+	Key   string       `json:"key"`
+	Value models.Event `json:"value"`
+}
+
+type kafkaProduceRequest struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Offset    *int64 `json:"offset"`
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// NewKafka returns publisher to topic of proxy at baseURL, e.g.
+// http://localhost:8082
+func NewKafka(baseURL string, topic string, timeout time.Duration) *Kafka {
+	return &Kafka{
+		url:    strings.TrimSuffix(baseURL, "/") + "/topics/" + url.PathEscape(topic),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *Kafka) Publish(ctx context.Context, event models.Event) error {
+	const op = "publisher.Kafka.Publish"
+
+	body, err := json.Marshal(kafkaProduceRequest{
+		Records: []kafkaRecord{{Key: event.SubjectID.String(), Value: event}},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", kafkaAccept)
+
+	var res kafkaProduceResponse
+	if err := send(p.client, req, &res); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(res.Offsets) != 1 {
+		return fmt.Errorf("%s: expected 1 offset, got %d", op, len(res.Offsets))
+	}
+	if o := res.Offsets[0]; o.ErrorCode != nil || o.Error != "" || o.Offset == nil {
+		return fmt.Errorf("%s: record rejected: %s", op, o.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafka_Publish(t *testing.T) {
+	event := testEvent()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/babs.auth", r.URL.Path)
+		assert.Equal(t, kafkaContentType, r.Header.Get("Content-Type"))
+
+		var req kafkaProduceRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if assert.Len(t, req.Records, 1) {
+			assert.Equal(t, event.SubjectID.String(), req.Records[0].Key)
+			assert.Equal(t, event.ID, req.Records[0].Value.ID)
+		}
+
+		w.Header().Set("Content-Type", kafkaAccept)
+		_, _ = w.Write([]byte(`{"offsets":[{"partition":0,"offset":42,"error_code":null,"error":null}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewKafka(srv.URL+"/", "babs.auth", time.Second)
+	require.NoError(t, p.Publish(context.Background(), event))
+}
+
+func TestKafka_PublishRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"leader not available"}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewKafka(srv.URL, "babs.auth", time.Second)
+	err := p.Publish(context.Background(), testEvent())
+	assert.ErrorContains(t, err, "leader not available")
+}
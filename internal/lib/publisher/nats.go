@@ -0,0 +1,269 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+const (
+	natsDefaultPort = "4222"
+	// natsMaxLine bounds protocol lines read from the server
+	natsMaxLine = 64 << 10
+)
+
+var errNATSHeaders = errors.New("nats server does not support headers")
+
+// NATS publishes events with the NATS core protocol to subject
+// "<prefix>.<event type>", e.g. "babs.auth.user.created". Event ID is
+// sent in the Nats-Msg-Id header, which lets JetStream streams drop
+// duplicates of redelivered events. Every publish is followed by PING and
+// acknowledged once the server answers PONG, that is once it has
+// processed the message.
+//
+// URL is nats://[user:pass@]host[:port], or tls://... for TLS; a user
+// without a password is sent as auth token. The connection is upgraded to
+// TLS after the plaintext INFO greeting, as NATS servers expect, also for
+// nats:// URLs when the server requires TLS.
+type NATS struct {
+	addr      string
+	useTLS    bool
+	tlsConfig *tls.Config
+	user      string
+	pass      string
+	token     string
+	prefix    string
+	timeout   time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+type natsInfo struct {
+	Headers     bool `json:"headers"`
+	TLSRequired bool `json:"tls_required"`
+}
+
+type natsConnect struct {
+	Verbose     bool   `json:"verbose"`
+	Pedantic    bool   `json:"pedantic"`
+	TLSRequired bool   `json:"tls_required"`
+	Headers     bool   `json:"headers"`
+	Name        string `json:"name"`
+	Lang        string `json:"lang"`
+	Version     string `json:"version"`
+	Protocol    int    `json:"protocol"`
+	User        string `json:"user,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+	Token       string `json:"auth_token,omitempty"`
+}
+
+// NewNATS returns publisher to server at rawURL. The connection is made
+// on first publish and remade after errors.
+func NewNATS(rawURL string, subjectPrefix string, timeout time.Duration) (*NATS, error) {
+	const op = "publisher.NewNATS"
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("%s: unsupported scheme %q", op, u.Scheme)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = natsDefaultPort
+	}
+
+	p := &NATS{
+		addr:      net.JoinHostPort(u.Hostname(), port),
+		useTLS:    u.Scheme == "tls",
+		tlsConfig: &tls.Config{ServerName: u.Hostname()},
+		prefix:    strings.TrimSuffix(subjectPrefix, "."),
+		timeout:   timeout,
+	}
+	if pass, ok := u.User.Password(); ok {
+		p.user, p.pass = u.User.Username(), pass
+	} else if u.User != nil {
+		p.token = u.User.Username()
+	}
+
+	return p, nil
+}
+
+func (p *NATS) Publish(ctx context.Context, event models.Event) error {
+	const op = "publisher.NATS.Publish"
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.publish(ctx, p.prefix+"."+event.Type, event.ID.String(), payload); err != nil {
+		// the connection state is unknown after an error, start over
+		p.closeConn()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *NATS) publish(ctx context.Context, subject string, msgID string, payload []byte) error {
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+	p.setDeadline(ctx)
+
+	headers := "NATS/1.0\r\nNats-Msg-Id: " + msgID + "\r\n\r\n"
+	msg := fmt.Sprintf("HPUB %s %d %d\r\n%s%s\r\nPING\r\n",
+		subject, len(headers), len(headers)+len(payload), headers, payload)
+	if _, err := p.conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+
+	return p.waitPong()
+}
+
+func (p *NATS) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	p.r = bufio.NewReaderSize(conn, 4096)
+	p.setDeadline(ctx)
+
+	line, err := p.readLine()
+	if err != nil {
+		return err
+	}
+	infoJSON, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return fmt.Errorf("decode server info: %w", err)
+	}
+	if !info.Headers {
+		return errNATSHeaders
+	}
+
+	// the server greets in plaintext and expects the handshake after INFO
+	tlsRequired := p.useTLS || info.TLSRequired
+	if tlsRequired {
+		tlsConn := tls.Client(conn, p.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("tls handshake: %w", err)
+		}
+		p.conn = tlsConn
+		p.r = bufio.NewReaderSize(tlsConn, 4096)
+	}
+
+	connect, err := json.Marshal(natsConnect{
+		TLSRequired: tlsRequired,
+		Headers:     true,
+		Name:        "babs-maps-auth",
+		Lang:        "go",
+		Version:     "1",
+		Protocol:    1,
+		User:        p.user,
+		Pass:        p.pass,
+		Token:       p.token,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(p.conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return err
+	}
+
+	// authorization errors are reported before PONG
+	return p.waitPong()
+}
+
+// waitPong reads server messages until PONG. Server PINGs are answered,
+// -ERR is returned as error.
+func (p *NATS) waitPong() error {
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK and INFO updates need no action
+	}
+}
+
+func (p *NATS) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := p.r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > natsMaxLine {
+			return "", errors.New("protocol line too long")
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// setDeadline bounds the next exchange by timeout and ctx deadline;
+// zero timeout means no limit
+func (p *NATS) setDeadline(ctx context.Context) {
+	var deadline time.Time
+	if p.timeout > 0 {
+		deadline = time.Now().Add(p.timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	_ = p.conn.SetDeadline(deadline)
+}
+
+func (p *NATS) closeConn() {
+	if p.conn != nil {
+		_ = p.conn.Close()
+		p.conn, p.r = nil, nil
+	}
+}
+
+func (p *NATS) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closeConn()
+	return nil
+}
@@ -0,0 +1,201 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// natsMsg is a message received by fakeNATS
+type natsMsg struct {
+	subject string
+	headers string
+	payload []byte
+}
+
+// fakeNATS speaks enough of the NATS protocol to accept HPUB. It closes
+// every connection after dropAfter messages, if set. With tlsConfig set it
+// requires TLS and upgrades connections after INFO, like a real server.
+type fakeNATS struct {
+	ln        net.Listener
+	msgs      chan natsMsg
+	connects  chan string
+	dropAfter int
+	tlsConfig *tls.Config
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	t.Helper()
+	return newFakeNATSWithTLS(t, nil)
+}
+
+func newFakeNATSWithTLS(t *testing.T, tlsConfig *tls.Config) *fakeNATS {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeNATS{ln: ln, msgs: make(chan natsMsg, 10), connects: make(chan string, 10), tlsConfig: tlsConfig}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+
+	return s
+}
+
+func (s *fakeNATS) url(userinfo string) string {
+	return "nats://" + userinfo + s.ln.Addr().String()
+}
+
+func (s *fakeNATS) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeNATS) handle(conn net.Conn) {
+	defer conn.Close()
+
+	if s.tlsConfig == nil {
+		fmt.Fprint(conn, `INFO {"server_id":"fake","headers":true,"max_payload":1048576}`+"\r\n")
+	} else {
+		fmt.Fprint(conn, `INFO {"server_id":"fake","headers":true,"tls_required":true}`+"\r\n")
+		tlsConn := tls.Server(conn, s.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		conn = tlsConn
+	}
+
+	r := bufio.NewReader(conn)
+	received := 0
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			s.connects <- strings.TrimPrefix(line, "CONNECT ")
+		case line == "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case strings.HasPrefix(line, "HPUB "):
+			var (
+				msg                natsMsg
+				hdrLen, totalBytes int
+			)
+			if _, err := fmt.Sscanf(line, "HPUB %s %d %d", &msg.subject, &hdrLen, &totalBytes); err != nil {
+				fmt.Fprintf(conn, "-ERR 'Unknown Protocol Operation'\r\n")
+				return
+			}
+			body := make([]byte, totalBytes+2)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			msg.headers, msg.payload = string(body[:hdrLen]), body[hdrLen:totalBytes]
+			s.msgs <- msg
+
+			received++
+			if s.dropAfter > 0 && received == s.dropAfter {
+				return
+			}
+		}
+	}
+}
+
+func TestNATS_Publish(t *testing.T) {
+	srv := newFakeNATS(t)
+
+	p, err := NewNATS(srv.url("svc:secret@"), "babs.auth.", time.Second)
+	require.NoError(t, err)
+	defer p.Close()
+
+	event := testEvent()
+	require.NoError(t, p.Publish(context.Background(), event))
+
+	var connect natsConnect
+	require.NoError(t, json.Unmarshal([]byte(<-srv.connects), &connect))
+	assert.Equal(t, "svc", connect.User)
+	assert.Equal(t, "secret", connect.Pass)
+	assert.True(t, connect.Headers)
+
+	msg := <-srv.msgs
+	assert.Equal(t, "babs.auth.user.created", msg.subject)
+	assert.Contains(t, msg.headers, "Nats-Msg-Id: "+event.ID.String()+"\r\n")
+
+	var got models.Event
+	require.NoError(t, json.Unmarshal(msg.payload, &got))
+	assert.Equal(t, event.ID, got.ID)
+}
+
+func TestNATS_Reconnects(t *testing.T) {
+	srv := newFakeNATS(t)
+	srv.dropAfter = 2
+
+	p, err := NewNATS(srv.url(""), "babs.auth", time.Second)
+	require.NoError(t, err)
+	defer p.Close()
+
+	require.NoError(t, p.Publish(context.Background(), testEvent()))
+	<-srv.msgs
+
+	// the server dropped the connection before PONG
+	err = p.Publish(context.Background(), testEvent())
+	require.Error(t, err)
+	<-srv.msgs
+
+	require.NoError(t, p.Publish(context.Background(), testEvent()))
+	<-srv.msgs
+	assert.Len(t, srv.connects, 2)
+}
+
+func TestNewNATS_UnsupportedScheme(t *testing.T) {
+	_, err := NewNATS("http://localhost:4222", "babs.auth", time.Second)
+	assert.Error(t, err)
+}
+
+func TestNATS_TLS(t *testing.T) {
+	// borrow the test certificate for 127.0.0.1
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	defer https.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(https.Certificate())
+
+	for _, scheme := range []string{"tls", "nats"} {
+		t.Run(scheme, func(t *testing.T) {
+			srv := newFakeNATSWithTLS(t, https.TLS)
+
+			p, err := NewNATS(scheme+"://"+srv.ln.Addr().String(), "babs.auth", time.Second)
+			require.NoError(t, err)
+			defer p.Close()
+			p.tlsConfig.RootCAs = roots
+
+			require.NoError(t, p.Publish(context.Background(), testEvent()))
+
+			var connect natsConnect
+			require.NoError(t, json.Unmarshal([]byte(<-srv.connects), &connect))
+			assert.True(t, connect.TLSRequired)
+			assert.Equal(t, "babs.auth.user.created", (<-srv.msgs).subject)
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package publisher
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/google/uuid"
+)
+
+func testEvent() models.Event {
+	return models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventUserCreated,
+		SubjectID: uuid.New(),
+		Payload:   json.RawMessage(`{"email":"a@example.com"}`),
+		CreatedAt: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,76 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/webhook"
+)
+
+// Webhook posts events as JSON to URL. Any 2xx response acknowledges the
+// event, other responses and network errors make the relay retry.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *Webhook) Publish(ctx context.Context, event models.Event) error {
+	const op = "publisher.Webhook.Publish"
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", event.ID.String())
+	req.Header.Set("X-Event-Type", event.Type)
+
+	if err := send(p.client, req, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// send does req and decodes JSON response into res, if it is not nil.
+// Responses other than 2xx are errors.
+func send(client *http.Client, req *http.Request, res any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body := io.LimitReader(resp.Body, webhook.MaxResponseBody)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, body)
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	if res != nil {
+		if err := json.NewDecoder(body).Decode(res); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	_, _ = io.Copy(io.Discard, body)
+
+	return nil
+}
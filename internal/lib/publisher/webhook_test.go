@@ -0,0 +1,45 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook_Publish(t *testing.T) {
+	event := testEvent()
+
+	var got models.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, event.ID.String(), r.Header.Get("X-Event-Id"))
+		assert.Equal(t, event.Type, r.Header.Get("X-Event-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p := NewWebhook(srv.URL, time.Second)
+	require.NoError(t, p.Publish(context.Background(), event))
+	assert.Equal(t, event.ID, got.ID)
+	assert.JSONEq(t, string(event.Payload), string(got.Payload))
+}
+
+func TestWebhook_PublishFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try later", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	p := NewWebhook(srv.URL, time.Second)
+	err := p.Publish(context.Background(), testEvent())
+	assert.ErrorContains(t, err, "503")
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret, err := NewSecret()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, secretPrefix))
+
+	body := []byte(`{"id":"1"}`)
+	now := time.Now()
+	header := Sign(secret, now, body)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", secret: secret, header: header, body: body, now: now},
+		{name: "clock skew", secret: secret, header: header, body: body, now: now.Add(-time.Minute)},
+		{name: "rotated secret", secret: secret, header: Sign("old", now, body) + "," + strings.Split(header, ",")[1], body: body, now: now},
+		{name: "tampered body", secret: secret, header: header, body: []byte(`{"id":"2"}`), now: now, wantErr: ErrNoSignature},
+		{name: "wrong secret", secret: "other", header: header, body: body, now: now, wantErr: ErrNoSignature},
+		{name: "replayed", secret: secret, header: header, body: body, now: now.Add(10 * time.Minute), wantErr: ErrTimestampExpired},
+		{name: "no timestamp", secret: secret, header: strings.Split(header, ",")[1], body: body, now: now, wantErr: ErrNoSignature},
+		{name: "empty", secret: secret, header: "", body: body, now: now, wantErr: ErrNoSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.now, 5*time.Minute)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Package worker has the polling loop and retry helpers shared by
+// background workers draining a table, such as the outbox relay and
+// webhook delivery.
+package worker
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// MaxErrorLen bounds the error text kept with a failed item
+const MaxErrorLen = 1024
+
+// Poll calls once until ctx is done. A full batch, once returning
+// batchSize items, is followed by the next right away, otherwise the next
+// call is made after interval. Errors are left to once to report.
+func Poll(ctx context.Context, interval time.Duration, batchSize int, once func(ctx context.Context) (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := once(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil && n == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backoff returns delay before the next attempt after attempts failures:
+// base doubled on every failure up to max
+func Backoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 0; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	return min(delay, max)
+}
+
+// ErrorText returns text of err cut to MaxErrorLen bytes
+func ErrorText(err error) string {
+	s := err.Error()
+	if len(s) <= MaxErrorLen {
+		return s
+	}
+	// Postgres rejects invalid UTF-8 left by a cut in the middle of a rune
+	return strings.ToValidUTF8(s[:MaxErrorLen], "")
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// full batches are drained without waiting for the interval
+	var calls atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Poll(ctx, time.Hour, 10, func(ctx context.Context) (int, error) {
+			if calls.Add(1) == 3 {
+				cancel()
+			}
+			return 10, nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("poll did not stop")
+	}
+	assert.Equal(t, int64(3), calls.Load())
+}
+
+func TestPoll_FailedBatchWaits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var calls atomic.Int64
+	Poll(ctx, time.Hour, 10, func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 10, errors.New("failed")
+	})
+
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func TestPoll_Interval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var calls atomic.Int64
+	Poll(ctx, 10*time.Millisecond, 10, func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 1, nil
+	})
+
+	assert.GreaterOrEqual(t, calls.Load(), int64(2))
+	assert.Less(t, calls.Load(), int64(10))
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, want := range []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second,
+	} {
+		assert.Equal(t, want, Backoff(time.Second, 10*time.Second, attempts), "attempts %d", attempts)
+	}
+	assert.Equal(t, 10*time.Second, Backoff(time.Second, 10*time.Second, 1000))
+}
+
+func TestErrorText(t *testing.T) {
+	assert.Equal(t, "short", ErrorText(errors.New("short")))
+
+	long := strings.Repeat("a", MaxErrorLen-1) + "ж"
+	got := ErrorText(errors.New(long))
+	assert.Equal(t, MaxErrorLen-1, len(got))
+	assert.True(t, utf8.ValidString(got))
+}
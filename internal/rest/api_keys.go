@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	authsvc "github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	GetAPIKeysURL   = "/me/api-keys"
+	PostAPIKeyURL   = "/me/api-keys"
+	DeleteAPIKeyURL = "/me/api-keys/{id}"
+)
+
+func registerAPIKeyRoutes(api huma.API, auth Auth) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-api-keys",
+		Method:        http.MethodGet,
+		Path:          GetAPIKeysURL,
+		Summary:       "Get API keys of the token owner and their organization",
+		Tags:          []string{"api-keys"},
+		DefaultStatus: http.StatusOK,
+		Security:      authenticated(),
+	}, func(ctx context.Context, input *struct{}) (*GetAPIKeysResponse, error) {
+		principal, err := keyManager(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := auth.APIKeys(ctx, principal.UserID)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := GetAPIKeysResponse{}
+		resp.Body.APIKeys = keys
+		if resp.Body.APIKeys == nil {
+			resp.Body.APIKeys = []models.APIKey{}
+		}
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-api-key",
+		Method:        http.MethodPost,
+		Path:          PostAPIKeyURL,
+		Summary:       "Create an API key; the key is shown only in this response",
+		Tags:          []string{"api-keys"},
+		DefaultStatus: http.StatusCreated,
+		Security:      authenticated(),
+	}, func(ctx context.Context, input *CreateAPIKeyInput) (*CreateAPIKeyResponse, error) {
+		principal, err := keyManager(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		key, secret, err := auth.CreateAPIKey(ctx, principal.UserID, authsvc.NewAPIKey{
+			Name:   input.Body.Name,
+			Scopes: input.Body.Scopes,
+			TTL:    time.Duration(input.Body.ExpiresIn) * time.Second,
+			Org:    input.Body.Org,
+		})
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := CreateAPIKeyResponse{}
+		resp.Body.APIKey = key
+		resp.Body.Key = secret
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "revoke-api-key",
+		Method:        http.MethodDelete,
+		Path:          DeleteAPIKeyURL,
+		Summary:       "Revoke an API key of the token owner or their organization",
+		Tags:          []string{"api-keys"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      authenticated(),
+	}, func(ctx context.Context, input *RevokeAPIKeyInput) (*struct{}, error) {
+		principal, err := keyManager(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		keyID, err := uuid.Parse(input.Id)
+		if err != nil {
+			return nil, errBadRequest("invalid api key id")
+		}
+
+		if err := auth.RevokeAPIKey(ctx, principal.UserID, keyID); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+}
+
+// keyManager returns the caller unless they authenticated with an API
+// key or a token of a third-party app: a leaked key must not be able to
+// mint longer-lived ones, and an app must not get keys with scopes the
+// user did not consent to
+func keyManager(ctx context.Context) (models.Principal, error) {
+	principal, _ := PrincipalFromContext(ctx)
+	if principal.APIKeyID != uuid.Nil {
+		return models.Principal{}, errForbidden("API keys cannot manage API keys, sign in instead")
+	}
+	if principal.ThirdParty {
+		return models.Principal{}, errForbidden("third-party apps cannot manage API keys")
+	}
+
+	return principal, nil
+}
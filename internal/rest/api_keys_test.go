@@ -0,0 +1,53 @@
+package rest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/rest"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAPIKey_ThirdPartyToken(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	id, email, pass := srv.register(t)
+
+	appID, err := srv.st.CreateApp(ctx, models.App{
+		Name:       "partner",
+		Type:       models.AppTypePublic,
+		GrantTypes: []string{models.GrantTypePassword},
+		Scopes:     []string{authz.MapsRead, authz.MapsWrite},
+	})
+	require.NoError(t, err)
+	require.NoError(t, srv.auth.GrantConsent(ctx, id, appID, []string{authz.MapsRead}))
+
+	token, err := srv.auth.Login(clientinfo.WithInfo(ctx, clientinfo.Info{AppID: appID}), email, pass)
+	require.NoError(t, err)
+
+	for _, scopes := range [][]string{{authz.MapsWrite}, {authz.MapsRead}} {
+		resp, body := srv.do(t, srv.request(t, http.MethodPost, rest.PostAPIKeyURL, token, map[string]any{
+			"name":   "partner key",
+			"scopes": scopes,
+		}))
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, string(body))
+	}
+
+	keys, err := srv.auth.APIKeys(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// the user may create the key signed in without the app
+	token, err = srv.auth.Login(ctx, email, pass)
+	require.NoError(t, err)
+	resp, body := srv.do(t, srv.request(t, http.MethodPost, rest.PostAPIKeyURL, token, map[string]any{
+		"name":   "own key",
+		"scopes": []string{authz.MapsWrite},
+	}))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+}
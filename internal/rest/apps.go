@@ -0,0 +1,130 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const (
+	AppsURL          = "/admin/apps"
+	AppURL           = "/admin/apps/{appId}"
+	PostAppSecretURL = "/admin/apps/{appId}/secret"
+)
+
+func registerAppRoutes(api huma.API, auth Auth) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-app",
+		Method:        http.MethodPost,
+		Path:          AppsURL,
+		Summary:       "Register an app",
+		Tags:          []string{"apps"},
+		DefaultStatus: http.StatusCreated,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *CreateAppInput) (*CreateAppResponse, error) {
+		app, secret, err := auth.CreateApp(ctx, input.Body.app(0))
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := CreateAppResponse{}
+		resp.Body.App = newAppView(app)
+		resp.Body.Secret = secret
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-apps",
+		Method:        http.MethodGet,
+		Path:          AppsURL,
+		Summary:       "Get apps",
+		Tags:          []string{"apps"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *struct{}) (*GetAppsResponse, error) {
+		apps, err := auth.Apps(ctx)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := GetAppsResponse{}
+		resp.Body.Apps = make([]AppView, 0, len(apps))
+		for _, app := range apps {
+			resp.Body.Apps = append(resp.Body.Apps, newAppView(app))
+		}
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-app",
+		Method:        http.MethodGet,
+		Path:          AppURL,
+		Summary:       "Get app",
+		Tags:          []string{"apps"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *AppInput) (*AppResponse, error) {
+		app, err := auth.App(ctx, input.AppId)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := AppResponse{}
+		resp.Body.App = newAppView(app)
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "update-app",
+		Method:        http.MethodPut,
+		Path:          AppURL,
+		Summary:       "Replace settings of an app, its secret is kept",
+		Tags:          []string{"apps"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *UpdateAppInput) (*AppResponse, error) {
+		app, err := auth.UpdateApp(ctx, input.Body.app(input.AppId))
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := AppResponse{}
+		resp.Body.App = newAppView(app)
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "delete-app",
+		Method:        http.MethodDelete,
+		Path:          AppURL,
+		Summary:       "Delete an app, its grants and sessions",
+		Tags:          []string{"apps"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *AppInput) (*struct{}, error) {
+		if err := auth.DeleteApp(ctx, input.AppId); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "rotate-app-secret",
+		Method:        http.MethodPost,
+		Path:          PostAppSecretURL,
+		Summary:       "Replace the secret of a confidential app",
+		Tags:          []string{"apps"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *AppInput) (*RotateAppSecretResponse, error) {
+		secret, err := auth.RotateAppSecret(ctx, input.AppId)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := RotateAppSecretResponse{}
+		resp.Body.Secret = secret
+		return &resp, nil
+	})
+}
@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/google/uuid"
+)
+
+// query converts query parameters of GET /audit to models.AuditQuery
+func (in *GetAuditInput) query() (models.AuditQuery, error) {
+	q := models.AuditQuery{
+		Limit: in.Limit,
+		Filter: models.AuditFilter{
+			Type:    in.Type,
+			Outcome: in.Outcome,
+			IP:      in.Ip,
+			From:    in.From,
+			To:      in.To,
+		},
+	}
+
+	var err error
+	if q.Filter.ActorID, err = optionalUUID(in.ActorId); err != nil {
+		return models.AuditQuery{}, errBadRequest("invalid actor id")
+	}
+	if q.Filter.SubjectID, err = optionalUUID(in.SubjectId); err != nil {
+		return models.AuditQuery{}, errBadRequest("invalid subject id")
+	}
+
+	return q, nil
+}
+
+func optionalUUID(s string) (*uuid.UUID, error) {
+	if s == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
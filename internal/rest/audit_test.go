@@ -0,0 +1,49 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAudit_FilterByType(t *testing.T) {
+	srv := newTestServer(t)
+	token := srv.login(t, true)
+
+	resp, body := srv.do(t, srv.request(t, http.MethodGet, rest.GetAuditURL+"?type="+models.AuditLogin, token, nil))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	var page struct {
+		Events []models.AuditEvent `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal(body, &page))
+	require.NotEmpty(t, page.Events)
+	for _, e := range page.Events {
+		assert.Equal(t, models.AuditLogin, e.Type)
+	}
+
+	// every recorded type can be filtered by
+	for _, typ := range []string{
+		models.AuditLogout,
+		models.AuditSessionRevoke,
+		models.AuditGrantCreate,
+		models.AuditGrantRevoke,
+		models.AuditAppCreate,
+		models.AuditAppUpdate,
+		models.AuditAppDelete,
+		models.AuditAppSecretRotate,
+		models.AuditAPIKeyCreate,
+		models.AuditAPIKeyRevoke,
+	} {
+		resp, body := srv.do(t, srv.request(t, http.MethodGet, rest.GetAuditURL+"?type="+typ, token, nil))
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "%s: %s", typ, body)
+	}
+
+	resp, _ = srv.do(t, srv.request(t, http.MethodGet, rest.GetAuditURL+"?type=user.role_change", token, nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
@@ -0,0 +1,218 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	authsvc "github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Cookies of browser sessions. The __Host- prefix makes browsers accept
+// them only if they are Secure, host-only and set for path /, so other
+// subdomains cannot plant them. Names are repeated in cookie tags of
+// input structs.
+const (
+	SessionCookie = "__Host-babs_session"
+	CSRFCookie    = "__Host-babs_csrf"
+)
+
+// csrfTokenBytes is the length of random CSRF tokens
+const csrfTokenBytes = 32
+
+const (
+	GetBrowserSessionURL = "/sso/session"
+	PostBrowserLoginURL  = "/sso/login"
+	PostBrowserLogoutURL = "/sso/logout"
+)
+
+// registerBrowserRoutes registers single sign-on operations of browsers.
+// They are authenticated with the session cookie instead of bearer tokens
+// and posts are protected from CSRF with double-submit tokens: the token
+// is kept in an HttpOnly cookie and must also be sent in X-CSRF-Token
+// header or csrf_token body field. Only pages of this service use the
+// session so far: there is no authorize endpoint yet that would send a
+// signed-in browser back to an app.
+func registerBrowserRoutes(api huma.API, auth Auth) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-browser-session",
+		Method:        http.MethodGet,
+		Path:          GetBrowserSessionURL,
+		Summary:       "Get browser session and CSRF token",
+		Tags:          []string{"sso"},
+		DefaultStatus: http.StatusOK,
+		Security:      public(),
+	}, func(ctx context.Context, input *GetBrowserSessionInput) (*GetBrowserSessionResponse, error) {
+		resp := GetBrowserSessionResponse{}
+
+		resp.Body.CSRFToken = input.CSRFCookie
+		if !validCSRFToken(input.CSRFCookie) {
+			token, err := newCSRFToken()
+			if err != nil {
+				return nil, err
+			}
+			resp.Body.CSRFToken = token
+			resp.SetCookie = append(resp.SetCookie, csrfCookie(token))
+		}
+
+		if input.SessionCookie == "" {
+			return &resp, nil
+		}
+		session, err := auth.BrowserSession(ctx, input.SessionCookie)
+		if err != nil {
+			if errors.Is(err, authsvc.ErrInvalidSession) {
+				resp.SetCookie = append(resp.SetCookie, expiredCookie(SessionCookie))
+				return &resp, nil
+			}
+			return nil, mapError(err)
+		}
+
+		resp.Body.Authenticated = true
+		resp.Body.Session = &session
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "browser-login",
+		Method:        http.MethodPost,
+		Path:          PostBrowserLoginURL,
+		Summary:       "Sign in the browser",
+		Tags:          []string{"sso"},
+		DefaultStatus: http.StatusOK,
+		Security:      public(),
+	}, func(ctx context.Context, input *BrowserLoginInput) (*BrowserLoginResponse, error) {
+		if err := checkCSRF(input.CSRFCookie, input.CSRFHeader, input.Body.CSRFToken); err != nil {
+			return nil, err
+		}
+
+		info := clientinfo.FromContext(ctx)
+		info.AppID = input.Body.AppId
+		ctx = clientinfo.WithInfo(ctx, info)
+
+		session, cookie, err := auth.BrowserLogin(ctx, input.Body.Email, input.Body.Password)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		// the previous session of the browser, if any, is replaced
+		if input.SessionCookie != "" {
+			if err := auth.BrowserLogout(ctx, input.SessionCookie); err != nil {
+				return nil, mapError(err)
+			}
+		}
+
+		// the token seen before login is rotated
+		token, err := newCSRFToken()
+		if err != nil {
+			return nil, err
+		}
+
+		resp := BrowserLoginResponse{}
+		resp.SetCookie = []http.Cookie{
+			sessionCookie(cookie, session.ExpiresAt),
+			csrfCookie(token),
+		}
+		resp.Body.Session = session
+		resp.Body.CSRFToken = token
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "browser-logout",
+		Method:        http.MethodPost,
+		Path:          PostBrowserLogoutURL,
+		Summary:       "Sign out the browser",
+		Tags:          []string{"sso"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      public(),
+	}, func(ctx context.Context, input *BrowserLogoutInput) (*BrowserLogoutResponse, error) {
+		var bodyToken string
+		if input.Body != nil {
+			bodyToken = input.Body.CSRFToken
+		}
+		if err := checkCSRF(input.CSRFCookie, input.CSRFHeader, bodyToken); err != nil {
+			return nil, err
+		}
+
+		if err := auth.BrowserLogout(ctx, input.SessionCookie); err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := BrowserLogoutResponse{}
+		resp.SetCookie = []http.Cookie{expiredCookie(SessionCookie)}
+		return &resp, nil
+	})
+}
+
+// checkCSRF compares the token of the CSRF cookie with the one sent in
+// header or, if there is none, in body
+func checkCSRF(cookie string, header string, body string) error {
+	sent := header
+	if sent == "" {
+		sent = body
+	}
+
+	if !validCSRFToken(cookie) || subtle.ConstantTimeCompare([]byte(cookie), []byte(sent)) != 1 {
+		return problem(http.StatusForbidden, ProblemCSRF, "missing or invalid CSRF token")
+	}
+
+	return nil
+}
+
+func newCSRFToken() (string, error) {
+	raw := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// validCSRFToken reports whether token looks like one of newCSRFToken
+func validCSRFToken(token string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil && len(raw) == csrfTokenBytes
+}
+
+// sessionCookie expires with the session. SameSite=Lax lets browsers send
+// it on top-level navigation from apps, as an authorize endpoint will
+// need, but not on cross-site posts.
+func sessionCookie(value string, expires time.Time) http.Cookie {
+	return http.Cookie{
+		Name:     SessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// csrfCookie lives as long as the browser
+func csrfCookie(token string) http.Cookie {
+	return http.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+func expiredCookie(name string) http.Cookie {
+	return http.Cookie{
+		Name:     name,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,177 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// responseCookie returns the cookie set by resp
+func responseCookie(t *testing.T, resp *http.Response, name string) *http.Cookie {
+	t.Helper()
+
+	for _, c := range resp.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	require.Failf(t, "cookie not set", "%s in %v", name, resp.Header.Values("Set-Cookie"))
+	return nil
+}
+
+// assertHostCookie checks attributes browsers require of __Host- cookies
+// and the ones the session relies on
+func assertHostCookie(t *testing.T, c *http.Cookie, sameSite http.SameSite) {
+	t.Helper()
+
+	assert.True(t, c.Secure, "Secure")
+	assert.True(t, c.HttpOnly, "HttpOnly")
+	assert.Equal(t, "/", c.Path)
+	assert.Empty(t, c.Domain)
+	assert.Equal(t, sameSite, c.SameSite)
+}
+
+// csrfToken gets a fresh CSRF token from the session endpoint
+func (s *testServer) csrfToken(t *testing.T) string {
+	t.Helper()
+
+	resp, body := s.do(t, s.request(t, http.MethodGet, rest.GetBrowserSessionURL, "", nil))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var session struct {
+		Authenticated bool   `json:"authenticated"`
+		CSRFToken     string `json:"csrf_token"`
+	}
+	require.NoError(t, json.Unmarshal(body, &session))
+	assert.False(t, session.Authenticated)
+
+	c := responseCookie(t, resp, rest.CSRFCookie)
+	assertHostCookie(t, c, http.SameSiteStrictMode)
+	require.Equal(t, c.Value, session.CSRFToken)
+
+	return session.CSRFToken
+}
+
+// browserLogin returns the response to a login with given cookies and header
+func (s *testServer) browserLogin(t *testing.T, email, pass string, cookies []*http.Cookie, header string) (*http.Response, []byte) {
+	t.Helper()
+
+	req := s.request(t, http.MethodPost, rest.PostBrowserLoginURL, "", map[string]any{
+		"email":    email,
+		"password": pass,
+	})
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	if header != "" {
+		req.Header.Set("X-CSRF-Token", header)
+	}
+
+	return s.do(t, req)
+}
+
+func TestBrowserLogin_RejectsCSRF(t *testing.T) {
+	srv := newTestServer(t)
+	_, email, pass := srv.register(t)
+	token, other := srv.csrfToken(t), srv.csrfToken(t)
+
+	tests := []struct {
+		name    string
+		cookies []*http.Cookie
+		header  string
+	}{
+		{name: "no token"},
+		{name: "no cookie", header: token},
+		{name: "no header", cookies: []*http.Cookie{{Name: rest.CSRFCookie, Value: token}}},
+		{
+			name:    "mismatch",
+			cookies: []*http.Cookie{{Name: rest.CSRFCookie, Value: token}},
+			header:  other,
+		},
+		{
+			// the double-submit check alone would accept any equal pair
+			name:    "not issued by server",
+			cookies: []*http.Cookie{{Name: rest.CSRFCookie, Value: "x"}},
+			header:  "x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := srv.browserLogin(t, email, pass, tt.cookies, tt.header)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+			assert.Empty(t, resp.Cookies())
+		})
+	}
+}
+
+func TestBrowserLogin_RotatesCSRFToken(t *testing.T) {
+	srv := newTestServer(t)
+	_, email, pass := srv.register(t)
+	token := srv.csrfToken(t)
+
+	resp, body := srv.browserLogin(t, email, pass, []*http.Cookie{{Name: rest.CSRFCookie, Value: token}}, token)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	var login struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	require.NoError(t, json.Unmarshal(body, &login))
+
+	session := responseCookie(t, resp, rest.SessionCookie)
+	assertHostCookie(t, session, http.SameSiteLaxMode)
+	assert.NotEmpty(t, session.Value)
+	assert.False(t, session.Expires.IsZero())
+
+	csrf := responseCookie(t, resp, rest.CSRFCookie)
+	assertHostCookie(t, csrf, http.SameSiteStrictMode)
+	assert.NotEqual(t, token, csrf.Value)
+	assert.Equal(t, csrf.Value, login.CSRFToken)
+
+	// the token seen before login no longer passes
+	req := srv.request(t, http.MethodPost, rest.PostBrowserLogoutURL, "", nil)
+	req.AddCookie(session)
+	req.AddCookie(csrf)
+	req.Header.Set("X-CSRF-Token", token)
+	resp, _ = srv.do(t, req)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestBrowserLogout_ExpiresSessionCookie(t *testing.T) {
+	srv := newTestServer(t)
+	_, email, pass := srv.register(t)
+	token := srv.csrfToken(t)
+
+	resp, _ := srv.browserLogin(t, email, pass, []*http.Cookie{{Name: rest.CSRFCookie, Value: token}}, token)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	session, csrf := responseCookie(t, resp, rest.SessionCookie), responseCookie(t, resp, rest.CSRFCookie)
+
+	// the token may also come in the body of form-like posts
+	req := srv.request(t, http.MethodPost, rest.PostBrowserLogoutURL, "", map[string]string{"csrf_token": csrf.Value})
+	req.AddCookie(session)
+	req.AddCookie(csrf)
+	resp, _ = srv.do(t, req)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	expired := responseCookie(t, resp, rest.SessionCookie)
+	assertHostCookie(t, expired, http.SameSiteLaxMode)
+	assert.Empty(t, expired.Value)
+	assert.Negative(t, expired.MaxAge)
+
+	// the old cookie does not sign the browser in
+	req = srv.request(t, http.MethodGet, rest.GetBrowserSessionURL, "", nil)
+	req.AddCookie(session)
+	req.AddCookie(csrf)
+	resp, body := srv.do(t, req)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var state struct {
+		Authenticated bool `json:"authenticated"`
+	}
+	require.NoError(t, json.Unmarshal(body, &state))
+	assert.False(t, state.Authenticated)
+	assert.Negative(t, responseCookie(t, resp, rest.SessionCookie).MaxAge)
+}
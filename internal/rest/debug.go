@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"net/http"
+
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5"
+)
+
+const GetDebugVarsURL = "/debug/vars"
+
+// registerDebugRoutes serves metrics published with expvar, e.g. stats
+// of the user cache, to admins
+func registerDebugRoutes(router chi.Router, log *slog.Logger, auth Auth) {
+	vars := expvar.Handler()
+
+	router.Get(GetDebugVarsURL, func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r.Header.Get("Authorization"))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="babs-maps"`)
+			writeProblemJSON(w, problem(http.StatusUnauthorized, ProblemUnauthorized, "bearer token required"))
+			return
+		}
+
+		principal, err := auth.Principal(r.Context(), token)
+		if err != nil {
+			p, ok := mapError(err).(*huma.ErrorModel)
+			if !ok {
+				log.Error("failed to authenticate", slog.String("path", r.URL.Path), sl.Err(err))
+				p = problem(http.StatusInternalServerError, ProblemInternal, "internal error")
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="babs-maps", error="invalid_token"`)
+			writeProblemJSON(w, p)
+			return
+		}
+		if !principal.IsAdmin {
+			writeProblemJSON(w, problem(http.StatusForbidden, ProblemForbidden, "admin required"))
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		vars.ServeHTTP(w, r)
+	})
+}
+
+// writeProblemJSON writes p from handlers registered outside of huma
+func writeProblemJSON(w http.ResponseWriter, p *huma.ErrorModel) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
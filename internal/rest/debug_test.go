@@ -0,0 +1,31 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugVars_AdminOnly(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, _ := srv.do(t, srv.request(t, http.MethodGet, rest.GetDebugVarsURL, "", nil))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = srv.do(t, srv.request(t, http.MethodGet, rest.GetDebugVarsURL, "not-a-token", nil))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = srv.do(t, srv.request(t, http.MethodGet, rest.GetDebugVarsURL, srv.login(t, false), nil))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+	resp, body := srv.do(t, srv.request(t, http.MethodGet, rest.GetDebugVarsURL, srv.login(t, true), nil))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var vars map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &vars))
+	assert.Contains(t, vars, "memstats")
+}
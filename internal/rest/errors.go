@@ -0,0 +1,187 @@
+package rest
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/password"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/services/quota"
+	"github.com/babs-corp/babs-maps-auth/internal/services/webhooks"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Problem types returned in the "type" field of RFC 7807 responses.
+// They are part of the API and must not change.
+const (
+	problemTypePrefix = "urn:babs-maps:auth:problem:"
+
+	ProblemBadRequest         = problemTypePrefix + "bad-request"
+	ProblemValidation         = problemTypePrefix + "validation-failed"
+	ProblemUnauthorized       = problemTypePrefix + "unauthorized"
+	ProblemForbidden          = problemTypePrefix + "forbidden"
+	ProblemNotFound           = problemTypePrefix + "not-found"
+	ProblemConflict           = problemTypePrefix + "conflict"
+	ProblemTooManyRequests    = problemTypePrefix + "too-many-requests"
+	ProblemInternal           = problemTypePrefix + "internal"
+	ProblemInvalidCredentials = problemTypePrefix + "invalid-credentials"
+	ProblemInvalidToken       = problemTypePrefix + "invalid-token"
+	ProblemUserExists         = problemTypePrefix + "user-exists"
+	ProblemUserNotFound       = problemTypePrefix + "user-not-found"
+	ProblemAccountLocked      = problemTypePrefix + "account-locked"
+	ProblemUserDisabled       = problemTypePrefix + "user-disabled"
+	ProblemTooManyAttempts    = problemTypePrefix + "too-many-attempts"
+	ProblemWeakPassword       = problemTypePrefix + "weak-password"
+	ProblemInvalidCursor      = problemTypePrefix + "invalid-cursor"
+	ProblemInvalidSession     = problemTypePrefix + "invalid-session"
+	ProblemCSRF               = problemTypePrefix + "csrf-failed"
+	ProblemConsentRequired    = problemTypePrefix + "consent-required"
+	ProblemInvalidScope       = problemTypePrefix + "invalid-scope"
+	ProblemUnauthorizedClient = problemTypePrefix + "unauthorized-client"
+)
+
+// statusProblems are problem types of errors created by huma itself,
+// e.g. when request validation fails
+var statusProblems = map[int]string{
+	http.StatusBadRequest:          ProblemBadRequest,
+	http.StatusUnauthorized:        ProblemUnauthorized,
+	http.StatusForbidden:           ProblemForbidden,
+	http.StatusNotFound:            ProblemNotFound,
+	http.StatusConflict:            ProblemConflict,
+	http.StatusUnprocessableEntity: ProblemValidation,
+	http.StatusTooManyRequests:     ProblemTooManyRequests,
+}
+
+// problem returns RFC 7807 error with given type
+func problem(status int, problemType string, detail string, errs ...error) *huma.ErrorModel {
+	p := &huma.ErrorModel{
+		Type:   problemType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+	for _, err := range errs {
+		if err != nil {
+			p.Add(err)
+		}
+	}
+
+	return p
+}
+
+// newErrorFunc returns replacement for huma.NewError which sets problem
+// types and never exposes messages of unexpected errors to clients
+func newErrorFunc(log *slog.Logger) func(status int, msg string, errs ...error) huma.StatusError {
+	return func(status int, msg string, errs ...error) huma.StatusError {
+		if status >= http.StatusInternalServerError {
+			log.Error("request failed", slog.Int("status", status), sl.Err(errors.New(msg)))
+			return problem(status, ProblemInternal, "internal error")
+		}
+
+		problemType, ok := statusProblems[status]
+		if !ok {
+			problemType = ProblemBadRequest
+		}
+
+		return problem(status, problemType, msg, errs...)
+	}
+}
+
+// mapError converts errors of the auth, webhooks and quota services to
+// problems.
+// Unknown errors are returned as is and end up as internal errors.
+func mapError(err error) error {
+	var (
+		policyErr  *password.PolicyError
+		webhookErr *webhooks.ValidationError
+		appErr     *auth.AppValidationError
+		keyErr     *auth.APIKeyValidationError
+		quotaErr   *quota.ValidationError
+	)
+
+	switch {
+	case errors.As(err, &policyErr):
+		details := make([]error, 0, len(policyErr.Violations))
+		for _, v := range policyErr.Violations {
+			details = append(details, &huma.ErrorDetail{
+				Message:  v.Message,
+				Location: "body.password",
+				Value:    v.Rule,
+			})
+		}
+		return problem(http.StatusUnprocessableEntity, ProblemWeakPassword,
+			"password does not satisfy policy", details...)
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		return problem(http.StatusUnauthorized, ProblemInvalidCredentials, "invalid credentials")
+	case errors.Is(err, auth.ErrInvalidToken):
+		return problem(http.StatusUnauthorized, ProblemInvalidToken, "invalid token")
+	case errors.Is(err, auth.ErrUserExists):
+		return problem(http.StatusConflict, ProblemUserExists, "user already exists")
+	case errors.Is(err, auth.ErrUserNotFound):
+		return problem(http.StatusNotFound, ProblemUserNotFound, "user not found")
+	case errors.Is(err, auth.ErrInvalidSession):
+		return problem(http.StatusUnauthorized, ProblemInvalidSession, "session is missing or expired")
+	case errors.Is(err, auth.ErrSessionNotFound):
+		return problem(http.StatusNotFound, ProblemNotFound, "session not found")
+	case errors.Is(err, auth.ErrConsentRequired):
+		return problem(http.StatusForbidden, ProblemConsentRequired,
+			"the user has not consented to the app, send them to "+ConsentPageURL)
+	case errors.As(err, &appErr):
+		return problem(http.StatusUnprocessableEntity, ProblemValidation, appErr.Error(), &huma.ErrorDetail{
+			Message:  appErr.Reason,
+			Location: "body." + appErr.Field,
+		})
+	case errors.Is(err, auth.ErrAppExists):
+		return problem(http.StatusConflict, ProblemConflict, "app with the name already exists")
+	case errors.Is(err, auth.ErrUnauthorizedClient):
+		return problem(http.StatusForbidden, ProblemUnauthorizedClient, "the app may not sign users in with password")
+	case errors.Is(err, auth.ErrAppNotFound):
+		return problem(http.StatusNotFound, ProblemNotFound, "app not found")
+	case errors.Is(err, auth.ErrGrantNotFound):
+		return problem(http.StatusNotFound, ProblemNotFound, "grant not found")
+	case errors.Is(err, auth.ErrInvalidScope):
+		return problem(http.StatusBadRequest, ProblemInvalidScope,
+			"scope is unknown or not allowed for the app, see "+GetScopesURL)
+	case errors.As(err, &keyErr):
+		return problem(http.StatusUnprocessableEntity, ProblemValidation, keyErr.Error(), &huma.ErrorDetail{
+			Message:  keyErr.Reason,
+			Location: "body." + keyErr.Field,
+		})
+	case errors.Is(err, auth.ErrAPIKeyNotFound):
+		return problem(http.StatusNotFound, ProblemNotFound, "api key not found")
+	case errors.Is(err, auth.ErrInvalidCursor):
+		return problem(http.StatusBadRequest, ProblemInvalidCursor, "invalid cursor")
+	case errors.Is(err, auth.ErrUserDisabled):
+		return problem(http.StatusForbidden, ProblemUserDisabled, "user is disabled")
+	case errors.Is(err, auth.ErrAccountLocked):
+		return problem(http.StatusTooManyRequests, ProblemAccountLocked,
+			"account is temporarily locked, try again later")
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return problem(http.StatusTooManyRequests, ProblemTooManyAttempts,
+			"too many login attempts, try again later")
+	case errors.As(err, &webhookErr):
+		return problem(http.StatusUnprocessableEntity, ProblemValidation, webhookErr.Error())
+	case errors.Is(err, webhooks.ErrSubscriptionNotFound):
+		return problem(http.StatusNotFound, ProblemNotFound, "webhook subscription not found")
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		return problem(http.StatusNotFound, ProblemNotFound, "webhook delivery not found")
+	case errors.Is(err, webhooks.ErrInvalidCursor):
+		return problem(http.StatusBadRequest, ProblemInvalidCursor, "invalid cursor")
+	case errors.As(err, &quotaErr):
+		return problem(http.StatusUnprocessableEntity, ProblemValidation, quotaErr.Error())
+	case errors.Is(err, quota.ErrQuotaNotFound):
+		return problem(http.StatusNotFound, ProblemNotFound, "quota not found")
+	}
+
+	return err
+}
+
+func errForbidden(detail string) error {
+	return problem(http.StatusForbidden, ProblemForbidden, detail)
+}
+
+func errBadRequest(detail string, errs ...error) error {
+	return problem(http.StatusBadRequest, ProblemBadRequest, detail, errs...)
+}
@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+const (
+	GetScopesURL   = "/scopes"
+	GetGrantsURL   = "/me/grants"
+	DeleteGrantURL = "/me/grants/{appId}"
+)
+
+func registerGrantRoutes(api huma.API, auth Auth) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-scopes",
+		Method:        http.MethodGet,
+		Path:          GetScopesURL,
+		Summary:       "Get scopes apps can request for tokens",
+		Tags:          []string{"grants"},
+		DefaultStatus: http.StatusOK,
+		Security:      public(),
+	}, func(ctx context.Context, input *struct{}) (*GetScopesResponse, error) {
+		resp := GetScopesResponse{}
+		resp.Body.Scopes = authz.Scopes()
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-grants",
+		Method:        http.MethodGet,
+		Path:          GetGrantsURL,
+		Summary:       "Get apps the token owner consented to",
+		Tags:          []string{"grants"},
+		DefaultStatus: http.StatusOK,
+		Security:      authenticated(),
+	}, func(ctx context.Context, input *struct{}) (*GetGrantsResponse, error) {
+		principal, _ := PrincipalFromContext(ctx)
+
+		grants, err := auth.Grants(ctx, principal.UserID)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := GetGrantsResponse{}
+		resp.Body.Grants = make([]GrantView, 0, len(grants))
+		for _, g := range grants {
+			brand, err := auth.Branding(ctx, g.AppID)
+			if err != nil {
+				return nil, mapError(err)
+			}
+			resp.Body.Grants = append(resp.Body.Grants, GrantView{Grant: g, AppName: brand.Name})
+		}
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "revoke-grant",
+		Method:        http.MethodDelete,
+		Path:          DeleteGrantURL,
+		Summary:       "Revoke access of an app and sign the token owner out of it",
+		Tags:          []string{"grants"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      authenticated(),
+	}, func(ctx context.Context, input *RevokeGrantInput) (*struct{}, error) {
+		principal, _ := PrincipalFromContext(ctx)
+
+		if err := auth.RevokeGrant(ctx, principal.UserID, input.AppId); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+}
@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	authsvc "github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Names of security schemes in OpenAPI document
+const (
+	bearerScheme   = "bearer"
+	appBasicScheme = "appBasic"
+)
+
+type principalKey struct{}
+
+// public marks operations which do not require authentication
+func public() []map[string][]string {
+	return nil
+}
+
+// authenticated marks operations which require a valid bearer token
+func authenticated() []map[string][]string {
+	return []map[string][]string{{bearerScheme: {}}}
+}
+
+// adminOnly marks operations which require a bearer token of an admin
+func adminOnly() []map[string][]string {
+	return []map[string][]string{{bearerScheme: {models.RoleAdmin}}}
+}
+
+// appCredentials marks operations which check "Authorization: Basic"
+// credentials of an app themselves
+func appCredentials() []map[string][]string {
+	return []map[string][]string{{appBasicScheme: {}}}
+}
+
+// optionalAuth marks operations which accept but do not require a token
+func optionalAuth() []map[string][]string {
+	return []map[string][]string{{}, {bearerScheme: {}}}
+}
+
+// PrincipalFromContext returns the authenticated caller. The second value
+// is false for anonymous requests.
+func PrincipalFromContext(ctx context.Context) (models.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(models.Principal)
+	return p, ok
+}
+
+// addSecurityScheme publishes bearer and app authentication in OpenAPI
+// document
+func addSecurityScheme(config *huma.Config) {
+	if config.Components.SecuritySchemes == nil {
+		config.Components.SecuritySchemes = map[string]*huma.SecurityScheme{}
+	}
+	config.Components.SecuritySchemes[bearerScheme] = &huma.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+	}
+	config.Components.SecuritySchemes[appBasicScheme] = &huma.SecurityScheme{
+		Type:        "http",
+		Scheme:      "basic",
+		Description: "app id and secret of a confidential app",
+	}
+}
+
+// authMiddleware authenticates requests with "Authorization: Bearer" header
+// and enforces security requirements declared on operations. Tokens sent
+// to public operations are ignored, so that a client holding an expired
+// token can still sign in again.
+func authMiddleware(api huma.API, auth Auth) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		required, optional, admin := requirements(ctx.Operation())
+		if !required {
+			next(ctx)
+			return
+		}
+
+		token, hasToken := bearerToken(ctx.Header("Authorization"))
+		if !hasToken {
+			if !optional {
+				ctx.SetHeader("WWW-Authenticate", `Bearer realm="babs-maps"`)
+				writeProblem(api, ctx, problem(http.StatusUnauthorized, ProblemUnauthorized,
+					"bearer token required"))
+				return
+			}
+			next(ctx)
+			return
+		}
+
+		principal, err := auth.Principal(ctx.Context(), token)
+		if err != nil {
+			ctx.SetHeader("WWW-Authenticate", `Bearer realm="babs-maps", error="invalid_token"`)
+			if p, ok := mapError(err).(*huma.ErrorModel); ok {
+				writeProblem(api, ctx, p)
+				return
+			}
+			huma.WriteErr(api, ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if admin && !principal.IsAdmin {
+			writeProblem(api, ctx, problem(http.StatusForbidden, ProblemForbidden, "admin required"))
+			return
+		}
+
+		reqCtx := context.WithValue(ctx.Context(), principalKey{}, principal)
+		reqCtx = authsvc.ContextWithActor(reqCtx, principal.UserID)
+		next(huma.WithContext(ctx, reqCtx))
+	}
+}
+
+// requirements reads security requirements of the operation. Empty
+// requirement object means that authentication is optional.
+func requirements(op *huma.Operation) (required bool, optional bool, admin bool) {
+	for _, req := range op.Security {
+		scopes, ok := req[bearerScheme]
+		if !ok {
+			optional = true
+			continue
+		}
+		required = true
+		admin = admin || slices.Contains(scopes, models.RoleAdmin)
+	}
+
+	return required, optional, admin
+}
+
+func bearerToken(header string) (string, bool) {
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+func writeProblem(api huma.API, ctx huma.Context, p *huma.ErrorModel) {
+	const contentType = "application/problem+json"
+
+	ctx.SetHeader("Content-Type", contentType)
+	ctx.SetStatus(p.Status)
+	_ = api.Marshal(ctx.BodyWriter(), contentType, p)
+}
@@ -0,0 +1,45 @@
+package rest_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/rest"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthMiddleware_PublicIgnoresToken(t *testing.T) {
+	srv := newTestServer(t)
+	_, email, pass := srv.register(t)
+
+	for _, token := range []string{"expired-or-garbage", srv.login(t, false)} {
+		resp, body := srv.do(t, srv.request(t, http.MethodPost, rest.PostLoginURL, token,
+			map[string]string{"email": email, "password": pass}))
+		assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+		resp, body = srv.do(t, srv.request(t, http.MethodPost, rest.PostRegisterURL, token,
+			map[string]string{"email": gofakeit.Email(), "password": pass}))
+		assert.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	}
+}
+
+func TestAuthMiddleware_Required(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, _ := srv.do(t, srv.request(t, http.MethodGet, rest.GetValidatedUsersURL, "", nil))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = srv.do(t, srv.request(t, http.MethodGet, rest.GetValidatedUsersURL, "garbage", nil))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), `error="invalid_token"`)
+
+	resp, body := srv.do(t, srv.request(t, http.MethodGet, rest.GetValidatedUsersURL, srv.login(t, false), nil))
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	// admin operations need an admin
+	resp, _ = srv.do(t, srv.request(t, http.MethodGet, rest.GetAuditURL, srv.login(t, false), nil))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, body = srv.do(t, srv.request(t, http.MethodGet, rest.GetAuditURL, srv.login(t, true), nil))
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+}
@@ -0,0 +1,609 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/password"
+	authsvc "github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	GetLoginPageURL          = "/ui/login"
+	GetRegisterPageURL       = "/ui/register"
+	GetForgotPasswordPageURL = "/ui/forgot-password"
+	PostLogoutPageURL        = "/ui/logout"
+	ConsentPageURL           = "/ui/consent"
+)
+
+// maxPageFormBytes limits bodies of form posts
+const maxPageFormBytes = 64 << 10
+
+// pagesPolicy is Content-Security-Policy of hosted pages. They have no
+// scripts; inline styles carry colors of app branding and logos may be
+// served from any https origin.
+const pagesPolicy = "default-src 'none'; style-src 'self' 'unsafe-inline'; img-src 'self' https:; " +
+	"form-action 'self'; frame-ancestors 'none'; base-uri 'none'"
+
+//go:embed pages
+var pagesFS embed.FS
+
+// pageTemplates are pages by file name, each executed within layout.html
+var pageTemplates = parsePages(
+	"login.html",
+	"register.html",
+	"signed_in.html",
+	"forgot_password.html",
+	"consent.html",
+	"error.html",
+)
+
+func parsePages(names ...string) map[string]*template.Template {
+	templates := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		templates[name] = template.Must(template.ParseFS(pagesFS, "pages/layout.html", "pages/"+name))
+	}
+
+	return templates
+}
+
+// pageData is rendered by page templates. AppID and ReturnTo are carried
+// through forms and links, so that the user comes back to the app.
+type pageData struct {
+	Title      string
+	Brand      models.Branding
+	AppID      int
+	ReturnTo   string
+	Query      template.URL
+	CSRFToken  string
+	Email      string
+	Error      string
+	Violations []string
+	// Scopes requested by the app on the consent page, Scope is the same
+	// as space-separated parameter and Permissions describe them
+	Scopes      []string
+	Scope       string
+	Permissions []authz.Scope
+}
+
+type pages struct {
+	log  *slog.Logger
+	auth Auth
+}
+
+// registerPages registers hosted login and registration pages. They are
+// plain HTML forms that work without JavaScript, branded for the app of
+// app_id parameter, and use the same cookies as the /sso operations.
+func registerPages(router chi.Router, log *slog.Logger, auth Auth) {
+	p := &pages{log: log, auth: auth}
+
+	static, err := fs.Sub(pagesFS, "pages/static")
+	if err != nil {
+		panic(err)
+	}
+
+	router.Route("/ui", func(r chi.Router) {
+		r.Use(pageHeaders)
+		r.Handle("/static/*", http.StripPrefix("/ui/static/", http.FileServer(http.FS(static))))
+		r.Get("/login", p.getLogin)
+		r.Post("/login", p.postLogin)
+		r.Get("/register", p.getRegister)
+		r.Post("/register", p.postRegister)
+		r.Post("/logout", p.postLogout)
+		r.Get("/forgot-password", p.getForgotPassword)
+		r.Get("/consent", p.getConsent)
+		r.Post("/consent", p.postConsent)
+		r.NotFound(p.notFound)
+	})
+}
+
+func pageHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", pagesPolicy)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "same-origin")
+		h.Set("Cache-Control", "no-store")
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxPageFormBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *pages) getLogin(w http.ResponseWriter, r *http.Request) {
+	data, err := p.newPage(w, r, "Sign in")
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		session, err := p.auth.BrowserSession(r.Context(), cookie.Value)
+		switch {
+		case err == nil:
+			p.signedIn(w, r, data, session)
+			return
+		case errors.Is(err, authsvc.ErrInvalidSession):
+			setCookie(w, expiredCookie(SessionCookie))
+		default:
+			p.fail(w, r, err)
+			return
+		}
+	}
+
+	p.render(w, http.StatusOK, "login.html", data)
+}
+
+func (p *pages) postLogin(w http.ResponseWriter, r *http.Request) {
+	data, err := p.newPage(w, r, "Sign in")
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+	if !p.checkCSRF(w, r, data) {
+		return
+	}
+
+	data.Email = r.PostFormValue("email")
+	ctx := withAppID(r.Context(), data.AppID)
+	session, cookie, err := p.auth.BrowserLogin(ctx, data.Email, r.PostFormValue("password"))
+	if err != nil {
+		p.formError(w, r, "login.html", data, err)
+		return
+	}
+
+	p.startSession(w, r, data, session, cookie)
+}
+
+func (p *pages) getRegister(w http.ResponseWriter, r *http.Request) {
+	data, err := p.newPage(w, r, "Create account")
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+
+	p.render(w, http.StatusOK, "register.html", data)
+}
+
+// postRegister registers the user and signs the browser in
+func (p *pages) postRegister(w http.ResponseWriter, r *http.Request) {
+	data, err := p.newPage(w, r, "Create account")
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+	if !p.checkCSRF(w, r, data) {
+		return
+	}
+
+	data.Email = r.PostFormValue("email")
+	pass := r.PostFormValue("password")
+	if _, err := mail.ParseAddress(data.Email); err != nil {
+		data.Error = "Enter a valid email address."
+		p.render(w, http.StatusUnprocessableEntity, "register.html", data)
+		return
+	}
+
+	ctx := withAppID(r.Context(), data.AppID)
+	if _, err := p.auth.RegisterNewUser(ctx, data.Email, pass); err != nil {
+		p.formError(w, r, "register.html", data, err)
+		return
+	}
+
+	session, cookie, err := p.auth.BrowserLogin(ctx, data.Email, pass)
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+
+	p.startSession(w, r, data, session, cookie)
+}
+
+func (p *pages) postLogout(w http.ResponseWriter, r *http.Request) {
+	data, err := p.newPage(w, r, "Sign out")
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+	if !p.checkCSRF(w, r, data) {
+		return
+	}
+
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		if err := p.auth.BrowserLogout(r.Context(), cookie.Value); err != nil {
+			p.fail(w, r, err)
+			return
+		}
+	}
+
+	setCookie(w, expiredCookie(SessionCookie))
+	http.Redirect(w, r, GetLoginPageURL+"?"+string(data.Query), http.StatusSeeOther)
+}
+
+// getForgotPassword explains how to get a new password. Users cannot
+// reset passwords themselves yet, administrators do it with
+// POST /admin/password.
+func (p *pages) getForgotPassword(w http.ResponseWriter, r *http.Request) {
+	data, err := p.newPage(w, r, "Forgot password")
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+
+	p.render(w, http.StatusOK, "forgot_password.html", data)
+}
+
+// getConsent asks the signed-in user to let the app use the scopes of
+// scope parameter. Apps start sign-in here: browsers without a session
+// are sent to sign in first, and users are not asked again for scopes
+// granted before or by first-party apps.
+func (p *pages) getConsent(w http.ResponseWriter, r *http.Request) {
+	data, session, ok := p.consentPage(w, r)
+	if !ok {
+		return
+	}
+
+	required, err := p.auth.ConsentRequired(r.Context(), session.UserID, data.AppID, data.Scopes)
+	if err != nil {
+		p.consentError(w, r, data, err)
+		return
+	}
+	if !required {
+		p.redirectBack(w, r, data)
+		return
+	}
+
+	user, err := p.auth.UserById(r.Context(), session.UserID)
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+	data.Email = user.Email
+
+	p.render(w, http.StatusOK, "consent.html", data)
+}
+
+func (p *pages) postConsent(w http.ResponseWriter, r *http.Request) {
+	data, session, ok := p.consentPage(w, r)
+	if !ok {
+		return
+	}
+	if !p.checkCSRF(w, r, data) {
+		return
+	}
+
+	if r.PostFormValue("decision") != "allow" {
+		p.redirectDenied(w, r, data)
+		return
+	}
+
+	ctx := withAppID(r.Context(), data.AppID)
+	if err := p.auth.GrantConsent(ctx, session.UserID, data.AppID, data.Scopes); err != nil {
+		p.consentError(w, r, data, err)
+		return
+	}
+
+	p.redirectBack(w, r, data)
+}
+
+// consentPage reads the app and scopes of the consent page and the
+// session of the browser. It responds itself and returns false if either
+// is missing.
+func (p *pages) consentPage(w http.ResponseWriter, r *http.Request) (pageData, models.Session, bool) {
+	data, err := p.newPage(w, r, "Allow access")
+	if err != nil {
+		p.fail(w, r, err)
+		return pageData{}, models.Session{}, false
+	}
+
+	if data.AppID == 0 {
+		p.consentError(w, r, data, authsvc.ErrInvalidScope)
+		return pageData{}, models.Session{}, false
+	}
+	scopes, err := p.auth.ConsentScopes(r.Context(), data.AppID, r.FormValue("scope"))
+	if err != nil {
+		p.consentError(w, r, data, err)
+		return pageData{}, models.Session{}, false
+	}
+	data.Scopes = scopes
+	data.Scope = authz.FormatScope(scopes)
+	for _, s := range scopes {
+		scope, _ := authz.Lookup(s)
+		data.Permissions = append(data.Permissions, scope)
+	}
+
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		session, err := p.auth.BrowserSession(r.Context(), cookie.Value)
+		switch {
+		case err == nil:
+			return data, session, true
+		case !errors.Is(err, authsvc.ErrInvalidSession):
+			p.fail(w, r, err)
+			return pageData{}, models.Session{}, false
+		}
+	}
+
+	// the browser comes back here after sign-in
+	consent := url.Values{}
+	consent.Set("app_id", strconv.Itoa(data.AppID))
+	consent.Set("scope", data.Scope)
+	if data.ReturnTo != "" {
+		consent.Set("return_to", data.ReturnTo)
+	}
+	login := url.Values{}
+	login.Set("app_id", strconv.Itoa(data.AppID))
+	login.Set("return_to", ConsentPageURL+"?"+consent.Encode())
+
+	http.Redirect(w, r, GetLoginPageURL+"?"+login.Encode(), http.StatusSeeOther)
+	return pageData{}, models.Session{}, false
+}
+
+func (p *pages) consentError(w http.ResponseWriter, r *http.Request, data pageData, err error) {
+	switch {
+	case errors.Is(err, authsvc.ErrAppNotFound):
+		data.Title = "Unknown app"
+		data.Error = "The app asking for access is not registered."
+		p.render(w, http.StatusNotFound, "error.html", data)
+	case errors.Is(err, authsvc.ErrInvalidScope):
+		data.Title = "Invalid request"
+		data.Error = "The app sent an invalid sign-in request."
+		p.render(w, http.StatusBadRequest, "error.html", data)
+	default:
+		p.fail(w, r, err)
+	}
+}
+
+// redirectDenied tells the app that the user did not allow access with
+// error parameter of OAuth
+func (p *pages) redirectDenied(w http.ResponseWriter, r *http.Request, data pageData) {
+	if data.ReturnTo == "" {
+		data.Title = "Access not allowed"
+		data.Error = "You did not allow " + data.Brand.Name + " to access your account."
+		p.render(w, http.StatusForbidden, "error.html", data)
+		return
+	}
+
+	target, err := url.Parse(data.ReturnTo)
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+	query := target.Query()
+	query.Set("error", "access_denied")
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusSeeOther)
+}
+
+func (p *pages) notFound(w http.ResponseWriter, r *http.Request) {
+	data, err := p.newPage(w, r, "Page not found")
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+
+	p.render(w, http.StatusNotFound, "error.html", data)
+}
+
+// newPage reads app and return path from the query or form, looks up the
+// branding and issues a CSRF cookie if the browser has none
+func (p *pages) newPage(w http.ResponseWriter, r *http.Request, title string) (pageData, error) {
+	appID, err := strconv.Atoi(r.FormValue("app_id"))
+	if err != nil || appID < 0 {
+		appID = 0
+	}
+	returnTo := safeReturnTo(r.FormValue("return_to"))
+
+	brand, err := p.auth.Branding(r.Context(), appID)
+	if err != nil {
+		return pageData{}, err
+	}
+
+	var token string
+	if cookie, err := r.Cookie(CSRFCookie); err == nil && validCSRFToken(cookie.Value) {
+		token = cookie.Value
+	} else {
+		token, err = newCSRFToken()
+		if err != nil {
+			return pageData{}, err
+		}
+		setCookie(w, csrfCookie(token))
+	}
+
+	query := url.Values{}
+	if appID != 0 {
+		query.Set("app_id", strconv.Itoa(appID))
+	}
+	if returnTo != "" {
+		query.Set("return_to", returnTo)
+	}
+
+	return pageData{
+		Title:     title,
+		Brand:     brand,
+		AppID:     appID,
+		ReturnTo:  returnTo,
+		Query:     template.URL(query.Encode()),
+		CSRFToken: token,
+	}, nil
+}
+
+// checkCSRF renders the error page and returns false if the form was not
+// posted from a page of the browser
+func (p *pages) checkCSRF(w http.ResponseWriter, r *http.Request, data pageData) bool {
+	var cookie string
+	if c, err := r.Cookie(CSRFCookie); err == nil {
+		cookie = c.Value
+	}
+	if checkCSRF(cookie, "", r.PostFormValue("csrf_token")) == nil {
+		return true
+	}
+
+	data.Title = "Form expired"
+	data.Error = "The form has expired. Go back, reload the page and try again."
+	p.render(w, http.StatusForbidden, "error.html", data)
+	return false
+}
+
+// startSession sets cookies of a new browser session, replacing the
+// previous one, and sends the browser back to the app
+func (p *pages) startSession(
+	w http.ResponseWriter,
+	r *http.Request,
+	data pageData,
+	session models.Session,
+	cookie string,
+) {
+	if previous, err := r.Cookie(SessionCookie); err == nil {
+		if err := p.auth.BrowserLogout(r.Context(), previous.Value); err != nil {
+			p.fail(w, r, err)
+			return
+		}
+	}
+
+	// the token seen before login is rotated
+	token, err := newCSRFToken()
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+	setCookie(w, sessionCookie(cookie, session.ExpiresAt))
+	setCookie(w, csrfCookie(token))
+
+	p.redirectBack(w, r, data)
+}
+
+// signedIn sends the browser back to the app or, if it came to sign in
+// directly, shows who is signed in
+func (p *pages) signedIn(w http.ResponseWriter, r *http.Request, data pageData, session models.Session) {
+	if data.ReturnTo != "" {
+		p.redirectBack(w, r, data)
+		return
+	}
+
+	user, err := p.auth.UserById(r.Context(), session.UserID)
+	if err != nil {
+		p.fail(w, r, err)
+		return
+	}
+
+	data.Title = "Signed in"
+	data.Email = user.Email
+	p.render(w, http.StatusOK, "signed_in.html", data)
+}
+
+func (p *pages) redirectBack(w http.ResponseWriter, r *http.Request, data pageData) {
+	target := data.ReturnTo
+	if target == "" {
+		target = GetLoginPageURL + "?" + string(data.Query)
+	}
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// formError shows the form again with the error if the user can fix it
+func (p *pages) formError(w http.ResponseWriter, r *http.Request, name string, data pageData, err error) {
+	var (
+		policyErr *password.PolicyError
+		status    int
+	)
+
+	switch {
+	case errors.As(err, &policyErr):
+		status = http.StatusUnprocessableEntity
+		data.Error = "Choose a stronger password."
+		for _, v := range policyErr.Violations {
+			data.Violations = append(data.Violations, v.Message)
+		}
+	case errors.Is(err, authsvc.ErrInvalidCredentials):
+		status = http.StatusUnauthorized
+		data.Error = "Invalid email or password."
+	case errors.Is(err, authsvc.ErrUserExists):
+		status = http.StatusConflict
+		data.Error = "An account with this email already exists."
+	case errors.Is(err, authsvc.ErrUserDisabled):
+		status = http.StatusForbidden
+		data.Error = "Your account is disabled."
+	case errors.Is(err, authsvc.ErrAccountLocked):
+		status = http.StatusTooManyRequests
+		data.Error = "Your account is temporarily locked. Try again later."
+	case errors.Is(err, authsvc.ErrTooManyAttempts):
+		status = http.StatusTooManyRequests
+		data.Error = "Too many sign-in attempts. Try again later."
+	default:
+		p.fail(w, r, err)
+		return
+	}
+
+	p.render(w, status, name, data)
+}
+
+// fail logs unexpected error and renders the error page without its
+// message
+func (p *pages) fail(w http.ResponseWriter, r *http.Request, err error) {
+	p.log.Error("page failed", slog.String("path", r.URL.Path), sl.Err(err))
+
+	p.render(w, http.StatusInternalServerError, "error.html", pageData{
+		Title: "Something went wrong",
+		Brand: authsvc.DefaultBranding,
+		Error: "Please try again later.",
+	})
+}
+
+// render executes the page before writing anything, so that a template
+// error does not leave a half-written page
+func (p *pages) render(w http.ResponseWriter, status int, name string, data pageData) {
+	var buf bytes.Buffer
+	if err := pageTemplates[name].ExecuteTemplate(&buf, "layout", data); err != nil {
+		p.log.Error("failed to render page", slog.String("page", name), sl.Err(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
+
+func setCookie(w http.ResponseWriter, cookie http.Cookie) {
+	http.SetCookie(w, &cookie)
+}
+
+func withAppID(ctx context.Context, appID int) context.Context {
+	info := clientinfo.FromContext(ctx)
+	info.AppID = appID
+
+	return clientinfo.WithInfo(ctx, info)
+}
+
+// safeReturnTo returns path if it stays on this service, so that pages
+// cannot be used to redirect users to other sites. Apps are to be
+// redirected to only by an authorize endpoint, to their registered
+// redirect URIs.
+func safeReturnTo(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, `\`) {
+		return ""
+	}
+
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return ""
+	}
+
+	return path
+}
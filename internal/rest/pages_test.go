@@ -0,0 +1,177 @@
+package rest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var csrfInputRe = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// loginPage opens the login page and returns the CSRF cookie it set
+func (s *testServer) loginPage(t *testing.T, query url.Values) (*http.Cookie, string) {
+	t.Helper()
+
+	resp, body := s.do(t, s.request(t, http.MethodGet, rest.GetLoginPageURL+"?"+query.Encode(), "", nil))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	csrf := responseCookie(t, resp, rest.CSRFCookie)
+	m := csrfInputRe.FindSubmatch(body)
+	require.NotNil(t, m, "csrf_token input")
+	require.Equal(t, csrf.Value, string(m[1]))
+
+	return csrf, string(body)
+}
+
+// postForm posts form to path with cookies and returns the response
+// itself rather than the page it redirects to
+func (s *testServer) postForm(t *testing.T, path string, form url.Values, cookies ...*http.Cookie) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, s.URL+path, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	client := *s.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, string(body)
+}
+
+func TestLoginPage_ReturnTo(t *testing.T) {
+	srv := newTestServer(t)
+	_, email, pass := srv.register(t)
+
+	tests := []struct {
+		returnTo string
+		want     string
+	}{
+		{returnTo: "/ui/consent?app_id=1&scope=maps.read", want: "/ui/consent?app_id=1&scope=maps.read"},
+		{returnTo: "/%2F%2Fevil.example", want: "/%2F%2Fevil.example"},
+		{returnTo: "", want: rest.GetLoginPageURL + "?"},
+		{returnTo: "//evil.example", want: rest.GetLoginPageURL + "?"},
+		{returnTo: `/\evil.example`, want: rest.GetLoginPageURL + "?"},
+		{returnTo: `\\evil.example`, want: rest.GetLoginPageURL + "?"},
+		{returnTo: "%2F%2Fevil.example", want: rest.GetLoginPageURL + "?"},
+		{returnTo: "https://evil.example/", want: rest.GetLoginPageURL + "?"},
+		{returnTo: "javascript:alert(1)", want: rest.GetLoginPageURL + "?"},
+		{returnTo: "/\t/evil.example", want: rest.GetLoginPageURL + "?"},
+		{returnTo: "evil.example", want: rest.GetLoginPageURL + "?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.returnTo, func(t *testing.T) {
+			// url.Values encodes the value once more, so "//" arrives as
+			// %2F%2F and "%2F%2F" as %252F%252F
+			query := url.Values{"return_to": {tt.returnTo}}
+			csrf, _ := srv.loginPage(t, query)
+
+			resp, _ := srv.postForm(t, rest.GetLoginPageURL, url.Values{
+				"csrf_token": {csrf.Value},
+				"email":      {email},
+				"password":   {pass},
+				"return_to":  {tt.returnTo},
+			}, csrf)
+
+			require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+			assert.Equal(t, tt.want, resp.Header.Get("Location"))
+		})
+	}
+}
+
+func TestLoginPage_RejectsCSRF(t *testing.T) {
+	srv := newTestServer(t)
+	_, email, pass := srv.register(t)
+	csrf, _ := srv.loginPage(t, nil)
+	other, _ := srv.loginPage(t, nil)
+
+	tests := []struct {
+		name    string
+		token   string
+		cookies []*http.Cookie
+	}{
+		{name: "no token", cookies: []*http.Cookie{csrf}},
+		{name: "no cookie", token: csrf.Value},
+		{name: "mismatch", token: other.Value, cookies: []*http.Cookie{csrf}},
+		{name: "not issued by server", token: "x", cookies: []*http.Cookie{{Name: rest.CSRFCookie, Value: "x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := srv.postForm(t, rest.GetLoginPageURL, url.Values{
+				"csrf_token": {tt.token},
+				"email":      {email},
+				"password":   {pass},
+			}, tt.cookies...)
+
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+			assert.Contains(t, body, "The form has expired")
+			for _, c := range resp.Cookies() {
+				assert.NotEqual(t, rest.SessionCookie, c.Name)
+			}
+		})
+	}
+
+	// the same form passes with the matching token
+	resp, _ := srv.postForm(t, rest.GetLoginPageURL, url.Values{
+		"csrf_token": {csrf.Value},
+		"email":      {email},
+		"password":   {pass},
+	}, csrf)
+	require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+	assert.NotEmpty(t, responseCookie(t, resp, rest.SessionCookie).Value)
+	assert.NotEqual(t, csrf.Value, responseCookie(t, resp, rest.CSRFCookie).Value)
+}
+
+func TestLoginPage_HostileBranding(t *testing.T) {
+	const script = "<script>alert(1)</script>"
+
+	srv := newTestServer(t)
+	// stored as is, bypassing validation of the admin API
+	appID, err := srv.st.CreateApp(context.Background(), models.App{
+		Name:            "hostile",
+		Type:            models.AppTypePublic,
+		DisplayName:     `</title>` + script,
+		LogoURL:         `https://cdn.example/logo.png" onerror="alert(1)`,
+		PrimaryColor:    `red; } </style>` + script,
+		BackgroundColor: "expression(alert(1))",
+	})
+	require.NoError(t, err)
+
+	query := url.Values{
+		"app_id":    {strconv.Itoa(appID)},
+		"return_to": {`/ui/consent?x="><script>alert(1)</script>`},
+	}
+	_, page := srv.loginPage(t, query)
+
+	assert.NotContains(t, page, script)
+	assert.NotContains(t, page, `" onerror=`)
+	assert.NotContains(t, page, "</style>"+script)
+	assert.NotContains(t, page, "expression(")
+	assert.Contains(t, page, "&lt;/title&gt;&lt;script&gt;")
+	// invalid colors fall back to defaults
+	assert.Contains(t, page, "--primary: #2563eb; --background: #f4f6fb;")
+
+	resp, _ := srv.do(t, srv.request(t, http.MethodGet, rest.GetLoginPageURL, "", nil))
+	assert.Equal(t, "default-src 'none'; style-src 'self' 'unsafe-inline'; img-src 'self' https:; "+
+		"form-action 'self'; frame-ancestors 'none'; base-uri 'none'", resp.Header.Get("Content-Security-Policy"))
+}
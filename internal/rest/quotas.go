@@ -0,0 +1,210 @@
+package rest
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	authsvc "github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+// Quotas charges requests of map services to request budgets
+type Quotas interface {
+	Check(ctx context.Context, token string, cost int64) (models.Principal, models.QuotaDecision, error)
+	SetQuota(ctx context.Context, q models.Quota) (models.Quota, error)
+	Quotas(ctx context.Context) ([]models.Quota, error)
+	DeleteQuota(ctx context.Context, subject models.QuotaSubject) error
+	Usage(ctx context.Context, filter models.UsageFilter) ([]models.QuotaUsage, error)
+}
+
+const (
+	PostQuotaCheckURL = "/quota/check"
+	GetQuotasURL      = "/admin/quotas"
+	QuotaURL          = "/admin/quotas/{subjectType}/{subjectId}"
+	GetUsageURL       = "/admin/usage"
+)
+
+func registerQuotaRoutes(api huma.API, auth Auth, quotas Quotas) {
+	huma.Register(api, huma.Operation{
+		OperationID: "check-quota",
+		Method:      http.MethodPost,
+		Path:        PostQuotaCheckURL,
+		Summary:     "Introspect a token and charge a request to its quotas",
+		Description: "Map services call it for every request they serve, authenticated with " +
+			"credentials of their confidential app. Like token introspection, invalid tokens " +
+			"are reported with active set to false.",
+		Tags:          []string{"quotas"},
+		DefaultStatus: http.StatusOK,
+		Security:      appCredentials(),
+	}, func(ctx context.Context, input *CheckQuotaInput) (*CheckQuotaResponse, error) {
+		appID, secret, ok := basicCredentials(input.Authorization)
+		if !ok {
+			return nil, errAppCredentials()
+		}
+		if _, err := auth.AuthenticateApp(ctx, appID, secret); err != nil {
+			if !errors.Is(err, authsvc.ErrInvalidCredentials) {
+				return nil, mapError(err)
+			}
+			return nil, errAppCredentials()
+		}
+
+		cost := input.Body.Cost
+		if cost == 0 {
+			cost = 1
+		}
+
+		resp := CheckQuotaResponse{}
+		principal, decision, err := quotas.Check(ctx, input.Body.Token, cost)
+		if err != nil {
+			if errors.Is(err, authsvc.ErrInvalidToken) {
+				return &resp, nil
+			}
+			return nil, mapError(err)
+		}
+
+		resp.Body.Active = true
+		resp.Body.Allowed = decision.Allowed
+		resp.Body.UserId = &principal.UserID
+		resp.Body.AppId = principal.AppID
+		resp.Body.OrgId = principal.OrgID
+		if principal.APIKeyID != uuid.Nil {
+			resp.Body.APIKeyId = &principal.APIKeyID
+		}
+		resp.Body.Scope = authz.FormatScope(principal.Scopes)
+		resp.Body.Budget = decision.Budget
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-quotas",
+		Method:        http.MethodGet,
+		Path:          GetQuotasURL,
+		Summary:       "Get request budgets of apps, organizations and API keys",
+		Tags:          []string{"quotas"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *struct{}) (*GetQuotasResponse, error) {
+		list, err := quotas.Quotas(ctx)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := GetQuotasResponse{}
+		resp.Body.Quotas = list
+		if resp.Body.Quotas == nil {
+			resp.Body.Quotas = []models.Quota{}
+		}
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "set-quota",
+		Method:        http.MethodPut,
+		Path:          QuotaURL,
+		Summary:       "Set the request budget of an app, organization or API key",
+		Tags:          []string{"quotas"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *SetQuotaInput) (*SetQuotaResponse, error) {
+		q, err := quotas.SetQuota(ctx, models.Quota{
+			QuotaSubject: models.QuotaSubject{Type: input.SubjectType, ID: input.SubjectId},
+			PerMinute:    input.Body.PerMinute,
+			PerDay:       input.Body.PerDay,
+			PerMonth:     input.Body.PerMonth,
+		})
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := SetQuotaResponse{}
+		resp.Body.Quota = q
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "delete-quota",
+		Method:        http.MethodDelete,
+		Path:          QuotaURL,
+		Summary:       "Lift the request budget of an app, organization or API key",
+		Tags:          []string{"quotas"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *DeleteQuotaInput) (*struct{}, error) {
+		subject := models.QuotaSubject{Type: input.SubjectType, ID: input.SubjectId}
+		if err := quotas.DeleteQuota(ctx, subject); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-usage",
+		Method:        http.MethodGet,
+		Path:          GetUsageURL,
+		Summary:       "Get requests charged to apps, organizations and API keys by day or month",
+		Tags:          []string{"quotas"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *GetUsageInput) (*GetUsageResponse, error) {
+		filter := models.UsageFilter{
+			Period: input.Period,
+			From:   input.From,
+			To:     input.To,
+		}
+		if input.SubjectType != "" {
+			filter.Subject = &models.QuotaSubject{Type: input.SubjectType, ID: input.SubjectId}
+		} else if input.SubjectId != "" {
+			return nil, errBadRequest("subject_id requires subject_type")
+		}
+
+		report, err := quotas.Usage(ctx, filter)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := GetUsageResponse{}
+		resp.Body.Usage = report
+		if resp.Body.Usage == nil {
+			resp.Body.Usage = []models.QuotaUsage{}
+		}
+		return &resp, nil
+	})
+}
+
+// basicCredentials parses "Authorization: Basic" header with app id as
+// the user name and app secret as the password
+func basicCredentials(header string) (appID int, secret string, ok bool) {
+	const prefix = "basic "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return 0, "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len(prefix):]))
+	if err != nil {
+		return 0, "", false
+	}
+	id, secret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return 0, "", false
+	}
+	appID, err = strconv.Atoi(id)
+	if err != nil || appID <= 0 || secret == "" {
+		return 0, "", false
+	}
+
+	return appID, secret, true
+}
+
+func errAppCredentials() error {
+	return huma.ErrorWithHeaders(
+		problem(http.StatusUnauthorized, ProblemUnauthorized, "credentials of a confidential app required"),
+		http.Header{"WWW-Authenticate": {`Basic realm="babs-maps"`}},
+	)
+}
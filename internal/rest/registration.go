@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	authsvc "github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/go-chi/chi/v5"
+)
+
+const PostAppRegistrationURL = "/oauth2/register"
+
+const maxRegistrationBytes = 64 << 10
+
+// Token endpoint authentication methods of RFC 7591. Apps registered
+// with "none" are public.
+const (
+	authMethodNone        = "none"
+	authMethodSecretBasic = "client_secret_basic"
+	authMethodSecretPost  = "client_secret_post"
+)
+
+// clientMetadata is the part of RFC 7591 client metadata apps can set
+type clientMetadata struct {
+	ClientName              string   `json:"client_name,omitempty"`
+	RedirectURIs            []string `json:"redirect_uris,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+	LogoURI                 string   `json:"logo_uri,omitempty"`
+}
+
+// clientInformation is the response of RFC 7591 registration
+type clientInformation struct {
+	ClientID         string `json:"client_id"`
+	ClientSecret     string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt int64  `json:"client_id_issued_at"`
+	// ClientSecretExpiresAt is 0 as secrets do not expire, and is
+	// omitted for public apps
+	ClientSecretExpiresAt *int64 `json:"client_secret_expires_at,omitempty"`
+	clientMetadata
+}
+
+// registrationError is an error response of RFC 7591
+type registrationError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// registerAppRegistration registers dynamic registration of RFC 7591.
+// Apps present the initial access token as a bearer token; without one
+// configured the endpoint answers 404.
+func registerAppRegistration(router chi.Router, log *slog.Logger, auth Auth) {
+	router.Post(PostAppRegistrationURL, func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRegistrationBytes)
+
+		var md clientMetadata
+		if err := json.NewDecoder(r.Body).Decode(&md); err != nil {
+			writeRegistrationError(w, http.StatusBadRequest, "invalid_client_metadata", "request body must be client metadata in JSON")
+			return
+		}
+
+		app := models.App{
+			DisplayName:  md.ClientName,
+			RedirectURIs: md.RedirectURIs,
+			GrantTypes:   md.GrantTypes,
+			Scopes:       authz.ParseScope(md.Scope),
+			LogoURL:      md.LogoURI,
+		}
+		if len(app.GrantTypes) == 0 {
+			app.GrantTypes = []string{models.GrantTypeAuthorizationCode}
+		}
+		switch md.TokenEndpointAuthMethod {
+		case "", authMethodSecretBasic, authMethodSecretPost:
+			app.Type = models.AppTypeConfidential
+		case authMethodNone:
+			app.Type = models.AppTypePublic
+		default:
+			writeRegistrationError(w, http.StatusBadRequest, "invalid_client_metadata", "unsupported token_endpoint_auth_method")
+			return
+		}
+
+		token, _ := bearerToken(r.Header.Get("Authorization"))
+		app, secret, err := auth.RegisterApp(r.Context(), token, app)
+		if err != nil {
+			var validationErr *authsvc.AppValidationError
+			switch {
+			case errors.Is(err, authsvc.ErrRegistrationDisabled):
+				http.NotFound(w, r)
+			case errors.Is(err, authsvc.ErrInvalidToken):
+				w.Header().Set("WWW-Authenticate", `Bearer realm="babs-maps", error="invalid_token"`)
+				writeRegistrationError(w, http.StatusUnauthorized, "invalid_token", "initial access token is missing or invalid")
+			case errors.As(err, &validationErr) && validationErr.Field == "redirect_uris":
+				writeRegistrationError(w, http.StatusBadRequest, "invalid_redirect_uri", validationErr.Reason)
+			case errors.As(err, &validationErr):
+				writeRegistrationError(w, http.StatusBadRequest, "invalid_client_metadata",
+					validationErr.Field+": "+validationErr.Reason)
+			default:
+				log.Error("failed to register app", sl.Err(err))
+				writeRegistrationError(w, http.StatusInternalServerError, "server_error", "")
+			}
+			return
+		}
+
+		info := clientInformation{
+			ClientID:         strconv.Itoa(app.ID),
+			ClientSecret:     secret,
+			ClientIDIssuedAt: time.Now().Unix(),
+			clientMetadata: clientMetadata{
+				ClientName:              app.DisplayName,
+				RedirectURIs:            app.RedirectURIs,
+				GrantTypes:              app.GrantTypes,
+				TokenEndpointAuthMethod: authMethodNone,
+				Scope:                   authz.FormatScope(app.Scopes),
+				LogoURI:                 app.LogoURL,
+			},
+		}
+		if app.Type == models.AppTypeConfidential {
+			var never int64
+			info.ClientSecretExpiresAt = &never
+			info.TokenEndpointAuthMethod = md.TokenEndpointAuthMethod
+			if info.TokenEndpointAuthMethod == "" {
+				info.TokenEndpointAuthMethod = authMethodSecretBasic
+			}
+		}
+
+		writeRegistrationJSON(w, http.StatusCreated, info)
+	})
+}
+
+func writeRegistrationError(w http.ResponseWriter, status int, code string, description string) {
+	writeRegistrationJSON(w, status, registrationError{Error: code, Description: description})
+}
+
+func writeRegistrationJSON(w http.ResponseWriter, status int, body any) {
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -1,27 +1,35 @@
 package rest
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
 	"github.com/google/uuid"
 )
 
 type LoginInput struct {
 	Body struct {
-		Email    string `json:"email" doc:"user email"`
-		Password string `json:"password" doc:"user password"`
+		Email    string `json:"email" minLength:"1" doc:"user email"`
+		Password string `json:"password" minLength:"1" doc:"user password"`
+		AppId    int    `json:"app_id,omitempty" minimum:"0" doc:"app the user signs in to, recorded with the session"`
+		Scope    string `json:"scope,omitempty" example:"maps:read tiles:render" doc:"space-separated scopes requested for the app, all the app is allowed if empty"`
 	}
 }
 
 type LoginResponse struct {
 	Body struct {
 		Token string `json:"token" example:"oirijt8u2j3f" doc:"jwt token"`
+		Scope string `json:"scope,omitempty" example:"maps:read tiles:render" doc:"space-separated scopes granted to the token"`
 	}
 }
 
 type RegisterInput struct {
 	Body struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" format:"email" doc:"user email"`
+		Password string `json:"password" minLength:"1" doc:"user password"`
+		AppId    int    `json:"app_id,omitempty" minimum:"0" doc:"app the user signs up through, sent in webhooks of the app"`
 	}
 }
 
@@ -37,31 +45,408 @@ type GetUserInput struct {
 
 type GetUserResponse struct {
 	Body struct {
-		// TODO: hide password and private data
-		User models.User `json:"user" doc:"full user info"`
+		User models.UserView `json:"user" doc:"user info visible to the caller"`
 	}
 }
 
 type GetUsersInput struct {
-	Limit uint `doc:"limit" query:"limit"`
+	Limit         uint      `query:"limit" maximum:"100" doc:"page size, 20 by default"`
+	Cursor        string    `query:"cursor" doc:"next_cursor of the previous page"`
+	Sort          string    `query:"sort" enum:"created_at,email" default:"created_at" doc:"sort field"`
+	Order         string    `query:"order" enum:"asc,desc" default:"asc" doc:"sort order"`
+	EmailPrefix   string    `query:"email_prefix" doc:"only users whose email starts with the prefix"`
+	Role          string    `query:"role" enum:"admin,user" doc:"only users with the role"`
+	Verified      string    `query:"verified" enum:"true,false" doc:"only verified or unverified users"`
+	Disabled      string    `query:"disabled" enum:"true,false" doc:"only disabled or enabled users"`
+	OrgId         string    `query:"org_id" format:"uuid" doc:"only members of the organization"`
+	CreatedAfter  time.Time `query:"created_after" doc:"only users created at or after the time"`
+	CreatedBefore time.Time `query:"created_before" doc:"only users created before the time"`
 }
 
 type GetUsersResponse struct {
 	Body struct {
-		// TODO: hide password and private data
-		Users []models.User `json:"users" doc:"full users info"`
+		Users      []models.UserView `json:"users" doc:"users info visible to the caller"`
+		NextCursor string            `json:"next_cursor,omitempty" doc:"cursor of the next page, empty on the last page"`
+		Total      int               `json:"total" doc:"number of users matching filters"`
 	}
 }
 
 type GetUserByTokenInput struct {
-	Body struct {
-		Token    string `json:"token"`
+	Body *struct {
+		Token string `json:"token,omitempty" doc:"deprecated, use Authorization header"`
 	}
 }
 
 type GetUserByTokenResponse struct {
 	Body struct {
-		// TODO: hide password and private data
-		User models.User `json:"user" doc:"full user info"`
+		User models.UserView `json:"user" doc:"info of the token owner"`
+	}
+}
+
+type UnlockUserInput struct {
+	Body struct {
+		Email string `json:"email" doc:"email of the locked user"`
+	}
+}
+
+type ChangePasswordInput struct {
+	Body struct {
+		OldPassword string `json:"old_password" doc:"current password"`
+		NewPassword string `json:"new_password" doc:"new password"`
+	}
+}
+
+type ResetPasswordInput struct {
+	Body struct {
+		UserId   string `json:"user_id" doc:"user uid"`
+		Password string `json:"password" doc:"new password"`
+	}
+}
+
+type GetAuditInput struct {
+	Limit     uint      `query:"limit" maximum:"500" doc:"page size, 50 by default"`
+	Cursor    string    `query:"cursor" doc:"next_cursor of the previous page"`
+	Type      string    `query:"type" enum:"login,logout,register,password.change,password.reset,user.unlock,session.revoke,grant.create,grant.revoke,app.create,app.update,app.delete,app.secret_rotate,api_key.create,api_key.revoke" doc:"only events of the type"`
+	Outcome   string    `query:"outcome" enum:"success,failure" doc:"only successful or failed actions"`
+	ActorId   string    `query:"actor_id" format:"uuid" doc:"only actions performed by the user"`
+	SubjectId string    `query:"subject_id" format:"uuid" doc:"only actions performed on the user"`
+	Ip        string    `query:"ip" doc:"only actions from the client IP"`
+	From      time.Time `query:"from" doc:"only events at or after the time"`
+	To        time.Time `query:"to" doc:"only events before the time"`
+}
+
+type GetAuditResponse struct {
+	Body struct {
+		Events     []models.AuditEvent `json:"events" doc:"audit events, newest first"`
+		NextCursor string              `json:"next_cursor,omitempty" doc:"cursor of the next page, empty on the last page"`
+	}
+}
+
+type CreateWebhookInput struct {
+	Body struct {
+		URL        string   `json:"url" format:"uri" doc:"endpoint receiving signed POST requests"`
+		AppId      *int     `json:"app_id,omitempty" doc:"events are sent about users who came through the app or consented to it"`
+		OrgId      string   `json:"org_id,omitempty" format:"uuid" doc:"events are sent about members of the organization"`
+		EventTypes []string `json:"event_types,omitempty" doc:"user.created; all by default"`
+	}
+}
+
+type CreateWebhookResponse struct {
+	Body struct {
+		Subscription models.WebhookSubscription `json:"subscription"`
+		Secret       string                     `json:"secret" doc:"HMAC key of X-Babs-Signature, shown only once"`
+	}
+}
+
+type GetWebhooksResponse struct {
+	Body struct {
+		Subscriptions []models.WebhookSubscription `json:"subscriptions"`
+	}
+}
+
+type DeleteWebhookInput struct {
+	Id string `path:"id" format:"uuid" doc:"subscription id"`
+}
+
+type GetWebhookDeliveriesInput struct {
+	Limit          uint   `query:"limit" maximum:"500" doc:"page size, 50 by default"`
+	Cursor         string `query:"cursor" doc:"next_cursor of the previous page"`
+	SubscriptionId string `query:"subscription_id" format:"uuid" doc:"only deliveries to the subscription"`
+	EventId        string `query:"event_id" format:"uuid" doc:"only deliveries of the event"`
+	Status         string `query:"status" enum:"pending,delivered,dead" doc:"only deliveries in the status"`
+}
+
+type GetWebhookDeliveriesResponse struct {
+	Body struct {
+		Deliveries []models.WebhookDelivery `json:"deliveries" doc:"delivery log, newest first"`
+		NextCursor string                   `json:"next_cursor,omitempty" doc:"cursor of the next page, empty on the last page"`
+	}
+}
+
+type RedeliverWebhookInput struct {
+	Id string `path:"id" format:"uuid" doc:"delivery id"`
+}
+
+type RedeliverWebhookResponse struct {
+	Body struct {
+		Delivery models.WebhookDelivery `json:"delivery"`
+	}
+}
+
+// SessionView is a session as shown to the user it belongs to
+type SessionView struct {
+	models.Session
+	Current bool `json:"current" doc:"the session of the token used for the request"`
+	Browser bool `json:"browser" doc:"the session is kept in a browser cookie"`
+}
+
+type GetSessionsResponse struct {
+	Body struct {
+		Sessions []SessionView `json:"sessions" doc:"active sessions, most recently seen first"`
+	}
+}
+
+type RevokeSessionInput struct {
+	Id string `path:"id" format:"uuid" doc:"session id"`
+}
+
+type GetUserSessionsInput struct {
+	UserId string `path:"userId" format:"uuid" doc:"user id"`
+}
+
+type RevokeUserSessionInput struct {
+	UserId string `path:"userId" format:"uuid" doc:"user id"`
+	Id     string `path:"id" format:"uuid" doc:"session id"`
+}
+
+type GetScopesResponse struct {
+	Body struct {
+		Scopes []authz.Scope `json:"scopes" doc:"known scopes with descriptions shown on the consent page"`
+	}
+}
+
+// AppBody is an app as set by admins
+type AppBody struct {
+	Name            string   `json:"name" minLength:"1" maxLength:"100" doc:"unique name of the app"`
+	Type            string   `json:"type,omitempty" enum:"confidential,public" doc:"public apps cannot keep a secret; confidential by default"`
+	FirstParty      bool     `json:"first_party,omitempty" doc:"users are not asked to consent to first-party apps"`
+	RedirectURIs    []string `json:"redirect_uris,omitempty" doc:"where users are sent back after sign-in"`
+	GrantTypes      []string `json:"grant_types,omitempty" doc:"password, authorization_code, refresh_token; password by default"`
+	Scopes          []string `json:"scopes,omitempty" doc:"scopes tokens of the app may carry"`
+	TokenTTL        int      `json:"token_ttl,omitempty" minimum:"0" doc:"lifetime of tokens of the app in seconds, 0 for the default"`
+	DisplayName     string   `json:"display_name,omitempty" doc:"name shown on hosted pages"`
+	LogoURL         string   `json:"logo_url,omitempty" doc:"https url of the logo shown on hosted pages"`
+	PrimaryColor    string   `json:"primary_color,omitempty" doc:"hex color of hosted pages"`
+	BackgroundColor string   `json:"background_color,omitempty" doc:"hex color of hosted pages"`
+}
+
+func (b AppBody) app(id int) models.App {
+	return models.App{
+		ID:              id,
+		Name:            b.Name,
+		Type:            b.Type,
+		FirstParty:      b.FirstParty,
+		RedirectURIs:    b.RedirectURIs,
+		GrantTypes:      b.GrantTypes,
+		Scopes:          b.Scopes,
+		TokenTTL:        time.Duration(b.TokenTTL) * time.Second,
+		DisplayName:     b.DisplayName,
+		LogoURL:         b.LogoURL,
+		PrimaryColor:    b.PrimaryColor,
+		BackgroundColor: b.BackgroundColor,
+	}
+}
+
+// AppView is an app as shown to admins, without its secret
+type AppView struct {
+	Id int `json:"id"`
+	AppBody
+}
+
+func newAppView(app models.App) AppView {
+	return AppView{
+		Id: app.ID,
+		AppBody: AppBody{
+			Name:            app.Name,
+			Type:            app.Type,
+			FirstParty:      app.FirstParty,
+			RedirectURIs:    app.RedirectURIs,
+			GrantTypes:      app.GrantTypes,
+			Scopes:          app.Scopes,
+			TokenTTL:        int(app.TokenTTL / time.Second),
+			DisplayName:     app.DisplayName,
+			LogoURL:         app.LogoURL,
+			PrimaryColor:    app.PrimaryColor,
+			BackgroundColor: app.BackgroundColor,
+		},
+	}
+}
+
+type CreateAppInput struct {
+	Body AppBody
+}
+
+type CreateAppResponse struct {
+	Body struct {
+		App    AppView `json:"app"`
+		Secret string  `json:"secret,omitempty" doc:"secret of a confidential app, shown only once"`
+	}
+}
+
+type GetAppsResponse struct {
+	Body struct {
+		Apps []AppView `json:"apps"`
+	}
+}
+
+type AppInput struct {
+	AppId int `path:"appId" minimum:"1" doc:"app id"`
+}
+
+type UpdateAppInput struct {
+	AppId int `path:"appId" minimum:"1" doc:"app id"`
+	Body  AppBody
+}
+
+type AppResponse struct {
+	Body struct {
+		App AppView `json:"app"`
+	}
+}
+
+type RotateAppSecretResponse struct {
+	Body struct {
+		Secret string `json:"secret" doc:"new secret of the app, shown only once"`
+	}
+}
+
+// GrantView is a consent of the user with the name of the app
+type GrantView struct {
+	models.Grant
+	AppName string `json:"app_name" doc:"display name of the app"`
+}
+
+type GetGrantsResponse struct {
+	Body struct {
+		Grants []GrantView `json:"grants" doc:"apps the user consented to"`
+	}
+}
+
+type RevokeGrantInput struct {
+	AppId int `path:"appId" minimum:"1" doc:"app id"`
+}
+
+type GetBrowserSessionInput struct {
+	SessionCookie string `cookie:"__Host-babs_session"`
+	CSRFCookie    string `cookie:"__Host-babs_csrf"`
+}
+
+type GetBrowserSessionResponse struct {
+	SetCookie []http.Cookie `header:"Set-Cookie"`
+	Body      struct {
+		Authenticated bool            `json:"authenticated" doc:"whether the browser is signed in"`
+		Session       *models.Session `json:"session,omitempty" doc:"session of the signed in user"`
+		CSRFToken     string          `json:"csrf_token" doc:"token to send in X-CSRF-Token header or csrf_token field of posts"`
+	}
+}
+
+type BrowserLoginInput struct {
+	SessionCookie string `cookie:"__Host-babs_session"`
+	CSRFCookie    string `cookie:"__Host-babs_csrf"`
+	CSRFHeader    string `header:"X-CSRF-Token"`
+	Body          struct {
+		Email     string `json:"email" minLength:"1" doc:"user email"`
+		Password  string `json:"password" minLength:"1" doc:"user password"`
+		AppId     int    `json:"app_id,omitempty" minimum:"0" doc:"app the user signs in to, recorded with the session"`
+		CSRFToken string `json:"csrf_token,omitempty" doc:"CSRF token, if not sent in X-CSRF-Token header"`
+	}
+}
+
+type BrowserLoginResponse struct {
+	SetCookie []http.Cookie `header:"Set-Cookie"`
+	Body      struct {
+		Session   models.Session `json:"session"`
+		CSRFToken string         `json:"csrf_token" doc:"new CSRF token, the previous one is no longer valid"`
+	}
+}
+
+type BrowserLogoutInput struct {
+	SessionCookie string `cookie:"__Host-babs_session"`
+	CSRFCookie    string `cookie:"__Host-babs_csrf"`
+	CSRFHeader    string `header:"X-CSRF-Token"`
+	Body          *struct {
+		CSRFToken string `json:"csrf_token,omitempty" doc:"CSRF token, if not sent in X-CSRF-Token header"`
+	}
+}
+
+type BrowserLogoutResponse struct {
+	SetCookie []http.Cookie `header:"Set-Cookie"`
+}
+
+type GetAPIKeysResponse struct {
+	Body struct {
+		APIKeys []models.APIKey `json:"api_keys" doc:"keys that are not revoked, newest first"`
+	}
+}
+
+type CreateAPIKeyInput struct {
+	Body struct {
+		Name      string   `json:"name" maxLength:"100" doc:"what the key is used for"`
+		Scopes    []string `json:"scopes,omitempty" doc:"scopes of the key, see /scopes"`
+		ExpiresIn int64    `json:"expires_in,omitempty" minimum:"0" doc:"lifetime in seconds, 90 days by default and at most 366 days"`
+		Org       bool     `json:"org,omitempty" doc:"the key belongs to the organization of the user"`
+	}
+}
+
+type CreateAPIKeyResponse struct {
+	Body struct {
+		APIKey models.APIKey `json:"api_key"`
+		Key    string        `json:"key" doc:"the API key, shown only once; send it as a bearer token"`
+	}
+}
+
+type RevokeAPIKeyInput struct {
+	Id string `path:"id" format:"uuid" doc:"api key id"`
+}
+
+type CheckQuotaInput struct {
+	Authorization string `header:"Authorization" doc:"Basic credentials of the calling app: app id and secret"`
+	Body          struct {
+		Token string `json:"token" minLength:"1" doc:"access token or API key the map service received"`
+		Cost  int64  `json:"cost,omitempty" minimum:"0" maximum:"1000" doc:"requests to charge, 1 by default"`
+	}
+}
+
+type CheckQuotaResponse struct {
+	Body struct {
+		Active   bool                `json:"active" doc:"the token is valid; other fields are set only for active tokens"`
+		Allowed  bool                `json:"allowed" doc:"the request fits quotas of the token and was charged"`
+		UserId   *uuid.UUID          `json:"user_id,omitempty"`
+		AppId    int                 `json:"app_id,omitempty"`
+		OrgId    *uuid.UUID          `json:"org_id,omitempty"`
+		APIKeyId *uuid.UUID          `json:"api_key_id,omitempty"`
+		Scope    string              `json:"scope,omitempty" doc:"space-separated scopes of the token"`
+		Budget   *models.QuotaBudget `json:"budget,omitempty" doc:"the tightest budget, missing if the token has no quotas"`
+	}
+}
+
+type GetQuotasResponse struct {
+	Body struct {
+		Quotas []models.Quota `json:"quotas"`
+	}
+}
+
+type SetQuotaInput struct {
+	SubjectType string `path:"subjectType" enum:"app,org,api_key"`
+	SubjectId   string `path:"subjectId" doc:"app id, organization id or api key id"`
+	Body        struct {
+		PerMinute int64 `json:"per_minute,omitempty" minimum:"0" doc:"requests per minute, 0 for no limit"`
+		PerDay    int64 `json:"per_day,omitempty" minimum:"0" doc:"requests per UTC day, 0 for no limit"`
+		PerMonth  int64 `json:"per_month,omitempty" minimum:"0" doc:"requests per UTC calendar month, 0 for no limit"`
+	}
+}
+
+type SetQuotaResponse struct {
+	Body struct {
+		Quota models.Quota `json:"quota"`
+	}
+}
+
+type DeleteQuotaInput struct {
+	SubjectType string `path:"subjectType" enum:"app,org,api_key"`
+	SubjectId   string `path:"subjectId" doc:"app id, organization id or api key id"`
+}
+
+type GetUsageInput struct {
+	Period      string    `query:"period" enum:"day,month" default:"day"`
+	SubjectType string    `query:"subject_type" enum:"app,org,api_key" doc:"report one subject only"`
+	SubjectId   string    `query:"subject_id"`
+	From        time.Time `query:"from" doc:"periods starting at or after"`
+	To          time.Time `query:"to" doc:"periods starting before"`
+}
+
+type GetUsageResponse struct {
+	Body struct {
+		Usage []models.QuotaUsage `json:"usage" doc:"ordered by period start, then subject"`
 	}
 }
@@ -0,0 +1,119 @@
+package rest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/lib/hasher"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/slogdiscard"
+	"github.com/babs-corp/babs-maps-auth/internal/rest"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret"
+
+type testServer struct {
+	*httptest.Server
+	auth *auth.Auth
+	st   *memory.Storage
+}
+
+// newTestServer serves the API over TLS, as __Host- cookies require,
+// backed by memory storage
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	st := memory.New()
+	log := slog.New(slogdiscard.NewDiscardHandler())
+	a := auth.New(log, st, st, time.Hour, testSecret,
+		auth.WithPasswordHasher(hasher.New(hasher.Bcrypt{Cost: 4})),
+		auth.WithTxManager(st),
+		auth.WithAppStore(st),
+		auth.WithGrants(st),
+		auth.WithAPIKeys(st),
+		auth.WithSessions(st),
+		auth.WithBrowserSessions(auth.BrowserPolicy{IdleTimeout: time.Hour, AbsoluteTimeout: 12 * time.Hour}),
+		auth.WithAuditLog(st),
+	)
+
+	router := chi.NewRouter()
+	rest.InitRoutes(router, log, a, nil, nil, nil)
+	srv := httptest.NewTLSServer(router)
+	t.Cleanup(srv.Close)
+
+	return &testServer{Server: srv, auth: a, st: st}
+}
+
+// register creates a user and returns its id, email and password
+func (s *testServer) register(t *testing.T) (uuid.UUID, string, string) {
+	t.Helper()
+
+	email, pass := gofakeit.Email(), gofakeit.Password(true, true, true, true, false, 12)
+	id, err := s.auth.RegisterNewUser(context.Background(), email, pass)
+	require.NoError(t, err)
+
+	return id, email, pass
+}
+
+// login returns a token of a new user, an admin if admin is set
+func (s *testServer) login(t *testing.T, admin bool) string {
+	t.Helper()
+
+	id, email, pass := s.register(t)
+	if admin {
+		require.NoError(t, s.st.SetAdmin(context.Background(), id, true))
+	}
+	token, err := s.auth.Login(context.Background(), email, pass)
+	require.NoError(t, err)
+
+	return token
+}
+
+// do sends req and returns the response with its body read
+func (s *testServer) do(t *testing.T, req *http.Request) (*http.Response, []byte) {
+	t.Helper()
+
+	resp, err := s.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, body
+}
+
+// request returns a request to the server with body encoded as JSON and
+// token as the bearer token, if they are set
+func (s *testServer) request(t *testing.T, method, path, token string, body any) *http.Request {
+	t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		require.NoError(t, err)
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, s.URL+path, r)
+	require.NoError(t, err)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req
+}
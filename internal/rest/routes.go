@@ -2,10 +2,14 @@ package rest
 
 import (
 	"context"
-	"fmt"
+	"log/slog"
 	"net/http"
+	"net/netip"
 
 	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	authsvc "github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/adapters/humachi"
 	"github.com/go-chi/chi/middleware"
@@ -18,30 +22,84 @@ type Auth interface {
 		email string,
 		password string,
 	) (token string, err error)
+	LoginWithScopes(ctx context.Context,
+		email string,
+		password string,
+		scopes []string,
+	) (token string, granted []string, err error)
 	RegisterNewUser(ctx context.Context,
 		email string,
 		password string,
 	) (userId uuid.UUID, err error)
 	IsAdmin(ctx context.Context, userId uuid.UUID) (bool, error)
 	UserById(ctx context.Context, userId uuid.UUID) (models.User, error)
-	Users(ctx context.Context, limit uint) ([]models.User, error)
+	Users(ctx context.Context, query models.UserQuery, cursor string) (models.UserPage, error)
 	ValidateToken(ctx context.Context, token string) (uuid.UUID, error)
+	Principal(ctx context.Context, token string) (models.Principal, error)
+	UnlockUser(ctx context.Context, email string) error
+	ChangePassword(ctx context.Context, userId uuid.UUID, oldPassword string, newPassword string) error
+	ResetPassword(ctx context.Context, userId uuid.UUID, newPassword string) error
+	AuditEvents(ctx context.Context, query models.AuditQuery, cursor string) (models.AuditPage, error)
+	Sessions(ctx context.Context, userId uuid.UUID) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error
+	BrowserLogin(ctx context.Context, email string, password string) (models.Session, string, error)
+	BrowserSession(ctx context.Context, cookie string) (models.Session, error)
+	BrowserLogout(ctx context.Context, cookie string) error
+	Branding(ctx context.Context, appId int) (models.Branding, error)
+	ConsentScopes(ctx context.Context, appId int, scope string) ([]string, error)
+	ConsentRequired(ctx context.Context, userId uuid.UUID, appId int, scopes []string) (bool, error)
+	GrantConsent(ctx context.Context, userId uuid.UUID, appId int, scopes []string) error
+	Grants(ctx context.Context, userId uuid.UUID) ([]models.Grant, error)
+	RevokeGrant(ctx context.Context, userId uuid.UUID, appId int) error
+	App(ctx context.Context, appId int) (models.App, error)
+	Apps(ctx context.Context) ([]models.App, error)
+	CreateApp(ctx context.Context, app models.App) (models.App, string, error)
+	RegisterApp(ctx context.Context, initialAccessToken string, app models.App) (models.App, string, error)
+	UpdateApp(ctx context.Context, app models.App) (models.App, error)
+	RotateAppSecret(ctx context.Context, appId int) (string, error)
+	DeleteApp(ctx context.Context, appId int) error
+	CreateAPIKey(ctx context.Context, userId uuid.UUID, key authsvc.NewAPIKey) (models.APIKey, string, error)
+	APIKeys(ctx context.Context, userId uuid.UUID) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userId uuid.UUID, keyId uuid.UUID) error
+	AuthenticateApp(ctx context.Context, appId int, secret string) (models.App, error)
 }
 
 const (
-	PostRegisterURL = "/register"
-	PostLoginURL    = "/login"
-	GetIsAdminURL   = "/isAdmin"
-	GetUserURL      = "/user/{userId}"
-	GetUsersURL     = "/users"
-	GetValidatedUsersURL     = "/user"
+	PostRegisterURL       = "/register"
+	PostLoginURL          = "/login"
+	GetIsAdminURL         = "/isAdmin"
+	GetUserURL            = "/user/{userId}"
+	GetUsersURL           = "/users"
+	GetValidatedUsersURL  = "/user"
+	PostUnlockUserURL     = "/admin/unlock"
+	PostChangePasswordURL = "/password"
+	PostResetPasswordURL  = "/admin/password"
+	GetAuditURL           = "/audit"
 )
 
-func InitRoutes(router *chi.Mux, auth Auth) {
+// InitRoutes registers API operations, hosted pages and dynamic app
+// registration. Webhook and quota operations are registered only if
+// webhooks and quotas are not nil. Client IPs are taken from proxy
+// headers only if the request comes from one of trustedProxies.
+func InitRoutes(
+	router *chi.Mux,
+	log *slog.Logger,
+	auth Auth,
+	webhooks Webhooks,
+	quotas Quotas,
+	trustedProxies []netip.Prefix,
+) {
 
+	huma.NewError = newErrorFunc(log)
+
+	router.Use(clientinfo.Middleware(trustedProxies))
 	router.Use(middleware.Logger)
 
-	api := humachi.New(router, huma.DefaultConfig("My API", "1.0.0"))
+	config := huma.DefaultConfig("My API", "1.0.0")
+	addSecurityScheme(&config)
+
+	api := humachi.New(router, config)
+	api.UseMiddleware(authMiddleware(api, auth))
 
 	huma.Register(api, huma.Operation{
 		OperationID:   "register-user",
@@ -50,10 +108,15 @@ func InitRoutes(router *chi.Mux, auth Auth) {
 		Summary:       "Register new user",
 		Tags:          []string{"auth"},
 		DefaultStatus: http.StatusCreated,
+		Security:      public(),
 	}, func(ctx context.Context, input *RegisterInput) (*RegisterResponse, error) {
-		id, err := auth.RegisterNewUser(context.Background(), input.Body.Email, input.Body.Password)
+		info := clientinfo.FromContext(ctx)
+		info.AppID = input.Body.AppId
+		ctx = clientinfo.WithInfo(ctx, info)
+
+		id, err := auth.RegisterNewUser(ctx, input.Body.Email, input.Body.Password)
 		if err != nil {
-			return nil, fmt.Errorf("cannot create user: %w", err)
+			return nil, mapError(err)
 		}
 		resp := RegisterResponse{}
 		resp.Body.Id = id
@@ -67,13 +130,24 @@ func InitRoutes(router *chi.Mux, auth Auth) {
 		Summary:       "Login user",
 		Tags:          []string{"auth"},
 		DefaultStatus: http.StatusOK,
+		Security:      public(),
 	}, func(ctx context.Context, input *LoginInput) (*LoginResponse, error) {
-		token, err := auth.Login(context.Background(), input.Body.Email, input.Body.Password)
+		info := clientinfo.FromContext(ctx)
+		info.AppID = input.Body.AppId
+		ctx = clientinfo.WithInfo(ctx, info)
+
+		scopes, err := authsvc.ParseScopes(input.Body.Scope)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		token, granted, err := auth.LoginWithScopes(ctx, input.Body.Email, input.Body.Password, scopes)
 		if err != nil {
-			return nil, fmt.Errorf("cannot login user: %w", err)
+			return nil, mapError(err)
 		}
 		resp := LoginResponse{}
 		resp.Body.Token = token
+		resp.Body.Scope = authz.FormatScope(granted)
 		return &resp, nil
 	})
 
@@ -84,21 +158,23 @@ func InitRoutes(router *chi.Mux, auth Auth) {
 		Summary:       "Get user by uuid",
 		Tags:          []string{"users"},
 		DefaultStatus: http.StatusOK,
+		Security:      authenticated(),
 	}, func(ctx context.Context, input *GetUserInput) (*GetUserResponse, error) {
 		rawUserID := input.Uid
 
 		userID, err := uuid.Parse(rawUserID)
 		if err != nil {
-			return nil, fmt.Errorf("cannot parse user id: %w", err)
+			return nil, errBadRequest("invalid user id")
 		}
 
 		user, err := auth.UserById(ctx, userID)
 		if err != nil {
-			return nil, fmt.Errorf("cannot get user: %w", err)
+			return nil, mapError(err)
 		}
 
+		principal, _ := PrincipalFromContext(ctx)
 		resp := GetUserResponse{}
-		resp.Body.User = user
+		resp.Body.User = models.UserViewFor(principal, user)
 		return &resp, nil
 	})
 
@@ -109,17 +185,23 @@ func InitRoutes(router *chi.Mux, auth Auth) {
 		Summary:       "Get users",
 		Tags:          []string{"users"},
 		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
 	}, func(ctx context.Context, input *GetUsersInput) (*GetUsersResponse, error) {
-		limit := input.Limit
-		if limit == 0 {
-			limit = 10
+		query, err := input.query()
+		if err != nil {
+			return nil, err
 		}
-		users, err := auth.Users(ctx, limit)
+
+		page, err := auth.Users(ctx, query, input.Cursor)
 		if err != nil {
-			return nil, fmt.Errorf("cannot get users: %w", err)
+			return nil, mapError(err)
 		}
+
+		principal, _ := PrincipalFromContext(ctx)
 		resp := GetUsersResponse{}
-		resp.Body.Users = users
+		resp.Body.Users = models.UserViewsFor(principal, page.Users)
+		resp.Body.NextCursor = page.NextCursor
+		resp.Body.Total = page.Total
 		return &resp, nil
 	})
 
@@ -130,18 +212,139 @@ func InitRoutes(router *chi.Mux, auth Auth) {
 		Summary:       "Get validated user by token",
 		Tags:          []string{"users"},
 		DefaultStatus: http.StatusOK,
+		Security:      optionalAuth(),
 	}, func(ctx context.Context, input *GetUserByTokenInput) (*GetUserByTokenResponse, error) {
-		token := input.Body.Token
-		uid, err := auth.ValidateToken(ctx, token)
+		var bodyToken string
+		if input.Body != nil {
+			bodyToken = input.Body.Token
+		}
+		uid, err := tokenOwner(ctx, auth, bodyToken)
 		if err != nil {
-			return nil, fmt.Errorf("invalid token: %w", err)
+			return nil, err
 		}
 		user, err := auth.UserById(ctx, uid)
 		if err != nil {
-			return nil, fmt.Errorf("cannot get user: %w", err)
+			return nil, mapError(err)
 		}
 		resp := GetUserByTokenResponse{}
-		resp.Body.User = user
+		resp.Body.User = models.SelfUserView(user)
 		return &resp, nil
 	})
-}
\ No newline at end of file
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "unlock-user",
+		Method:        http.MethodPost,
+		Path:          PostUnlockUserURL,
+		Summary:       "Unlock user account locked after failed logins",
+		Tags:          []string{"admin"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *UnlockUserInput) (*struct{}, error) {
+		if err := auth.UnlockUser(ctx, input.Body.Email); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "change-password",
+		Method:        http.MethodPost,
+		Path:          PostChangePasswordURL,
+		Summary:       "Change password of the token owner",
+		Tags:          []string{"auth"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      authenticated(),
+	}, func(ctx context.Context, input *ChangePasswordInput) (*struct{}, error) {
+		principal, _ := PrincipalFromContext(ctx)
+
+		err := auth.ChangePassword(ctx, principal.UserID, input.Body.OldPassword, input.Body.NewPassword)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "reset-password",
+		Method:        http.MethodPost,
+		Path:          PostResetPasswordURL,
+		Summary:       "Set new password of any user",
+		Tags:          []string{"admin"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *ResetPasswordInput) (*struct{}, error) {
+		userID, err := uuid.Parse(input.Body.UserId)
+		if err != nil {
+			return nil, errBadRequest("invalid user id")
+		}
+
+		if err := auth.ResetPassword(ctx, userID, input.Body.Password); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-audit",
+		Method:        http.MethodGet,
+		Path:          GetAuditURL,
+		Summary:       "Get audit log of security-relevant events",
+		Tags:          []string{"admin"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *GetAuditInput) (*GetAuditResponse, error) {
+		query, err := input.query()
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := auth.AuditEvents(ctx, query, input.Cursor)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := GetAuditResponse{}
+		resp.Body.Events = page.Events
+		if resp.Body.Events == nil {
+			resp.Body.Events = []models.AuditEvent{}
+		}
+		resp.Body.NextCursor = page.NextCursor
+		return &resp, nil
+	})
+
+	registerSessionRoutes(api, auth)
+	registerBrowserRoutes(api, auth)
+	registerGrantRoutes(api, auth)
+	registerAPIKeyRoutes(api, auth)
+	registerAppRoutes(api, auth)
+
+	if webhooks != nil {
+		registerWebhookRoutes(api, webhooks)
+	}
+	if quotas != nil {
+		registerQuotaRoutes(api, auth, quotas)
+	}
+
+	registerPages(router, log, auth)
+	registerAppRegistration(router, log, auth)
+	registerDebugRoutes(router, log, auth)
+}
+
+// tokenOwner returns id of the authenticated caller. Deprecated clients
+// which pass token in request body instead of Authorization header are
+// still supported.
+func tokenOwner(ctx context.Context, auth Auth, bodyToken string) (uuid.UUID, error) {
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return principal.UserID, nil
+	}
+	if bodyToken == "" {
+		return uuid.UUID{}, problem(http.StatusUnauthorized, ProblemUnauthorized, "bearer token required")
+	}
+
+	uid, err := auth.ValidateToken(ctx, bodyToken)
+	if err != nil {
+		return uuid.UUID{}, mapError(err)
+	}
+
+	return uid, nil
+}
@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	GetSessionsURL       = "/me/sessions"
+	DeleteSessionURL     = "/me/sessions/{id}"
+	GetUserSessionsURL   = "/admin/users/{userId}/sessions"
+	DeleteUserSessionURL = "/admin/users/{userId}/sessions/{id}"
+)
+
+func registerSessionRoutes(api huma.API, auth Auth) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-sessions",
+		Method:        http.MethodGet,
+		Path:          GetSessionsURL,
+		Summary:       "Get active sessions of the token owner",
+		Tags:          []string{"sessions"},
+		DefaultStatus: http.StatusOK,
+		Security:      authenticated(),
+	}, func(ctx context.Context, input *struct{}) (*GetSessionsResponse, error) {
+		principal, _ := PrincipalFromContext(ctx)
+
+		return sessionsResponse(ctx, auth, principal.UserID, principal.SessionID)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "revoke-session",
+		Method:        http.MethodDelete,
+		Path:          DeleteSessionURL,
+		Summary:       "Sign the token owner out of a session",
+		Tags:          []string{"sessions"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      authenticated(),
+	}, func(ctx context.Context, input *RevokeSessionInput) (*struct{}, error) {
+		principal, _ := PrincipalFromContext(ctx)
+
+		sessionID, err := uuid.Parse(input.Id)
+		if err != nil {
+			return nil, errBadRequest("invalid session id")
+		}
+
+		if err := auth.RevokeSession(ctx, principal.UserID, sessionID); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-user-sessions",
+		Method:        http.MethodGet,
+		Path:          GetUserSessionsURL,
+		Summary:       "Get active sessions of any user",
+		Tags:          []string{"admin"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *GetUserSessionsInput) (*GetSessionsResponse, error) {
+		userID, err := uuid.Parse(input.UserId)
+		if err != nil {
+			return nil, errBadRequest("invalid user id")
+		}
+
+		principal, _ := PrincipalFromContext(ctx)
+		return sessionsResponse(ctx, auth, userID, principal.SessionID)
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "revoke-user-session",
+		Method:        http.MethodDelete,
+		Path:          DeleteUserSessionURL,
+		Summary:       "Sign any user out of a session",
+		Tags:          []string{"admin"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *RevokeUserSessionInput) (*struct{}, error) {
+		userID, err := uuid.Parse(input.UserId)
+		if err != nil {
+			return nil, errBadRequest("invalid user id")
+		}
+		sessionID, err := uuid.Parse(input.Id)
+		if err != nil {
+			return nil, errBadRequest("invalid session id")
+		}
+
+		if err := auth.RevokeSession(ctx, userID, sessionID); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+}
+
+// sessionsResponse lists sessions of userID marking the current one
+func sessionsResponse(
+	ctx context.Context,
+	auth Auth,
+	userID uuid.UUID,
+	currentID uuid.UUID,
+) (*GetSessionsResponse, error) {
+	sessions, err := auth.Sessions(ctx, userID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	resp := GetSessionsResponse{}
+	resp.Body.Sessions = make([]SessionView, 0, len(sessions))
+	for _, s := range sessions {
+		resp.Body.Sessions = append(resp.Body.Sessions, SessionView{
+			Session: s,
+			Current: currentID != uuid.Nil && s.ID == currentID,
+			Browser: s.IsBrowser(),
+		})
+	}
+	return &resp, nil
+}
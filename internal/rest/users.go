@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/google/uuid"
+)
+
+// query converts query parameters of GET /users to models.UserQuery
+func (in *GetUsersInput) query() (models.UserQuery, error) {
+	q := models.UserQuery{
+		Sort:  in.Sort,
+		Desc:  in.Order == "desc",
+		Limit: in.Limit,
+		Filter: models.UserFilter{
+			EmailPrefix:   in.EmailPrefix,
+			Role:          in.Role,
+			Verified:      optionalBool(in.Verified),
+			Disabled:      optionalBool(in.Disabled),
+			CreatedAfter:  in.CreatedAfter,
+			CreatedBefore: in.CreatedBefore,
+		},
+	}
+
+	if in.OrgId != "" {
+		orgID, err := uuid.Parse(in.OrgId)
+		if err != nil {
+			return models.UserQuery{}, errBadRequest("invalid org id")
+		}
+		q.Filter.OrgID = &orgID
+	}
+
+	return q, nil
+}
+
+func optionalBool(s string) *bool {
+	if s == "" {
+		return nil
+	}
+	b := s == "true"
+	return &b
+}
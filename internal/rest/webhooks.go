@@ -0,0 +1,172 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/google/uuid"
+)
+
+type Webhooks interface {
+	CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	Subscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+	Deliveries(ctx context.Context, query models.WebhookDeliveryQuery, cursor string) (models.WebhookDeliveryPage, error)
+	Redeliver(ctx context.Context, id uuid.UUID) (models.WebhookDelivery, error)
+}
+
+const (
+	WebhooksURL             = "/admin/webhooks"
+	WebhookURL              = "/admin/webhooks/{id}"
+	WebhookDeliveriesURL    = "/admin/webhooks/deliveries"
+	PostWebhookRedeliverURL = "/admin/webhooks/deliveries/{id}/redeliver"
+)
+
+func registerWebhookRoutes(api huma.API, webhooks Webhooks) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-webhook",
+		Method:        http.MethodPost,
+		Path:          WebhooksURL,
+		Summary:       "Subscribe an endpoint to user lifecycle events",
+		Tags:          []string{"webhooks"},
+		DefaultStatus: http.StatusCreated,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *CreateWebhookInput) (*CreateWebhookResponse, error) {
+		orgID, err := optionalUUID(input.Body.OrgId)
+		if err != nil {
+			return nil, errBadRequest("invalid org id")
+		}
+
+		sub, err := webhooks.CreateSubscription(ctx, models.WebhookSubscription{
+			URL:        input.Body.URL,
+			AppID:      input.Body.AppId,
+			OrgID:      orgID,
+			EventTypes: input.Body.EventTypes,
+		})
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := CreateWebhookResponse{}
+		resp.Body.Subscription = sub
+		resp.Body.Secret = sub.Secret
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-webhooks",
+		Method:        http.MethodGet,
+		Path:          WebhooksURL,
+		Summary:       "Get webhook subscriptions",
+		Tags:          []string{"webhooks"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *struct{}) (*GetWebhooksResponse, error) {
+		subs, err := webhooks.Subscriptions(ctx)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := GetWebhooksResponse{}
+		resp.Body.Subscriptions = subs
+		if resp.Body.Subscriptions == nil {
+			resp.Body.Subscriptions = []models.WebhookSubscription{}
+		}
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "delete-webhook",
+		Method:        http.MethodDelete,
+		Path:          WebhookURL,
+		Summary:       "Delete webhook subscription and its delivery log",
+		Tags:          []string{"webhooks"},
+		DefaultStatus: http.StatusNoContent,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *DeleteWebhookInput) (*struct{}, error) {
+		id, err := uuid.Parse(input.Id)
+		if err != nil {
+			return nil, errBadRequest("invalid subscription id")
+		}
+
+		if err := webhooks.DeleteSubscription(ctx, id); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "get-webhook-deliveries",
+		Method:        http.MethodGet,
+		Path:          WebhookDeliveriesURL,
+		Summary:       "Get webhook delivery log",
+		Tags:          []string{"webhooks"},
+		DefaultStatus: http.StatusOK,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *GetWebhookDeliveriesInput) (*GetWebhookDeliveriesResponse, error) {
+		query, err := input.query()
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := webhooks.Deliveries(ctx, query, input.Cursor)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := GetWebhookDeliveriesResponse{}
+		resp.Body.Deliveries = page.Deliveries
+		if resp.Body.Deliveries == nil {
+			resp.Body.Deliveries = []models.WebhookDelivery{}
+		}
+		resp.Body.NextCursor = page.NextCursor
+		return &resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "redeliver-webhook",
+		Method:        http.MethodPost,
+		Path:          PostWebhookRedeliverURL,
+		Summary:       "Send webhook delivery again, also when it is dead",
+		Tags:          []string{"webhooks"},
+		DefaultStatus: http.StatusAccepted,
+		Security:      adminOnly(),
+	}, func(ctx context.Context, input *RedeliverWebhookInput) (*RedeliverWebhookResponse, error) {
+		id, err := uuid.Parse(input.Id)
+		if err != nil {
+			return nil, errBadRequest("invalid delivery id")
+		}
+
+		delivery, err := webhooks.Redeliver(ctx, id)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		resp := RedeliverWebhookResponse{}
+		resp.Body.Delivery = delivery
+		return &resp, nil
+	})
+}
+
+// query converts query parameters of GET /admin/webhooks/deliveries to
+// models.WebhookDeliveryQuery
+func (in *GetWebhookDeliveriesInput) query() (models.WebhookDeliveryQuery, error) {
+	q := models.WebhookDeliveryQuery{
+		Limit: in.Limit,
+		Filter: models.WebhookDeliveryFilter{
+			Status: in.Status,
+		},
+	}
+
+	var err error
+	if q.Filter.SubscriptionID, err = optionalUUID(in.SubscriptionId); err != nil {
+		return models.WebhookDeliveryQuery{}, errBadRequest("invalid subscription id")
+	}
+	if q.Filter.EventID, err = optionalUUID(in.EventId); err != nil {
+		return models.WebhookDeliveryQuery{}, errBadRequest("invalid event id")
+	}
+
+	return q, nil
+}
@@ -0,0 +1,357 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key, so that keys are told apart from
+// tokens wherever either is accepted
+const APIKeyPrefix = "bmk_"
+
+const (
+	// DefaultAPIKeyTTL is the lifetime of keys created without one
+	DefaultAPIKeyTTL = 90 * 24 * time.Hour
+	// MaxAPIKeyTTL limits the lifetime of keys, so leaked ones stop working
+	MaxAPIKeyTTL = 366 * 24 * time.Hour
+
+	apiKeyIDBytes     = 6
+	apiKeySecretBytes = 32
+	maxAPIKeyNameLen  = 100
+	// apiKeyTouchInterval limits how often last used time of a key is saved
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+// APIKeyValidationError tells which field of a new key is invalid and
+// why. Field is named as in the API. It matches ErrInvalidAPIKey.
+type APIKeyValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *APIKeyValidationError) Error() string {
+	return ErrInvalidAPIKey.Error() + ": " + e.Field + ": " + e.Reason
+}
+
+func (e *APIKeyValidationError) Unwrap() error {
+	return ErrInvalidAPIKey
+}
+
+// APIKeyStore keeps API keys
+type APIKeyStore interface {
+	// SaveAPIKey fails with storage.ErrAPIKeyExists if the prefix is taken
+	SaveAPIKey(ctx context.Context, key models.APIKey) error
+	APIKey(ctx context.Context, id uuid.UUID) (models.APIKey, error)
+	APIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error)
+	// APIKeys returns keys that are not revoked of the user and of the
+	// organization orgID, newest first. Nil orgID matches no organization.
+	APIKeys(ctx context.Context, userID uuid.UUID, orgID *uuid.UUID) ([]models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+	// RevokeAPIKey keeps the time of the first revocation if the key is
+	// already revoked
+	RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// WithAPIKeys lets users create API keys and makes Principal and
+// ValidateToken accept them
+func WithAPIKeys(store APIKeyStore) Option {
+	return func(a *Auth) {
+		a.apiKeys = store
+	}
+}
+
+// NewAPIKey describes a key to create
+type NewAPIKey struct {
+	Name   string
+	Scopes []string
+	// TTL is DefaultAPIKeyTTL if zero
+	TTL time.Duration
+	// Org makes the key owned by the organization of the user
+	Org bool
+}
+
+// CreateAPIKey creates a key of the user or of their organization. The
+// key is returned only here; later only its prefix is shown.
+func (a *Auth) CreateAPIKey(
+	ctx context.Context,
+	userID uuid.UUID,
+	req NewAPIKey,
+) (_ models.APIKey, secret string, err error) {
+	const op = "auth.CreateAPIKey"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+	)
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditAPIKeyCreate, err)
+		event.SubjectID = &userID
+		a.audit(ctx, event)
+	}()
+
+	if a.apiKeys == nil {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrInvalidAPIKey)
+	}
+
+	key, err := a.newAPIKey(ctx, userID, req)
+	if err != nil {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	id := make([]byte, apiKeyIDBytes)
+	raw := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(id); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("%s: generate key: %w", op, err)
+	}
+	if _, err := rand.Read(raw); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("%s: generate key: %w", op, err)
+	}
+	key.Prefix = APIKeyPrefix + hex.EncodeToString(id)
+	secret = key.Prefix + "_" + base64.RawURLEncoding.EncodeToString(raw)
+	key.Hash = hashAPIKey(secret)
+
+	if err := a.apiKeys.SaveAPIKey(ctx, key); err != nil {
+		log.Error("failed to save api key", sl.Err(err))
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key created", slog.String("prefix", key.Prefix))
+
+	return key, secret, nil
+}
+
+// newAPIKey validates req and returns the key it describes without the
+// secret
+func (a *Auth) newAPIKey(ctx context.Context, userID uuid.UUID, req NewAPIKey) (models.APIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	switch {
+	case name == "":
+		return models.APIKey{}, &APIKeyValidationError{Field: "name", Reason: "must not be empty"}
+	case utf8.RuneCountInString(name) > maxAPIKeyNameLen:
+		return models.APIKey{}, &APIKeyValidationError{
+			Field:  "name",
+			Reason: fmt.Sprintf("must be at most %d characters", maxAPIKeyNameLen),
+		}
+	}
+
+	for _, s := range req.Scopes {
+		if _, ok := authz.Lookup(s); !ok {
+			return models.APIKey{}, &APIKeyValidationError{Field: "scopes", Reason: fmt.Sprintf("unknown scope %q", s)}
+		}
+	}
+
+	ttl := req.TTL
+	switch {
+	case ttl == 0:
+		ttl = DefaultAPIKeyTTL
+	case ttl < 0 || ttl > MaxAPIKeyTTL:
+		return models.APIKey{}, &APIKeyValidationError{
+			Field:  "expires_in",
+			Reason: fmt.Sprintf("must be positive and at most %s", MaxAPIKeyTTL),
+		}
+	}
+
+	now := time.Now().UTC()
+	key := models.APIKey{
+		ID:        uuid.New(),
+		Name:      name,
+		UserID:    userID,
+		Scopes:    sortedSet(req.Scopes),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if req.Org {
+		user, err := a.UserById(ctx, userID)
+		if err != nil {
+			return models.APIKey{}, err
+		}
+		if user.OrgID == nil {
+			return models.APIKey{}, &APIKeyValidationError{Field: "org", Reason: "the user belongs to no organization"}
+		}
+		key.OrgID = user.OrgID
+	}
+
+	return key, nil
+}
+
+// APIKeys returns keys that are not revoked of the user and of their
+// organization, newest first
+func (a *Auth) APIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	const op = "auth.APIKeys"
+
+	if a.apiKeys == nil {
+		return nil, nil
+	}
+
+	user, err := a.UserById(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := a.apiKeys.APIKeys(ctx, userID, user.OrgID)
+	if err != nil {
+		a.log.Error("failed to list api keys", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes key keyID of the user or of their organization.
+// Requests with the key are rejected from now on; keys of others are not
+// found.
+func (a *Auth) RevokeAPIKey(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) (err error) {
+	const op = "auth.RevokeAPIKey"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+		slog.String("api_key_id", keyID.String()),
+	)
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditAPIKeyRevoke, err)
+		event.SubjectID = &userID
+		a.audit(ctx, event)
+	}()
+
+	if a.apiKeys == nil {
+		return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+	}
+
+	key, err := a.apiKeys.APIKey(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+		}
+		log.Error("failed to get api key", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if key.RevokedAt != nil {
+		return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+	}
+	if key.UserID != userID {
+		user, err := a.UserById(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if key.OrgID == nil || user.OrgID == nil || *key.OrgID != *user.OrgID {
+			return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+		}
+	}
+
+	if err := a.apiKeys.RevokeAPIKey(ctx, keyID, time.Now().UTC()); err != nil {
+		log.Error("failed to revoke api key", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key revoked")
+
+	return nil
+}
+
+// IsAPIKey reports whether token is an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// validateAPIKey returns the key secret belongs to if it is active.
+// Unknown, revoked and expired keys are ErrInvalidToken.
+func (a *Auth) validateAPIKey(ctx context.Context, secret string) (models.APIKey, error) {
+	if a.apiKeys == nil {
+		return models.APIKey{}, fmt.Errorf("api keys are disabled: %w", ErrInvalidToken)
+	}
+
+	id, _, ok := strings.Cut(strings.TrimPrefix(secret, APIKeyPrefix), "_")
+	if !ok || len(id) != 2*apiKeyIDBytes {
+		return models.APIKey{}, fmt.Errorf("malformed api key: %w", ErrInvalidToken)
+	}
+
+	key, err := a.apiKeys.APIKeyByPrefix(ctx, APIKeyPrefix+id)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			return models.APIKey{}, fmt.Errorf("api key not found: %w", ErrInvalidToken)
+		}
+		return models.APIKey{}, fmt.Errorf("get api key: %w", err)
+	}
+	if subtle.ConstantTimeCompare(key.Hash, hashAPIKey(secret)) != 1 {
+		return models.APIKey{}, fmt.Errorf("api key mismatch: %w", ErrInvalidToken)
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return models.APIKey{}, fmt.Errorf("api key is not active: %w", ErrInvalidToken)
+	}
+	a.touchAPIKey(ctx, key, now)
+
+	return key, nil
+}
+
+// touchAPIKey saves now as last used time of key unless it was saved
+// recently. Failures are only logged.
+func (a *Auth) touchAPIKey(ctx context.Context, key models.APIKey, now time.Time) {
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < apiKeyTouchInterval {
+		return
+	}
+
+	if err := a.apiKeys.TouchAPIKey(ctx, key.ID, now.UTC()); err != nil {
+		a.log.Error("failed to touch api key", slog.String("api_key_id", key.ID.String()), sl.Err(err))
+	}
+}
+
+// apiKeyPrincipal returns the caller authenticated with API key secret.
+// Keys carry their scopes only: admin permissions of the owner are not
+// granted to them.
+func (a *Auth) apiKeyPrincipal(ctx context.Context, secret string) (models.Principal, error) {
+	key, err := a.validateAPIKey(ctx, secret)
+	if err != nil {
+		return models.Principal{}, err
+	}
+
+	user, err := a.activeUser(ctx, key.UserID)
+	if err != nil {
+		return models.Principal{}, fmt.Errorf("owner of api key: %w", err)
+	}
+
+	orgID := user.OrgID
+	if key.OrgID != nil {
+		orgID = key.OrgID
+	}
+
+	return models.Principal{
+		UserID:   key.UserID,
+		APIKeyID: key.ID,
+		OrgID:    orgID,
+		Scopes:   key.Scopes,
+	}, nil
+}
+
+// hashAPIKey returns the hash of secret kept in models.APIKey
+func hashAPIKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
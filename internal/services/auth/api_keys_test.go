@@ -0,0 +1,157 @@
+package auth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+	userID, _, _ := register(t, a)
+	require.NoError(t, st.SetAdmin(ctx, userID, true))
+
+	key, secret, err := a.CreateAPIKey(ctx, userID, auth.NewAPIKey{
+		Name:   " tiles renderer ",
+		Scopes: []string{authz.TilesRender, authz.MapsRead, authz.TilesRender},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "tiles renderer", key.Name)
+	assert.Equal(t, []string{authz.MapsRead, authz.TilesRender}, key.Scopes)
+	assert.True(t, strings.HasPrefix(secret, key.Prefix+"_"))
+	assert.True(t, auth.IsAPIKey(secret))
+	assert.WithinDuration(t, time.Now().Add(auth.DefaultAPIKeyTTL), key.ExpiresAt, time.Minute)
+
+	stored, err := st.APIKey(ctx, key.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored.Hash), secret, "only the hash of the key is kept")
+
+	principal, err := a.Principal(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, models.Principal{
+		UserID:   userID,
+		APIKeyID: key.ID,
+		Scopes:   key.Scopes,
+	}, principal, "keys of admins do not get admin permissions")
+
+	owner, err := a.ValidateToken(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, userID, owner)
+
+	used, err := st.APIKey(ctx, key.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, used.LastUsedAt)
+
+	creates := allAuditEvents(t, a, models.AuditFilter{Type: models.AuditAPIKeyCreate})
+	require.Len(t, creates, 1)
+	assert.Equal(t, models.AuditSuccess, creates[0].Outcome)
+	assert.Equal(t, &userID, creates[0].SubjectID)
+}
+
+func TestCreateAPIKey_Invalid(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+	userID, _, _ := register(t, a)
+
+	tests := []struct {
+		name  string
+		key   auth.NewAPIKey
+		field string
+	}{
+		{name: "empty name", key: auth.NewAPIKey{Name: " "}, field: "name"},
+		{name: "long name", key: auth.NewAPIKey{Name: strings.Repeat("k", 101)}, field: "name"},
+		{name: "unknown scope", key: auth.NewAPIKey{Name: "k", Scopes: []string{"admin"}}, field: "scopes"},
+		{name: "negative ttl", key: auth.NewAPIKey{Name: "k", TTL: -time.Hour}, field: "expires_in"},
+		{name: "long ttl", key: auth.NewAPIKey{Name: "k", TTL: auth.MaxAPIKeyTTL + time.Hour}, field: "expires_in"},
+		{name: "no organization", key: auth.NewAPIKey{Name: "k", Org: true}, field: "org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := a.CreateAPIKey(ctx, userID, tt.key)
+			require.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+
+			var verr *auth.APIKeyValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Equal(t, tt.field, verr.Field)
+		})
+	}
+}
+
+func TestAPIKey_Rejected(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+	userID, _, _ := register(t, a)
+
+	key, secret, err := a.CreateAPIKey(ctx, userID, auth.NewAPIKey{Name: "geocoder", TTL: time.Hour})
+	require.NoError(t, err)
+
+	for _, token := range []string{
+		secret + "x",
+		key.Prefix + "_" + strings.Repeat("A", 43),
+		auth.APIKeyPrefix + "000000000000_secret",
+		auth.APIKeyPrefix + "short",
+	} {
+		_, err := a.Principal(ctx, token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, token)
+	}
+
+	require.NoError(t, st.RevokeAPIKey(ctx, key.ID, time.Now().UTC()))
+	_, err = a.ValidateToken(ctx, secret)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	_, expired, err := a.CreateAPIKey(ctx, userID, auth.NewAPIKey{Name: "expired", TTL: time.Nanosecond})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = a.Principal(ctx, expired)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	disabled := newAuthWithStorage(st)
+	_, err = disabled.Principal(ctx, secret)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestAPIKeys_Org(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+	alice, _, _ := register(t, a)
+	bob, _, _ := register(t, a)
+	eve, _, _ := register(t, a)
+	org := uuid.New()
+	require.NoError(t, st.SetOrg(ctx, alice, &org))
+	require.NoError(t, st.SetOrg(ctx, bob, &org))
+
+	shared, _, err := a.CreateAPIKey(ctx, alice, auth.NewAPIKey{Name: "shared", Org: true})
+	require.NoError(t, err)
+	require.NotNil(t, shared.OrgID)
+	assert.Equal(t, org, *shared.OrgID)
+	personal, _, err := a.CreateAPIKey(ctx, alice, auth.NewAPIKey{Name: "personal"})
+	require.NoError(t, err)
+
+	keys, err := a.APIKeys(ctx, bob)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, shared.ID, keys[0].ID)
+
+	keys, err = a.APIKeys(ctx, alice)
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	assert.ErrorIs(t, a.RevokeAPIKey(ctx, bob, personal.ID), auth.ErrAPIKeyNotFound)
+	assert.ErrorIs(t, a.RevokeAPIKey(ctx, eve, shared.ID), auth.ErrAPIKeyNotFound)
+	require.NoError(t, a.RevokeAPIKey(ctx, bob, shared.ID))
+	assert.ErrorIs(t, a.RevokeAPIKey(ctx, alice, shared.ID), auth.ErrAPIKeyNotFound)
+
+	keys, err = a.APIKeys(ctx, alice)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, personal.ID, keys[0].ID)
+}
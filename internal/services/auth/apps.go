@@ -0,0 +1,533 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+)
+
+// DefaultBranding is used for pages of unknown apps and fills values an
+// app leaves empty
+var DefaultBranding = models.Branding{
+	Name:            "Babs Maps",
+	PrimaryColor:    "#2563eb",
+	BackgroundColor: "#f4f6fb",
+}
+
+// colorRe matches CSS hex colors, the only ones allowed in branding
+var colorRe = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// WithApps lets hosted pages use branding of apps
+func WithApps(apps AppProvider) Option {
+	return func(a *Auth) {
+		a.appProvider = apps
+	}
+}
+
+// Branding returns the look of hosted pages of app. Pages of unknown apps
+// and invalid values of branding fall back to DefaultBranding, as app id
+// usually comes from a link anyone can make.
+func (a *Auth) Branding(ctx context.Context, appID int) (models.Branding, error) {
+	const op = "auth.Branding"
+
+	if a.appProvider == nil || appID == 0 {
+		return DefaultBranding, nil
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return DefaultBranding, nil
+		}
+		a.log.Error("failed to get app", slog.String("op", op), sl.Err(err))
+		return models.Branding{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return branding(app), nil
+}
+
+func branding(app models.App) models.Branding {
+	b := DefaultBranding
+	switch {
+	case app.DisplayName != "":
+		b.Name = app.DisplayName
+	case app.Name != "":
+		b.Name = app.Name
+	}
+	if validLogoURL(app.LogoURL) {
+		b.LogoURL = app.LogoURL
+	}
+	if colorRe.MatchString(app.PrimaryColor) {
+		b.PrimaryColor = app.PrimaryColor
+	}
+	if colorRe.MatchString(app.BackgroundColor) {
+		b.BackgroundColor = app.BackgroundColor
+	}
+
+	return b
+}
+
+// validLogoURL reports whether logo is an absolute https URL
+func validLogoURL(logo string) bool {
+	u, err := url.Parse(logo)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+const (
+	appSecretBytes = 32
+	maxAppNameLen  = 100
+	minAppTokenTTL = time.Minute
+)
+
+var (
+	ErrAppExists            = errors.New("app already exists")
+	ErrInvalidApp           = errors.New("invalid app")
+	ErrRegistrationDisabled = errors.New("app registration is disabled")
+	ErrUnauthorizedClient   = errors.New("grant type is not allowed for the app")
+)
+
+// AppValidationError tells which field of an app is invalid and why.
+// Field is named as in the admin API. It matches ErrInvalidApp.
+type AppValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *AppValidationError) Error() string {
+	return ErrInvalidApp.Error() + ": " + e.Field + ": " + e.Reason
+}
+
+func (e *AppValidationError) Unwrap() error {
+	return ErrInvalidApp
+}
+
+// AppStore keeps apps managed through the API
+type AppStore interface {
+	AppProvider
+	// CreateApp saves app with a new ID and returns the ID
+	CreateApp(ctx context.Context, app models.App) (int, error)
+	Apps(ctx context.Context) ([]models.App, error)
+	// UpdateApp replaces every field of the app but its secret
+	UpdateApp(ctx context.Context, app models.App) error
+	UpdateAppSecret(ctx context.Context, appID int, secret string) error
+	// DeleteApp deletes the app together with grants of users to it
+	DeleteApp(ctx context.Context, appID int) error
+}
+
+// WithAppStore lets admins manage apps. It implies WithApps.
+func WithAppStore(store AppStore) Option {
+	return func(a *Auth) {
+		a.appProvider = store
+		a.appStore = store
+	}
+}
+
+// WithAppRegistration lets apps register themselves presenting
+// initialAccessToken, see RegisterApp. It should be used together with
+// WithAppStore.
+func WithAppRegistration(initialAccessToken string) Option {
+	return func(a *Auth) {
+		a.registrationToken = initialAccessToken
+	}
+}
+
+// App returns the app of appID
+func (a *Auth) App(ctx context.Context, appID int) (models.App, error) {
+	const op = "auth.App"
+
+	app, err := a.app(ctx, appID)
+	if err != nil {
+		if !errors.Is(err, ErrAppNotFound) {
+			a.log.Error("failed to get app", slog.String("op", op), sl.Err(err))
+		}
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// Apps returns every app ordered by id
+func (a *Auth) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "auth.Apps"
+
+	if a.appStore == nil {
+		return nil, nil
+	}
+
+	apps, err := a.appStore.Apps(ctx)
+	if err != nil {
+		a.log.Error("failed to list apps", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+// CreateApp validates app and saves it with a new ID and secret. The
+// secret is returned only here and by RotateAppSecret; public apps get
+// none.
+func (a *Auth) CreateApp(ctx context.Context, app models.App) (created models.App, secret string, err error) {
+	const op = "auth.CreateApp"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("name", app.Name),
+	)
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditAppCreate, err)
+		event.AppID = created.ID
+		a.audit(ctx, event)
+	}()
+
+	if a.appStore == nil {
+		return models.App{}, "", fmt.Errorf("%s: %w", op, ErrRegistrationDisabled)
+	}
+
+	app, err = normalizeApp(app)
+	if err != nil {
+		return models.App{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// public apps keep the hash of a secret nobody knows, as secrets are
+	// unique in the database
+	secret, err = newAppSecret()
+	if err != nil {
+		return models.App{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	app.Secret = hashAppSecret(secret)
+	if app.Type == models.AppTypePublic {
+		secret = ""
+	}
+
+	app.ID, err = a.appStore.CreateApp(ctx, app)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppExists) {
+			return models.App{}, "", fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+		log.Error("failed to save app", sl.Err(err))
+		return models.App{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app created", slog.Int("app_id", app.ID))
+
+	return app, secret, nil
+}
+
+// RegisterApp is dynamic registration of RFC 7591: it creates a
+// third-party app for a caller presenting the initial access token set
+// with WithAppRegistration. The name of the app is generated, so that
+// apps cannot take names of each other; the name they choose is kept as
+// display name.
+func (a *Auth) RegisterApp(
+	ctx context.Context,
+	initialAccessToken string,
+	app models.App,
+) (models.App, string, error) {
+	const op = "auth.RegisterApp"
+
+	if a.registrationToken == "" || a.appStore == nil {
+		return models.App{}, "", fmt.Errorf("%s: %w", op, ErrRegistrationDisabled)
+	}
+	if subtle.ConstantTimeCompare([]byte(initialAccessToken), []byte(a.registrationToken)) != 1 {
+		return models.App{}, "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return models.App{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	app.Name = "registered-" + hex.EncodeToString(suffix)
+	app.FirstParty = false
+
+	created, secret, err := a.CreateApp(ctx, app)
+	if err != nil {
+		return models.App{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return created, secret, nil
+}
+
+// UpdateApp validates app and replaces the app of app.ID with it. The
+// secret is kept; apps changed to confidential get one with
+// RotateAppSecret.
+func (a *Auth) UpdateApp(ctx context.Context, app models.App) (updated models.App, err error) {
+	const op = "auth.UpdateApp"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", app.ID),
+	)
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditAppUpdate, err)
+		event.AppID = app.ID
+		a.audit(ctx, event)
+	}()
+
+	if a.appStore == nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+
+	updated, err = normalizeApp(app)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.appStore.UpdateApp(ctx, updated); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrAppNotFound):
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		case errors.Is(err, storage.ErrAppExists):
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+		log.Error("failed to update app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app updated")
+
+	return a.App(ctx, app.ID)
+}
+
+// RotateAppSecret replaces the secret of a confidential app and returns
+// the new one, the old secret stops working at once
+func (a *Auth) RotateAppSecret(ctx context.Context, appID int) (secret string, err error) {
+	const op = "auth.RotateAppSecret"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditAppSecretRotate, err)
+		event.AppID = appID
+		a.audit(ctx, event)
+	}()
+
+	if a.appStore == nil {
+		return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+
+	app, err := a.app(ctx, appID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if app.Type == models.AppTypePublic {
+		return "", fmt.Errorf("%s: %w", op, &AppValidationError{Field: "type", Reason: "public apps have no secret"})
+	}
+
+	secret, err = newAppSecret()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.appStore.UpdateAppSecret(ctx, appID, hashAppSecret(secret)); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		log.Error("failed to save app secret", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app secret rotated")
+
+	return secret, nil
+}
+
+// AuthenticateApp checks the secret of a confidential app, e.g. of a map
+// service calling the API with its own credentials. Unknown apps, public
+// apps and wrong secrets are ErrInvalidCredentials.
+func (a *Auth) AuthenticateApp(ctx context.Context, appID int, secret string) (models.App, error) {
+	const op = "auth.AuthenticateApp"
+
+	app, err := a.app(ctx, appID)
+	if err != nil {
+		if errors.Is(err, ErrAppNotFound) {
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		a.log.Error("failed to get app", slog.String("op", op), sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	hash := hashAppSecret(secret)
+	if app.Type == models.AppTypePublic || subtle.ConstantTimeCompare([]byte(app.Secret), []byte(hash)) != 1 {
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	return app, nil
+}
+
+// DeleteApp deletes the app with grants of users to it and revokes
+// sessions of its tokens
+func (a *Auth) DeleteApp(ctx context.Context, appID int) (err error) {
+	const op = "auth.DeleteApp"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditAppDelete, err)
+		event.AppID = appID
+		a.audit(ctx, event)
+	}()
+
+	if a.appStore == nil {
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+
+	err = a.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := a.appStore.DeleteApp(ctx, appID); err != nil {
+			if errors.Is(err, storage.ErrAppNotFound) {
+				return ErrAppNotFound
+			}
+			return err
+		}
+
+		if a.sessions == nil {
+			return nil
+		}
+		return a.sessions.RevokeAllAppSessions(ctx, appID, time.Now().UTC())
+	})
+	if err != nil {
+		if !errors.Is(err, ErrAppNotFound) {
+			log.Error("failed to delete app", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app deleted")
+
+	return nil
+}
+
+// normalizeApp validates app and fills defaults: apps are confidential
+// and use the login operation unless told otherwise
+func normalizeApp(app models.App) (models.App, error) {
+	app.Name = strings.TrimSpace(app.Name)
+	if app.Name == "" || len(app.Name) > maxAppNameLen {
+		return models.App{}, &AppValidationError{Field: "name", Reason: fmt.Sprintf("must be 1 to %d bytes", maxAppNameLen)}
+	}
+
+	switch app.Type {
+	case "":
+		app.Type = models.AppTypeConfidential
+	case models.AppTypeConfidential, models.AppTypePublic:
+	default:
+		return models.App{}, &AppValidationError{Field: "type", Reason: fmt.Sprintf("unknown type %q", app.Type)}
+	}
+
+	if len(app.GrantTypes) == 0 {
+		app.GrantTypes = []string{models.GrantTypePassword}
+	}
+	app.GrantTypes = sortedSet(app.GrantTypes)
+	for _, g := range app.GrantTypes {
+		if !slices.Contains(grantTypes, g) {
+			return models.App{}, &AppValidationError{Field: "grant_types", Reason: fmt.Sprintf("unknown grant type %q", g)}
+		}
+	}
+
+	app.RedirectURIs = sortedSet(app.RedirectURIs)
+	for _, uri := range app.RedirectURIs {
+		if !validRedirectURI(uri, app.Type) {
+			return models.App{}, &AppValidationError{Field: "redirect_uris", Reason: fmt.Sprintf("invalid redirect uri %q", uri)}
+		}
+	}
+	if len(app.RedirectURIs) == 0 && slices.Contains(app.GrantTypes, models.GrantTypeAuthorizationCode) {
+		return models.App{}, &AppValidationError{Field: "redirect_uris", Reason: "required for authorization_code"}
+	}
+
+	app.Scopes = sortedSet(app.Scopes)
+	for _, s := range app.Scopes {
+		if _, ok := authz.Lookup(s); !ok {
+			return models.App{}, &AppValidationError{Field: "scopes", Reason: fmt.Sprintf("unknown scope %q", s)}
+		}
+	}
+
+	if app.TokenTTL < 0 || (app.TokenTTL > 0 && app.TokenTTL < minAppTokenTTL) {
+		return models.App{}, &AppValidationError{Field: "token_ttl", Reason: "must be 0 for the default or at least a minute"}
+	}
+
+	if app.LogoURL != "" && !validLogoURL(app.LogoURL) {
+		return models.App{}, &AppValidationError{Field: "logo_url", Reason: "must be an https url"}
+	}
+	if app.PrimaryColor != "" && !colorRe.MatchString(app.PrimaryColor) {
+		return models.App{}, &AppValidationError{Field: "primary_color", Reason: "must be a hex color"}
+	}
+	if app.BackgroundColor != "" && !colorRe.MatchString(app.BackgroundColor) {
+		return models.App{}, &AppValidationError{Field: "background_color", Reason: "must be a hex color"}
+	}
+
+	return app, nil
+}
+
+// grantTypes are grant types apps may be allowed
+var grantTypes = []string{
+	models.GrantTypePassword,
+	models.GrantTypeAuthorizationCode,
+	models.GrantTypeRefreshToken,
+}
+
+// validRedirectURI reports whether uri is an absolute URI without
+// fragment the app may be sent back to. Following RFC 8252, plain http is
+// allowed for loopback addresses only, and public (native) apps may use
+// private-use schemes in reverse domain notation.
+func validRedirectURI(uri string, appType string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || strings.ContainsAny(uri, " \t\r\n") {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		ip := net.ParseIP(host)
+		return host == "localhost" || (ip != nil && ip.IsLoopback())
+	default:
+		return appType == models.AppTypePublic && strings.Contains(u.Scheme, ".")
+	}
+}
+
+func newAppSecret() (string, error) {
+	raw := make([]byte, appSecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generate secret: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashAppSecret returns the hash of secret kept in models.App
+func hashAppSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// sortedSet returns values sorted and without duplicates
+func sortedSet(values []string) []string {
+	values = slices.Clone(values)
+	slices.Sort(values)
+
+	return slices.Compact(values)
+}
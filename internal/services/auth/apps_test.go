@@ -0,0 +1,286 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranding(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	a := newAuthWithStorage(st, auth.WithApps(st))
+
+	require.NoError(t, st.SaveApp(ctx, models.App{
+		ID:              1,
+		Name:            "tiles",
+		DisplayName:     "Babs Tiles",
+		LogoURL:         "https://cdn.example.com/tiles.svg",
+		PrimaryColor:    "#ff6600",
+		BackgroundColor: "#fff",
+	}))
+	require.NoError(t, st.SaveApp(ctx, models.App{
+		ID:              2,
+		Name:            "geocoder",
+		LogoURL:         "javascript:alert(1)",
+		PrimaryColor:    "red;background:url(https://evil.example.com)",
+		BackgroundColor: "#12345",
+	}))
+
+	tests := []struct {
+		name  string
+		appID int
+		want  models.Branding
+	}{
+		{
+			name:  "branded app",
+			appID: 1,
+			want: models.Branding{
+				Name:            "Babs Tiles",
+				LogoURL:         "https://cdn.example.com/tiles.svg",
+				PrimaryColor:    "#ff6600",
+				BackgroundColor: "#fff",
+			},
+		},
+		{
+			name:  "invalid values fall back to defaults",
+			appID: 2,
+			want: models.Branding{
+				Name:            "geocoder",
+				PrimaryColor:    auth.DefaultBranding.PrimaryColor,
+				BackgroundColor: auth.DefaultBranding.BackgroundColor,
+			},
+		},
+		{name: "unknown app", appID: 42, want: auth.DefaultBranding},
+		{name: "no app", appID: 0, want: auth.DefaultBranding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.Branding(ctx, tt.appID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreateApp(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+
+	app, secret, err := a.CreateApp(ctx, models.App{
+		Name:         " tiles ",
+		RedirectURIs: []string{"https://tiles.example.com/cb", "https://tiles.example.com/cb"},
+		GrantTypes:   []string{models.GrantTypeAuthorizationCode, models.GrantTypePassword},
+		Scopes:       []string{authz.TilesRender},
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, app.ID)
+	assert.Equal(t, "tiles", app.Name)
+	assert.Equal(t, models.AppTypeConfidential, app.Type, "apps are confidential by default")
+	assert.Equal(t, []string{"https://tiles.example.com/cb"}, app.RedirectURIs)
+	assert.Equal(t, []string{models.GrantTypeAuthorizationCode, models.GrantTypePassword}, app.GrantTypes)
+	require.NotEmpty(t, secret)
+
+	stored, err := st.App(ctx, app.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, stored.Secret, secret, "only the hash of the secret is kept")
+	assert.Len(t, stored.Secret, 64)
+
+	_, _, err = a.CreateApp(ctx, models.App{Name: "tiles"})
+	assert.ErrorIs(t, err, auth.ErrAppExists)
+
+	public, secret, err := a.CreateApp(ctx, models.App{Name: "mobile", Type: models.AppTypePublic})
+	require.NoError(t, err)
+	assert.Empty(t, secret, "public apps get no secret")
+	assert.Equal(t, []string{models.GrantTypePassword}, public.GrantTypes)
+
+	creates := allAuditEvents(t, a, models.AuditFilter{Type: models.AuditAppCreate})
+	require.Len(t, creates, 3)
+	outcomes := []string{creates[0].Outcome, creates[1].Outcome, creates[2].Outcome}
+	assert.ElementsMatch(t, []string{models.AuditSuccess, models.AuditSuccess, models.AuditFailure}, outcomes)
+}
+
+func TestCreateApp_Invalid(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		app   models.App
+		field string
+	}{
+		{name: "no name", app: models.App{Name: " "}, field: "name"},
+		{name: "unknown type", app: models.App{Name: "a", Type: "trusted"}, field: "type"},
+		{name: "unknown grant type", app: models.App{Name: "a", GrantTypes: []string{"implicit"}}, field: "grant_types"},
+		{
+			name:  "authorization code without redirect uri",
+			app:   models.App{Name: "a", GrantTypes: []string{models.GrantTypeAuthorizationCode}},
+			field: "redirect_uris",
+		},
+		{name: "plain http", app: models.App{Name: "a", RedirectURIs: []string{"http://example.com/cb"}}, field: "redirect_uris"},
+		{name: "relative", app: models.App{Name: "a", RedirectURIs: []string{"/cb"}}, field: "redirect_uris"},
+		{name: "fragment", app: models.App{Name: "a", RedirectURIs: []string{"https://example.com/cb#x"}}, field: "redirect_uris"},
+		{
+			name:  "private-use scheme of confidential app",
+			app:   models.App{Name: "a", RedirectURIs: []string{"com.example.app:/cb"}},
+			field: "redirect_uris",
+		},
+		{
+			name:  "script",
+			app:   models.App{Name: "a", Type: models.AppTypePublic, RedirectURIs: []string{"javascript:alert(1)"}},
+			field: "redirect_uris",
+		},
+		{name: "unknown scope", app: models.App{Name: "a", Scopes: []string{"maps:delete"}}, field: "scopes"},
+		{name: "short token ttl", app: models.App{Name: "a", TokenTTL: time.Second}, field: "token_ttl"},
+		{name: "http logo", app: models.App{Name: "a", LogoURL: "http://example.com/logo.png"}, field: "logo_url"},
+		{name: "color", app: models.App{Name: "a", PrimaryColor: "red"}, field: "primary_color"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := a.CreateApp(ctx, tt.app)
+			require.ErrorIs(t, err, auth.ErrInvalidApp)
+
+			var appErr *auth.AppValidationError
+			require.ErrorAs(t, err, &appErr)
+			assert.Equal(t, tt.field, appErr.Field)
+		})
+	}
+
+	valid := []models.App{
+		{Name: "loopback", RedirectURIs: []string{"http://127.0.0.1:8765/cb", "http://localhost/cb"}},
+		{Name: "native", Type: models.AppTypePublic, RedirectURIs: []string{"com.example.app:/cb"}},
+	}
+	for _, app := range valid {
+		_, _, err := a.CreateApp(ctx, app)
+		assert.NoError(t, err, app.Name)
+	}
+}
+
+func TestUpdateApp(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+
+	app, _, err := a.CreateApp(ctx, models.App{Name: "tiles"})
+	require.NoError(t, err)
+	stored, err := st.App(ctx, app.ID)
+	require.NoError(t, err)
+
+	app.DisplayName = "Babs Tiles"
+	app.GrantTypes = nil
+	updated, err := a.UpdateApp(ctx, app)
+	require.NoError(t, err)
+	assert.Equal(t, "Babs Tiles", updated.DisplayName)
+	assert.Equal(t, []string{models.GrantTypePassword}, updated.GrantTypes)
+	assert.Equal(t, stored.Secret, updated.Secret, "secret is kept")
+
+	_, err = a.UpdateApp(ctx, models.App{ID: 42, Name: "missing"})
+	assert.ErrorIs(t, err, auth.ErrAppNotFound)
+	_, err = a.UpdateApp(ctx, models.App{ID: app.ID})
+	assert.ErrorIs(t, err, auth.ErrInvalidApp)
+}
+
+func TestRotateAppSecret(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+
+	app, secret, err := a.CreateApp(ctx, models.App{Name: "tiles"})
+	require.NoError(t, err)
+	before, err := st.App(ctx, app.ID)
+	require.NoError(t, err)
+
+	rotated, err := a.RotateAppSecret(ctx, app.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, rotated)
+
+	after, err := st.App(ctx, app.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, before.Secret, after.Secret)
+
+	public, _, err := a.CreateApp(ctx, models.App{Name: "mobile", Type: models.AppTypePublic})
+	require.NoError(t, err)
+	_, err = a.RotateAppSecret(ctx, public.ID)
+	assert.ErrorIs(t, err, auth.ErrInvalidApp)
+
+	_, err = a.RotateAppSecret(ctx, 42)
+	assert.ErrorIs(t, err, auth.ErrAppNotFound)
+}
+
+func TestDeleteApp_RevokesTokens(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+	_, email, pass := register(t, a)
+
+	app, _, err := a.CreateApp(ctx, models.App{Name: "tiles", FirstParty: true})
+	require.NoError(t, err)
+	token, err := a.Login(withApp(app.ID), email, pass)
+	require.NoError(t, err)
+	other, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+
+	require.NoError(t, a.DeleteApp(ctx, app.ID))
+
+	_, err = a.ValidateToken(ctx, token)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	_, err = a.ValidateToken(ctx, other)
+	assert.NoError(t, err, "tokens of other apps are kept")
+
+	_, err = a.App(ctx, app.ID)
+	assert.ErrorIs(t, err, auth.ErrAppNotFound)
+	assert.ErrorIs(t, a.DeleteApp(ctx, app.ID), auth.ErrAppNotFound)
+}
+
+func TestRegisterApp(t *testing.T) {
+	ctx := context.Background()
+
+	disabled, _ := newAuth(t)
+	_, _, err := disabled.RegisterApp(ctx, "", models.App{})
+	assert.ErrorIs(t, err, auth.ErrRegistrationDisabled)
+
+	a, _ := newAuth(t, auth.WithAppRegistration("initial-token"))
+	_, _, err = a.RegisterApp(ctx, "wrong", models.App{})
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	app, secret, err := a.RegisterApp(ctx, "initial-token", models.App{
+		Name:         "maps",
+		FirstParty:   true,
+		DisplayName:  "Partner",
+		RedirectURIs: []string{"https://partner.example.com/cb"},
+		GrantTypes:   []string{models.GrantTypeAuthorizationCode},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, secret)
+	assert.False(t, app.FirstParty, "registered apps are third-party")
+	assert.NotEqual(t, "maps", app.Name, "registered apps do not choose names")
+	assert.Equal(t, "Partner", app.DisplayName)
+}
+
+func TestLogin_AppSettings(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+	_, email, pass := register(t, a)
+
+	short, _, err := a.CreateApp(ctx, models.App{Name: "short", FirstParty: true, TokenTTL: 5 * time.Minute})
+	require.NoError(t, err)
+	token, err := a.Login(withApp(short.ID), email, pass)
+	require.NoError(t, err)
+
+	claims, err := authz.Validate(token, testSecret)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), claims.ExpiresAt, 5*time.Second)
+
+	redirectOnly, _, err := a.CreateApp(ctx, models.App{
+		Name:         "redirect-only",
+		RedirectURIs: []string{"https://example.com/cb"},
+		GrantTypes:   []string{models.GrantTypeAuthorizationCode},
+	})
+	require.NoError(t, err)
+	_, err = a.Login(withApp(redirectOnly.ID), email, pass)
+	assert.ErrorIs(t, err, auth.ErrUnauthorizedClient)
+}
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/hasher"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/paging"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/password"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// AuditLog is an append-only store of audit events
+type AuditLog interface {
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) error
+	// AuditEvents returns at most query.Limit+1 events, newest first
+	AuditEvents(ctx context.Context, query models.AuditQuery) ([]models.AuditEvent, error)
+}
+
+// AuditSink receives a copy of every audit event, e.g. for shipping to SIEM
+type AuditSink interface {
+	WriteAuditEvent(ctx context.Context, event models.AuditEvent) error
+}
+
+// WithAuditLog enables recording of security-relevant events
+func WithAuditLog(log AuditLog, sinks ...AuditSink) Option {
+	return func(a *Auth) {
+		a.auditLog = log
+		a.auditSinks = sinks
+	}
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns ctx of a request made by authenticated user
+// actorID. Audit events record the actor.
+func ContextWithActor(ctx context.Context, actorID uuid.UUID) context.Context {
+	return context.WithValue(ctx, actorKey{}, actorID)
+}
+
+func actorFromContext(ctx context.Context) *uuid.UUID {
+	actorID, ok := ctx.Value(actorKey{}).(uuid.UUID)
+	if !ok {
+		return nil
+	}
+	return &actorID
+}
+
+// auditEvent starts an event of type typ filled from ctx. Outcome is set
+// from err.
+func auditEvent(ctx context.Context, typ string, err error) models.AuditEvent {
+	info := clientinfo.FromContext(ctx)
+
+	event := models.AuditEvent{
+		ID:        uuid.New(),
+		Type:      typ,
+		Outcome:   models.AuditSuccess,
+		ActorID:   actorFromContext(ctx),
+		IP:        info.IP,
+		UserAgent: info.UserAgent,
+		AppID:     info.AppID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		event.Outcome = models.AuditFailure
+		event.Reason = auditReason(err)
+	}
+
+	return event
+}
+
+// auditReason returns stable code of err. Unexpected errors are recorded
+// as "internal", their text may hold details not meant for the log.
+func auditReason(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidCredentials), errors.Is(err, hasher.ErrMismatch):
+		return "invalid_credentials"
+	case errors.Is(err, ErrUserNotFound):
+		return "user_not_found"
+	case errors.Is(err, ErrUserExists):
+		return "user_exists"
+	case errors.Is(err, ErrAccountLocked):
+		return "account_locked"
+	case errors.Is(err, ErrUserDisabled):
+		return "user_disabled"
+	case errors.Is(err, ErrTooManyAttempts):
+		return "too_many_attempts"
+	case errors.Is(err, password.ErrPolicyViolation):
+		return "weak_password"
+	case errors.Is(err, ErrSessionNotFound):
+		return "session_not_found"
+	case errors.Is(err, ErrAppNotFound):
+		return "app_not_found"
+	case errors.Is(err, ErrGrantNotFound):
+		return "grant_not_found"
+	case errors.Is(err, ErrConsentRequired):
+		return "consent_required"
+	case errors.Is(err, ErrInvalidScope):
+		return "invalid_scope"
+	case errors.Is(err, ErrAppExists):
+		return "app_exists"
+	case errors.Is(err, ErrInvalidApp):
+		return "invalid_app"
+	case errors.Is(err, ErrUnauthorizedClient):
+		return "unauthorized_client"
+	case errors.Is(err, ErrAPIKeyNotFound):
+		return "api_key_not_found"
+	case errors.Is(err, ErrInvalidAPIKey):
+		return "invalid_api_key"
+	default:
+		return "internal"
+	}
+}
+
+// saveAuditEvent records event and returns error of the audit log only.
+// It is used inside transactions, where the event must be stored together
+// with the change it describes.
+func (a *Auth) saveAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	if a.auditLog == nil {
+		return nil
+	}
+
+	if err := a.auditLog.SaveAuditEvent(ctx, event); err != nil {
+		return fmt.Errorf("save audit event: %w", err)
+	}
+
+	return nil
+}
+
+// audit records event, failures are only logged
+func (a *Auth) audit(ctx context.Context, event models.AuditEvent) {
+	if err := a.saveAuditEvent(ctx, event); err != nil {
+		a.log.Error("failed to save audit event", slog.String("type", event.Type), sl.Err(err))
+	}
+	a.sinkAuditEvent(ctx, event)
+}
+
+// sinkAuditEvent copies event to sinks
+func (a *Auth) sinkAuditEvent(ctx context.Context, event models.AuditEvent) {
+	for _, sink := range a.auditSinks {
+		if err := sink.WriteAuditEvent(ctx, event); err != nil {
+			a.log.Error("failed to write audit event to sink", slog.String("type", event.Type), sl.Err(err))
+		}
+	}
+}
+
+// AuditEvents returns one page of audit events matching query, newest
+// first. cursor is NextCursor of the previous page, or empty.
+func (a *Auth) AuditEvents(
+	ctx context.Context,
+	query models.AuditQuery,
+	cursor string,
+) (models.AuditPage, error) {
+	const op = "auth.AuditEvents"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	if a.auditLog == nil {
+		return models.AuditPage{}, nil
+	}
+
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultAuditLimit
+	case query.Limit > maxAuditLimit:
+		query.Limit = maxAuditLimit
+	}
+
+	if cursor != "" {
+		after, err := paging.DecodeCursor[models.AuditCursor](cursor)
+		if err != nil {
+			return models.AuditPage{}, fmt.Errorf("%s: %w", op, ErrInvalidCursor)
+		}
+		query.After = &after
+	}
+
+	events, err := a.auditLog.AuditEvents(ctx, query)
+	if err != nil {
+		log.Error("failed to list audit events", sl.Err(err))
+		return models.AuditPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.AuditPage{Events: events}
+	if uint(len(events)) > query.Limit {
+		page.Events = events[:query.Limit]
+		last := page.Events[len(page.Events)-1]
+		page.NextCursor = paging.EncodeCursor(models.AuditCursor{
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	return page, nil
+}
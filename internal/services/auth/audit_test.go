@@ -0,0 +1,175 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+}
+
+func (s *recordingSink) WriteAuditEvent(_ context.Context, event models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	return nil
+}
+
+func newAuditedAuth(t *testing.T) (*auth.Auth, *memory.Storage, *recordingSink) {
+	t.Helper()
+
+	st := memory.New()
+	sink := &recordingSink{}
+	a := newAuthWithStorage(st, auth.WithAuditLog(st, sink))
+
+	return a, st, sink
+}
+
+func allAuditEvents(t *testing.T, a *auth.Auth, filter models.AuditFilter) []models.AuditEvent {
+	t.Helper()
+
+	page, err := a.AuditEvents(context.Background(), models.AuditQuery{Filter: filter, Limit: 500}, "")
+	require.NoError(t, err)
+	return page.Events
+}
+
+func TestAudit_LoginAndRegister(t *testing.T) {
+	a, st, sink := newAuditedAuth(t)
+	ctx := clientinfo.WithInfo(context.Background(), clientinfo.Info{
+		IP:        "192.0.2.10",
+		UserAgent: "test-agent",
+	})
+
+	email, pass := gofakeit.Email(), randomPassword()
+	id, err := a.RegisterNewUser(ctx, email, pass)
+	require.NoError(t, err)
+
+	_, err = a.Login(ctx, email, "wrong")
+	require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	_, err = a.Login(ctx, email, pass)
+	require.NoError(t, err)
+
+	_, err = a.RegisterNewUser(ctx, email, pass)
+	require.ErrorIs(t, err, auth.ErrUserExists)
+
+	events := allAuditEvents(t, a, models.AuditFilter{})
+	require.Len(t, events, 4)
+	assert.Len(t, sink.events, 4)
+
+	// newest first
+	dup, login, failed, reg := events[0], events[1], events[2], events[3]
+
+	assert.Equal(t, models.AuditRegister, reg.Type)
+	assert.Equal(t, models.AuditSuccess, reg.Outcome)
+	assert.Equal(t, &id, reg.SubjectID)
+	assert.Nil(t, reg.ActorID)
+	assert.Equal(t, "192.0.2.10", reg.IP)
+	assert.Equal(t, "test-agent", reg.UserAgent)
+
+	assert.Equal(t, models.AuditLogin, failed.Type)
+	assert.Equal(t, models.AuditFailure, failed.Outcome)
+	assert.Equal(t, "invalid_credentials", failed.Reason)
+	assert.Equal(t, email, failed.Email)
+
+	assert.Equal(t, models.AuditLogin, login.Type)
+	assert.Equal(t, models.AuditSuccess, login.Outcome)
+	assert.Equal(t, &id, login.ActorID)
+	assert.Equal(t, &id, login.SubjectID)
+
+	assert.Equal(t, models.AuditRegister, dup.Type)
+	assert.Equal(t, "user_exists", dup.Reason)
+
+	failures := allAuditEvents(t, a, models.AuditFilter{Outcome: models.AuditFailure})
+	assert.Len(t, failures, 2)
+
+	_, err = st.UserById(ctx, id)
+	require.NoError(t, err)
+}
+
+func TestAudit_ResetPasswordRecordsActor(t *testing.T) {
+	a, _, _ := newAuditedAuth(t)
+
+	id, _, _ := register(t, a)
+	admin := uuid.New()
+
+	ctx := auth.ContextWithActor(context.Background(), admin)
+	require.NoError(t, a.ResetPassword(ctx, id, randomPassword()))
+
+	events := allAuditEvents(t, a, models.AuditFilter{Type: models.AuditPasswordReset})
+	require.Len(t, events, 1)
+	assert.Equal(t, &admin, events[0].ActorID)
+	assert.Equal(t, &id, events[0].SubjectID)
+
+	byActor := allAuditEvents(t, a, models.AuditFilter{ActorID: &admin})
+	assert.Len(t, byActor, 1)
+}
+
+// failingAuditLog fails to save any event
+type failingAuditLog struct {
+	*memory.Storage
+}
+
+func (l failingAuditLog) SaveAuditEvent(context.Context, models.AuditEvent) error {
+	return errors.New("disk full")
+}
+
+func TestAudit_RegistrationIsAtomic(t *testing.T) {
+	st := memory.New()
+	a := newAuthWithStorage(st, auth.WithAuditLog(failingAuditLog{st}))
+
+	email := gofakeit.Email()
+	_, err := a.RegisterNewUser(context.Background(), email, randomPassword())
+	require.Error(t, err)
+
+	// user is not saved without its audit event
+	_, err = st.User(context.Background(), email)
+	assert.Error(t, err)
+}
+
+func TestAudit_Pagination(t *testing.T) {
+	a, _, _ := newAuditedAuth(t)
+	ctx := context.Background()
+
+	const eventsCount = 7
+	for i := 0; i < eventsCount; i++ {
+		_, _ = a.Login(ctx, gofakeit.Email(), "wrong")
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, eventsCount)
+
+		page, err := a.AuditEvents(ctx, models.AuditQuery{Limit: 3}, cursor)
+		require.NoError(t, err)
+
+		for _, e := range page.Events {
+			assert.False(t, seen[e.ID])
+			seen[e.ID] = true
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Len(t, seen, eventsCount)
+
+	_, err := a.AuditEvents(ctx, models.AuditQuery{}, "%%%")
+	assert.ErrorIs(t, err, auth.ErrInvalidCursor)
+}
@@ -2,18 +2,19 @@ package auth
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/hasher"
 	jwt_lib "github.com/babs-corp/babs-maps-auth/internal/lib/jwt"
 	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
 	"github.com/babs-corp/babs-maps-auth/internal/storage"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type Auth struct {
@@ -21,8 +22,44 @@ type Auth struct {
 	userSaver    UserSaver
 	userProvider UserProvider
 	appProvider  AppProvider
+	hasher       PasswordHasher
+	policy       PasswordPolicy
+	attempts     AttemptStore
+	lockout      LockoutPolicy
+	txManager    TxManager
+	auditLog     AuditLog
+	auditSinks   []AuditSink
+	outbox       EventOutbox
+	sessions     SessionStore
+	grants       GrantStore
+	appStore     AppStore
+	apiKeys      APIKeyStore
+	browser      BrowserPolicy
 	tokenTTL     time.Duration
-	secret       string
+	signingKey   jwt_lib.SigningKey
+	// registrationToken is the initial access token of RegisterApp
+	registrationToken string
+}
+
+// Option configures optional parts of Auth service
+type Option func(a *Auth)
+
+// WithSigningKey signs tokens with EdDSA and key instead of HS256 and the
+// secret, so that resource servers verify them with the public key and
+// cannot issue tokens. Tokens signed with the secret are no longer
+// accepted.
+func WithSigningKey(key ed25519.PrivateKey) Option {
+	return func(a *Auth) {
+		a.signingKey.Private = key
+	}
+}
+
+// WithPasswordHasher sets hasher used for new passwords.
+// By default passwords are hashed with bcrypt.
+func WithPasswordHasher(h PasswordHasher) Option {
+	return func(a *Auth) {
+		a.hasher = h
+	}
 }
 
 type UserSaver interface {
@@ -31,12 +68,14 @@ type UserSaver interface {
 		email string,
 		passHash []byte,
 	) (uid uuid.UUID, err error)
+	UpdatePassHash(ctx context.Context, userID uuid.UUID, passHash []byte) error
 }
 
 // We can get user not only from Database, but e.g. from kafka, cache, etc...
 type UserProvider interface {
 	User(ctx context.Context, email string) (models.User, error)
-	Users(ctx context.Context, limit uint) ([]models.User, error)
+	ListUsers(ctx context.Context, query models.UserQuery) ([]models.User, error)
+	CountUsers(ctx context.Context, filter models.UserFilter) (int, error)
 	UserById(ctx context.Context, id uuid.UUID) (models.User, error)
 	IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error)
 }
@@ -45,10 +84,31 @@ type AppProvider interface {
 	App(ctx context.Context, appID int) (models.App, error)
 }
 
+// PasswordHasher hashes passwords. Hashes must be self-describing, so that
+// hashes produced with older algorithms or parameters can still be verified.
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	// Compare returns hasher.ErrMismatch if password does not match hash
+	Compare(hash []byte, password string) error
+	NeedsRehash(hash []byte) bool
+}
+
+// PasswordPolicy checks strength of new passwords
+type PasswordPolicy interface {
+	// Validate returns *password.PolicyError listing broken rules
+	Validate(password string, email string) error
+}
+
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrUserExists         = errors.New("user already exists")
+	ErrAccountLocked      = errors.New("account is temporarily locked")
+	ErrUserDisabled       = errors.New("user is disabled")
+	ErrTooManyAttempts    = errors.New("too many login attempts")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrInvalidCursor      = errors.New("invalid cursor")
+	ErrSessionNotFound    = errors.New("session not found")
 )
 
 // New returns a new instance of Auth service
@@ -58,22 +118,100 @@ func New(
 	userProvider UserProvider,
 	tokenTTL time.Duration,
 	secret string,
+	opts ...Option,
 ) *Auth {
-	return &Auth{
+	a := &Auth{
 		log:          log,
 		userSaver:    userSaver,
 		userProvider: userProvider,
+		hasher:       hasher.New(hasher.Bcrypt{}),
+		txManager:    noTx{},
 		tokenTTL:     tokenTTL,
-		secret:       secret,
+		signingKey:   jwt_lib.SigningKey{Secret: secret},
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
 }
 
+// Login issues a token with default scopes of the app of ctx
 func (a *Auth) Login(
 	ctx context.Context,
 	email string,
 	password string,
 ) (string, error) {
-	const op = "auth.Login"
+	token, _, err := a.LoginWithScopes(ctx, email, password, nil)
+	return token, err
+}
+
+// LoginWithScopes issues a token with scopes negotiated for the app of
+// ctx and returns the token and its scopes. With no scopes requested the
+// token gets the default ones, see tokenScopes. Tokens live for the token
+// TTL of the app if it sets one.
+func (a *Auth) LoginWithScopes(
+	ctx context.Context,
+	email string,
+	password string,
+	requested []string,
+) (token string, scopes []string, err error) {
+	const op = "auth.LoginWithScopes"
+
+	user, err := a.authenticate(ctx, email, password)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.loginApp(ctx)
+	if err != nil {
+		if !errors.Is(err, ErrAppNotFound) && !errors.Is(err, ErrUnauthorizedClient) {
+			a.log.Error("failed to get app", sl.Err(err))
+		}
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// tokens for third-party apps need consent given on the consent page
+	scopes, err = a.tokenScopes(ctx, user.ID, app, requested)
+	if err != nil {
+		if !errors.Is(err, ErrConsentRequired) && !errors.Is(err, ErrInvalidScope) {
+			a.log.Error("failed to negotiate scopes", sl.Err(err))
+		}
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ttl := a.tokenTTL
+	if app.TokenTTL > 0 {
+		ttl = app.TokenTTL
+	}
+
+	var sessionID uuid.UUID
+	if a.sessions != nil {
+		session, err := a.startSession(ctx, user.ID, ttl, nil)
+		if err != nil {
+			a.log.Error("failed to start session", sl.Err(err))
+			return "", nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessionID = session.ID
+	}
+
+	token, err = jwt_lib.NewToken(user, sessionID, app.ID, scopes, a.signingKey, ttl)
+	if err != nil {
+		a.log.Error("failed to create token", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, scopes, nil
+}
+
+// authenticate checks password of the user with lockout and audits the
+// attempt. It is shared by token and browser logins.
+func (a *Auth) authenticate(
+	ctx context.Context,
+	email string,
+	password string,
+) (user models.User, err error) {
+	const op = "auth.authenticate"
 
 	log := a.log.With(
 		slog.String("op", op),
@@ -81,40 +219,63 @@ func (a *Auth) Login(
 	)
 	log.Info("login user")
 
-	user, err := a.userProvider.User(ctx, email)
+	var userID *uuid.UUID
+	defer func() {
+		event := auditEvent(ctx, models.AuditLogin, err)
+		event.Email = email
+		event.SubjectID = userID
+		if err == nil {
+			event.ActorID = userID
+		}
+		a.audit(ctx, event)
+	}()
+
+	if err := a.checkLockout(ctx, email); err != nil {
+		if errors.Is(err, ErrAccountLocked) || errors.Is(err, ErrTooManyAttempts) {
+			log.Warn("login rejected", sl.Err(err))
+		} else {
+			log.Error("failed to check login attempts", sl.Err(err))
+		}
+
+		return models.User{}, err
+	}
+
+	user, err = a.userProvider.User(ctx, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			a.log.Warn("user not found", sl.Err(err))
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			a.registerFailedLogin(ctx, email)
+			return models.User{}, ErrInvalidCredentials
 		}
 		a.log.Error("failed to get user", sl.Err(err))
 
-		return "", fmt.Errorf("%s: %w", op, err)
+		return models.User{}, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
+	if err := a.hasher.Compare(user.PassHash, password); err != nil {
+		if !errors.Is(err, hasher.ErrMismatch) {
+			a.log.Error("failed to compare password hash", sl.Err(err))
+		}
 		a.log.Info("invalid password", sl.Err(err))
+		a.registerFailedLogin(ctx, email)
 
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		return models.User{}, ErrInvalidCredentials
 	}
 
-	// app, err := a.appProvider.App(ctx, appId)
-	// if err != nil {
-	// 	if errors.Is(err, storage.ErrAppNotFound) {
-	// 		a.log.Warn("app not found", sl.Err(err))
-	// 		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
-	// 	}
-	// 	a.log.Error("failed to get app", sl.Err(err))
-	// }
-	// a.log.Info("app found", slog.String("app", app.Name))
+	// checked after the password, so that it tells nothing to guessers
+	if user.Disabled {
+		id := user.ID
+		userID = &id
+		log.Warn("login of disabled user rejected")
 
-	token, err := jwt_lib.NewToken(user, a.secret, a.tokenTTL)
-	if err != nil {
-		a.log.Error("failed to create token", sl.Err(err))
-		return "", fmt.Errorf("%s: %w", op, err)
+		return models.User{}, ErrUserDisabled
 	}
 
-	return token, nil
+	userID = &user.ID
+	a.resetFailedLogins(ctx, email)
+	a.rehashPassword(ctx, user, password)
+
+	return user, nil
 }
 
 // RegisterNewUser registers new user in the system and returns user ID
@@ -123,7 +284,7 @@ func (a *Auth) RegisterNewUser(
 	ctx context.Context,
 	email string,
 	password string,
-) (uuid.UUID, error) {
+) (userID uuid.UUID, err error) {
 	const op = "auth.RegisterNewUser"
 
 	log := a.log.With(
@@ -132,16 +293,48 @@ func (a *Auth) RegisterNewUser(
 	)
 	log.Info("registering new user")
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password),
-		bcrypt.DefaultCost,
-	)
+	// successful registration is audited in its transaction
+	defer func() {
+		if err != nil {
+			event := auditEvent(ctx, models.AuditRegister, err)
+			event.Email = email
+			a.audit(ctx, event)
+		}
+	}()
+
+	if err := a.validatePassword(password, email); err != nil {
+		log.Warn("password rejected by policy", sl.Err(err))
+
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.hasher.Hash(password)
 	if err != nil {
 		log.Error("failed to hash password", sl.Err(err))
 
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	id, err := a.userSaver.SaveUser(ctx, email, passHash)
+	var (
+		id    uuid.UUID
+		event models.AuditEvent
+	)
+	err = a.txManager.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = a.userSaver.SaveUser(ctx, email, passHash)
+		if err != nil {
+			return err
+		}
+
+		event = auditEvent(ctx, models.AuditRegister, nil)
+		event.Email = email
+		event.SubjectID = &id
+		if err := a.saveAuditEvent(ctx, event); err != nil {
+			return err
+		}
+
+		return a.saveUserEvent(ctx, models.EventUserCreated, id)
+	})
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
 			log.Warn("user already exists", sl.Err(err))
@@ -151,10 +344,35 @@ func (a *Auth) RegisterNewUser(
 
 		return uuid.UUID{}, fmt.Errorf("cannot register user")
 	}
+	a.sinkAuditEvent(ctx, event)
 
 	return id, nil
 }
 
+// rehashPassword replaces hash of the user's password if it was produced by
+// an outdated algorithm or with weaker parameters than currently configured.
+// Failures are only logged, because the user has already been authenticated.
+func (a *Auth) rehashPassword(ctx context.Context, user models.User, password string) {
+	if !a.hasher.NeedsRehash(user.PassHash) {
+		return
+	}
+
+	log := a.log.With(slog.String("user_id", user.ID.String()))
+
+	passHash, err := a.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to rehash password", sl.Err(err))
+		return
+	}
+
+	if err := a.userSaver.UpdatePassHash(ctx, user.ID, passHash); err != nil {
+		log.Error("failed to save rehashed password", sl.Err(err))
+		return
+	}
+
+	log.Info("password rehashed")
+}
+
 // IsAdmin checks if user is admin
 func (a *Auth) IsAdmin(
 	ctx context.Context,
@@ -169,7 +387,7 @@ func (a *Auth) IsAdmin(
 
 	isAdmin, err := a.userProvider.IsAdmin(ctx, userId)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
+		if errors.Is(err, storage.ErrUserNotFound) {
 			a.log.Warn("user not found", sl.Err(err))
 			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
@@ -194,7 +412,7 @@ func (a *Auth) UserById(
 
 	user, err := a.userProvider.UserById(ctx, userId)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
+		if errors.Is(err, storage.ErrUserNotFound) {
 			a.log.Warn("user not found", sl.Err(err))
 			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
@@ -206,60 +424,129 @@ func (a *Auth) UserById(
 	return user, nil
 }
 
-func (a *Auth) Users(
+// ValidateToken returns owner of the token. Tokens of revoked sessions
+// and of deleted or disabled users are rejected. API keys are accepted as
+// tokens of their owner.
+func (a *Auth) ValidateToken(
 	ctx context.Context,
-	limit uint,
-) ([]models.User, error) {
-	const op = "auth.Users"
+	token string,
+) (uuid.UUID, error) {
+	var userID uuid.UUID
+	if IsAPIKey(token) {
+		key, err := a.validateAPIKey(ctx, token)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("[auth.ValidateToken] %w", err)
+		}
+		userID = key.UserID
+	} else {
+		claims, err := a.validateToken(ctx, token)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		userID = claims.UserID
+	}
 
-	a.log.With(
-		slog.String("op", op),
-	)
+	if _, err := a.activeUser(ctx, userID); err != nil {
+		return uuid.Nil, fmt.Errorf("[auth.ValidateToken] %w", err)
+	}
+
+	return userID, nil
+}
+
+// validateToken returns claims of the token, rejecting tokens of revoked
+// sessions
+func (a *Auth) validateToken(
+	ctx context.Context,
+	token string,
+) (authz.Claims, error) {
+	const op = "auth.validateToken"
 
-	users, err := a.userProvider.Users(ctx, limit)
+	claims, err := a.signingKey.Validate(token)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
-			a.log.Warn("user not found", sl.Err(err))
-			return []models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
-		}
+		return authz.Claims{}, fmt.Errorf("[%s] cannot validate token: %w: %w", op, ErrInvalidToken, err)
+	}
 
-		a.log.Error("failed to check if user is admin", sl.Err(err))
-		return []models.User{}, fmt.Errorf("%s: %w", op, err)
+	if err := a.checkSession(ctx, claims.UserID, claims.SessionID); err != nil {
+		return authz.Claims{}, fmt.Errorf("[%s] %w", op, err)
 	}
 
-	return users, nil
+	return claims, nil
 }
 
-func (a *Auth) ValidateToken(
+// Principal validates token and returns the caller it was issued to.
+// token may also be an API key.
+func (a *Auth) Principal(
 	ctx context.Context,
 	token string,
-) (uuid.UUID, error) {
-	const op = "auth.validateToken"
+) (models.Principal, error) {
+	const op = "auth.Principal"
 
-	a.log.With(
-		slog.String("op", op),
-	)
+	if IsAPIKey(token) {
+		principal, err := a.apiKeyPrincipal(ctx, token)
+		if err != nil {
+			return models.Principal{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return principal, nil
+	}
 
-	tokenParsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		return []byte(a.secret), nil
-	})
+	claims, err := a.validateToken(ctx, token)
+	if err != nil {
+		return models.Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return models.Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	thirdParty, err := a.thirdParty(ctx, claims.AppID)
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("[%s] cannot validate token: %w", op, err)
+		if errors.Is(err, ErrAppNotFound) {
+			// token of a deleted app
+			return models.Principal{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return models.Principal{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
-	if !ok {
-		return uuid.UUID{}, fmt.Errorf("[%s] cannot parse token claims", op)
+	return models.Principal{
+		UserID:     claims.UserID,
+		SessionID:  claims.SessionID,
+		AppID:      claims.AppID,
+		OrgID:      user.OrgID,
+		IsAdmin:    user.IsAdmin && !thirdParty,
+		ThirdParty: thirdParty,
+		Scopes:     claims.Scopes,
+	}, nil
+}
+
+// activeUser returns the owner of a token, key or session. It fails with
+// ErrInvalidToken if the user was deleted or disabled since.
+func (a *Auth) activeUser(ctx context.Context, userID uuid.UUID) (models.User, error) {
+	user, err := a.UserById(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return models.User{}, fmt.Errorf("user was deleted: %w", ErrInvalidToken)
+		}
+		return models.User{}, err
 	}
+	if user.Disabled {
+		return models.User{}, fmt.Errorf("%w: %w", ErrInvalidToken, ErrUserDisabled)
+	}
+
+	return user, nil
+}
 
-	str_uid, ok := claims["uid"].(string)
-	if !ok {
-		return uuid.UUID{}, fmt.Errorf("[%s] cannot parse string uuid", op)
+// thirdParty reports whether appID is an app other than a first-party
+// one. Tokens issued without app are not third-party.
+func (a *Auth) thirdParty(ctx context.Context, appID int) (bool, error) {
+	if appID == 0 {
+		return false, nil
 	}
-	uid, err := uuid.Parse(str_uid)
+
+	app, err := a.app(ctx, appID)
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("[%s] cannot parse uuid claims", op)
+		return false, err
 	}
-	
-	return uid, nil
+
+	return !app.FirstParty, nil
 }
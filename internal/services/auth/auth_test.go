@@ -0,0 +1,555 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/hasher"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/slogdiscard"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/password"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecret   = "test-secret"
+	testTokenTTL = time.Hour
+
+	passDefaultLen = 12
+)
+
+// fastBcrypt keeps tests quick, production cost comes from config
+var fastBcrypt = hasher.Bcrypt{Cost: 4}
+
+var fastArgon2id = hasher.Argon2id{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// newAuth returns service backed by new memory storage with every store
+// enabled. Options are applied after the defaults, so they can add
+// policies or replace stores.
+func newAuth(t *testing.T, opts ...auth.Option) (*auth.Auth, *memory.Storage) {
+	t.Helper()
+
+	st := memory.New()
+	opts = append([]auth.Option{
+		auth.WithAppStore(st),
+		auth.WithGrants(st),
+		auth.WithSessions(st),
+		auth.WithAPIKeys(st),
+		auth.WithAuditLog(st),
+	}, opts...)
+
+	return newAuthWithStorage(st, opts...), st
+}
+
+// newAuthWithStorage returns service backed by st with only transactions
+// enabled, for tests that share storage or pick stores themselves
+func newAuthWithStorage(st *memory.Storage, opts ...auth.Option) *auth.Auth {
+	log := slog.New(slogdiscard.NewDiscardHandler())
+	opts = append([]auth.Option{
+		auth.WithPasswordHasher(hasher.New(fastBcrypt)),
+		auth.WithTxManager(st),
+	}, opts...)
+
+	return auth.New(log, st, st, testTokenTTL, testSecret, opts...)
+}
+
+func randomPassword() string {
+	return gofakeit.Password(true, true, true, true, false, passDefaultLen)
+}
+
+func register(t *testing.T, a *auth.Auth) (uuid.UUID, string, string) {
+	t.Helper()
+
+	email, pass := gofakeit.Email(), randomPassword()
+	id, err := a.RegisterNewUser(context.Background(), email, pass)
+	require.NoError(t, err)
+
+	return id, email, pass
+}
+
+func TestRegisterNewUser_HappyPath(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+
+	id, email, pass := register(t, a)
+	assert.NotEqual(t, uuid.UUID{}, id)
+
+	user, err := st.UserById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, email, user.Email)
+	assert.False(t, user.IsAdmin)
+	assert.NotContains(t, string(user.PassHash), pass)
+	assert.NoError(t, hasher.New(fastBcrypt).Compare(user.PassHash, pass))
+}
+
+func TestRegisterNewUser_Duplicate(t *testing.T) {
+	a, _ := newAuth(t)
+
+	_, email, _ := register(t, a)
+
+	_, err := a.RegisterNewUser(context.Background(), email, randomPassword())
+	assert.ErrorIs(t, err, auth.ErrUserExists)
+}
+
+func TestRegisterNewUser_PolicyViolation(t *testing.T) {
+	a, _ := newAuth(t, auth.WithPasswordPolicy(password.Policy{
+		MinLength:    10,
+		RequireDigit: true,
+		ForbidEmail:  true,
+	}))
+
+	_, err := a.RegisterNewUser(context.Background(), "john.smith@example.com", "john.smith")
+	require.ErrorIs(t, err, password.ErrPolicyViolation)
+
+	var policyErr *password.PolicyError
+	require.True(t, errors.As(err, &policyErr))
+
+	var rules []string
+	for _, v := range policyErr.Violations {
+		rules = append(rules, v.Rule)
+	}
+	assert.ElementsMatch(t, []string{password.RuleDigit, password.RuleContainsEmail}, rules)
+}
+
+func TestLogin_HappyPath(t *testing.T) {
+	a, _ := newAuth(t)
+
+	id, email, pass := register(t, a)
+
+	loginTime := time.Now()
+	token, err := a.Login(context.Background(), email, pass)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return []byte(testSecret), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	assert.Equal(t, id.String(), claims["uid"])
+	assert.Equal(t, email, claims["email"])
+
+	const deltaSeconds = 1
+	assert.InDelta(t, loginTime.Add(testTokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
+}
+
+func TestLogin_InvalidCredentials(t *testing.T) {
+	a, _ := newAuth(t)
+
+	_, email, pass := register(t, a)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{name: "wrong password", email: email, password: pass + "x"},
+		{name: "empty password", email: email, password: ""},
+		{name: "unknown email", email: gofakeit.Email(), password: pass},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := a.Login(context.Background(), tt.email, tt.password)
+			assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+			assert.Empty(t, token)
+		})
+	}
+}
+
+func TestLogin_LocksAccountAfterFailures(t *testing.T) {
+	const maxAttempts = 3
+
+	st := memory.New()
+	a, _ := newAuth(t, auth.WithLockout(st, auth.LockoutPolicy{
+		MaxAttempts:   maxAttempts,
+		IPMaxAttempts: 100,
+		BaseDelay:     time.Minute,
+		MaxDelay:      time.Hour,
+		Window:        time.Hour,
+	}))
+	ctx := clientinfo.WithInfo(context.Background(), clientinfo.Info{IP: "192.0.2.1"})
+
+	_, email, pass := register(t, a)
+
+	for i := 0; i < maxAttempts; i++ {
+		_, err := a.Login(ctx, email, "wrong")
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+
+	// locked even with the right password, and regardless of email case
+	_, err := a.Login(ctx, strings.ToUpper(email), pass)
+	assert.ErrorIs(t, err, auth.ErrAccountLocked)
+
+	require.NoError(t, a.UnlockUser(ctx, email))
+
+	_, err = a.Login(ctx, email, pass)
+	assert.NoError(t, err)
+}
+
+func TestLogin_ThrottlesClientIP(t *testing.T) {
+	const ipMaxAttempts = 2
+
+	st := memory.New()
+	a, _ := newAuth(t, auth.WithLockout(st, auth.LockoutPolicy{
+		MaxAttempts:   100,
+		IPMaxAttempts: ipMaxAttempts,
+		BaseDelay:     time.Minute,
+		MaxDelay:      time.Hour,
+		Window:        time.Hour,
+	}))
+	ctx := clientinfo.WithInfo(context.Background(), clientinfo.Info{IP: "192.0.2.2"})
+
+	_, email, pass := register(t, a)
+
+	for i := 0; i < ipMaxAttempts; i++ {
+		_, err := a.Login(ctx, gofakeit.Email(), "wrong")
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+
+	_, err := a.Login(ctx, email, pass)
+	assert.ErrorIs(t, err, auth.ErrTooManyAttempts)
+
+	other := clientinfo.WithInfo(context.Background(), clientinfo.Info{IP: "192.0.2.3"})
+	_, err = a.Login(other, email, pass)
+	assert.NoError(t, err)
+}
+
+func TestLogin_RehashesOutdatedPassword(t *testing.T) {
+	st := memory.New()
+	log := slog.New(slogdiscard.NewDiscardHandler())
+	ctx := context.Background()
+
+	old := auth.New(log, st, st, testTokenTTL, testSecret,
+		auth.WithPasswordHasher(hasher.New(fastBcrypt)))
+	email, pass := gofakeit.Email(), randomPassword()
+	id, err := old.RegisterNewUser(ctx, email, pass)
+	require.NoError(t, err)
+
+	upgraded := auth.New(log, st, st, testTokenTTL, testSecret,
+		auth.WithPasswordHasher(hasher.New(fastArgon2id, fastBcrypt)))
+	_, err = upgraded.Login(ctx, email, pass)
+	require.NoError(t, err)
+
+	user, err := st.UserById(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(user.PassHash), "$argon2id$"))
+
+	_, err = upgraded.Login(ctx, email, pass)
+	assert.NoError(t, err)
+}
+
+func TestValidateToken(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+
+	id, email, pass := register(t, a)
+	token, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+
+	uid, err := a.ValidateToken(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, id, uid)
+
+	sign := func(method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return s
+	}
+	valid := jwt.MapClaims{"uid": id.String(), "exp": time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "garbage", token: "not-a-token"},
+		{name: "empty", token: ""},
+		{name: "wrong secret", token: sign(jwt.SigningMethodHS256, []byte("other"), valid)},
+		{name: "other algorithm", token: sign(jwt.SigningMethodHS512, []byte(testSecret), valid)},
+		{name: "unsigned", token: sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid)},
+		{name: "expired", token: sign(jwt.SigningMethodHS256, []byte(testSecret), jwt.MapClaims{
+			"uid": id.String(), "exp": time.Now().Add(-time.Minute).Unix(),
+		})},
+		{name: "no uid", token: sign(jwt.SigningMethodHS256, []byte(testSecret), jwt.MapClaims{
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})},
+		{name: "malformed uid", token: sign(jwt.SigningMethodHS256, []byte(testSecret), jwt.MapClaims{
+			"uid": "42", "exp": time.Now().Add(time.Hour).Unix(),
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.ValidateToken(ctx, tt.token)
+			assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		})
+	}
+}
+
+func TestValidateToken_SigningKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	a, _ := newAuth(t, auth.WithSigningKey(priv))
+	ctx := context.Background()
+
+	id, email, pass := register(t, a)
+	token, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+
+	// resource servers need only the public key
+	claims, err := authz.ValidateWithKey(token, pub)
+	require.NoError(t, err)
+	assert.Equal(t, id, claims.UserID)
+	_, err = authz.Validate(token, testSecret)
+	assert.ErrorIs(t, err, authz.ErrInvalidToken)
+
+	uid, err := a.ValidateToken(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, id, uid)
+
+	// the secret no longer issues tokens
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid": id.String(), "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	_, err = a.ValidateToken(ctx, forged)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestPrincipal(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+
+	id, email, pass := register(t, a)
+	require.NoError(t, st.SetAdmin(ctx, id, true))
+
+	token, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+
+	principal, err := a.Principal(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, id, principal.UserID)
+	assert.True(t, principal.IsAdmin)
+
+	// token of a user which no longer exists
+	ghost := models.User{ID: uuid.New(), Email: gofakeit.Email()}
+	ghostToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid": ghost.ID.String(), "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	_, err = a.Principal(ctx, ghostToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestDisabledUser(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+
+	id, email, pass := register(t, a)
+	token, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+	_, key, err := a.CreateAPIKey(ctx, id, auth.NewAPIKey{Name: "k"})
+	require.NoError(t, err)
+
+	require.NoError(t, st.SetDisabled(ctx, id, true))
+
+	_, err = a.Login(ctx, email, pass)
+	assert.ErrorIs(t, err, auth.ErrUserDisabled)
+	_, err = a.Login(ctx, email, "wrong")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials, "only the owner of the password learns the user is disabled")
+
+	// tokens and keys issued before stop working
+	for _, token := range []string{token, key} {
+		_, err = a.Principal(ctx, token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		_, err = a.ValidateToken(ctx, token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	}
+
+	require.NoError(t, st.SetDisabled(ctx, id, false))
+	_, err = a.Principal(ctx, token)
+	assert.NoError(t, err)
+}
+
+func TestPrincipal_ThirdPartyApp(t *testing.T) {
+	a, st := newAuth(t)
+	saveTestApps(t, st)
+	ctx := context.Background()
+
+	id, email, pass := register(t, a)
+	require.NoError(t, st.SetAdmin(ctx, id, true))
+	require.NoError(t, a.GrantConsent(ctx, id, thirdPartyAppID, []string{authz.MapsRead}))
+
+	token, err := a.Login(withApp(firstPartyAppID), email, pass)
+	require.NoError(t, err)
+	principal, err := a.Principal(ctx, token)
+	require.NoError(t, err)
+	assert.True(t, principal.IsAdmin)
+	assert.False(t, principal.ThirdParty)
+
+	// an admin consenting to an app does not make the app an admin
+	token, err = a.Login(withApp(thirdPartyAppID), email, pass)
+	require.NoError(t, err)
+	principal, err = a.Principal(ctx, token)
+	require.NoError(t, err)
+	assert.False(t, principal.IsAdmin)
+	assert.True(t, principal.ThirdParty)
+	assert.Equal(t, []string{authz.MapsRead}, principal.Scopes)
+
+	// tokens of a deleted app
+	require.NoError(t, st.DeleteApp(ctx, thirdPartyAppID))
+	_, err = a.Principal(ctx, token)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestUserLookups_NotFound(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+
+	_, err := a.UserById(ctx, uuid.New())
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+
+	_, err = a.IsAdmin(ctx, uuid.New())
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+}
+
+func TestChangePassword(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+
+	id, email, pass := register(t, a)
+	newPass := randomPassword()
+
+	err := a.ChangePassword(ctx, id, "wrong", newPass)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	require.NoError(t, a.ChangePassword(ctx, id, pass, newPass))
+
+	_, err = a.Login(ctx, email, pass)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, err = a.Login(ctx, email, newPass)
+	assert.NoError(t, err)
+
+	err = a.ResetPassword(ctx, uuid.New(), newPass)
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+}
+
+func TestChangePassword_CountsFailedAttempts(t *testing.T) {
+	const maxAttempts = 3
+
+	// attempts kept in the same storage must survive the rolled back change
+	st := memory.New()
+	a := newAuthWithStorage(st, auth.WithLockout(st, auth.LockoutPolicy{
+		MaxAttempts:   maxAttempts,
+		IPMaxAttempts: 100,
+		BaseDelay:     time.Minute,
+		MaxDelay:      time.Hour,
+		Window:        time.Hour,
+	}))
+	ctx := clientinfo.WithInfo(context.Background(), clientinfo.Info{IP: "192.0.2.4"})
+
+	id, email, pass := register(t, a)
+
+	for i := 0; i < maxAttempts; i++ {
+		err := a.ChangePassword(ctx, id, "wrong", randomPassword())
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+
+	err := a.ChangePassword(ctx, id, pass, randomPassword())
+	assert.ErrorIs(t, err, auth.ErrAccountLocked)
+	_, err = a.Login(ctx, email, pass)
+	assert.ErrorIs(t, err, auth.ErrAccountLocked)
+
+	require.NoError(t, a.UnlockUser(ctx, email))
+	require.NoError(t, a.ChangePassword(ctx, id, pass, randomPassword()))
+}
+
+func TestUsers_Pagination(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+
+	const usersCount = 7
+	registered := make(map[uuid.UUID]bool)
+	for i := 0; i < usersCount; i++ {
+		id, _, _ := register(t, a)
+		registered[id] = true
+	}
+
+	for _, sort := range []string{models.UserSortCreatedAt, models.UserSortEmail} {
+		for _, desc := range []bool{false, true} {
+			query := models.UserQuery{Sort: sort, Desc: desc, Limit: 3}
+			seen := make(map[uuid.UUID]bool)
+			var prev *models.User
+
+			cursor := ""
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, usersCount, "pagination does not terminate")
+
+				page, err := a.Users(ctx, query, cursor)
+				require.NoError(t, err)
+				assert.Equal(t, usersCount, page.Total)
+				assert.LessOrEqual(t, len(page.Users), 3)
+
+				for _, u := range page.Users {
+					assert.False(t, seen[u.ID], "user returned twice")
+					seen[u.ID] = true
+					if prev != nil && sort == models.UserSortEmail {
+						assert.Equal(t, desc, u.Email < prev.Email)
+					}
+					u := u
+					prev = &u
+				}
+
+				if page.NextCursor == "" {
+					break
+				}
+				cursor = page.NextCursor
+			}
+
+			assert.Equal(t, registered, seen)
+		}
+	}
+}
+
+func TestUsers_InvalidCursor(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		register(t, a)
+	}
+
+	page, err := a.Users(ctx, models.UserQuery{Limit: 1}, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, page.NextCursor)
+
+	_, err = a.Users(ctx, models.UserQuery{Limit: 1}, "%%%")
+	assert.ErrorIs(t, err, auth.ErrInvalidCursor)
+
+	// cursor issued for another sort order
+	_, err = a.Users(ctx, models.UserQuery{Limit: 1, Sort: models.UserSortEmail}, page.NextCursor)
+	assert.ErrorIs(t, err, auth.ErrInvalidCursor)
+}
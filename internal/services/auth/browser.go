@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+)
+
+// cookieBytes is the length of random part of session cookies
+const cookieBytes = 32
+
+var (
+	ErrInvalidSession = errors.New("invalid session")
+
+	errBrowserSessionsDisabled = errors.New("browser sessions are disabled")
+)
+
+// BrowserPolicy limits browser sessions. A session ends after IdleTimeout
+// without requests and AbsoluteTimeout after login, whichever is first.
+type BrowserPolicy struct {
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+}
+
+// WithBrowserSessions enables single sign-on sessions kept in browser
+// cookies. It should be used together with WithSessions.
+func WithBrowserSessions(policy BrowserPolicy) Option {
+	return func(a *Auth) {
+		a.browser = policy
+	}
+}
+
+// BrowserLogin authenticates the user and starts a browser session. It
+// returns the session and value of its cookie; only hash of the value is
+// stored.
+func (a *Auth) BrowserLogin(
+	ctx context.Context,
+	email string,
+	password string,
+) (models.Session, string, error) {
+	const op = "auth.BrowserLogin"
+
+	if a.sessions == nil || a.browser.AbsoluteTimeout <= 0 {
+		return models.Session{}, "", fmt.Errorf("%s: %w", op, errBrowserSessionsDisabled)
+	}
+
+	user, err := a.authenticate(ctx, email, password)
+	if err != nil {
+		return models.Session{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	raw := make([]byte, cookieBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return models.Session{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	cookie := base64.RawURLEncoding.EncodeToString(raw)
+
+	session, err := a.startSession(ctx, user.ID, a.browser.AbsoluteTimeout, hashCookie(cookie))
+	if err != nil {
+		a.log.Error("failed to start browser session", slog.String("op", op), sl.Err(err))
+		return models.Session{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, cookie, nil
+}
+
+// BrowserSession returns the active session of cookie and prolongs it
+// until the idle timeout. It returns ErrInvalidSession if the session is
+// unknown, revoked or timed out, or its user is disabled.
+func (a *Auth) BrowserSession(ctx context.Context, cookie string) (models.Session, error) {
+	const op = "auth.BrowserSession"
+
+	if a.sessions == nil || cookie == "" {
+		return models.Session{}, fmt.Errorf("%s: %w", op, ErrInvalidSession)
+	}
+
+	session, err := a.sessions.SessionByCookie(ctx, hashCookie(cookie))
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, ErrInvalidSession)
+		}
+		a.log.Error("failed to get browser session", slog.String("op", op), sl.Err(err))
+
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	if !a.sessionActive(session, now) {
+		return models.Session{}, fmt.Errorf("%s: %w", op, ErrInvalidSession)
+	}
+	if _, err := a.activeUser(ctx, session.UserID); err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, ErrInvalidSession)
+		}
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+	a.touchSession(ctx, session, now)
+
+	return session, nil
+}
+
+// BrowserLogout revokes the session of cookie. Unknown and ended sessions
+// are ignored.
+func (a *Auth) BrowserLogout(ctx context.Context, cookie string) error {
+	const op = "auth.BrowserLogout"
+
+	session, err := a.BrowserSession(ctx, cookie)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSession) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.sessions.RevokeSession(ctx, session.ID, time.Now().UTC()); err != nil {
+		a.log.Error("failed to revoke browser session", slog.String("op", op), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := auditEvent(ctx, models.AuditLogout, nil)
+	event.ActorID = &session.UserID
+	event.SubjectID = &session.UserID
+	a.audit(ctx, event)
+
+	return nil
+}
+
+func hashCookie(cookie string) []byte {
+	sum := sha256.Sum256([]byte(cookie))
+	return sum[:]
+}
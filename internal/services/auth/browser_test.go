@@ -0,0 +1,131 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testBrowserPolicy = auth.BrowserPolicy{
+	IdleTimeout:     30 * time.Minute,
+	AbsoluteTimeout: 12 * time.Hour,
+}
+
+func TestBrowser_LoginAndLogout(t *testing.T) {
+	a, st := newAuth(t, auth.WithBrowserSessions(testBrowserPolicy))
+	ctx := context.Background()
+
+	userID, email, pass := register(t, a)
+	session, cookie, err := a.BrowserLogin(ctx, email, pass)
+	require.NoError(t, err)
+	require.NotEmpty(t, cookie)
+	assert.Equal(t, userID, session.UserID)
+	assert.True(t, session.IsBrowser())
+	assert.WithinDuration(t, session.CreatedAt.Add(testBrowserPolicy.AbsoluteTimeout), session.ExpiresAt, time.Second)
+
+	// only hash of the cookie is stored
+	stored, err := st.Session(ctx, session.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored.CookieHash), cookie)
+
+	got, err := a.BrowserSession(ctx, cookie)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, got.ID)
+
+	sessions, err := a.Sessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	require.NoError(t, a.BrowserLogout(ctx, cookie))
+	_, err = a.BrowserSession(ctx, cookie)
+	assert.ErrorIs(t, err, auth.ErrInvalidSession)
+
+	// logging out twice is fine
+	require.NoError(t, a.BrowserLogout(ctx, cookie))
+
+	events := allAuditEvents(t, a, models.AuditFilter{Type: models.AuditLogout})
+	require.Len(t, events, 1)
+	assert.Equal(t, &userID, events[0].SubjectID)
+}
+
+func TestBrowser_InvalidCredentials(t *testing.T) {
+	a, _ := newAuth(t, auth.WithBrowserSessions(testBrowserPolicy))
+	ctx := context.Background()
+
+	_, email, _ := register(t, a)
+	_, _, err := a.BrowserLogin(ctx, email, "wrong")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	_, err = a.BrowserSession(ctx, "")
+	assert.ErrorIs(t, err, auth.ErrInvalidSession)
+	_, err = a.BrowserSession(ctx, "unknown")
+	assert.ErrorIs(t, err, auth.ErrInvalidSession)
+}
+
+func TestBrowser_IdleTimeout(t *testing.T) {
+	a, st := newAuth(t, auth.WithBrowserSessions(testBrowserPolicy))
+	ctx := context.Background()
+
+	userID, email, pass := register(t, a)
+	session, cookie, err := a.BrowserLogin(ctx, email, pass)
+	require.NoError(t, err)
+
+	// activity within the idle timeout prolongs the session
+	require.NoError(t, st.TouchSession(ctx, session.ID, time.Now().Add(-20*time.Minute)))
+	_, err = a.BrowserSession(ctx, cookie)
+	require.NoError(t, err)
+
+	stored, err := st.Session(ctx, session.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), stored.LastSeenAt, time.Minute)
+
+	require.NoError(t, st.TouchSession(ctx, session.ID, time.Now().Add(-31*time.Minute)))
+	_, err = a.BrowserSession(ctx, cookie)
+	assert.ErrorIs(t, err, auth.ErrInvalidSession)
+
+	sessions, err := a.Sessions(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestBrowser_AbsoluteTimeout(t *testing.T) {
+	a, _ := newAuth(t, auth.WithBrowserSessions(auth.BrowserPolicy{
+		IdleTimeout:     time.Hour,
+		AbsoluteTimeout: time.Millisecond,
+	}))
+	ctx := context.Background()
+
+	_, email, pass := register(t, a)
+	_, cookie, err := a.BrowserLogin(ctx, email, pass)
+	require.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = a.BrowserSession(ctx, cookie)
+	assert.ErrorIs(t, err, auth.ErrInvalidSession)
+}
+
+func TestBrowser_RevokedSessionIsRejected(t *testing.T) {
+	a, _ := newAuth(t, auth.WithBrowserSessions(testBrowserPolicy))
+	ctx := context.Background()
+
+	userID, email, pass := register(t, a)
+	session, cookie, err := a.BrowserLogin(ctx, email, pass)
+	require.NoError(t, err)
+
+	require.NoError(t, a.RevokeSession(ctx, userID, session.ID))
+	_, err = a.BrowserSession(ctx, cookie)
+	assert.ErrorIs(t, err, auth.ErrInvalidSession)
+}
+
+func TestBrowser_Disabled(t *testing.T) {
+	a, _ := newAuth(t)
+
+	_, email, pass := register(t, a)
+	_, _, err := a.BrowserLogin(context.Background(), email, pass)
+	assert.Error(t, err)
+}
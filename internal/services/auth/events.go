@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/google/uuid"
+)
+
+// EventOutbox stores domain events for other services. Events are saved
+// in the transaction of the change they describe and published later by
+// outbox.Relay, so an event is sent if and only if the change is committed.
+type EventOutbox interface {
+	SaveEvent(ctx context.Context, event models.Event) error
+}
+
+// WithEventOutbox enables publishing of user lifecycle events. It should
+// be used together with WithTxManager.
+func WithEventOutbox(outbox EventOutbox) Option {
+	return func(a *Auth) {
+		a.outbox = outbox
+	}
+}
+
+// saveUserEvent adds event of type typ about the stored user to the
+// outbox. The app of ctx is recorded as the app the user came through.
+func (a *Auth) saveUserEvent(ctx context.Context, typ string, userID uuid.UUID) error {
+	if a.outbox == nil {
+		return nil
+	}
+
+	user, err := a.userProvider.UserById(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	payload, err := json.Marshal(models.UserEventPayload{
+		ID:       user.ID,
+		Email:    user.Email,
+		OrgID:    user.OrgID,
+		AppID:    clientinfo.FromContext(ctx).AppID,
+		Verified: user.Verified,
+		Disabled: user.Disabled,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal event payload: %w", err)
+	}
+
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      typ,
+		SubjectID: user.ID,
+		Payload:   payload,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := a.outbox.SaveEvent(ctx, event); err != nil {
+		return fmt.Errorf("save event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents_UserCreated(t *testing.T) {
+	st := memory.New()
+	a := newAuthWithStorage(st, auth.WithEventOutbox(st))
+
+	email := gofakeit.Email()
+	id, err := a.RegisterNewUser(context.Background(), email, randomPassword())
+	require.NoError(t, err)
+
+	// failed registrations leave no events
+	_, err = a.RegisterNewUser(context.Background(), email, randomPassword())
+	require.ErrorIs(t, err, auth.ErrUserExists)
+
+	events := st.OutboxEvents()
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventUserCreated, events[0].Type)
+	assert.Equal(t, id, events[0].SubjectID)
+	assert.Nil(t, events[0].PublishedAt)
+
+	var payload models.UserEventPayload
+	require.NoError(t, json.Unmarshal(events[0].Payload, &payload))
+	assert.Equal(t, id, payload.ID)
+	assert.Equal(t, email, payload.Email)
+}
+
+func TestEvents_RolledBackWithUser(t *testing.T) {
+	st := memory.New()
+	a := newAuthWithStorage(st, auth.WithEventOutbox(st), auth.WithAuditLog(failingAuditLog{st}))
+
+	_, err := a.RegisterNewUser(context.Background(), gofakeit.Email(), randomPassword())
+	require.Error(t, err)
+
+	assert.Empty(t, st.OutboxEvents())
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAppNotFound     = errors.New("app not found")
+	ErrGrantNotFound   = errors.New("grant not found")
+	ErrConsentRequired = errors.New("consent required")
+)
+
+// GrantStore keeps consents of users to apps
+type GrantStore interface {
+	// SaveGrant keeps creation time of the grant if it already exists
+	SaveGrant(ctx context.Context, grant models.Grant) error
+	Grant(ctx context.Context, userID uuid.UUID, appID int) (models.Grant, error)
+	Grants(ctx context.Context, userID uuid.UUID) ([]models.Grant, error)
+	DeleteGrant(ctx context.Context, userID uuid.UUID, appID int) error
+}
+
+// WithGrants makes logins to apps other than first-party ones require
+// consent of the user. It should be used together with WithApps.
+func WithGrants(store GrantStore) Option {
+	return func(a *Auth) {
+		a.grants = store
+	}
+}
+
+// ConsentRequired reports whether the user has to consent to scopes of
+// the app: apps other than first-party ones need a grant covering them
+func (a *Auth) ConsentRequired(
+	ctx context.Context,
+	userID uuid.UUID,
+	appID int,
+	scopes []string,
+) (bool, error) {
+	const op = "auth.ConsentRequired"
+
+	if a.grants == nil {
+		return false, nil
+	}
+
+	app, err := a.app(ctx, appID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAllowed(app, scopes); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	required, err := a.consentRequired(ctx, userID, app, scopes)
+	if err != nil {
+		a.log.Error("failed to get grant", slog.String("op", op), sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return required, nil
+}
+
+func (a *Auth) consentRequired(
+	ctx context.Context,
+	userID uuid.UUID,
+	app models.App,
+	scopes []string,
+) (bool, error) {
+	if app.FirstParty {
+		return false, nil
+	}
+
+	grant, err := a.grants.Grant(ctx, userID, app.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrGrantNotFound) {
+			return true, nil
+		}
+		return false, fmt.Errorf("get grant: %w", err)
+	}
+
+	return !grant.Covers(scopes), nil
+}
+
+// GrantConsent records consent of the user to scopes of the app. Scopes
+// granted before are kept.
+func (a *Auth) GrantConsent(
+	ctx context.Context,
+	userID uuid.UUID,
+	appID int,
+	scopes []string,
+) (err error) {
+	const op = "auth.GrantConsent"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+		slog.Int("app_id", appID),
+	)
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditGrantCreate, err)
+		event.SubjectID = &userID
+		event.AppID = appID
+		a.audit(ctx, event)
+	}()
+
+	if a.grants == nil {
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+	app, err := a.app(ctx, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := checkAllowed(app, scopes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now().UTC()
+	grant := models.Grant{
+		UserID:    userID,
+		AppID:     appID,
+		Scopes:    scopes,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = a.txManager.InTx(ctx, func(ctx context.Context) error {
+		old, err := a.grants.Grant(ctx, userID, appID)
+		switch {
+		case err == nil:
+			grant.Scopes = append(slices.Clone(old.Scopes), scopes...)
+			slices.Sort(grant.Scopes)
+			grant.Scopes = slices.Compact(grant.Scopes)
+		case !errors.Is(err, storage.ErrGrantNotFound):
+			return err
+		}
+
+		return a.grants.SaveGrant(ctx, grant)
+	})
+	if err != nil {
+		log.Error("failed to save grant", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("consent granted", slog.Any("scopes", grant.Scopes))
+
+	return nil
+}
+
+// Grants returns apps the user consented to
+func (a *Auth) Grants(ctx context.Context, userID uuid.UUID) ([]models.Grant, error) {
+	const op = "auth.Grants"
+
+	if a.grants == nil {
+		return nil, nil
+	}
+
+	grants, err := a.grants.Grants(ctx, userID)
+	if err != nil {
+		a.log.Error("failed to list grants", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return grants, nil
+}
+
+// RevokeGrant withdraws consent of the user to the app and revokes
+// sessions of the app, so tokens it holds are rejected from now on
+func (a *Auth) RevokeGrant(ctx context.Context, userID uuid.UUID, appID int) (err error) {
+	const op = "auth.RevokeGrant"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+		slog.Int("app_id", appID),
+	)
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditGrantRevoke, err)
+		event.SubjectID = &userID
+		event.AppID = appID
+		a.audit(ctx, event)
+	}()
+
+	if a.grants == nil {
+		return fmt.Errorf("%s: %w", op, ErrGrantNotFound)
+	}
+
+	err = a.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := a.grants.DeleteGrant(ctx, userID, appID); err != nil {
+			if errors.Is(err, storage.ErrGrantNotFound) {
+				return ErrGrantNotFound
+			}
+			return err
+		}
+
+		if a.sessions == nil {
+			return nil
+		}
+		return a.sessions.RevokeAppSessions(ctx, userID, appID, time.Now().UTC())
+	})
+	if err != nil {
+		if !errors.Is(err, ErrGrantNotFound) {
+			log.Error("failed to revoke grant", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("grant revoked")
+
+	return nil
+}
+
+func (a *Auth) app(ctx context.Context, appID int) (models.App, error) {
+	if a.appProvider == nil {
+		return models.App{}, ErrAppNotFound
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.App{}, ErrAppNotFound
+		}
+		return models.App{}, fmt.Errorf("get app: %w", err)
+	}
+
+	return app, nil
+}
@@ -0,0 +1,138 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	firstPartyAppID = 1
+	thirdPartyAppID = 2
+	unknownAppID    = 42
+)
+
+// saveTestApps saves a first-party and a third-party app allowed the
+// password grant
+func saveTestApps(t *testing.T, st *memory.Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	scopes := []string{authz.MapsRead, authz.MapsWrite, authz.LayersRead, authz.LayersWrite}
+	password := []string{models.GrantTypePassword}
+	require.NoError(t, st.SaveApp(ctx, models.App{
+		ID: firstPartyAppID, Name: "maps", FirstParty: true, GrantTypes: password, Scopes: scopes,
+	}))
+	require.NoError(t, st.SaveApp(ctx, models.App{
+		ID: thirdPartyAppID, Name: "partner", GrantTypes: password, Scopes: scopes,
+	}))
+}
+
+func withApp(appID int) context.Context {
+	return clientinfo.WithInfo(context.Background(), clientinfo.Info{AppID: appID})
+}
+
+func TestGrants_ConsentRequired(t *testing.T) {
+	a, st := newAuth(t)
+	saveTestApps(t, st)
+	ctx := context.Background()
+	userID, _, _ := register(t, a)
+
+	required, err := a.ConsentRequired(ctx, userID, firstPartyAppID, []string{"maps:read"})
+	require.NoError(t, err)
+	assert.False(t, required, "first-party apps need no consent")
+
+	required, err = a.ConsentRequired(ctx, userID, thirdPartyAppID, []string{"maps:read"})
+	require.NoError(t, err)
+	assert.True(t, required)
+
+	require.NoError(t, a.GrantConsent(ctx, userID, thirdPartyAppID, []string{"maps:read"}))
+
+	required, err = a.ConsentRequired(ctx, userID, thirdPartyAppID, []string{"maps:read"})
+	require.NoError(t, err)
+	assert.False(t, required, "consent is remembered")
+
+	required, err = a.ConsentRequired(ctx, userID, thirdPartyAppID, []string{"layers:write", "maps:read"})
+	require.NoError(t, err)
+	assert.True(t, required, "new scopes need consent")
+
+	require.NoError(t, a.GrantConsent(ctx, userID, thirdPartyAppID, []string{"layers:write"}))
+	grants, err := a.Grants(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, thirdPartyAppID, grants[0].AppID)
+	assert.Equal(t, []string{"layers:write", "maps:read"}, grants[0].Scopes, "scopes accumulate")
+
+	_, err = a.ConsentRequired(ctx, userID, thirdPartyAppID, []string{authz.TilesRender})
+	assert.ErrorIs(t, err, auth.ErrInvalidScope, "the app is not allowed the scope")
+	assert.ErrorIs(t, a.GrantConsent(ctx, userID, thirdPartyAppID, []string{authz.TilesRender}), auth.ErrInvalidScope)
+
+	_, err = a.ConsentRequired(ctx, userID, unknownAppID, nil)
+	assert.ErrorIs(t, err, auth.ErrAppNotFound)
+	assert.ErrorIs(t, a.GrantConsent(ctx, userID, unknownAppID, nil), auth.ErrAppNotFound)
+}
+
+func TestLogin_RequiresConsent(t *testing.T) {
+	a, st := newAuth(t)
+	saveTestApps(t, st)
+	userID, email, pass := register(t, a)
+
+	_, err := a.Login(withApp(thirdPartyAppID), email, pass)
+	assert.ErrorIs(t, err, auth.ErrConsentRequired)
+
+	_, err = a.Login(withApp(unknownAppID), email, pass)
+	assert.ErrorIs(t, err, auth.ErrAppNotFound)
+
+	_, err = a.Login(withApp(firstPartyAppID), email, pass)
+	assert.NoError(t, err)
+	_, err = a.Login(context.Background(), email, pass)
+	assert.NoError(t, err, "logins without app need no consent")
+
+	require.NoError(t, a.GrantConsent(context.Background(), userID, thirdPartyAppID, nil))
+	_, err = a.Login(withApp(thirdPartyAppID), email, pass)
+	assert.NoError(t, err)
+}
+
+func TestRevokeGrant_RevokesTokensOfApp(t *testing.T) {
+	a, st := newAuth(t)
+	saveTestApps(t, st)
+	ctx := context.Background()
+	userID, email, pass := register(t, a)
+	require.NoError(t, a.GrantConsent(ctx, userID, thirdPartyAppID, []string{"maps:read"}))
+
+	partnerToken, err := a.Login(withApp(thirdPartyAppID), email, pass)
+	require.NoError(t, err)
+	mapsToken, err := a.Login(withApp(firstPartyAppID), email, pass)
+	require.NoError(t, err)
+
+	require.NoError(t, a.RevokeGrant(ctx, userID, thirdPartyAppID))
+
+	_, err = a.ValidateToken(ctx, partnerToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	_, err = a.ValidateToken(ctx, mapsToken)
+	assert.NoError(t, err, "tokens of other apps are kept")
+
+	grants, err := a.Grants(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+
+	_, err = a.Login(withApp(thirdPartyAppID), email, pass)
+	assert.ErrorIs(t, err, auth.ErrConsentRequired)
+
+	assert.ErrorIs(t, a.RevokeGrant(ctx, userID, thirdPartyAppID), auth.ErrGrantNotFound)
+
+	revokes := allAuditEvents(t, a, models.AuditFilter{Type: models.AuditGrantRevoke, SubjectID: &userID})
+	require.Len(t, revokes, 2)
+	outcomes := []string{revokes[0].Outcome, revokes[1].Outcome}
+	assert.ElementsMatch(t, []string{models.AuditSuccess, models.AuditFailure}, outcomes)
+	for _, e := range revokes {
+		assert.Equal(t, thirdPartyAppID, e.AppID)
+	}
+}
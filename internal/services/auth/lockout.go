@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+)
+
+// AttemptStore keeps failed login counters. Implementations must update
+// counters atomically, so that several instances can share one store.
+type AttemptStore interface {
+	LoginAttempts(ctx context.Context, key string) (models.LoginAttempts, error)
+	// RegisterFailedLogin increments the counter for key. Counters whose last
+	// failure happened before since are restarted from one.
+	RegisterFailedLogin(ctx context.Context, key string, at time.Time, since time.Time) (models.LoginAttempts, error)
+	ResetLoginAttempts(ctx context.Context, key string) error
+}
+
+// LockoutPolicy describes when a key gets locked and for how long.
+// After MaxAttempts failures the key is locked for BaseDelay, and every
+// further failure doubles the delay up to MaxDelay. Failures older than
+// Window are forgotten.
+type LockoutPolicy struct {
+	MaxAttempts   int
+	IPMaxAttempts int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	Window        time.Duration
+}
+
+const (
+	emailKeyPrefix = "email:"
+	ipKeyPrefix    = "ip:"
+)
+
+// WithLockout enables brute-force protection of Login
+func WithLockout(store AttemptStore, policy LockoutPolicy) Option {
+	return func(a *Auth) {
+		a.attempts = store
+		a.lockout = policy
+	}
+}
+
+// lockedUntil returns the moment until which a key with given attempts
+// stays locked. Zero time means the key is not locked.
+func (p LockoutPolicy) lockedUntil(attempts models.LoginAttempts, maxAttempts int) time.Time {
+	if maxAttempts <= 0 || attempts.Failures < maxAttempts {
+		return time.Time{}
+	}
+
+	delay := p.BaseDelay
+	for i := maxAttempts; i < attempts.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return attempts.LastFailedAt.Add(delay)
+}
+
+type lockoutKey struct {
+	key         string
+	maxAttempts int
+	err         error
+}
+
+func (a *Auth) lockoutKeys(ctx context.Context, email string) []lockoutKey {
+	keys := []lockoutKey{{
+		key:         emailKeyPrefix + normalizeEmail(email),
+		maxAttempts: a.lockout.MaxAttempts,
+		err:         ErrAccountLocked,
+	}}
+
+	if ip := clientinfo.FromContext(ctx).IP; ip != "" {
+		keys = append(keys, lockoutKey{
+			key:         ipKeyPrefix + ip,
+			maxAttempts: a.lockout.IPMaxAttempts,
+			err:         ErrTooManyAttempts,
+		})
+	}
+
+	return keys
+}
+
+// checkLockout returns ErrAccountLocked or ErrTooManyAttempts if login
+// for the email from the current client must be rejected without checking
+// the password.
+func (a *Auth) checkLockout(ctx context.Context, email string) error {
+	if a.attempts == nil {
+		return nil
+	}
+
+	now := time.Now()
+	for _, k := range a.lockoutKeys(ctx, email) {
+		attempts, err := a.attempts.LoginAttempts(ctx, k.key)
+		if err != nil {
+			return err
+		}
+
+		if now.Sub(attempts.LastFailedAt) > a.lockout.Window {
+			continue
+		}
+		if now.Before(a.lockout.lockedUntil(attempts, k.maxAttempts)) {
+			return k.err
+		}
+	}
+
+	return nil
+}
+
+// registerFailedLogin counts a failed login for the email and the current client
+func (a *Auth) registerFailedLogin(ctx context.Context, email string) {
+	if a.attempts == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, k := range a.lockoutKeys(ctx, email) {
+		if _, err := a.attempts.RegisterFailedLogin(ctx, k.key, now, now.Add(-a.lockout.Window)); err != nil {
+			a.log.Error("failed to register failed login", slog.String("key", k.key), sl.Err(err))
+		}
+	}
+}
+
+// resetFailedLogins forgets failed logins of the email after a successful login
+func (a *Auth) resetFailedLogins(ctx context.Context, email string) {
+	if a.attempts == nil {
+		return
+	}
+
+	if err := a.attempts.ResetLoginAttempts(ctx, emailKeyPrefix+normalizeEmail(email)); err != nil {
+		a.log.Error("failed to reset login attempts", sl.Err(err))
+	}
+}
+
+// UnlockUser removes lockout of the account with given email
+func (a *Auth) UnlockUser(
+	ctx context.Context,
+	email string,
+) (err error) {
+	const op = "auth.UnlockUser"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+	log.Info("unlocking user")
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditUserUnlock, err)
+		event.Email = email
+		a.audit(ctx, event)
+	}()
+
+	if a.attempts == nil {
+		return nil
+	}
+
+	if err := a.attempts.ResetLoginAttempts(ctx, emailKeyPrefix+normalizeEmail(email)); err != nil {
+		log.Error("failed to unlock user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/hasher"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+// WithPasswordPolicy sets policy applied to every new password
+func WithPasswordPolicy(p PasswordPolicy) Option {
+	return func(a *Auth) {
+		a.policy = p
+	}
+}
+
+func (a *Auth) validatePassword(password string, email string) error {
+	if a.policy == nil {
+		return nil
+	}
+
+	return a.policy.Validate(password, email)
+}
+
+// ChangePassword sets new password of the user after checking the old one
+func (a *Auth) ChangePassword(
+	ctx context.Context,
+	userID uuid.UUID,
+	oldPassword string,
+	newPassword string,
+) (err error) {
+	const op = "auth.ChangePassword"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+	)
+	log.Info("changing password")
+
+	defer a.auditPasswordFailure(ctx, models.AuditPasswordChange, userID, &err)
+
+	// the old password is checked against the hash being replaced. Wrong
+	// old passwords count as failed logins, so a stolen token cannot be used
+	// to guess the password; they are registered after the rollback.
+	var (
+		event    models.AuditEvent
+		email    string
+		mismatch bool
+	)
+	err = a.txManager.InTx(ctx, func(ctx context.Context) error {
+		user, err := a.userProvider.UserById(ctx, userID)
+		if err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				log.Warn("user not found", sl.Err(err))
+				return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+			}
+			log.Error("failed to get user", sl.Err(err))
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		email = user.Email
+
+		if err := a.checkLockout(ctx, email); err != nil {
+			if errors.Is(err, ErrAccountLocked) || errors.Is(err, ErrTooManyAttempts) {
+				log.Warn("password change rejected", sl.Err(err))
+			} else {
+				log.Error("failed to check login attempts", sl.Err(err))
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := a.hasher.Compare(user.PassHash, oldPassword); err != nil {
+			if !errors.Is(err, hasher.ErrMismatch) {
+				log.Error("failed to compare password hash", sl.Err(err))
+			}
+			log.Info("invalid old password", sl.Err(err))
+			mismatch = true
+
+			return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+
+		if err := a.setPassword(ctx, log, op, user.ID, user.Email, newPassword); err != nil {
+			return err
+		}
+
+		event = auditEvent(ctx, models.AuditPasswordChange, nil)
+		event.SubjectID = &user.ID
+		event.Email = user.Email
+		return a.saveAuditEvent(ctx, event)
+	})
+	if err != nil {
+		if mismatch {
+			a.registerFailedLogin(ctx, email)
+		}
+		return err
+	}
+	a.resetFailedLogins(ctx, email)
+	a.sinkAuditEvent(ctx, event)
+
+	return nil
+}
+
+// ResetPassword sets new password of the user without checking the old one.
+// Callers are responsible for verifying that the reset is authorized.
+func (a *Auth) ResetPassword(
+	ctx context.Context,
+	userID uuid.UUID,
+	newPassword string,
+) (err error) {
+	const op = "auth.ResetPassword"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+	)
+	log.Info("resetting password")
+
+	defer a.auditPasswordFailure(ctx, models.AuditPasswordReset, userID, &err)
+
+	var event models.AuditEvent
+	err = a.txManager.InTx(ctx, func(ctx context.Context) error {
+		user, err := a.userProvider.UserById(ctx, userID)
+		if err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				log.Warn("user not found", sl.Err(err))
+				return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+			}
+			log.Error("failed to get user", sl.Err(err))
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := a.setPassword(ctx, log, op, user.ID, user.Email, newPassword); err != nil {
+			return err
+		}
+
+		event = auditEvent(ctx, models.AuditPasswordReset, nil)
+		event.SubjectID = &user.ID
+		event.Email = user.Email
+		return a.saveAuditEvent(ctx, event)
+	})
+	if err != nil {
+		return err
+	}
+	a.sinkAuditEvent(ctx, event)
+
+	return nil
+}
+
+// auditPasswordFailure records failed password change of userID if *err
+// is set. Successful changes are audited in their transaction.
+func (a *Auth) auditPasswordFailure(ctx context.Context, typ string, userID uuid.UUID, err *error) {
+	if *err == nil {
+		return
+	}
+
+	event := auditEvent(ctx, typ, *err)
+	event.SubjectID = &userID
+	a.audit(ctx, event)
+}
+
+func (a *Auth) setPassword(
+	ctx context.Context,
+	log *slog.Logger,
+	op string,
+	userID uuid.UUID,
+	email string,
+	password string,
+) error {
+	if err := a.validatePassword(password, email); err != nil {
+		log.Warn("password rejected by policy", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to hash password", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userSaver.UpdatePassHash(ctx, userID, passHash); err != nil {
+		log.Error("failed to save password", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
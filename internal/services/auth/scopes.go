@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidScope = errors.New("invalid scope")
+
+// ParseScopes parses space-separated scopes of scope parameter. Scopes
+// are returned sorted and without duplicates; unknown ones are rejected.
+func ParseScopes(scope string) ([]string, error) {
+	scopes := authz.ParseScope(scope)
+	for _, s := range scopes {
+		if _, ok := authz.Lookup(s); !ok {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidScope, s)
+		}
+	}
+
+	return scopes, nil
+}
+
+// ConsentScopes returns scopes the app asks the user to consent to with
+// scope parameter, every scope allowed for the app if it is empty
+func (a *Auth) ConsentScopes(ctx context.Context, appID int, scope string) ([]string, error) {
+	const op = "auth.ConsentScopes"
+
+	scopes, err := ParseScopes(scope)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.app(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(scopes) == 0 {
+		return allowedScopes(app), nil
+	}
+	if err := checkAllowed(app, scopes); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return scopes, nil
+}
+
+// loginApp returns the app of ctx users sign in to with password, the
+// zero app if there is none. Apps not allowed the password grant get
+// ErrUnauthorizedClient.
+func (a *Auth) loginApp(ctx context.Context) (models.App, error) {
+	appID := clientinfo.FromContext(ctx).AppID
+	if appID == 0 || a.appProvider == nil {
+		return models.App{}, nil
+	}
+
+	app, err := a.app(ctx, appID)
+	if err != nil {
+		return models.App{}, err
+	}
+	if !slices.Contains(app.GrantTypes, models.GrantTypePassword) {
+		return models.App{}, ErrUnauthorizedClient
+	}
+
+	return app, nil
+}
+
+// tokenScopes negotiates scopes of a token of the user for app. Requested
+// scopes the app is not allowed are dropped, and with none requested the
+// token gets every allowed scope. Tokens for third-party apps carry only
+// scopes the user consented to: requesting others fails with
+// ErrConsentRequired. Tokens issued without app have no scopes.
+func (a *Auth) tokenScopes(ctx context.Context, userID uuid.UUID, app models.App, requested []string) ([]string, error) {
+	if app.ID == 0 {
+		if len(requested) > 0 {
+			return nil, fmt.Errorf("%w: scopes are issued to apps only", ErrInvalidScope)
+		}
+		return nil, nil
+	}
+
+	allowed := allowedScopes(app)
+	scopes := allowed
+	if requested != nil {
+		scopes = intersect(requested, allowed)
+	}
+
+	if a.grants == nil || app.FirstParty {
+		return scopes, nil
+	}
+
+	grant, err := a.grants.Grant(ctx, userID, app.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrGrantNotFound) {
+			return nil, ErrConsentRequired
+		}
+		return nil, fmt.Errorf("get grant: %w", err)
+	}
+	if requested == nil {
+		return intersect(scopes, grant.Scopes), nil
+	}
+	if !grant.Covers(scopes) {
+		return nil, ErrConsentRequired
+	}
+
+	return scopes, nil
+}
+
+// allowedScopes returns known scopes allowed for the app
+func allowedScopes(app models.App) []string {
+	scopes := make([]string, 0, len(app.Scopes))
+	for _, s := range app.Scopes {
+		if _, ok := authz.Lookup(s); ok {
+			scopes = append(scopes, s)
+		}
+	}
+	slices.Sort(scopes)
+
+	return slices.Compact(scopes)
+}
+
+// checkAllowed returns ErrInvalidScope if the app may not get any of
+// scopes
+func checkAllowed(app models.App, scopes []string) error {
+	allowed := allowedScopes(app)
+	for _, s := range scopes {
+		if !slices.Contains(allowed, s) {
+			return fmt.Errorf("%w: %q is not allowed for the app", ErrInvalidScope, s)
+		}
+	}
+
+	return nil
+}
+
+// intersect returns scopes of a found in b
+func intersect(a []string, b []string) []string {
+	scopes := make([]string, 0, len(a))
+	for _, s := range a {
+		if slices.Contains(b, s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
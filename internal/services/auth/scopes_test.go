@@ -0,0 +1,127 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", scope: "", want: []string{}},
+		{name: "sorted and deduplicated", scope: " tiles:render maps:read  tiles:render ", want: []string{"maps:read", "tiles:render"}},
+		{name: "unknown", scope: "layers:styles:write", wantErr: true},
+		{name: "upper case", scope: "Maps:read", wantErr: true},
+		{name: "markup", scope: "<b>", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := auth.ParseScopes(tt.scope)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, auth.ErrInvalidScope)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoginWithScopes(t *testing.T) {
+	a, st := newAuth(t)
+	saveTestApps(t, st)
+	userID, email, pass := register(t, a)
+	require.NoError(t, a.GrantConsent(context.Background(), userID, thirdPartyAppID, []string{authz.MapsRead}))
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		requested []string
+		want      []string
+		wantErr   error
+	}{
+		{
+			name: "first-party app gets every allowed scope by default",
+			ctx:  withApp(firstPartyAppID),
+			want: []string{authz.LayersRead, authz.LayersWrite, authz.MapsRead, authz.MapsWrite},
+		},
+		{
+			name:      "scopes not allowed for the app are dropped",
+			ctx:       withApp(firstPartyAppID),
+			requested: []string{authz.MapsRead, authz.TilesRender},
+			want:      []string{authz.MapsRead},
+		},
+		{
+			name: "third-party app gets granted scopes by default",
+			ctx:  withApp(thirdPartyAppID),
+			want: []string{authz.MapsRead},
+		},
+		{
+			name:      "third-party app needs consent to other scopes",
+			ctx:       withApp(thirdPartyAppID),
+			requested: []string{authz.MapsWrite},
+			wantErr:   auth.ErrConsentRequired,
+		},
+		{
+			name: "no app, no scopes",
+			ctx:  context.Background(),
+		},
+		{
+			name:      "scopes without app",
+			ctx:       context.Background(),
+			requested: []string{authz.MapsRead},
+			wantErr:   auth.ErrInvalidScope,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, granted, err := a.LoginWithScopes(tt.ctx, email, pass, tt.requested)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, granted)
+
+			principal, err := a.Principal(context.Background(), token)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, principal.Scopes)
+
+			claims, err := authz.Validate(token, testSecret)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, claims.Scopes)
+			assert.Equal(t, userID, claims.UserID)
+		})
+	}
+}
+
+func TestConsentScopes(t *testing.T) {
+	a, st := newAuth(t)
+	saveTestApps(t, st)
+	ctx := context.Background()
+
+	scopes, err := a.ConsentScopes(ctx, thirdPartyAppID, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{authz.LayersRead, authz.LayersWrite, authz.MapsRead, authz.MapsWrite}, scopes,
+		"apps ask for every allowed scope by default")
+
+	scopes, err = a.ConsentScopes(ctx, thirdPartyAppID, "maps:write maps:read")
+	require.NoError(t, err)
+	assert.Equal(t, []string{authz.MapsRead, authz.MapsWrite}, scopes)
+
+	_, err = a.ConsentScopes(ctx, thirdPartyAppID, authz.TilesRender)
+	assert.ErrorIs(t, err, auth.ErrInvalidScope)
+	_, err = a.ConsentScopes(ctx, thirdPartyAppID, "maps:delete")
+	assert.ErrorIs(t, err, auth.ErrInvalidScope)
+	_, err = a.ConsentScopes(ctx, unknownAppID, "")
+	assert.ErrorIs(t, err, auth.ErrAppNotFound)
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+// sessionTouchInterval limits how often last seen time of a session is
+// saved, so that every request does not write to the database
+const sessionTouchInterval = time.Minute
+
+// SessionStore keeps sessions of users
+type SessionStore interface {
+	SaveSession(ctx context.Context, session models.Session) error
+	Session(ctx context.Context, id uuid.UUID) (models.Session, error)
+	SessionByCookie(ctx context.Context, cookieHash []byte) (models.Session, error)
+	// Sessions returns sessions of the user active at now, most recently
+	// seen first
+	Sessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error)
+	TouchSession(ctx context.Context, id uuid.UUID, at time.Time) error
+	// RevokeSession keeps the time of the first revocation if the session
+	// is already revoked
+	RevokeSession(ctx context.Context, id uuid.UUID, at time.Time) error
+	// RevokeAppSessions revokes active sessions of tokens the user
+	// signed in to the app with, browser sessions are kept
+	RevokeAppSessions(ctx context.Context, userID uuid.UUID, appID int, at time.Time) error
+	// RevokeAllAppSessions is RevokeAppSessions for every user
+	RevokeAllAppSessions(ctx context.Context, appID int, at time.Time) error
+}
+
+// WithSessions makes every login start a session. Tokens of revoked
+// sessions are rejected.
+func WithSessions(store SessionStore) Option {
+	return func(a *Auth) {
+		a.sessions = store
+	}
+}
+
+// startSession records login of user from the client of ctx. The session
+// lasts for ttl; browser sessions are found by hash of their cookie.
+func (a *Auth) startSession(
+	ctx context.Context,
+	userID uuid.UUID,
+	ttl time.Duration,
+	cookieHash []byte,
+) (models.Session, error) {
+	info := clientinfo.FromContext(ctx)
+	now := time.Now().UTC()
+	session := models.Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		AppID:      info.AppID,
+		Device:     clientinfo.Device(info.UserAgent),
+		UserAgent:  info.UserAgent,
+		IP:         info.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+		CookieHash: cookieHash,
+	}
+	if err := a.sessions.SaveSession(ctx, session); err != nil {
+		return models.Session{}, fmt.Errorf("save session: %w", err)
+	}
+
+	return session, nil
+}
+
+// sessionActive reports whether session is accepted at now. Browser
+// sessions also end after the idle timeout.
+func (a *Auth) sessionActive(session models.Session, now time.Time) bool {
+	if !session.Active(now) {
+		return false
+	}
+	if session.IsBrowser() && a.browser.IdleTimeout > 0 {
+		return now.Sub(session.LastSeenAt) < a.browser.IdleTimeout
+	}
+
+	return true
+}
+
+// touchSession saves now as last seen time of session unless it was saved
+// recently. Failures are only logged.
+func (a *Auth) touchSession(ctx context.Context, session models.Session, now time.Time) {
+	if now.Sub(session.LastSeenAt) < sessionTouchInterval {
+		return
+	}
+
+	if err := a.sessions.TouchSession(ctx, session.ID, now.UTC()); err != nil {
+		a.log.Error("failed to touch session", slog.String("session_id", session.ID.String()), sl.Err(err))
+	}
+}
+
+// checkSession returns ErrInvalidToken if session sessionID of the token
+// owner userID was revoked. Tokens issued without a session are accepted
+// until they expire.
+func (a *Auth) checkSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error {
+	if a.sessions == nil || sessionID == uuid.Nil {
+		return nil
+	}
+
+	session, err := a.sessions.Session(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return fmt.Errorf("session not found: %w", ErrInvalidToken)
+		}
+		return err
+	}
+
+	now := time.Now()
+	if session.UserID != userID || !a.sessionActive(session, now) {
+		return fmt.Errorf("session is not active: %w", ErrInvalidToken)
+	}
+	a.touchSession(ctx, session, now)
+
+	return nil
+}
+
+// Sessions returns active sessions of the user, most recently seen first
+func (a *Auth) Sessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	const op = "auth.Sessions"
+
+	if a.sessions == nil {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	sessions, err := a.sessions.Sessions(ctx, userID, now)
+	if err != nil {
+		a.log.Error("failed to list sessions", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return slices.DeleteFunc(sessions, func(s models.Session) bool {
+		return !a.sessionActive(s, now)
+	}), nil
+}
+
+// RevokeSession signs the user out of session sessionID. Tokens of the
+// session are rejected from now on. Sessions of other users are not found.
+func (a *Auth) RevokeSession(
+	ctx context.Context,
+	userID uuid.UUID,
+	sessionID uuid.UUID,
+) (err error) {
+	const op = "auth.RevokeSession"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+		slog.String("session_id", sessionID.String()),
+	)
+
+	defer func() {
+		event := auditEvent(ctx, models.AuditSessionRevoke, err)
+		event.SubjectID = &userID
+		a.audit(ctx, event)
+	}()
+
+	if a.sessions == nil {
+		return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+	}
+
+	session, err := a.sessions.Session(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+		log.Error("failed to get session", sl.Err(err))
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if session.UserID != userID {
+		return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+	}
+
+	if err := a.sessions.RevokeSession(ctx, sessionID, time.Now().UTC()); err != nil {
+		log.Error("failed to revoke session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session revoked")
+
+	return nil
+}
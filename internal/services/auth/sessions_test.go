@@ -0,0 +1,147 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	jwt_lib "github.com/babs-corp/babs-maps-auth/internal/lib/jwt"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+
+func TestSessions_LoginStartsSession(t *testing.T) {
+	a, st := newAuth(t)
+	saveTestApps(t, st)
+	ctx := clientinfo.WithInfo(context.Background(), clientinfo.Info{
+		IP:        "192.0.2.1",
+		UserAgent: testUserAgent,
+		AppID:     firstPartyAppID,
+	})
+
+	userID, email, pass := register(t, a)
+	token, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+
+	principal, err := a.Principal(ctx, token)
+	require.NoError(t, err)
+	require.NotEqual(t, uuid.Nil, principal.SessionID)
+
+	sessions, err := a.Sessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	s := sessions[0]
+	assert.Equal(t, principal.SessionID, s.ID)
+	assert.Equal(t, userID, s.UserID)
+	assert.Equal(t, firstPartyAppID, s.AppID)
+	assert.Equal(t, "Firefox on Linux", s.Device)
+	assert.Equal(t, testUserAgent, s.UserAgent)
+	assert.Equal(t, "192.0.2.1", s.IP)
+	assert.WithinDuration(t, s.CreatedAt.Add(testTokenTTL), s.ExpiresAt, time.Second)
+	assert.Nil(t, s.RevokedAt)
+}
+
+func TestSessions_RevokedTokenIsRejected(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+
+	userID, email, pass := register(t, a)
+	phone, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+	laptop, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+
+	principal, err := a.Principal(ctx, phone)
+	require.NoError(t, err)
+
+	require.NoError(t, a.RevokeSession(ctx, userID, principal.SessionID))
+	// revoking twice is fine
+	require.NoError(t, a.RevokeSession(ctx, userID, principal.SessionID))
+
+	_, err = a.ValidateToken(ctx, phone)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	_, err = a.Principal(ctx, phone)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	_, err = a.ValidateToken(ctx, laptop)
+	assert.NoError(t, err)
+
+	sessions, err := a.Sessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.NotEqual(t, principal.SessionID, sessions[0].ID)
+
+	events := allAuditEvents(t, a, models.AuditFilter{Type: models.AuditSessionRevoke})
+	require.Len(t, events, 2)
+	assert.Equal(t, &userID, events[0].SubjectID)
+}
+
+func TestSessions_OtherUsersSessionIsNotFound(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+
+	_, email, pass := register(t, a)
+	token, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+	principal, err := a.Principal(ctx, token)
+	require.NoError(t, err)
+
+	otherID, _, _ := register(t, a)
+	err = a.RevokeSession(ctx, otherID, principal.SessionID)
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+
+	err = a.RevokeSession(ctx, principal.UserID, uuid.New())
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+
+	_, err = a.ValidateToken(ctx, token)
+	assert.NoError(t, err)
+}
+
+func TestSessions_ValidationTouchesSession(t *testing.T) {
+	a, st := newAuth(t)
+	ctx := context.Background()
+
+	_, email, pass := register(t, a)
+	token, err := a.Login(ctx, email, pass)
+	require.NoError(t, err)
+	principal, err := a.Principal(ctx, token)
+	require.NoError(t, err)
+
+	stale := time.Now().Add(-time.Hour).UTC()
+	require.NoError(t, st.TouchSession(ctx, principal.SessionID, stale))
+
+	_, err = a.ValidateToken(ctx, token)
+	require.NoError(t, err)
+
+	s, err := st.Session(ctx, principal.SessionID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), s.LastSeenAt, time.Minute)
+}
+
+func TestSessions_TokenWithoutSessionIsAccepted(t *testing.T) {
+	a, _ := newAuth(t)
+	ctx := context.Background()
+
+	userID, email, _ := register(t, a)
+	key := jwt_lib.SigningKey{Secret: testSecret}
+	token, err := jwt_lib.NewToken(models.User{ID: userID, Email: email}, uuid.Nil, 0, nil, key, time.Minute)
+	require.NoError(t, err)
+
+	principal, err := a.Principal(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, userID, principal.UserID)
+	assert.Equal(t, uuid.Nil, principal.SessionID)
+
+	// a token of a session which does not exist is forged or was purged
+	token, err = jwt_lib.NewToken(models.User{ID: userID, Email: email}, uuid.New(), 0, nil, key, time.Minute)
+	require.NoError(t, err)
+	_, err = a.ValidateToken(ctx, token)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
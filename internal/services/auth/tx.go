@@ -0,0 +1,24 @@
+package auth
+
+import "context"
+
+// TxManager runs several storage calls atomically. Storage methods called
+// with the ctx passed to fn take part in the transaction.
+type TxManager interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WithTxManager makes multi-step flows atomic. Without it every storage
+// call is committed on its own.
+func WithTxManager(tm TxManager) Option {
+	return func(a *Auth) {
+		a.txManager = tm
+	}
+}
+
+// noTx runs fn without a transaction
+type noTx struct{}
+
+func (noTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/paging"
+)
+
+const (
+	defaultUsersLimit = 20
+	maxUsersLimit     = 100
+)
+
+// Users returns one page of users matching query. cursor is NextCursor
+// of the previous page, or empty for the first page.
+func (a *Auth) Users(
+	ctx context.Context,
+	query models.UserQuery,
+	cursor string,
+) (models.UserPage, error) {
+	const op = "auth.Users"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultUsersLimit
+	case query.Limit > maxUsersLimit:
+		query.Limit = maxUsersLimit
+	}
+	if query.Sort == "" {
+		query.Sort = models.UserSortCreatedAt
+	}
+
+	if cursor != "" {
+		after, err := paging.DecodeCursor[models.UserCursor](cursor)
+		if err != nil || after.Sort != query.Sort || after.Desc != query.Desc {
+			return models.UserPage{}, fmt.Errorf("%s: %w", op, ErrInvalidCursor)
+		}
+		query.After = &after
+	}
+
+	users, err := a.userProvider.ListUsers(ctx, query)
+	if err != nil {
+		log.Error("failed to list users", sl.Err(err))
+		return models.UserPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	total, err := a.userProvider.CountUsers(ctx, query.Filter)
+	if err != nil {
+		log.Error("failed to count users", sl.Err(err))
+		return models.UserPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.UserPage{
+		Users: users,
+		Total: total,
+	}
+	if uint(len(users)) > query.Limit {
+		page.Users = users[:query.Limit]
+		last := page.Users[len(page.Users)-1]
+		page.NextCursor = paging.EncodeCursor(models.UserCursor{
+			Sort:      query.Sort,
+			Desc:      query.Desc,
+			CreatedAt: last.CreatedAt,
+			Email:     last.Email,
+			ID:        last.ID,
+		})
+	}
+
+	return page, nil
+}
@@ -0,0 +1,8 @@
+package outbox
+
+import "time"
+
+// SetNow replaces the clock of r in tests
+func (r *Relay) SetNow(now func() time.Time) {
+	r.now = now
+}
@@ -0,0 +1,31 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+type fanout []EventPublisher
+
+// Fanout returns publisher sending every event to all of publishers. An
+// event is acknowledged once all of them accept it; if one fails the
+// event is retried for all, so the others may see it more than once.
+func Fanout(publishers ...EventPublisher) EventPublisher {
+	if len(publishers) == 1 {
+		return publishers[0]
+	}
+	return fanout(publishers)
+}
+
+func (f fanout) Publish(ctx context.Context, event models.Event) error {
+	var errs []error
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,173 @@
+// Package outbox publishes domain events stored in the transactional
+// outbox. Events are written by services in the transaction of the change
+// they describe, and Relay delivers them at least once: consumers must
+// tolerate duplicates, e.g. by remembering event IDs.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/worker"
+	"github.com/google/uuid"
+)
+
+// purgeInterval is how often published events past retention are deleted
+const purgeInterval = time.Hour
+
+// EventPublisher delivers events to consumers. Publish returns nil only
+// once the event is accepted by the broker or the receiver.
+type EventPublisher interface {
+	Publish(ctx context.Context, event models.Event) error
+}
+
+// Store keeps events until they are published
+type Store interface {
+	// ClaimEvents returns up to limit unpublished events due at now, oldest
+	// first, and postpones their next attempt to leaseUntil so that other
+	// relays skip them. Events of a relay which died are claimed again
+	// once the lease expires.
+	ClaimEvents(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error)
+	MarkEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error
+	MarkEventFailed(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, reason string) error
+	// DeletePublishedEvents removes events published before t
+	DeletePublishedEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Policy configures Relay. BaseDelay is doubled on every failed attempt
+// up to MaxDelay. Published events are kept for Retention, zero keeps
+// them forever.
+type Policy struct {
+	BatchSize    int
+	PollInterval time.Duration
+	Lease        time.Duration
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	Retention    time.Duration
+}
+
+// Relay moves events from the outbox to the publisher
+type Relay struct {
+	log       *slog.Logger
+	store     Store
+	publisher EventPublisher
+	policy    Policy
+	now       func() time.Time
+}
+
+func New(log *slog.Logger, store Store, publisher EventPublisher, policy Policy) *Relay {
+	return &Relay{
+		log:       log,
+		store:     store,
+		publisher: publisher,
+		policy:    policy,
+		now:       time.Now,
+	}
+}
+
+// Run publishes events until ctx is done. Full batches are followed by
+// the next one right away, otherwise the outbox is polled every
+// PollInterval.
+func (r *Relay) Run(ctx context.Context) {
+	const op = "outbox.Run"
+
+	log := r.log.With(
+		slog.String("op", op),
+	)
+	log.Info("starting outbox relay")
+
+	var lastPurge time.Time
+	worker.Poll(ctx, r.policy.PollInterval, r.policy.BatchSize, func(ctx context.Context) (int, error) {
+		n, err := r.RelayOnce(ctx)
+		if err != nil {
+			log.Error("failed to relay events", sl.Err(err))
+		}
+
+		if r.policy.Retention > 0 && r.now().Sub(lastPurge) >= purgeInterval {
+			r.purge(ctx)
+			lastPurge = r.now()
+		}
+
+		return n, err
+	})
+
+	log.Info("outbox relay stopped")
+}
+
+// RelayOnce publishes one batch of due events and returns the number of
+// events claimed. Failures of single events are recorded with them.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	const op = "outbox.RelayOnce"
+
+	log := r.log.With(
+		slog.String("op", op),
+	)
+
+	now := r.now()
+	leaseUntil := now.Add(r.policy.Lease)
+	events, err := r.store.ClaimEvents(ctx, now, leaseUntil, r.policy.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// storages do not promise the order of claimed events
+	slices.SortFunc(events, func(a, b models.OutboxEvent) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	for _, event := range events {
+		if ctx.Err() != nil || !r.now().Before(leaseUntil) {
+			// the rest is claimed again after the lease, publishing it
+			// now could race with another relay
+			return len(events), nil
+		}
+
+		log := log.With(
+			slog.String("event_id", event.ID.String()),
+			slog.String("type", event.Type),
+		)
+
+		if err := r.publisher.Publish(ctx, event.Event); err != nil {
+			next := r.now().Add(worker.Backoff(r.policy.BaseDelay, r.policy.MaxDelay, event.Attempts))
+			log.Warn("failed to publish event",
+				slog.Int("attempts", event.Attempts+1),
+				slog.Time("next_attempt_at", next),
+				sl.Err(err),
+			)
+
+			if err := r.store.MarkEventFailed(ctx, event.ID, next, worker.ErrorText(err)); err != nil {
+				log.Error("failed to mark event failed", sl.Err(err))
+			}
+			continue
+		}
+
+		if err := r.store.MarkEventPublished(ctx, event.ID, r.now()); err != nil {
+			// the event is published again after the lease
+			log.Error("failed to mark event published", sl.Err(err))
+		}
+	}
+
+	return len(events), nil
+}
+
+func (r *Relay) purge(ctx context.Context) {
+	const op = "outbox.purge"
+
+	log := r.log.With(
+		slog.String("op", op),
+	)
+
+	n, err := r.store.DeletePublishedEvents(ctx, r.now().Add(-r.policy.Retention))
+	if err != nil {
+		log.Error("failed to delete published events", sl.Err(err))
+		return
+	}
+	if n > 0 {
+		log.Info("deleted published events", slog.Int64("count", n))
+	}
+}
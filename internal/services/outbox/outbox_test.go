@@ -0,0 +1,172 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/slogdiscard"
+	"github.com/babs-corp/babs-maps-auth/internal/services/outbox"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = outbox.Policy{
+	BatchSize:    10,
+	PollInterval: time.Millisecond,
+	Lease:        time.Minute,
+	BaseDelay:    time.Second,
+	MaxDelay:     4 * time.Second,
+	Retention:    time.Hour,
+}
+
+// fakePublisher records published events and fails while err is set
+type fakePublisher struct {
+	mu        sync.Mutex
+	err       error
+	published []models.Event
+}
+
+func (p *fakePublisher) Publish(_ context.Context, event models.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func (p *fakePublisher) setErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *fakePublisher) events() []models.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]models.Event(nil), p.published...)
+}
+
+func newTestRelay(t *testing.T) (*outbox.Relay, *memory.Storage, *fakePublisher, *time.Time) {
+	t.Helper()
+
+	st := memory.New()
+	pub := &fakePublisher{}
+	log := slog.New(slogdiscard.NewDiscardHandler())
+
+	r := outbox.New(log, st, pub, testPolicy)
+	now := time.Now()
+	r.SetNow(func() time.Time { return now })
+
+	return r, st, pub, &now
+}
+
+func saveEvent(t *testing.T, st *memory.Storage, createdAt time.Time) models.Event {
+	t.Helper()
+
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventUserCreated,
+		SubjectID: uuid.New(),
+		Payload:   json.RawMessage(`{}`),
+		CreatedAt: createdAt,
+	}
+	require.NoError(t, st.SaveEvent(context.Background(), event))
+
+	return event
+}
+
+func TestRelay_PublishesInOrder(t *testing.T) {
+	r, st, pub, now := newTestRelay(t)
+	ctx := context.Background()
+
+	first := saveEvent(t, st, now.Add(-time.Second))
+	second := saveEvent(t, st, *now)
+
+	n, err := r.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	published := pub.events()
+	require.Len(t, published, 2)
+	assert.Equal(t, first.ID, published[0].ID)
+	assert.Equal(t, second.ID, published[1].ID)
+
+	// published events are not sent again
+	n, err = r.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	for _, e := range st.OutboxEvents() {
+		assert.NotNil(t, e.PublishedAt)
+	}
+}
+
+func TestRelay_RetriesWithBackoff(t *testing.T) {
+	r, st, pub, now := newTestRelay(t)
+	ctx := context.Background()
+
+	event := saveEvent(t, st, *now)
+	pub.setErr(errors.New("broker is down"))
+
+	// BaseDelay doubles up to MaxDelay
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		n, err := r.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+
+		events := st.OutboxEvents()
+		require.Len(t, events, 1)
+		assert.Equal(t, now.Add(delay), events[0].NextAttemptAt)
+		assert.Equal(t, "broker is down", events[0].LastError)
+
+		// not due before the delay passes
+		n, err = r.RelayOnce(ctx)
+		require.NoError(t, err)
+		require.Zero(t, n)
+
+		*now = now.Add(delay)
+	}
+	assert.Equal(t, 4, st.OutboxEvents()[0].Attempts)
+
+	pub.setErr(nil)
+	_, err := r.RelayOnce(ctx)
+	require.NoError(t, err)
+
+	published := pub.events()
+	require.Len(t, published, 1)
+	assert.Equal(t, event.ID, published[0].ID)
+}
+
+func TestRelay_Run(t *testing.T) {
+	st := memory.New()
+	pub := &fakePublisher{}
+	log := slog.New(slogdiscard.NewDiscardHandler())
+	r := outbox.New(log, st, pub, testPolicy)
+
+	// more than one batch
+	for i := 0; i < 25; i++ {
+		saveEvent(t, st, time.Now())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool { return len(pub.events()) == 25 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,371 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Subjects returns subjects requests of principal are charged to
+func Subjects(p models.Principal) []models.QuotaSubject {
+	var subjects []models.QuotaSubject
+	if p.AppID != 0 {
+		subjects = append(subjects, models.QuotaSubject{Type: models.QuotaSubjectApp, ID: strconv.Itoa(p.AppID)})
+	}
+	if p.OrgID != nil {
+		subjects = append(subjects, models.QuotaSubject{Type: models.QuotaSubjectOrg, ID: p.OrgID.String()})
+	}
+	if p.APIKeyID != uuid.Nil {
+		subjects = append(subjects, models.QuotaSubject{Type: models.QuotaSubjectAPIKey, ID: p.APIKeyID.String()})
+	}
+
+	return subjects
+}
+
+// Check resolves token to its caller and charges a request of cost to
+// its subjects if their budgets allow it. Invalid tokens are errors of
+// the Introspector.
+func (s *Service) Check(
+	ctx context.Context,
+	token string,
+	cost int64,
+) (models.Principal, models.QuotaDecision, error) {
+	const op = "quota.Check"
+
+	if cost < 1 || cost > MaxCost {
+		return models.Principal{}, models.QuotaDecision{}, fmt.Errorf("%s: %w", op,
+			&ValidationError{Reason: fmt.Sprintf("cost must be between 1 and %d", MaxCost)})
+	}
+
+	principal, err := s.auth.Principal(ctx, token)
+	if err != nil {
+		return models.Principal{}, models.QuotaDecision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subjects := Subjects(principal)
+	now := s.now().UTC()
+
+	quotas, err := s.quotas(ctx, subjects, now)
+	if err != nil {
+		s.log.Error("failed to get quotas", slog.String("op", op), sl.Err(err))
+		return models.Principal{}, models.QuotaDecision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if budget := s.mirrorDenies(quotas, cost, now); budget != nil {
+		return principal, models.QuotaDecision{Budget: budget}, nil
+	}
+
+	decision, err := s.charge(ctx, subjects, quotas, cost, now)
+	if err != nil {
+		s.log.Error("failed to charge request", slog.String("op", op), sl.Err(err))
+		return models.Principal{}, models.QuotaDecision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return principal, decision, nil
+}
+
+// quotas returns quotas of subjects that have one, from the cache if it
+// is fresh
+func (s *Service) quotas(ctx context.Context, subjects []models.QuotaSubject, now time.Time) ([]models.Quota, error) {
+	var quotas []models.Quota
+	for _, subject := range subjects {
+		s.mu.Lock()
+		st, ok := s.states[subject]
+		fresh := ok && now.Sub(st.loadedAt) < s.policy.CacheTTL
+		var cached *models.Quota
+		if fresh {
+			cached = st.quota
+		}
+		s.mu.Unlock()
+
+		if !fresh {
+			var err error
+			if cached, err = s.loadQuota(ctx, subject, now); err != nil {
+				return nil, err
+			}
+		}
+		if cached != nil {
+			quotas = append(quotas, *cached)
+		}
+	}
+
+	return quotas, nil
+}
+
+// loadQuota reads the quota of subject into the cache. The mirror is
+// reset if limits changed.
+func (s *Service) loadQuota(ctx context.Context, subject models.QuotaSubject, now time.Time) (*models.Quota, error) {
+	var quota *models.Quota
+	q, err := s.store.Quota(ctx, subject)
+	switch {
+	case err == nil:
+		quota = &q
+	case !errors.Is(err, storage.ErrQuotaNotFound):
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.states[subject]
+	if !ok || !sameLimits(st.quota, quota) {
+		st = &state{usage: make(map[string]window)}
+		s.states[subject] = st
+	}
+	st.quota = quota
+	st.loadedAt = now
+
+	return quota, nil
+}
+
+func sameLimits(a, b *models.Quota) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.PerMinute == b.PerMinute && a.PerDay == b.PerDay && a.PerMonth == b.PerMonth
+}
+
+// mirrorDenies returns the budget the mirror proves exhausted, nil if the
+// database has to decide
+func (s *Service) mirrorDenies(quotas []models.Quota, cost int64, now time.Time) *models.QuotaBudget {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, q := range quotas {
+		if q.PerMinute > 0 {
+			if cost > q.PerMinute {
+				b := minuteBudget(q, models.QuotaBucket{Tokens: float64(q.PerMinute), UpdatedAt: now}, cost, now)
+				return &b
+			}
+			if st := s.states[q.QuotaSubject]; st != nil && st.bucket != nil {
+				bucket := refill(*st.bucket, q.PerMinute, now)
+				if bucket.Tokens < float64(cost) {
+					b := minuteBudget(q, bucket, cost, now)
+					return &b
+				}
+			}
+		}
+
+		for _, p := range periods(q) {
+			st := s.states[q.QuotaSubject]
+			if st == nil {
+				continue
+			}
+			w, ok := st.usage[p.period]
+			start := periodStart(p.period, now)
+			if ok && w.start.Equal(start) && w.used+cost > p.limit {
+				b := periodBudget(q, p.period, p.limit, w.used, now)
+				return &b
+			}
+		}
+	}
+
+	return nil
+}
+
+// charge decides on the request in the database and charges it to
+// every subject if it is allowed. The mirror is updated with what the
+// database holds.
+func (s *Service) charge(
+	ctx context.Context,
+	subjects []models.QuotaSubject,
+	quotas []models.Quota,
+	cost int64,
+	now time.Time,
+) (models.QuotaDecision, error) {
+	var (
+		decision models.QuotaDecision
+		// buckets are refilled buckets as they are in the database until
+		// the request is allowed and charged to them
+		buckets = make(map[models.QuotaSubject]models.QuotaBucket)
+		usage   = make(map[models.QuotaSubject]map[string]window)
+	)
+
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		decision = models.QuotaDecision{}
+		clear(buckets)
+		clear(usage)
+		charged := make(map[models.QuotaSubject]models.QuotaBucket)
+
+		var tightest *models.QuotaBudget
+		tighter := func(b models.QuotaBudget) {
+			if tightest == nil || b.Remaining < tightest.Remaining {
+				tightest = &b
+			}
+		}
+
+		for _, q := range quotas {
+			// the bucket row serializes checks of the subject, so that
+			// period usage read below stays current until it is charged,
+			// even if the quota has no minute limit
+			bucket, err := s.store.LockQuotaBucket(ctx, q.QuotaSubject, float64(q.PerMinute), now)
+			if err != nil {
+				return fmt.Errorf("lock bucket: %w", err)
+			}
+			if q.PerMinute > 0 {
+				bucket = refill(bucket, q.PerMinute, now)
+				buckets[q.QuotaSubject] = bucket
+				if bucket.Tokens < float64(cost) {
+					b := minuteBudget(q, bucket, cost, now)
+					decision.Budget = &b
+					return nil
+				}
+				bucket.Tokens -= float64(cost)
+				charged[q.QuotaSubject] = bucket
+				tighter(minuteBudget(q, bucket, cost, now))
+			}
+
+			usage[q.QuotaSubject] = make(map[string]window)
+			for _, p := range periods(q) {
+				start := periodStart(p.period, now)
+				used, err := s.store.QuotaUsage(ctx, q.QuotaSubject, p.period, start)
+				if err != nil {
+					return fmt.Errorf("get usage: %w", err)
+				}
+				usage[q.QuotaSubject][p.period] = window{start: start, used: used}
+				if used+cost > p.limit {
+					b := periodBudget(q, p.period, p.limit, used, now)
+					decision.Budget = &b
+					return nil
+				}
+				tighter(periodBudget(q, p.period, p.limit, used+cost, now))
+			}
+		}
+
+		for subject, bucket := range charged {
+			if err := s.store.SaveQuotaBucket(ctx, subject, bucket); err != nil {
+				return fmt.Errorf("save bucket: %w", err)
+			}
+			buckets[subject] = bucket
+		}
+		for _, subject := range subjects {
+			for _, period := range []string{models.QuotaPeriodDay, models.QuotaPeriodMonth} {
+				if err := s.store.AddQuotaUsage(ctx, subject, period, periodStart(period, now), cost); err != nil {
+					return fmt.Errorf("add usage: %w", err)
+				}
+			}
+		}
+		for _, windows := range usage {
+			for period, w := range windows {
+				w.used += cost
+				windows[period] = w
+			}
+		}
+
+		decision.Allowed = true
+		decision.Budget = tightest
+		return nil
+	})
+	if err != nil {
+		return models.QuotaDecision{}, err
+	}
+
+	s.mirror(buckets, usage)
+
+	return decision, nil
+}
+
+// mirror saves state of subjects the database returned
+func (s *Service) mirror(buckets map[models.QuotaSubject]models.QuotaBucket, usage map[models.QuotaSubject]map[string]window) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for subject, bucket := range buckets {
+		if st := s.states[subject]; st != nil {
+			st.bucket = &bucket
+		}
+	}
+	for subject, windows := range usage {
+		if st := s.states[subject]; st != nil {
+			for period, w := range windows {
+				st.usage[period] = w
+			}
+		}
+	}
+}
+
+// refill adds tokens accrued since the last update of bucket. The bucket
+// holds up to perMinute tokens and refills them evenly over a minute.
+func refill(bucket models.QuotaBucket, perMinute int64, now time.Time) models.QuotaBucket {
+	if elapsed := now.Sub(bucket.UpdatedAt); elapsed > 0 {
+		bucket.Tokens += elapsed.Minutes() * float64(perMinute)
+	}
+	bucket.Tokens = math.Min(bucket.Tokens, float64(perMinute))
+	if now.After(bucket.UpdatedAt) {
+		bucket.UpdatedAt = now
+	}
+
+	return bucket
+}
+
+func minuteBudget(q models.Quota, bucket models.QuotaBucket, cost int64, now time.Time) models.QuotaBudget {
+	resetAt := now
+	if missing := float64(cost) - bucket.Tokens; missing > 0 {
+		resetAt = now.Add(time.Duration(missing / float64(q.PerMinute) * float64(time.Minute)))
+	}
+
+	return models.QuotaBudget{
+		QuotaSubject: q.QuotaSubject,
+		Period:       models.QuotaPeriodMinute,
+		Limit:        q.PerMinute,
+		Remaining:    max(int64(math.Floor(bucket.Tokens)), 0),
+		ResetAt:      resetAt,
+	}
+}
+
+func periodBudget(q models.Quota, period string, limit int64, used int64, now time.Time) models.QuotaBudget {
+	return models.QuotaBudget{
+		QuotaSubject: q.QuotaSubject,
+		Period:       period,
+		Limit:        limit,
+		Remaining:    max(limit-used, 0),
+		ResetAt:      periodEnd(period, now),
+	}
+}
+
+type periodLimit struct {
+	period string
+	limit  int64
+}
+
+// periods returns calendar periods q limits
+func periods(q models.Quota) []periodLimit {
+	var limits []periodLimit
+	if q.PerDay > 0 {
+		limits = append(limits, periodLimit{period: models.QuotaPeriodDay, limit: q.PerDay})
+	}
+	if q.PerMonth > 0 {
+		limits = append(limits, periodLimit{period: models.QuotaPeriodMonth, limit: q.PerMonth})
+	}
+
+	return limits
+}
+
+// periodStart returns the start of the UTC day or month of now
+func periodStart(period string, now time.Time) time.Time {
+	now = now.UTC()
+	if period == models.QuotaPeriodMonth {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func periodEnd(period string, now time.Time) time.Time {
+	start := periodStart(period, now)
+	if period == models.QuotaPeriodMonth {
+		return start.AddDate(0, 1, 0)
+	}
+
+	return start.AddDate(0, 0, 1)
+}
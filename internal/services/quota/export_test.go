@@ -0,0 +1,8 @@
+package quota
+
+import "time"
+
+// SetNow replaces the clock of s in tests
+func (s *Service) SetNow(now func() time.Time) {
+	s.now = now
+}
@@ -0,0 +1,258 @@
+// Package quota enforces request budgets of apps, organizations and API
+// keys. Map services call Check with the token of every request they
+// serve; the request is charged to the app the token was issued to, the
+// organization of the caller and the API key, and is allowed only if
+// every budget defined for them allows it.
+//
+// The minute budget is a token bucket and day and month budgets are
+// counters of calendar periods, all kept in the database so that every
+// instance of the service enforces the same budgets. Each instance also
+// mirrors the state it last saw in memory. A bucket in the database is
+// never fuller and a counter never lower than its mirror, so requests
+// the mirror rejects are rejected without a database round trip.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+// MaxCost limits the cost of one checked request
+const MaxCost = 1000
+
+var (
+	ErrQuotaNotFound = errors.New("quota not found")
+	ErrInvalidQuota  = errors.New("invalid quota")
+)
+
+// ValidationError tells why a quota or a check is invalid. It matches
+// ErrInvalidQuota.
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return ErrInvalidQuota.Error() + ": " + e.Reason
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidQuota
+}
+
+// Store keeps quotas, their buckets and usage
+type Store interface {
+	SaveQuota(ctx context.Context, q models.Quota) error
+	Quota(ctx context.Context, subject models.QuotaSubject) (models.Quota, error)
+	Quotas(ctx context.Context) ([]models.Quota, error)
+	DeleteQuota(ctx context.Context, subject models.QuotaSubject) error
+
+	// LockQuotaBucket returns the minute bucket of subject, creating one
+	// holding tokens at now if there is none, and keeps other
+	// transactions from locking it until the transaction of ctx ends.
+	// Checks lock it before reading usage of the subject.
+	LockQuotaBucket(ctx context.Context, subject models.QuotaSubject, tokens float64, now time.Time) (models.QuotaBucket, error)
+	SaveQuotaBucket(ctx context.Context, subject models.QuotaSubject, bucket models.QuotaBucket) error
+	// QuotaUsage returns 0 for periods without requests
+	QuotaUsage(ctx context.Context, subject models.QuotaSubject, period string, start time.Time) (int64, error)
+	AddQuotaUsage(ctx context.Context, subject models.QuotaSubject, period string, start time.Time, n int64) error
+	// UsageReport returns usage matching filter, oldest period first
+	UsageReport(ctx context.Context, filter models.UsageFilter) ([]models.QuotaUsage, error)
+}
+
+type TxManager interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Introspector resolves tokens and API keys to callers
+type Introspector interface {
+	Principal(ctx context.Context, token string) (models.Principal, error)
+}
+
+// Policy configures Service. Quotas are cached for CacheTTL, so changes
+// made on other instances apply after it.
+type Policy struct {
+	CacheTTL time.Duration
+}
+
+type Service struct {
+	log    *slog.Logger
+	store  Store
+	tx     TxManager
+	auth   Introspector
+	policy Policy
+	now    func() time.Time
+
+	mu     sync.Mutex
+	states map[models.QuotaSubject]*state
+}
+
+// state is the in-memory mirror of a subject
+type state struct {
+	// quota is nil if the subject has none
+	quota    *models.Quota
+	loadedAt time.Time
+	bucket   *models.QuotaBucket
+	usage    map[string]window
+}
+
+// window is the usage of a subject in a period starting at start
+type window struct {
+	start time.Time
+	used  int64
+}
+
+func New(log *slog.Logger, store Store, tx TxManager, auth Introspector, policy Policy) *Service {
+	return &Service{
+		log:    log,
+		store:  store,
+		tx:     tx,
+		auth:   auth,
+		policy: policy,
+		now:    time.Now,
+		states: make(map[models.QuotaSubject]*state),
+	}
+}
+
+// SetQuota validates q and replaces limits of its subject
+func (s *Service) SetQuota(ctx context.Context, q models.Quota) (models.Quota, error) {
+	const op = "quota.SetQuota"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("subject_type", q.Type),
+		slog.String("subject_id", q.ID),
+	)
+
+	if err := validateQuota(q); err != nil {
+		return models.Quota{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	q.UpdatedAt = s.now().UTC()
+	if err := s.store.SaveQuota(ctx, q); err != nil {
+		log.Error("failed to save quota", sl.Err(err))
+		return models.Quota{}, fmt.Errorf("%s: %w", op, err)
+	}
+	s.forget(q.QuotaSubject)
+
+	log.Info("quota set",
+		slog.Int64("per_minute", q.PerMinute),
+		slog.Int64("per_day", q.PerDay),
+		slog.Int64("per_month", q.PerMonth),
+	)
+
+	return q, nil
+}
+
+func validateQuota(q models.Quota) error {
+	if err := validateSubject(q.QuotaSubject); err != nil {
+		return err
+	}
+	if q.PerMinute < 0 || q.PerDay < 0 || q.PerMonth < 0 {
+		return &ValidationError{Reason: "limits must not be negative"}
+	}
+	if q.PerMinute == 0 && q.PerDay == 0 && q.PerMonth == 0 {
+		return &ValidationError{Reason: "at least one limit must be set, delete the quota to lift limits"}
+	}
+
+	return nil
+}
+
+// validateSubject checks that ID of subject is an app id or a UUID as its
+// type requires
+func validateSubject(subject models.QuotaSubject) error {
+	switch subject.Type {
+	case models.QuotaSubjectApp:
+		if id, err := strconv.Atoi(subject.ID); err != nil || id <= 0 || strconv.Itoa(id) != subject.ID {
+			return &ValidationError{Reason: "subject id of an app must be a positive integer"}
+		}
+	case models.QuotaSubjectOrg, models.QuotaSubjectAPIKey:
+		if id, err := uuid.Parse(subject.ID); err != nil || id.String() != subject.ID {
+			return &ValidationError{Reason: "subject id must be a lowercase UUID"}
+		}
+	default:
+		return &ValidationError{Reason: fmt.Sprintf("subject type must be one of %s, %s, %s",
+			models.QuotaSubjectApp, models.QuotaSubjectOrg, models.QuotaSubjectAPIKey)}
+	}
+
+	return nil
+}
+
+// Quotas returns every quota
+func (s *Service) Quotas(ctx context.Context) ([]models.Quota, error) {
+	const op = "quota.Quotas"
+
+	quotas, err := s.store.Quotas(ctx)
+	if err != nil {
+		s.log.Error("failed to list quotas", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return quotas, nil
+}
+
+// DeleteQuota lifts limits of subject. Its usage is still counted.
+func (s *Service) DeleteQuota(ctx context.Context, subject models.QuotaSubject) error {
+	const op = "quota.DeleteQuota"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("subject_type", subject.Type),
+		slog.String("subject_id", subject.ID),
+	)
+
+	if err := s.store.DeleteQuota(ctx, subject); err != nil {
+		if errors.Is(err, storage.ErrQuotaNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrQuotaNotFound)
+		}
+		log.Error("failed to delete quota", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s.forget(subject)
+
+	log.Info("quota deleted")
+
+	return nil
+}
+
+// Usage returns counts of requests charged to subjects in days or months,
+// oldest first
+func (s *Service) Usage(ctx context.Context, filter models.UsageFilter) ([]models.QuotaUsage, error) {
+	const op = "quota.Usage"
+
+	switch filter.Period {
+	case "", models.QuotaPeriodDay, models.QuotaPeriodMonth:
+	default:
+		return nil, fmt.Errorf("%s: %w", op, &ValidationError{Reason: "period must be day or month"})
+	}
+	if filter.Subject != nil && filter.Subject.ID != "" {
+		if err := validateSubject(*filter.Subject); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	report, err := s.store.UsageReport(ctx, filter)
+	if err != nil {
+		s.log.Error("failed to get usage", slog.String("op", op), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return report, nil
+}
+
+// forget drops the mirror of subject, so that its quota is read again
+func (s *Service) forget(subject models.QuotaSubject) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, subject)
+}
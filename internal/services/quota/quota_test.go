@@ -0,0 +1,397 @@
+package quota_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/slogdiscard"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/services/quota"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// introspector resolves tokens to fixed principals
+type introspector map[string]models.Principal
+
+func (i introspector) Principal(_ context.Context, token string) (models.Principal, error) {
+	p, ok := i[token]
+	if !ok {
+		return models.Principal{}, auth.ErrInvalidToken
+	}
+	return p, nil
+}
+
+// countingStore counts transactions reaching the database
+type countingStore struct {
+	*memory.Storage
+	locks atomic.Int64
+}
+
+func (s *countingStore) LockQuotaBucket(
+	ctx context.Context,
+	subject models.QuotaSubject,
+	tokens float64,
+	now time.Time,
+) (models.QuotaBucket, error) {
+	s.locks.Add(1)
+	return s.Storage.LockQuotaBucket(ctx, subject, tokens, now)
+}
+
+// rowLockStore runs transactions concurrently like a database under READ
+// COMMITTED: only buckets are locked, until the transaction ends
+type rowLockStore struct {
+	*memory.Storage
+
+	mu    sync.Mutex
+	locks map[models.QuotaSubject]*sync.Mutex
+}
+
+type heldLocksKey struct{}
+
+func (s *rowLockStore) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	var held []*sync.Mutex
+	defer func() {
+		for _, l := range held {
+			l.Unlock()
+		}
+	}()
+
+	return fn(context.WithValue(ctx, heldLocksKey{}, &held))
+}
+
+func (s *rowLockStore) LockQuotaBucket(
+	ctx context.Context,
+	subject models.QuotaSubject,
+	tokens float64,
+	now time.Time,
+) (models.QuotaBucket, error) {
+	s.mu.Lock()
+	l, ok := s.locks[subject]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[subject] = l
+	}
+	s.mu.Unlock()
+
+	l.Lock()
+	held := ctx.Value(heldLocksKey{}).(*[]*sync.Mutex)
+	*held = append(*held, l)
+
+	return s.Storage.LockQuotaBucket(ctx, subject, tokens, now)
+}
+
+// QuotaUsage gives other checks time to read the same usage
+func (s *rowLockStore) QuotaUsage(
+	ctx context.Context,
+	subject models.QuotaSubject,
+	period string,
+	start time.Time,
+) (int64, error) {
+	used, err := s.Storage.QuotaUsage(ctx, subject, period, start)
+	time.Sleep(time.Millisecond)
+	return used, err
+}
+
+var (
+	orgID    = uuid.MustParse("6f1c2a3e-0000-4000-8000-000000000001")
+	keyID    = uuid.MustParse("6f1c2a3e-0000-4000-8000-000000000002")
+	appToken = "app-token"
+	keyToken = "key-token"
+	start    = time.Date(2026, 3, 31, 23, 58, 0, 0, time.UTC)
+)
+
+func newTestService(t *testing.T) (*quota.Service, *countingStore, *time.Time) {
+	t.Helper()
+
+	st := &countingStore{Storage: memory.New()}
+	log := slog.New(slogdiscard.NewDiscardHandler())
+	s := quota.New(log, st, st, introspector{
+		appToken: {UserID: uuid.New(), AppID: 7, OrgID: &orgID},
+		keyToken: {UserID: uuid.New(), OrgID: &orgID, APIKeyID: keyID},
+	}, quota.Policy{CacheTTL: time.Minute})
+
+	now := start
+	s.SetNow(func() time.Time { return now })
+
+	return s, st, &now
+}
+
+func appSubject() models.QuotaSubject {
+	return models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "7"}
+}
+
+func orgSubject() models.QuotaSubject {
+	return models.QuotaSubject{Type: models.QuotaSubjectOrg, ID: orgID.String()}
+}
+
+func TestCheck_MinuteLimit(t *testing.T) {
+	s, _, now := newTestService(t)
+	ctx := context.Background()
+
+	_, err := s.SetQuota(ctx, models.Quota{QuotaSubject: appSubject(), PerMinute: 3})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		p, d, err := s.Check(ctx, appToken, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 7, p.AppID)
+		require.True(t, d.Allowed, "request %d", i)
+		assert.Equal(t, models.QuotaPeriodMinute, d.Budget.Period)
+		assert.Equal(t, int64(2-i), d.Budget.Remaining)
+	}
+
+	_, d, err := s.Check(ctx, appToken, 1)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, appSubject(), d.Budget.QuotaSubject)
+	assert.Equal(t, int64(0), d.Budget.Remaining)
+	assert.Equal(t, now.Add(20*time.Second), d.Budget.ResetAt)
+
+	// a token refills in 20s
+	*now = now.Add(20 * time.Second)
+	_, d, err = s.Check(ctx, appToken, 1)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+
+	_, d, err = s.Check(ctx, appToken, 1)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+
+	// cost above the limit never fits
+	*now = now.Add(time.Hour)
+	_, d, err = s.Check(ctx, appToken, 4)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+}
+
+func TestCheck_PeriodLimits(t *testing.T) {
+	s, _, now := newTestService(t)
+	ctx := context.Background()
+
+	_, err := s.SetQuota(ctx, models.Quota{QuotaSubject: orgSubject(), PerDay: 5, PerMonth: 8})
+	require.NoError(t, err)
+
+	// both tokens belong to the org
+	_, d, err := s.Check(ctx, appToken, 3)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	_, d, err = s.Check(ctx, keyToken, 2)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+
+	_, d, err = s.Check(ctx, appToken, 1)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, models.QuotaPeriodDay, d.Budget.Period)
+	assert.Equal(t, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), d.Budget.ResetAt)
+
+	// the next day is in the next month as well
+	*now = now.Add(time.Hour)
+	_, d, err = s.Check(ctx, appToken, 5)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	_, d, err = s.Check(ctx, appToken, 1)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+
+	// the month limit holds on the next day
+	*now = now.Add(24 * time.Hour)
+	_, d, err = s.Check(ctx, appToken, 3)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	assert.Equal(t, models.QuotaPeriodMonth, d.Budget.Period)
+	assert.Equal(t, int64(0), d.Budget.Remaining)
+	_, d, err = s.Check(ctx, appToken, 1)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, models.QuotaPeriodMonth, d.Budget.Period)
+}
+
+func TestCheck_Concurrent(t *testing.T) {
+	st := &rowLockStore{Storage: memory.New(), locks: make(map[models.QuotaSubject]*sync.Mutex)}
+	log := slog.New(slogdiscard.NewDiscardHandler())
+	introspect := introspector{appToken: {UserID: uuid.New(), AppID: 7}}
+	ctx := context.Background()
+
+	// instances share the store but not mirrors
+	const instances, checks = 4, 10
+	services := make([]*quota.Service, instances)
+	for i := range services {
+		services[i] = quota.New(log, st, st, introspect, quota.Policy{CacheTTL: time.Minute})
+		services[i].SetNow(func() time.Time { return start })
+	}
+	_, err := services[0].SetQuota(ctx, models.Quota{QuotaSubject: appSubject(), PerDay: 5})
+	require.NoError(t, err)
+
+	var (
+		wg      sync.WaitGroup
+		allowed atomic.Int64
+	)
+	for _, s := range services {
+		for i := 0; i < checks; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, d, err := s.Check(ctx, appToken, 1)
+				assert.NoError(t, err)
+				if d.Allowed {
+					allowed.Add(1)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(5), allowed.Load())
+	usage, err := services[0].Usage(ctx, models.UsageFilter{Period: models.QuotaPeriodDay, Subject: ptr(appSubject())})
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.Equal(t, int64(5), usage[0].Used)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestCheck_DeniedByMirror(t *testing.T) {
+	s, st, _ := newTestService(t)
+	ctx := context.Background()
+
+	_, err := s.SetQuota(ctx, models.Quota{QuotaSubject: appSubject(), PerMinute: 1})
+	require.NoError(t, err)
+
+	_, d, err := s.Check(ctx, appToken, 1)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.Equal(t, int64(1), st.locks.Load())
+
+	for i := 0; i < 5; i++ {
+		_, d, err = s.Check(ctx, appToken, 1)
+		require.NoError(t, err)
+		assert.False(t, d.Allowed)
+	}
+	assert.Equal(t, int64(1), st.locks.Load(), "denials must not reach the database")
+}
+
+func TestCheck_DeniedRequestIsNotCharged(t *testing.T) {
+	s, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	_, err := s.SetQuota(ctx, models.Quota{QuotaSubject: appSubject(), PerMinute: 10})
+	require.NoError(t, err)
+	_, err = s.SetQuota(ctx, models.Quota{QuotaSubject: orgSubject(), PerDay: 2})
+	require.NoError(t, err)
+
+	_, d, err := s.Check(ctx, appToken, 2)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	_, d, err = s.Check(ctx, appToken, 1)
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	assert.Equal(t, orgSubject(), d.Budget.QuotaSubject)
+
+	// the app bucket kept the tokens of the denied request
+	require.NoError(t, s.DeleteQuota(ctx, orgSubject()))
+	_, d, err = s.Check(ctx, appToken, 8)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+
+	usage, err := s.Usage(ctx, models.UsageFilter{Period: models.QuotaPeriodDay})
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	for _, u := range usage {
+		assert.Equal(t, int64(10), u.Used, u.Type)
+	}
+}
+
+func TestCheck_Errors(t *testing.T) {
+	s, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	_, _, err := s.Check(ctx, "unknown", 1)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	_, _, err = s.Check(ctx, appToken, 0)
+	assert.ErrorIs(t, err, quota.ErrInvalidQuota)
+	_, _, err = s.Check(ctx, appToken, quota.MaxCost+1)
+	assert.ErrorIs(t, err, quota.ErrInvalidQuota)
+
+	// tokens without quotas are allowed
+	_, d, err := s.Check(ctx, keyToken, 1)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Nil(t, d.Budget)
+}
+
+func TestUsage(t *testing.T) {
+	s, _, now := newTestService(t)
+	ctx := context.Background()
+
+	_, _, err := s.Check(ctx, keyToken, 2)
+	require.NoError(t, err)
+	*now = now.Add(time.Hour)
+	_, _, err = s.Check(ctx, keyToken, 3)
+	require.NoError(t, err)
+
+	key := models.QuotaSubject{Type: models.QuotaSubjectAPIKey, ID: keyID.String()}
+	days, err := s.Usage(ctx, models.UsageFilter{Period: models.QuotaPeriodDay, Subject: &key})
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+	assert.Equal(t, time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), days[0].Start.UTC())
+	assert.Equal(t, int64(2), days[0].Used)
+	assert.Equal(t, int64(3), days[1].Used)
+
+	months, err := s.Usage(ctx, models.UsageFilter{
+		Period: models.QuotaPeriodMonth,
+		From:   time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Len(t, months, 2)
+	for _, m := range months {
+		assert.Equal(t, int64(3), m.Used)
+	}
+
+	_, err = s.Usage(ctx, models.UsageFilter{Period: models.QuotaPeriodMinute})
+	assert.ErrorIs(t, err, quota.ErrInvalidQuota)
+}
+
+func TestSetQuota_Invalid(t *testing.T) {
+	s, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		quota models.Quota
+	}{
+		{"no limits", models.Quota{QuotaSubject: appSubject()}},
+		{"negative", models.Quota{QuotaSubject: appSubject(), PerMinute: -1, PerDay: 10}},
+		{"bad type", models.Quota{QuotaSubject: models.QuotaSubject{Type: "user", ID: "7"}, PerDay: 1}},
+		{"bad app id", models.Quota{QuotaSubject: models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "07"}, PerDay: 1}},
+		{"bad org id", models.Quota{QuotaSubject: models.QuotaSubject{Type: models.QuotaSubjectOrg, ID: "acme"}, PerDay: 1}},
+		{"uppercase uuid", models.Quota{
+			QuotaSubject: models.QuotaSubject{Type: models.QuotaSubjectAPIKey, ID: "6F1C2A3E-0000-4000-8000-000000000002"},
+			PerDay:       1,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.SetQuota(ctx, tt.quota)
+			assert.ErrorIs(t, err, quota.ErrInvalidQuota)
+		})
+	}
+
+	err := s.DeleteQuota(ctx, appSubject())
+	assert.ErrorIs(t, err, quota.ErrQuotaNotFound)
+
+	list, err := s.Quotas(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
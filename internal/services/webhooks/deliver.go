@@ -0,0 +1,149 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/webhook"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/worker"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Run sends due deliveries until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	const op = "webhooks.Run"
+
+	log := s.log.With(
+		slog.String("op", op),
+	)
+	log.Info("starting webhook delivery")
+
+	worker.Poll(ctx, s.policy.PollInterval, s.policy.BatchSize, func(ctx context.Context) (int, error) {
+		n, err := s.DeliverOnce(ctx)
+		if err != nil {
+			log.Error("failed to deliver webhooks", sl.Err(err))
+		}
+		return n, err
+	})
+
+	log.Info("webhook delivery stopped")
+}
+
+// DeliverOnce sends one batch of due deliveries and returns the number of
+// deliveries claimed
+func (s *Service) DeliverOnce(ctx context.Context) (int, error) {
+	const op = "webhooks.DeliverOnce"
+
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	now := s.now()
+	leaseUntil := now.Add(s.policy.Lease)
+	deliveries, err := s.store.ClaimWebhookDeliveries(ctx, now, leaseUntil, s.policy.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	slices.SortFunc(deliveries, func(a, b models.WebhookDelivery) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	subs := make(map[uuid.UUID]models.WebhookSubscription)
+	for _, d := range deliveries {
+		if ctx.Err() != nil || !s.now().Before(leaseUntil) {
+			// the rest is claimed again after the lease
+			return len(deliveries), nil
+		}
+
+		log := log.With(
+			slog.String("delivery_id", d.ID.String()),
+			slog.String("subscription_id", d.SubscriptionID.String()),
+		)
+
+		sub, ok := subs[d.SubscriptionID]
+		if !ok {
+			sub, err = s.store.WebhookSubscription(ctx, d.SubscriptionID)
+			if err != nil {
+				// the subscription was deleted along with the delivery
+				if !errors.Is(err, storage.ErrWebhookSubscriptionNotFound) {
+					log.Error("failed to get subscription", sl.Err(err))
+				}
+				continue
+			}
+			subs[d.SubscriptionID] = sub
+		}
+
+		s.attempt(ctx, log, sub, d)
+	}
+
+	return len(deliveries), nil
+}
+
+// attempt sends d once and saves the outcome
+func (s *Service) attempt(ctx context.Context, log *slog.Logger, sub models.WebhookSubscription, d models.WebhookDelivery) {
+	status, err := s.send(ctx, sub, d)
+
+	d.Attempts++
+	d.LastStatus = status
+	d.LastError = ""
+	switch {
+	case err == nil:
+		now := s.now().UTC()
+		d.Status = models.WebhookDelivered
+		d.DeliveredAt = &now
+	case d.Attempts >= s.policy.MaxAttempts:
+		d.Status = models.WebhookDead
+		d.LastError = worker.ErrorText(err)
+		log.Warn("webhook delivery is dead", slog.Int("attempts", d.Attempts), sl.Err(err))
+	default:
+		d.NextAttemptAt = s.now().Add(worker.Backoff(s.policy.BaseDelay, s.policy.MaxDelay, d.Attempts-1)).UTC()
+		d.LastError = worker.ErrorText(err)
+		log.Warn("webhook delivery failed",
+			slog.Int("attempts", d.Attempts),
+			slog.Time("next_attempt_at", d.NextAttemptAt),
+			sl.Err(err),
+		)
+	}
+
+	if err := s.store.UpdateWebhookDelivery(ctx, d); err != nil {
+		// the delivery is attempted again after the lease
+		log.Error("failed to save delivery", sl.Err(err))
+	}
+}
+
+// send posts the delivery signed with the subscription secret and returns
+// HTTP status of the response, if any
+func (s *Service) send(ctx context.Context, sub models.WebhookSubscription, d models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(sub.Secret, s.now(), d.Payload))
+	req.Header.Set(webhook.EventIDHeader, d.EventID.String())
+	req.Header.Set(webhook.EventTypeHeader, d.EventType)
+	req.Header.Set(webhook.DeliveryIDHeader, d.ID.String())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhook.MaxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
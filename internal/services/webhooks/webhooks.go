@@ -0,0 +1,343 @@
+// Package webhooks sends user lifecycle events to HTTP endpoints of
+// partners. Service receives events from the outbox relay, stores a
+// delivery for every matching subscription and sends deliveries with
+// signed requests, retrying with exponential backoff. Deliveries which
+// keep failing are dead until redelivered by an admin.
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/paging"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/webhook"
+	"github.com/babs-corp/babs-maps-auth/internal/services/outbox"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+var _ outbox.EventPublisher = (*Service)(nil)
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+)
+
+// ValidationError tells why a subscription is invalid. It matches
+// ErrInvalidSubscription.
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return ErrInvalidSubscription.Error() + ": " + e.Reason
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidSubscription
+}
+
+// Store keeps subscriptions and deliveries
+type Store interface {
+	SaveWebhookSubscription(ctx context.Context, sub models.WebhookSubscription) error
+	WebhookSubscription(ctx context.Context, id uuid.UUID) (models.WebhookSubscription, error)
+	WebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error
+
+	// SaveWebhookDeliveries skips deliveries of an event already stored
+	// for the subscription, so that redelivered events are sent once
+	SaveWebhookDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	// ClaimWebhookDeliveries returns up to limit pending deliveries due at
+	// now and postpones their next attempt to leaseUntil, like
+	// outbox.Store.ClaimEvents
+	ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error)
+	// UpdateWebhookDelivery saves status, attempts, next attempt, last
+	// status and error and delivery time of d
+	UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error
+	WebhookDelivery(ctx context.Context, id uuid.UUID) (models.WebhookDelivery, error)
+	// WebhookDeliveries returns at most query.Limit+1 deliveries, newest first
+	WebhookDeliveries(ctx context.Context, query models.WebhookDeliveryQuery) ([]models.WebhookDelivery, error)
+
+	// Grants returns apps the user consented to, for subscriptions of apps
+	Grants(ctx context.Context, userID uuid.UUID) ([]models.Grant, error)
+}
+
+// Policy configures delivery. BaseDelay is doubled on every failed
+// attempt up to MaxDelay; after MaxAttempts failures a delivery is dead.
+type Policy struct {
+	Timeout      time.Duration
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	BatchSize    int
+	PollInterval time.Duration
+	Lease        time.Duration
+}
+
+type Service struct {
+	log    *slog.Logger
+	store  Store
+	client *http.Client
+	policy Policy
+	now    func() time.Time
+}
+
+func New(log *slog.Logger, store Store, policy Policy) *Service {
+	return &Service{
+		log:    log,
+		store:  store,
+		client: &http.Client{Timeout: policy.Timeout},
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// CreateSubscription validates sub and saves it with a new ID and secret.
+// The secret is returned only here.
+func (s *Service) CreateSubscription(
+	ctx context.Context,
+	sub models.WebhookSubscription,
+) (models.WebhookSubscription, error) {
+	const op = "webhooks.CreateSubscription"
+
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	if err := validateSubscription(sub); err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sub.ID = uuid.New()
+	sub.Secret = secret
+	sub.CreatedAt = s.now().UTC()
+	if err := s.store.SaveWebhookSubscription(ctx, sub); err != nil {
+		log.Error("failed to save subscription", sl.Err(err))
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("webhook subscription created", slog.String("id", sub.ID.String()))
+
+	return sub, nil
+}
+
+func validateSubscription(sub models.WebhookSubscription) error {
+	if sub.OrgID == nil && sub.AppID == nil {
+		return &ValidationError{Reason: "org or app is required, subscriptions receive events about their members or users only"}
+	}
+
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return &ValidationError{Reason: "url must be an absolute http(s) url"}
+	}
+
+	for _, typ := range sub.EventTypes {
+		if !slices.Contains(eventTypes, typ) {
+			return &ValidationError{Reason: fmt.Sprintf("unknown event type %q", typ)}
+		}
+	}
+
+	return nil
+}
+
+// eventTypes are events which can be subscribed to
+var eventTypes = []string{
+	models.EventUserCreated,
+}
+
+func (s *Service) Subscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	const op = "webhooks.Subscriptions"
+
+	subs, err := s.store.WebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subs, nil
+}
+
+// DeleteSubscription stops deliveries to the subscription. Its delivery
+// log is deleted as well.
+func (s *Service) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	const op = "webhooks.DeleteSubscription"
+
+	if err := s.store.DeleteWebhookSubscription(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrWebhookSubscriptionNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrSubscriptionNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("webhook subscription deleted", slog.String("op", op), slog.String("id", id.String()))
+
+	return nil
+}
+
+// Publish stores a delivery of event for every matching subscription.
+// Deliveries are sent by Run.
+func (s *Service) Publish(ctx context.Context, event models.Event) error {
+	const op = "webhooks.Publish"
+
+	var user models.UserEventPayload
+	if err := json.Unmarshal(event.Payload, &user); err != nil {
+		return fmt.Errorf("%s: decode payload: %w", op, err)
+	}
+
+	subs, err := s.store.WebhookSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	apps, err := s.userApps(ctx, event.SubjectID, user.AppID, subs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := s.now().UTC()
+	var deliveries []models.WebhookDelivery
+	for _, sub := range subs {
+		if !sub.Matches(event.Type, user.OrgID, apps) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:             uuid.New(),
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        body,
+			Status:         models.WebhookPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := s.store.SaveWebhookDeliveries(ctx, deliveries); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// userApps returns the app the user came through, if known, and the apps
+// they consented to. Grants are read only if an app subscribed.
+func (s *Service) userApps(
+	ctx context.Context,
+	userID uuid.UUID,
+	appID int,
+	subs []models.WebhookSubscription,
+) ([]int, error) {
+	var apps []int
+	if appID != 0 {
+		apps = append(apps, appID)
+	}
+
+	if !slices.ContainsFunc(subs, func(sub models.WebhookSubscription) bool { return sub.AppID != nil }) {
+		return apps, nil
+	}
+
+	grants, err := s.store.Grants(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get grants: %w", err)
+	}
+	for _, g := range grants {
+		apps = append(apps, g.AppID)
+	}
+
+	return apps, nil
+}
+
+// Deliveries returns one page of the delivery log matching query, newest
+// first. cursor is NextCursor of the previous page, or empty.
+func (s *Service) Deliveries(
+	ctx context.Context,
+	query models.WebhookDeliveryQuery,
+	cursor string,
+) (models.WebhookDeliveryPage, error) {
+	const op = "webhooks.Deliveries"
+
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultDeliveriesLimit
+	case query.Limit > maxDeliveriesLimit:
+		query.Limit = maxDeliveriesLimit
+	}
+
+	if cursor != "" {
+		after, err := paging.DecodeCursor[models.WebhookDeliveryCursor](cursor)
+		if err != nil {
+			return models.WebhookDeliveryPage{}, fmt.Errorf("%s: %w", op, ErrInvalidCursor)
+		}
+		query.After = &after
+	}
+
+	deliveries, err := s.store.WebhookDeliveries(ctx, query)
+	if err != nil {
+		return models.WebhookDeliveryPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.WebhookDeliveryPage{Deliveries: deliveries}
+	if uint(len(deliveries)) > query.Limit {
+		page.Deliveries = deliveries[:query.Limit]
+		last := page.Deliveries[len(page.Deliveries)-1]
+		page.NextCursor = paging.EncodeCursor(models.WebhookDeliveryCursor{
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	return page, nil
+}
+
+// Redeliver sends delivery again with a fresh attempts budget, whatever
+// its status is
+func (s *Service) Redeliver(ctx context.Context, id uuid.UUID) (models.WebhookDelivery, error) {
+	const op = "webhooks.Redeliver"
+
+	d, err := s.store.WebhookDelivery(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebhookDeliveryNotFound) {
+			return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, ErrDeliveryNotFound)
+		}
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	d.Status = models.WebhookPending
+	d.Attempts = 0
+	d.NextAttemptAt = s.now().UTC()
+	if err := s.store.UpdateWebhookDelivery(ctx, d); err != nil {
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.Info("webhook redelivery requested", slog.String("op", op), slog.String("id", id.String()))
+
+	return d, nil
+}
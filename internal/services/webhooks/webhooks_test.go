@@ -0,0 +1,445 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/clientinfo"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/hasher"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/slogdiscard"
+	"github.com/babs-corp/babs-maps-auth/internal/lib/webhook"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/services/outbox"
+	"github.com/babs-corp/babs-maps-auth/internal/services/webhooks"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = webhooks.Policy{
+	Timeout:      time.Second,
+	MaxAttempts:  3,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	BatchSize:    10,
+	PollInterval: time.Millisecond,
+	Lease:        time.Minute,
+}
+
+// receiver records requests and answers with status
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T) (*receiver, *httptest.Server) {
+	t.Helper()
+
+	rec := &receiver{status: http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.requests = append(rec.requests, r)
+		rec.bodies = append(rec.bodies, body)
+		w.WriteHeader(rec.status)
+	}))
+	t.Cleanup(srv.Close)
+
+	return rec, srv
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newTestService(t *testing.T) (*webhooks.Service, *time.Time) {
+	t.Helper()
+
+	return newTestServiceWithStorage(t, memory.New())
+}
+
+func newTestServiceWithStorage(t *testing.T, st *memory.Storage) (*webhooks.Service, *time.Time) {
+	t.Helper()
+
+	log := slog.New(slogdiscard.NewDiscardHandler())
+	s := webhooks.New(log, st, testPolicy)
+	now := time.Now()
+	s.SetNow(func() time.Time { return now })
+
+	return s, &now
+}
+
+func testEvent(t *testing.T, typ string, orgID *uuid.UUID) models.Event {
+	t.Helper()
+
+	return testAppEvent(t, typ, orgID, 0)
+}
+
+// testAppEvent returns an event about a new user who came through appID
+func testAppEvent(t *testing.T, typ string, orgID *uuid.UUID, appID int) models.Event {
+	t.Helper()
+
+	userID := uuid.New()
+	payload, err := json.Marshal(models.UserEventPayload{
+		ID:    userID,
+		Email: "a@example.com",
+		OrgID: orgID,
+		AppID: appID,
+	})
+	require.NoError(t, err)
+
+	return models.Event{
+		ID:        uuid.New(),
+		Type:      typ,
+		SubjectID: userID,
+		Payload:   payload,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+func deliveries(t *testing.T, s *webhooks.Service) []models.WebhookDelivery {
+	t.Helper()
+
+	page, err := s.Deliveries(context.Background(), models.WebhookDeliveryQuery{}, "")
+	require.NoError(t, err)
+	return page.Deliveries
+}
+
+// testOrgID is the organization of subscriptions and events of tests
+// which do not test matching
+var testOrgID = uuid.New()
+
+func appID(id int) *int {
+	return &id
+}
+
+func TestService_DeliversSignedEvent(t *testing.T) {
+	s, now := newTestService(t)
+	rec, srv := newReceiver(t)
+	ctx := context.Background()
+
+	sub, err := s.CreateSubscription(ctx, models.WebhookSubscription{AppID: appID(1), OrgID: &testOrgID, URL: srv.URL})
+	require.NoError(t, err)
+	require.NotEmpty(t, sub.Secret)
+
+	event := testEvent(t, models.EventUserCreated, &testOrgID)
+	require.NoError(t, s.Publish(ctx, event))
+
+	n, err := s.DeliverOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.Equal(t, 1, rec.count())
+
+	req, body := rec.requests[0], rec.bodies[0]
+	assert.Equal(t, event.ID.String(), req.Header.Get(webhook.EventIDHeader))
+	assert.Equal(t, event.Type, req.Header.Get(webhook.EventTypeHeader))
+	require.NoError(t, webhook.Verify(sub.Secret, req.Header.Get(webhook.SignatureHeader), body, *now, time.Minute))
+
+	var got models.Event
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, event.ID, got.ID)
+
+	list := deliveries(t, s)
+	require.Len(t, list, 1)
+	assert.Equal(t, models.WebhookDelivered, list[0].Status)
+	assert.Equal(t, http.StatusOK, list[0].LastStatus)
+	assert.Equal(t, 1, list[0].Attempts)
+	assert.NotNil(t, list[0].DeliveredAt)
+
+	// delivered deliveries are not sent again
+	n, err = s.DeliverOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestService_RetriesUntilDead(t *testing.T) {
+	s, now := newTestService(t)
+	rec, srv := newReceiver(t)
+	rec.setStatus(http.StatusInternalServerError)
+	ctx := context.Background()
+
+	_, err := s.CreateSubscription(ctx, models.WebhookSubscription{AppID: appID(1), OrgID: &testOrgID, URL: srv.URL})
+	require.NoError(t, err)
+	require.NoError(t, s.Publish(ctx, testEvent(t, models.EventUserCreated, &testOrgID)))
+
+	for i, delay := range []time.Duration{time.Second, 2 * time.Second} {
+		_, err := s.DeliverOnce(ctx)
+		require.NoError(t, err)
+		require.Equal(t, i+1, rec.count())
+
+		d := deliveries(t, s)[0]
+		assert.Equal(t, models.WebhookPending, d.Status)
+		assert.Equal(t, http.StatusInternalServerError, d.LastStatus)
+		assert.Contains(t, d.LastError, "500")
+		assert.True(t, d.NextAttemptAt.Equal(now.Add(delay)), "attempt %d", i+1)
+
+		// not due before the backoff
+		n, err := s.DeliverOnce(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		*now = now.Add(delay)
+	}
+
+	_, err = s.DeliverOnce(ctx)
+	require.NoError(t, err)
+	d := deliveries(t, s)[0]
+	assert.Equal(t, models.WebhookDead, d.Status)
+	assert.Equal(t, 3, d.Attempts)
+
+	*now = now.Add(time.Hour)
+	n, err := s.DeliverOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// redelivery revives the dead delivery
+	rec.setStatus(http.StatusNoContent)
+	d, err = s.Redeliver(ctx, d.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookPending, d.Status)
+	assert.Zero(t, d.Attempts)
+
+	_, err = s.DeliverOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, rec.count())
+	assert.Equal(t, models.WebhookDelivered, deliveries(t, s)[0].Status)
+
+	_, err = s.Redeliver(ctx, uuid.New())
+	assert.ErrorIs(t, err, webhooks.ErrDeliveryNotFound)
+}
+
+func TestService_PublishMatchesSubscriptions(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	orgID, otherOrgID := uuid.New(), uuid.New()
+
+	org, err := s.CreateSubscription(ctx, models.WebhookSubscription{
+		OrgID: &orgID,
+		URL:   "https://example.com/org",
+	})
+	require.NoError(t, err)
+	other, err := s.CreateSubscription(ctx, models.WebhookSubscription{
+		AppID: appID(1),
+		OrgID: &otherOrgID,
+		URL:   "https://example.com/other",
+	})
+	require.NoError(t, err)
+
+	member := testEvent(t, models.EventUserCreated, &orgID)
+	require.NoError(t, s.Publish(ctx, member))
+	// the relay publishes an event again after a failure of another publisher
+	require.NoError(t, s.Publish(ctx, member))
+
+	got := deliveries(t, s)
+	require.Len(t, got, 1)
+	assert.Equal(t, member.ID, got[0].EventID)
+	assert.Equal(t, org.ID, got[0].SubscriptionID)
+
+	// users outside of organizations and apps are not sent to anyone
+	outsider := testEvent(t, models.EventUserCreated, nil)
+	require.NoError(t, s.Publish(ctx, outsider))
+	assert.Len(t, deliveries(t, s), 1)
+
+	otherMember := testEvent(t, models.EventUserCreated, &otherOrgID)
+	require.NoError(t, s.Publish(ctx, otherMember))
+
+	page, err := s.Deliveries(ctx, models.WebhookDeliveryQuery{
+		Filter: models.WebhookDeliveryFilter{EventID: &otherMember.ID},
+	}, "")
+	require.NoError(t, err)
+	require.Len(t, page.Deliveries, 1)
+	assert.Equal(t, other.ID, page.Deliveries[0].SubscriptionID)
+
+	// deleting a subscription deletes its deliveries
+	require.NoError(t, s.DeleteSubscription(ctx, other.ID))
+	assert.Len(t, deliveries(t, s), 1)
+	assert.ErrorIs(t, s.DeleteSubscription(ctx, other.ID), webhooks.ErrSubscriptionNotFound)
+}
+
+func TestService_PublishMatchesAppSubscriptions(t *testing.T) {
+	st := memory.New()
+	s, _ := newTestServiceWithStorage(t, st)
+	ctx := context.Background()
+
+	sub, err := s.CreateSubscription(ctx, models.WebhookSubscription{
+		AppID: appID(1),
+		URL:   "https://example.com/app",
+	})
+	require.NoError(t, err)
+
+	// users who came through the app
+	signedUp := testAppEvent(t, models.EventUserCreated, nil, 1)
+	require.NoError(t, s.Publish(ctx, signedUp))
+	require.NoError(t, s.Publish(ctx, testAppEvent(t, models.EventUserCreated, nil, 2)))
+
+	got := deliveries(t, s)
+	require.Len(t, got, 1)
+	assert.Equal(t, signedUp.ID, got[0].EventID)
+	assert.Equal(t, sub.ID, got[0].SubscriptionID)
+
+	// and users who consented to it
+	consented := testEvent(t, models.EventUserCreated, nil)
+	require.NoError(t, st.SaveGrant(ctx, models.Grant{UserID: consented.SubjectID, AppID: 1}))
+	require.NoError(t, s.Publish(ctx, consented))
+	assert.Len(t, deliveries(t, s), 2)
+}
+
+func TestService_DeliversRegisteredUser(t *testing.T) {
+	st := memory.New()
+	s, now := newTestServiceWithStorage(t, st)
+	rec, srv := newReceiver(t)
+	ctx := context.Background()
+	log := slog.New(slogdiscard.NewDiscardHandler())
+
+	sub, err := s.CreateSubscription(ctx, models.WebhookSubscription{AppID: appID(1), URL: srv.URL})
+	require.NoError(t, err)
+
+	a := auth.New(log, st, st, time.Hour, "test-secret",
+		auth.WithPasswordHasher(hasher.New(hasher.Bcrypt{Cost: 4})),
+		auth.WithTxManager(st),
+		auth.WithEventOutbox(st),
+	)
+	relay := outbox.New(log, st, s, outbox.Policy{BatchSize: 10, Lease: time.Minute, BaseDelay: time.Second})
+
+	email := gofakeit.Email()
+	id, err := a.RegisterNewUser(clientinfo.WithInfo(ctx, clientinfo.Info{AppID: 1}), email, "correct horse battery")
+	require.NoError(t, err)
+	// nobody subscribed to users of no app and no organization
+	_, err = a.RegisterNewUser(ctx, gofakeit.Email(), "correct horse battery")
+	require.NoError(t, err)
+
+	n, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	n, err = s.DeliverOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.Equal(t, 1, rec.count())
+
+	req, body := rec.requests[0], rec.bodies[0]
+	require.NoError(t, webhook.Verify(sub.Secret, req.Header.Get(webhook.SignatureHeader), body, *now, time.Minute))
+
+	var event models.Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, models.EventUserCreated, event.Type)
+	assert.Equal(t, id, event.SubjectID)
+
+	var user models.UserEventPayload
+	require.NoError(t, json.Unmarshal(event.Payload, &user))
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, email, user.Email)
+	assert.Equal(t, 1, user.AppID)
+}
+
+func TestSubscription_WithoutOrgAndAppMatchesNothing(t *testing.T) {
+	orgID := uuid.New()
+	legacy := models.WebhookSubscription{URL: "https://example.com"}
+
+	assert.False(t, legacy.Matches(models.EventUserCreated, nil, nil))
+	assert.False(t, legacy.Matches(models.EventUserCreated, &orgID, []int{1}))
+}
+
+func TestService_CreateSubscriptionValidates(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		sub  models.WebhookSubscription
+	}{
+		{
+			name: "no org or app",
+			sub:  models.WebhookSubscription{URL: "https://example.com"},
+		},
+		{
+			name: "relative url",
+			sub:  models.WebhookSubscription{OrgID: &testOrgID, URL: "/hook"},
+		},
+		{
+			name: "not http",
+			sub:  models.WebhookSubscription{OrgID: &testOrgID, URL: "ftp://example.com"},
+		},
+		{
+			name: "unknown event",
+			sub: models.WebhookSubscription{
+				OrgID:      &testOrgID,
+				URL:        "https://example.com",
+				EventTypes: []string{"user.deleted"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CreateSubscription(ctx, tt.sub)
+			require.ErrorIs(t, err, webhooks.ErrInvalidSubscription)
+
+			var vErr *webhooks.ValidationError
+			assert.ErrorAs(t, err, &vErr)
+		})
+	}
+
+	subs, err := s.Subscriptions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+}
+
+func TestService_DeliveriesPagination(t *testing.T) {
+	s, now := newTestService(t)
+	ctx := context.Background()
+
+	_, err := s.CreateSubscription(ctx, models.WebhookSubscription{AppID: appID(1), OrgID: &testOrgID, URL: "https://example.com"})
+	require.NoError(t, err)
+
+	var want []uuid.UUID
+	for i := 0; i < 5; i++ {
+		event := testEvent(t, models.EventUserCreated, &testOrgID)
+		require.NoError(t, s.Publish(ctx, event))
+		want = append([]uuid.UUID{event.ID}, want...)
+		*now = now.Add(time.Second)
+	}
+
+	var (
+		got    []uuid.UUID
+		cursor string
+	)
+	for {
+		page, err := s.Deliveries(ctx, models.WebhookDeliveryQuery{Limit: 2}, cursor)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Deliveries), 2)
+		for _, d := range page.Deliveries {
+			got = append(got, d.EventID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, want, got)
+
+	_, err = s.Deliveries(ctx, models.WebhookDeliveryQuery{}, "not a cursor")
+	assert.ErrorIs(t, err, webhooks.ErrInvalidCursor)
+}
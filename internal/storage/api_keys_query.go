@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"strings"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+// APIKeyColumns are columns scanned by ScanAPIKey. Scopes are kept as one
+// space-separated string.
+const APIKeyColumns = "id, prefix, hash, name, user_id, org_id, scopes, created_at, expires_at, last_used_at, revoked_at"
+
+// APIKeyInsertSQL inserts models.APIKey with "?" placeholders. Arguments
+// are APIKeyArgs.
+const APIKeyInsertSQL = "INSERT INTO api_keys (" + APIKeyColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// APIKeysListSQL selects keys that are not revoked of a user and of an
+// organization, newest first. Arguments are the user id and org id; a NULL
+// org id matches no organization keys.
+const APIKeysListSQL = "SELECT " + APIKeyColumns + " FROM api_keys " +
+	"WHERE revoked_at IS NULL AND (user_id = ? OR org_id = ?) ORDER BY created_at DESC, id"
+
+// APIKeyRevokeSQL revokes a key keeping the time of the first revocation.
+// Arguments are the time and key id.
+const APIKeyRevokeSQL = "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?"
+
+// APIKeyArgs returns arguments of APIKeyInsertSQL
+func APIKeyArgs(k models.APIKey) []any {
+	return []any{
+		k.ID, k.Prefix, k.Hash, k.Name, k.UserID, k.OrgID, strings.Join(k.Scopes, " "),
+		k.CreatedAt, k.ExpiresAt, k.LastUsedAt, k.RevokedAt,
+	}
+}
+
+// ScanAPIKey reads a row selected with APIKeyColumns
+func ScanAPIKey(row interface{ Scan(dest ...any) error }) (models.APIKey, error) {
+	var (
+		k      models.APIKey
+		scopes string
+	)
+	err := row.Scan(&k.ID, &k.Prefix, &k.Hash, &k.Name, &k.UserID, &k.OrgID, &scopes,
+		&k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	k.Scopes = strings.Fields(scopes)
+
+	return k, nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+// appFields are columns of apps set by AppArgs. Lists are kept as one
+// space-separated string and token TTL in seconds.
+const appFields = "name, secret, type, first_party, redirect_uris, grant_types, scopes, token_ttl, " +
+	"display_name, logo_url, primary_color, background_color"
+
+// AppColumns are columns scanned by ScanApp
+const AppColumns = "id, " + appFields
+
+// AppInsertSQL saves models.App with ID assigned by the database.
+// Arguments are AppArgs.
+const AppInsertSQL = "INSERT INTO apps (" + appFields + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// AppUpdateSQL replaces fields of the app of the last argument but its
+// secret. Arguments are AppUpdateArgs.
+const AppUpdateSQL = "UPDATE apps SET name = ?, type = ?, first_party = ?, redirect_uris = ?, grant_types = ?, " +
+	"scopes = ?, token_ttl = ?, display_name = ?, logo_url = ?, primary_color = ?, background_color = ? WHERE id = ?"
+
+// AppArgs returns arguments of AppInsertSQL
+func AppArgs(a models.App) []any {
+	return []any{
+		a.Name, a.Secret, a.Type, a.FirstParty,
+		strings.Join(a.RedirectURIs, " "), strings.Join(a.GrantTypes, " "), strings.Join(a.Scopes, " "),
+		int64(a.TokenTTL / time.Second),
+		a.DisplayName, a.LogoURL, a.PrimaryColor, a.BackgroundColor,
+	}
+}
+
+// AppUpdateArgs returns arguments of AppUpdateSQL
+func AppUpdateArgs(a models.App) []any {
+	return []any{
+		a.Name, a.Type, a.FirstParty,
+		strings.Join(a.RedirectURIs, " "), strings.Join(a.GrantTypes, " "), strings.Join(a.Scopes, " "),
+		int64(a.TokenTTL / time.Second),
+		a.DisplayName, a.LogoURL, a.PrimaryColor, a.BackgroundColor,
+		a.ID,
+	}
+}
+
+// ScanApp reads a row selected with AppColumns
+func ScanApp(row interface{ Scan(dest ...any) error }) (models.App, error) {
+	var (
+		a                                models.App
+		redirectURIs, grantTypes, scopes string
+		tokenTTL                         int64
+	)
+	err := row.Scan(&a.ID, &a.Name, &a.Secret, &a.Type, &a.FirstParty, &redirectURIs, &grantTypes, &scopes, &tokenTTL,
+		&a.DisplayName, &a.LogoURL, &a.PrimaryColor, &a.BackgroundColor)
+	if err != nil {
+		return models.App{}, err
+	}
+	a.RedirectURIs = strings.Fields(redirectURIs)
+	a.GrantTypes = strings.Fields(grantTypes)
+	a.Scopes = strings.Fields(scopes)
+	a.TokenTTL = time.Duration(tokenTTL) * time.Second
+
+	return a, nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import "github.com/babs-corp/babs-maps-auth/internal/domain/models"
+
+// AuditColumns are columns scanned into models.AuditEvent
+const AuditColumns = "id, type, outcome, reason, actor_id, subject_id, email, ip, user_agent, app_id, created_at"
+
+// AuditInsertSQL inserts models.AuditEvent with "?" placeholders
+const AuditInsertSQL = "INSERT INTO audit_events (" + AuditColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// AuditInsertArgs returns arguments of AuditInsertSQL
+func AuditInsertArgs(e models.AuditEvent) []any {
+	return []any{
+		e.ID, e.Type, e.Outcome, e.Reason, e.ActorID, e.SubjectID,
+		e.Email, e.IP, e.UserAgent, e.AppID, e.CreatedAt,
+	}
+}
+
+// AuditListSQL builds query selecting a page of audit events, newest
+// first. Like UsersListSQL it uses "?" placeholders and returns at most
+// q.Limit+1 rows.
+func AuditListSQL(q models.AuditQuery) (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+
+	f := q.Filter
+	if f.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, f.Type)
+	}
+	if f.Outcome != "" {
+		where = append(where, "outcome = ?")
+		args = append(args, f.Outcome)
+	}
+	if f.ActorID != nil {
+		where = append(where, "actor_id = ?")
+		args = append(args, *f.ActorID)
+	}
+	if f.SubjectID != nil {
+		where = append(where, "subject_id = ?")
+		args = append(args, *f.SubjectID)
+	}
+	if f.IP != "" {
+		where = append(where, "ip = ?")
+		args = append(args, f.IP)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.To)
+	}
+	if q.After != nil {
+		where = append(where, "(created_at, id) < (?, ?)")
+		args = append(args, q.After.CreatedAt, q.After.ID)
+	}
+
+	query := "SELECT " + AuditColumns + " FROM audit_events" + whereClause(where) +
+		" ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	return query, args
+}
@@ -0,0 +1,194 @@
+// Package cache provides caching decorators for storages.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.UserProvider = (*UserCache)(nil)
+var _ auth.UserSaver = (*UserCache)(nil)
+
+// UserCache is a read-through cache of users looked up by id, which is
+// what every token validation does. Lookups by email are not cached:
+// login must always compare against the current password hash.
+//
+// Changes made through the cache invalidate its entries. Changes made
+// elsewhere (other instances, manual SQL) are picked up after TTL, or
+// immediately if Invalidate is fed from the database, see
+// postgres.Storage.ListenUserChanges.
+type UserCache struct {
+	auth.UserProvider
+	saver auth.UserSaver
+
+	ttl time.Duration
+	now func() time.Time
+
+	mu    sync.Mutex
+	users *lru[uuid.UUID, models.User]
+	// loads are generations of users being read from the provider.
+	// Invalidate and Purge bump them, so that a user read before a change
+	// is not cached after it.
+	loads map[uuid.UUID]*load
+	epoch uint64
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+// load is a generation of a user read from the provider by one or more
+// concurrent misses
+type load struct {
+	gen     uint64
+	readers int
+}
+
+// Stats are counters of cache usage since start
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Size          int    `json:"size"`
+}
+
+// NewUserCache wraps provider and saver, keeping at most size users
+// for ttl each.
+func NewUserCache(provider auth.UserProvider, saver auth.UserSaver, size int, ttl time.Duration) *UserCache {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &UserCache{
+		UserProvider: provider,
+		saver:        saver,
+		ttl:          ttl,
+		now:          time.Now,
+		users:        newLRU[uuid.UUID, models.User](size),
+		loads:        make(map[uuid.UUID]*load),
+	}
+}
+
+func (c *UserCache) UserById(ctx context.Context, id uuid.UUID) (models.User, error) {
+	// a transaction may see its own uncommitted changes
+	if storage.InTx(ctx) {
+		return c.UserProvider.UserById(ctx, id)
+	}
+
+	now := c.now()
+
+	c.mu.Lock()
+	user, ok := c.users.get(id, now)
+	if ok {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return withOwnHash(user), nil
+	}
+	l := c.loads[id]
+	if l == nil {
+		l = &load{}
+		c.loads[id] = l
+	}
+	l.readers++
+	gen, epoch := l.gen, c.epoch
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	user, err := c.UserProvider.UserById(ctx, id)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l.readers--; l.readers == 0 {
+		delete(c.loads, id)
+	}
+	if err != nil {
+		// not found is not cached: the user may be registered any moment
+		return models.User{}, err
+	}
+	// the user may have changed while it was read
+	if l.gen == gen && c.epoch == epoch {
+		if c.users.add(id, withOwnHash(user), now.Add(c.ttl)) {
+			c.evictions.Add(1)
+		}
+	}
+
+	return user, nil
+}
+
+// withOwnHash returns user with a copy of its password hash, so that
+// callers cannot change cached users
+func withOwnHash(user models.User) models.User {
+	user.PassHash = bytes.Clone(user.PassHash)
+	return user
+}
+
+// IsAdmin is answered from the cached user
+func (c *UserCache) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
+	user, err := c.UserById(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return user.IsAdmin, nil
+}
+
+func (c *UserCache) SaveUser(ctx context.Context, email string, passHash []byte) (uuid.UUID, error) {
+	return c.saver.SaveUser(ctx, email, passHash)
+}
+
+func (c *UserCache) UpdatePassHash(ctx context.Context, userID uuid.UUID, passHash []byte) error {
+	defer c.Invalidate(userID)
+
+	return c.saver.UpdatePassHash(ctx, userID, passHash)
+}
+
+// Invalidate drops cached user. It must be called after the user is
+// updated, disabled or its roles change.
+func (c *UserCache) Invalidate(id uuid.UUID) {
+	c.mu.Lock()
+	removed := c.users.remove(id)
+	if l := c.loads[id]; l != nil {
+		l.gen++
+	}
+	c.mu.Unlock()
+
+	if removed {
+		c.invalidations.Add(1)
+	}
+}
+
+// Purge drops all cached users, e.g. when invalidations could have been missed
+func (c *UserCache) Purge() {
+	c.mu.Lock()
+	n := c.users.len()
+	c.users.purge()
+	c.epoch++
+	c.mu.Unlock()
+
+	c.invalidations.Add(uint64(n))
+}
+
+func (c *UserCache) Stats() Stats {
+	c.mu.Lock()
+	size := c.users.len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+		Size:          size,
+	}
+}
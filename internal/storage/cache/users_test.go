@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/memory"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCache(t *testing.T, size int, ttl time.Duration) (*UserCache, *memory.Storage, *time.Time) {
+	t.Helper()
+
+	st := memory.New()
+	c := NewUserCache(st, st, size, ttl)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	return c, st, &now
+}
+
+func saveUser(t *testing.T, c *UserCache) uuid.UUID {
+	t.Helper()
+
+	id, err := c.SaveUser(context.Background(), gofakeit.Email(), []byte("hash"))
+	require.NoError(t, err)
+	return id
+}
+
+func TestUserCache_ReadThrough(t *testing.T) {
+	c, st, _ := newTestCache(t, 10, time.Minute)
+	ctx := context.Background()
+	id := saveUser(t, c)
+
+	_, err := c.UserById(ctx, id)
+	require.NoError(t, err)
+
+	// served from cache although storage has changed
+	require.NoError(t, st.SetAdmin(ctx, id, true))
+	isAdmin, err := c.IsAdmin(ctx, id)
+	require.NoError(t, err)
+	assert.False(t, isAdmin)
+
+	c.Invalidate(id)
+	isAdmin, err = c.IsAdmin(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 2, Invalidations: 1, Size: 1}, c.Stats())
+}
+
+func TestUserCache_Expires(t *testing.T) {
+	c, st, now := newTestCache(t, 10, time.Minute)
+	ctx := context.Background()
+	id := saveUser(t, c)
+
+	_, err := c.UserById(ctx, id)
+	require.NoError(t, err)
+	require.NoError(t, st.SetAdmin(ctx, id, true))
+
+	*now = now.Add(time.Minute)
+
+	isAdmin, err := c.IsAdmin(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+	assert.Equal(t, uint64(2), c.Stats().Misses)
+}
+
+func TestUserCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c, _, _ := newTestCache(t, 2, time.Minute)
+	ctx := context.Background()
+	a, b, d := saveUser(t, c), saveUser(t, c), saveUser(t, c)
+
+	for _, id := range []uuid.UUID{a, b, a, d} {
+		_, err := c.UserById(ctx, id)
+		require.NoError(t, err)
+	}
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Size)
+
+	// a was used after b, so b was evicted
+	_, err := c.UserById(ctx, a)
+	require.NoError(t, err)
+	assert.Equal(t, stats.Hits+1, c.Stats().Hits)
+
+	_, err = c.UserById(ctx, b)
+	require.NoError(t, err)
+	assert.Equal(t, stats.Misses+1, c.Stats().Misses)
+}
+
+func TestUserCache_UpdateInvalidates(t *testing.T) {
+	c, _, _ := newTestCache(t, 10, time.Minute)
+	ctx := context.Background()
+	id := saveUser(t, c)
+
+	_, err := c.UserById(ctx, id)
+	require.NoError(t, err)
+
+	require.NoError(t, c.UpdatePassHash(ctx, id, []byte("new")))
+
+	user, err := c.UserById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new"), user.PassHash)
+}
+
+func TestUserCache_NotFoundIsNotCached(t *testing.T) {
+	c, _, _ := newTestCache(t, 10, time.Minute)
+	ctx := context.Background()
+
+	_, err := c.UserById(ctx, uuid.New())
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	assert.Equal(t, 0, c.Stats().Size)
+}
+
+func TestUserCache_Purge(t *testing.T) {
+	c, _, _ := newTestCache(t, 10, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := c.UserById(ctx, saveUser(t, c))
+		require.NoError(t, err)
+	}
+
+	c.Purge()
+	assert.Equal(t, Stats{Misses: 3, Invalidations: 3}, c.Stats())
+}
+
+// changingProvider changes the user right after it is read, before the
+// cache adds it
+type changingProvider struct {
+	*memory.Storage
+	change func(id uuid.UUID)
+}
+
+func (p *changingProvider) UserById(ctx context.Context, id uuid.UUID) (models.User, error) {
+	user, err := p.Storage.UserById(ctx, id)
+	if p.change != nil {
+		p.change(id)
+	}
+	return user, err
+}
+
+func TestUserCache_ChangedWhileRead(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		invalidate func(c *UserCache, id uuid.UUID)
+	}{
+		{"invalidate", func(c *UserCache, id uuid.UUID) { c.Invalidate(id) }},
+		{"purge", func(c *UserCache, _ uuid.UUID) { c.Purge() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := memory.New()
+			p := &changingProvider{Storage: st}
+			c := NewUserCache(p, st, 10, time.Minute)
+			id := saveUser(t, c)
+
+			p.change = func(id uuid.UUID) {
+				require.NoError(t, st.SetAdmin(ctx, id, true))
+				tt.invalidate(c, id)
+			}
+			user, err := c.UserById(ctx, id)
+			require.NoError(t, err)
+			assert.False(t, user.IsAdmin)
+			assert.Equal(t, 0, c.Stats().Size, "stale user must not be cached")
+
+			p.change = nil
+			isAdmin, err := c.IsAdmin(ctx, id)
+			require.NoError(t, err)
+			assert.True(t, isAdmin)
+			assert.Equal(t, 1, c.Stats().Size)
+		})
+	}
+}
+
+func TestUserCache_ReturnsOwnHash(t *testing.T) {
+	c, _, _ := newTestCache(t, 10, time.Minute)
+	ctx := context.Background()
+	id := saveUser(t, c)
+
+	user, err := c.UserById(ctx, id)
+	require.NoError(t, err)
+	user.PassHash[0] = 'x'
+
+	user, err = c.UserById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hash"), user.PassHash)
+	user.PassHash[0] = 'x'
+
+	user, err = c.UserById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hash"), user.PassHash)
+}
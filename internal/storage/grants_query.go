@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"strings"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+// GrantColumns are columns scanned by ScanGrant. Scopes are kept as one
+// space-separated string, as in OAuth scope parameters.
+const GrantColumns = "user_id, app_id, scopes, created_at, updated_at"
+
+// GrantUpsertSQL saves models.Grant keeping its creation time if the user
+// already granted the app. Arguments are GrantArgs.
+const GrantUpsertSQL = "INSERT INTO grants (" + GrantColumns + ") VALUES (?, ?, ?, ?, ?) " +
+	"ON CONFLICT (user_id, app_id) DO UPDATE SET scopes = excluded.scopes, updated_at = excluded.updated_at"
+
+// GrantArgs returns arguments of GrantUpsertSQL
+func GrantArgs(g models.Grant) []any {
+	return []any{g.UserID, g.AppID, strings.Join(g.Scopes, " "), g.CreatedAt, g.UpdatedAt}
+}
+
+// ScanGrant reads a row selected with GrantColumns
+func ScanGrant(row interface{ Scan(dest ...any) error }) (models.Grant, error) {
+	var (
+		g      models.Grant
+		scopes string
+	)
+	err := row.Scan(&g.UserID, &g.AppID, &scopes, &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		return models.Grant{}, err
+	}
+	g.Scopes = strings.Fields(scopes)
+
+	return g, nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.APIKeyStore = (*Storage)(nil)
+
+func (s *Storage) SaveAPIKey(_ context.Context, key models.APIKey) error {
+	const op = "storage.memory.SaveAPIKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.apiKeys {
+		if k.ID == key.ID || k.Prefix == key.Prefix {
+			return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyExists)
+		}
+	}
+	key.Scopes = slices.Clone(key.Scopes)
+	s.apiKeys[key.ID] = key
+
+	return nil
+}
+
+func (s *Storage) APIKey(_ context.Context, id uuid.UUID) (models.APIKey, error) {
+	const op = "storage.memory.APIKey"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) APIKeyByPrefix(_ context.Context, prefix string) (models.APIKey, error) {
+	const op = "storage.memory.APIKeyByPrefix"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+
+	return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+}
+
+// APIKeys mirrors storage.APIKeysListSQL
+func (s *Storage) APIKeys(_ context.Context, userID uuid.UUID, orgID *uuid.UUID) ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []models.APIKey
+	for _, key := range s.apiKeys {
+		if key.RevokedAt != nil {
+			continue
+		}
+		ofOrg := orgID != nil && key.OrgID != nil && *key.OrgID == *orgID
+		if key.UserID == userID || ofOrg {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b models.APIKey) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+
+	return keys, nil
+}
+
+func (s *Storage) TouchAPIKey(_ context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.memory.TouchAPIKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+	key.LastUsedAt = &at
+	s.apiKeys[id] = key
+
+	return nil
+}
+
+// RevokeAPIKey mirrors storage.APIKeyRevokeSQL
+func (s *Storage) RevokeAPIKey(_ context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.memory.RevokeAPIKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+		s.apiKeys[id] = key
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+)
+
+var _ auth.AppStore = (*Storage)(nil)
+
+func (s *Storage) CreateApp(_ context.Context, app models.App) (int, error) {
+	const op = "storage.memory.CreateApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.appExists(app) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+	}
+
+	app.ID = 1
+	for id := range s.apps {
+		app.ID = max(app.ID, id+1)
+	}
+	s.apps[app.ID] = app
+
+	return app.ID, nil
+}
+
+func (s *Storage) Apps(_ context.Context) ([]models.App, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apps := make([]models.App, 0, len(s.apps))
+	for _, app := range s.apps {
+		apps = append(apps, app)
+	}
+	slices.SortFunc(apps, func(a, b models.App) int { return a.ID - b.ID })
+
+	return apps, nil
+}
+
+func (s *Storage) UpdateApp(_ context.Context, app models.App) error {
+	const op = "storage.memory.UpdateApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.apps[app.ID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	if s.appExists(app) {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+	}
+
+	app.Secret = old.Secret
+	s.apps[app.ID] = app
+
+	return nil
+}
+
+func (s *Storage) UpdateAppSecret(_ context.Context, appID int, secret string) error {
+	const op = "storage.memory.UpdateAppSecret"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	app.Secret = secret
+	s.apps[appID] = app
+
+	return nil
+}
+
+func (s *Storage) DeleteApp(_ context.Context, appID int) error {
+	const op = "storage.memory.DeleteApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.apps[appID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	delete(s.apps, appID)
+	for key := range s.grants {
+		if key.appID == appID {
+			delete(s.grants, key)
+		}
+	}
+
+	return nil
+}
+
+// appExists reports whether another app has the name of app, which is
+// unique like in the databases
+func (s *Storage) appExists(app models.App) bool {
+	for id, other := range s.apps {
+		if id != app.ID && other.Name == app.Name {
+			return true
+		}
+	}
+
+	return false
+}
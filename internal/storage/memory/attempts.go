@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+)
+
+var _ auth.AttemptStore = (*AttemptStore)(nil)
+
+// AttemptStore keeps failed login counters in process memory.
+// It is suitable for single-node setups only.
+type AttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempts
+}
+
+func NewAttemptStore() *AttemptStore {
+	return &AttemptStore{
+		attempts: make(map[string]models.LoginAttempts),
+	}
+}
+
+func (s *AttemptStore) LoginAttempts(_ context.Context, key string) (models.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, ok := s.attempts[key]
+	if !ok {
+		return models.LoginAttempts{Key: key}, nil
+	}
+
+	return attempts, nil
+}
+
+func (s *AttemptStore) RegisterFailedLogin(_ context.Context, key string, at time.Time, since time.Time) (models.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.attempts[key]
+	if attempts.LastFailedAt.Before(since) {
+		attempts.Failures = 0
+	}
+	attempts.Key = key
+	attempts.Failures++
+	attempts.LastFailedAt = at
+	s.attempts[key] = attempts
+
+	return attempts, nil
+}
+
+func (s *AttemptStore) ResetLoginAttempts(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/google/uuid"
+)
+
+var _ auth.AuditLog = (*Storage)(nil)
+
+func (s *Storage) SaveAuditEvent(_ context.Context, event models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditEvents = append(s.auditEvents, event)
+
+	return nil
+}
+
+// AuditEvents mirrors storage.AuditListSQL
+func (s *Storage) AuditEvents(_ context.Context, q models.AuditQuery) ([]models.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []models.AuditEvent
+	for _, e := range s.auditEvents {
+		if matchAuditEvent(q, e) {
+			events = append(events, e)
+		}
+	}
+
+	slices.SortFunc(events, func(a, b models.AuditEvent) int {
+		return -compareAuditEvents(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+
+	if uint(len(events)) > q.Limit+1 {
+		events = events[:q.Limit+1]
+	}
+
+	return events, nil
+}
+
+func matchAuditEvent(q models.AuditQuery, e models.AuditEvent) bool {
+	f := q.Filter
+	switch {
+	case f.Type != "" && e.Type != f.Type,
+		f.Outcome != "" && e.Outcome != f.Outcome,
+		f.ActorID != nil && (e.ActorID == nil || *e.ActorID != *f.ActorID),
+		f.SubjectID != nil && (e.SubjectID == nil || *e.SubjectID != *f.SubjectID),
+		f.IP != "" && e.IP != f.IP,
+		!f.From.IsZero() && e.CreatedAt.Before(f.From),
+		!f.To.IsZero() && !e.CreatedAt.Before(f.To):
+		return false
+	}
+
+	if q.After != nil {
+		return compareAuditEvents(e.CreatedAt, e.ID, q.After.CreatedAt, q.After.ID) < 0
+	}
+
+	return true
+}
+
+func compareAuditEvents(aTime time.Time, aID uuid.UUID, bTime time.Time, bID uuid.UUID) int {
+	if c := aTime.Compare(bTime); c != 0 {
+		return c
+	}
+	return bytes.Compare(aID[:], bID[:])
+}
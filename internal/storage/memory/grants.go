@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.GrantStore = (*Storage)(nil)
+
+type grantKey struct {
+	userID uuid.UUID
+	appID  int
+}
+
+// SaveGrant mirrors storage.GrantUpsertSQL
+func (s *Storage) SaveGrant(_ context.Context, grant models.Grant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := grantKey{userID: grant.UserID, appID: grant.AppID}
+	if old, ok := s.grants[key]; ok {
+		grant.CreatedAt = old.CreatedAt
+	}
+	grant.Scopes = slices.Clone(grant.Scopes)
+	s.grants[key] = grant
+
+	return nil
+}
+
+func (s *Storage) Grant(_ context.Context, userID uuid.UUID, appID int) (models.Grant, error) {
+	const op = "storage.memory.Grant"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	grant, ok := s.grants[grantKey{userID: userID, appID: appID}]
+	if !ok {
+		return models.Grant{}, fmt.Errorf("%s: %w", op, storage.ErrGrantNotFound)
+	}
+
+	return grant, nil
+}
+
+func (s *Storage) Grants(_ context.Context, userID uuid.UUID) ([]models.Grant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var grants []models.Grant
+	for key, grant := range s.grants {
+		if key.userID == userID {
+			grants = append(grants, grant)
+		}
+	}
+	slices.SortFunc(grants, func(a, b models.Grant) int {
+		return cmp.Compare(a.AppID, b.AppID)
+	})
+
+	return grants, nil
+}
+
+func (s *Storage) DeleteGrant(_ context.Context, userID uuid.UUID, appID int) error {
+	const op = "storage.memory.DeleteGrant"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := grantKey{userID: userID, appID: appID}
+	if _, ok := s.grants[key]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGrantNotFound)
+	}
+	delete(s.grants, key)
+
+	return nil
+}
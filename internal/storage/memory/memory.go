@@ -0,0 +1,335 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.UserProvider = (*Storage)(nil)
+var _ auth.UserSaver = (*Storage)(nil)
+var _ auth.AppProvider = (*Storage)(nil)
+var _ auth.AttemptStore = (*Storage)(nil)
+
+// Storage keeps all data in process memory. It is safe for concurrent use
+// and is meant for unit tests and ephemeral demos: data is lost on restart.
+type Storage struct {
+	*AttemptStore
+
+	// txMu serializes transactions
+	txMu sync.Mutex
+
+	mu      sync.RWMutex
+	users   map[uuid.UUID]models.User
+	byEmail map[string]uuid.UUID
+	apps    map[int]models.App
+
+	auditEvents []models.AuditEvent
+	outbox      []models.OutboxEvent
+
+	webhookSubs       map[uuid.UUID]models.WebhookSubscription
+	webhookDeliveries []models.WebhookDelivery
+
+	sessions map[uuid.UUID]models.Session
+	grants   map[grantKey]models.Grant
+	apiKeys  map[uuid.UUID]models.APIKey
+
+	quotas       map[models.QuotaSubject]models.Quota
+	quotaBuckets map[models.QuotaSubject]models.QuotaBucket
+	quotaUsage   map[usageKey]int64
+}
+
+func New() *Storage {
+	return &Storage{
+		AttemptStore: NewAttemptStore(),
+		users:        make(map[uuid.UUID]models.User),
+		byEmail:      make(map[string]uuid.UUID),
+		apps:         make(map[int]models.App),
+		webhookSubs:  make(map[uuid.UUID]models.WebhookSubscription),
+		sessions:     make(map[uuid.UUID]models.Session),
+		grants:       make(map[grantKey]models.Grant),
+		apiKeys:      make(map[uuid.UUID]models.APIKey),
+		quotas:       make(map[models.QuotaSubject]models.Quota),
+		quotaBuckets: make(map[models.QuotaSubject]models.QuotaBucket),
+		quotaUsage:   make(map[usageKey]int64),
+	}
+}
+
+func (s *Storage) Close() error {
+	return nil
+}
+
+func (s *Storage) SaveUser(_ context.Context, email string, passHash []byte) (uuid.UUID, error) {
+	const op = "storage.memory.SaveUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byEmail[email]; ok {
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	user := models.User{
+		ID:        uuid.New(),
+		Email:     email,
+		PassHash:  bytes.Clone(passHash),
+		CreatedAt: time.Now().UTC(),
+	}
+	s.users[user.ID] = user
+	s.byEmail[email] = user.ID
+
+	return user.ID, nil
+}
+
+func (s *Storage) UpdatePassHash(_ context.Context, userID uuid.UUID, passHash []byte) error {
+	const op = "storage.memory.UpdatePassHash"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	user.PassHash = bytes.Clone(passHash)
+	s.users[userID] = user
+
+	return nil
+}
+
+// SetAdmin grants or revokes admin role. There is no API for it yet,
+// it lets tests and demos seed admin users.
+func (s *Storage) SetAdmin(_ context.Context, userID uuid.UUID, isAdmin bool) error {
+	const op = "storage.memory.SetAdmin"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	user.IsAdmin = isAdmin
+	s.users[userID] = user
+
+	return nil
+}
+
+// SetDisabled disables or enables the user. There is no API for it yet,
+// it lets tests and demos seed disabled users.
+func (s *Storage) SetDisabled(_ context.Context, userID uuid.UUID, disabled bool) error {
+	const op = "storage.memory.SetDisabled"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	user.Disabled = disabled
+	s.users[userID] = user
+
+	return nil
+}
+
+// SetOrg moves the user to organization orgID, nil removes them from
+// their organization. There is no API for it yet, it lets tests and demos
+// seed organizations.
+func (s *Storage) SetOrg(_ context.Context, userID uuid.UUID, orgID *uuid.UUID) error {
+	const op = "storage.memory.SetOrg"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	user.OrgID = orgID
+	s.users[userID] = cloneUser(user)
+
+	return nil
+}
+
+func (s *Storage) User(_ context.Context, email string) (models.User, error) {
+	const op = "storage.memory.User"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byEmail[email]
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return cloneUser(s.users[id]), nil
+}
+
+func (s *Storage) UserById(_ context.Context, id uuid.UUID) (models.User, error) {
+	const op = "storage.memory.UserById"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return cloneUser(user), nil
+}
+
+func (s *Storage) IsAdmin(_ context.Context, userID uuid.UUID) (bool, error) {
+	const op = "storage.memory.IsAdmin"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return user.IsAdmin, nil
+}
+
+// ListUsers mirrors storage.UsersListSQL: it returns at most q.Limit+1
+// users so that the caller can tell whether there is a next page.
+func (s *Storage) ListUsers(_ context.Context, q models.UserQuery) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var users []models.User
+	for _, u := range s.users {
+		if matchUser(q.Filter, u) && afterCursor(q, u) {
+			users = append(users, u)
+		}
+	}
+
+	slices.SortFunc(users, func(a, b models.User) int {
+		c := compareUsers(q.Sort, a, b)
+		if q.Desc {
+			return -c
+		}
+		return c
+	})
+
+	if uint(len(users)) > q.Limit+1 {
+		users = users[:q.Limit+1]
+	}
+	for i := range users {
+		users[i] = cloneUser(users[i])
+	}
+
+	return users, nil
+}
+
+func (s *Storage) CountUsers(_ context.Context, f models.UserFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n int
+	for _, u := range s.users {
+		if matchUser(f, u) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// SaveApp adds or replaces app
+func (s *Storage) SaveApp(_ context.Context, app models.App) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apps[app.ID] = app
+
+	return nil
+}
+
+func (s *Storage) App(_ context.Context, appID int) (models.App, error) {
+	const op = "storage.memory.App"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	app, ok := s.apps[appID]
+	if !ok {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return app, nil
+}
+
+func matchUser(f models.UserFilter, u models.User) bool {
+	if f.EmailPrefix != "" && !strings.HasPrefix(u.Email, f.EmailPrefix) {
+		return false
+	}
+	if f.Role != "" && u.Role() != f.Role {
+		return false
+	}
+	if f.Verified != nil && u.Verified != *f.Verified {
+		return false
+	}
+	if f.Disabled != nil && u.Disabled != *f.Disabled {
+		return false
+	}
+	if f.OrgID != nil && (u.OrgID == nil || *u.OrgID != *f.OrgID) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && u.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !u.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+func afterCursor(q models.UserQuery, u models.User) bool {
+	if q.After == nil {
+		return true
+	}
+
+	cursor := models.User{
+		ID:        q.After.ID,
+		Email:     q.After.Email,
+		CreatedAt: q.After.CreatedAt,
+	}
+	c := compareUsers(q.Sort, u, cursor)
+	if q.Desc {
+		return c < 0
+	}
+	return c > 0
+}
+
+func compareUsers(sort string, a, b models.User) int {
+	if sort == models.UserSortEmail {
+		return strings.Compare(a.Email, b.Email)
+	}
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+func cloneUser(u models.User) models.User {
+	u.PassHash = bytes.Clone(u.PassHash)
+	if u.OrgID != nil {
+		orgID := *u.OrgID
+		u.OrgID = &orgID
+	}
+	return u
+}
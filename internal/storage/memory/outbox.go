@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/services/outbox"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.EventOutbox = (*Storage)(nil)
+var _ outbox.Store = (*Storage)(nil)
+
+func (s *Storage) SaveEvent(_ context.Context, event models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.Payload = slices.Clone(event.Payload)
+	s.outbox = append(s.outbox, models.OutboxEvent{
+		Event:         event,
+		NextAttemptAt: event.CreatedAt,
+	})
+
+	return nil
+}
+
+func (s *Storage) ClaimEvents(_ context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// events are appended in order of creation
+	var events []models.OutboxEvent
+	for i := range s.outbox {
+		if len(events) == limit {
+			break
+		}
+
+		e := &s.outbox[i]
+		if e.PublishedAt != nil || e.NextAttemptAt.After(now) {
+			continue
+		}
+		e.NextAttemptAt = leaseUntil
+
+		claimed := *e
+		claimed.Payload = slices.Clone(e.Payload)
+		events = append(events, claimed)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) MarkEventPublished(_ context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.memory.MarkEventPublished"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.outboxEvent(id)
+	if e == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrEventNotFound)
+	}
+	e.PublishedAt = &at
+
+	return nil
+}
+
+func (s *Storage) MarkEventFailed(_ context.Context, id uuid.UUID, nextAttemptAt time.Time, reason string) error {
+	const op = "storage.memory.MarkEventFailed"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.outboxEvent(id)
+	if e == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrEventNotFound)
+	}
+	e.Attempts++
+	e.NextAttemptAt = nextAttemptAt
+	e.LastError = reason
+
+	return nil
+}
+
+func (s *Storage) DeletePublishedEvents(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.outbox)
+	s.outbox = slices.DeleteFunc(s.outbox, func(e models.OutboxEvent) bool {
+		return e.PublishedAt != nil && e.PublishedAt.Before(before)
+	})
+
+	return int64(n - len(s.outbox)), nil
+}
+
+// OutboxEvents returns all events of the outbox, published or not. There
+// is no API for it, it lets tests inspect the outbox.
+func (s *Storage) OutboxEvents() []models.OutboxEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.outbox)
+}
+
+func (s *Storage) outboxEvent(id uuid.UUID) *models.OutboxEvent {
+	for i := range s.outbox {
+		if s.outbox[i].ID == id {
+			return &s.outbox[i]
+		}
+	}
+	return nil
+}
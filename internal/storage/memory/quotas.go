@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/quota"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+)
+
+var _ quota.Store = (*Storage)(nil)
+
+type usageKey struct {
+	subject models.QuotaSubject
+	period  string
+	start   time.Time
+}
+
+func (s *Storage) SaveQuota(_ context.Context, q models.Quota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quotas[q.QuotaSubject] = q
+
+	return nil
+}
+
+func (s *Storage) Quota(_ context.Context, subject models.QuotaSubject) (models.Quota, error) {
+	const op = "storage.memory.Quota"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	q, ok := s.quotas[subject]
+	if !ok {
+		return models.Quota{}, fmt.Errorf("%s: %w", op, storage.ErrQuotaNotFound)
+	}
+
+	return q, nil
+}
+
+func (s *Storage) Quotas(_ context.Context) ([]models.Quota, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var quotas []models.Quota
+	for _, q := range s.quotas {
+		quotas = append(quotas, q)
+	}
+	slices.SortFunc(quotas, func(a, b models.Quota) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.ID, b.ID))
+	})
+
+	return quotas, nil
+}
+
+func (s *Storage) DeleteQuota(_ context.Context, subject models.QuotaSubject) error {
+	const op = "storage.memory.DeleteQuota"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.quotas[subject]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrQuotaNotFound)
+	}
+	delete(s.quotas, subject)
+
+	return nil
+}
+
+// LockQuotaBucket relies on InTx serializing transactions
+func (s *Storage) LockQuotaBucket(
+	_ context.Context,
+	subject models.QuotaSubject,
+	tokens float64,
+	now time.Time,
+) (models.QuotaBucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.quotaBuckets[subject]
+	if !ok {
+		b = models.QuotaBucket{Tokens: tokens, UpdatedAt: now}
+		s.quotaBuckets[subject] = b
+	}
+
+	return b, nil
+}
+
+func (s *Storage) SaveQuotaBucket(_ context.Context, subject models.QuotaSubject, b models.QuotaBucket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quotaBuckets[subject] = b
+
+	return nil
+}
+
+func (s *Storage) QuotaUsage(
+	_ context.Context,
+	subject models.QuotaSubject,
+	period string,
+	start time.Time,
+) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.quotaUsage[usageKey{subject: subject, period: period, start: start.UTC()}], nil
+}
+
+func (s *Storage) AddQuotaUsage(
+	_ context.Context,
+	subject models.QuotaSubject,
+	period string,
+	start time.Time,
+	n int64,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quotaUsage[usageKey{subject: subject, period: period, start: start.UTC()}] += n
+
+	return nil
+}
+
+// UsageReport mirrors storage.UsageReportSQL
+func (s *Storage) UsageReport(_ context.Context, f models.UsageFilter) ([]models.QuotaUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var report []models.QuotaUsage
+	for key, used := range s.quotaUsage {
+		switch {
+		case f.Period != "" && key.period != f.Period,
+			f.Subject != nil && key.subject.Type != f.Subject.Type,
+			f.Subject != nil && f.Subject.ID != "" && key.subject.ID != f.Subject.ID,
+			!f.From.IsZero() && key.start.Before(f.From),
+			!f.To.IsZero() && !key.start.Before(f.To):
+			continue
+		}
+		report = append(report, models.QuotaUsage{
+			QuotaSubject: key.subject,
+			Period:       key.period,
+			Start:        key.start,
+			Used:         used,
+		})
+	}
+	slices.SortFunc(report, func(a, b models.QuotaUsage) int {
+		return cmp.Or(
+			a.Start.Compare(b.Start),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.ID, b.ID),
+			cmp.Compare(a.Period, b.Period),
+		)
+	})
+
+	return report, nil
+}
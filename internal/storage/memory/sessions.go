@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.SessionStore = (*Storage)(nil)
+
+func (s *Storage) SaveSession(_ context.Context, session models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = session
+
+	return nil
+}
+
+func (s *Storage) Session(_ context.Context, id uuid.UUID) (models.Session, error) {
+	const op = "storage.memory.Session"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	return session, nil
+}
+
+func (s *Storage) SessionByCookie(_ context.Context, cookieHash []byte) (models.Session, error) {
+	const op = "storage.memory.SessionByCookie"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, session := range s.sessions {
+		if session.CookieHash != nil && bytes.Equal(session.CookieHash, cookieHash) {
+			return session, nil
+		}
+	}
+
+	return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+}
+
+// Sessions mirrors storage.SessionsListSQL
+func (s *Storage) Sessions(_ context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sessions []models.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.Active(now) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	slices.SortFunc(sessions, func(a, b models.Session) int {
+		if c := b.LastSeenAt.Compare(a.LastSeenAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+
+	return sessions, nil
+}
+
+func (s *Storage) TouchSession(_ context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.memory.TouchSession"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+	session.LastSeenAt = at
+	s.sessions[id] = session
+
+	return nil
+}
+
+func (s *Storage) RevokeSession(_ context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.memory.RevokeSession"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+	if session.RevokedAt == nil {
+		session.RevokedAt = &at
+		s.sessions[id] = session
+	}
+
+	return nil
+}
+
+// RevokeAppSessions mirrors storage.SessionRevokeAppSQL
+func (s *Storage) RevokeAppSessions(_ context.Context, userID uuid.UUID, appID int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID && session.AppID == appID && session.RevokedAt == nil && !session.IsBrowser() {
+			session.RevokedAt = &at
+			s.sessions[id] = session
+		}
+	}
+
+	return nil
+}
+
+// RevokeAllAppSessions mirrors storage.SessionRevokeAllAppSQL
+func (s *Storage) RevokeAllAppSessions(_ context.Context, appID int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.AppID == appID && session.RevokedAt == nil && !session.IsBrowser() {
+			session.RevokedAt = &at
+			s.sessions[id] = session
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.TxManager = (*Storage)(nil)
+
+// snapshot is a copy of storage data taken at the start of a transaction
+type snapshot struct {
+	users   map[uuid.UUID]models.User
+	byEmail map[string]uuid.UUID
+	apps    map[int]models.App
+
+	auditEvents []models.AuditEvent
+	outbox      []models.OutboxEvent
+
+	webhookSubs       map[uuid.UUID]models.WebhookSubscription
+	webhookDeliveries []models.WebhookDelivery
+
+	sessions map[uuid.UUID]models.Session
+	grants   map[grantKey]models.Grant
+	apiKeys  map[uuid.UUID]models.APIKey
+
+	quotas       map[models.QuotaSubject]models.Quota
+	quotaBuckets map[models.QuotaSubject]models.QuotaBucket
+	quotaUsage   map[usageKey]int64
+}
+
+// InTx runs fn and restores data as it was before the call if fn fails.
+// Transactions are serialized, but writes made outside of them
+// concurrently with a failing transaction are lost as well; the storage
+// is meant for tests and demos.
+func (s *Storage) InTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if storage.InTx(ctx) {
+		return fn(ctx)
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	snap := s.snapshot()
+	defer func() {
+		if p := recover(); p != nil {
+			s.restore(snap)
+			panic(p)
+		}
+	}()
+
+	if err := fn(storage.ContextWithTx(ctx, s)); err != nil {
+		s.restore(snap)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) snapshot() snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return snapshot{
+		users:   maps.Clone(s.users),
+		byEmail: maps.Clone(s.byEmail),
+		apps:    maps.Clone(s.apps),
+
+		auditEvents: slices.Clone(s.auditEvents),
+		outbox:      slices.Clone(s.outbox),
+
+		webhookSubs:       maps.Clone(s.webhookSubs),
+		webhookDeliveries: slices.Clone(s.webhookDeliveries),
+
+		sessions: maps.Clone(s.sessions),
+		grants:   maps.Clone(s.grants),
+		apiKeys:  maps.Clone(s.apiKeys),
+
+		quotas:       maps.Clone(s.quotas),
+		quotaBuckets: maps.Clone(s.quotaBuckets),
+		quotaUsage:   maps.Clone(s.quotaUsage),
+	}
+}
+
+func (s *Storage) restore(snap snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = snap.users
+	s.byEmail = snap.byEmail
+	s.apps = snap.apps
+	s.auditEvents = snap.auditEvents
+	s.outbox = snap.outbox
+	s.webhookSubs = snap.webhookSubs
+	s.webhookDeliveries = snap.webhookDeliveries
+	s.sessions = snap.sessions
+	s.grants = snap.grants
+	s.apiKeys = snap.apiKeys
+	s.quotas = snap.quotas
+	s.quotaBuckets = snap.quotaBuckets
+	s.quotaUsage = snap.quotaUsage
+}
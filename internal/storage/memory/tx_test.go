@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_InTx(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+
+	id, err := s.SaveUser(ctx, "kept@example.com", []byte("hash"))
+	require.NoError(t, err)
+
+	err = s.InTx(ctx, func(ctx context.Context) error {
+		assert.True(t, storage.InTx(ctx))
+
+		if _, err := s.SaveUser(ctx, "dropped@example.com", []byte("hash")); err != nil {
+			return err
+		}
+		if err := s.UpdatePassHash(ctx, id, []byte("new")); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+
+	_, err = s.User(ctx, "dropped@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	user, err := s.UserById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hash"), user.PassHash)
+
+	err = s.InTx(ctx, func(ctx context.Context) error {
+		return s.UpdatePassHash(ctx, id, []byte("new"))
+	})
+	require.NoError(t, err)
+
+	user, err = s.UserById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new"), user.PassHash)
+}
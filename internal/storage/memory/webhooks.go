@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/webhooks"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ webhooks.Store = (*Storage)(nil)
+
+func (s *Storage) SaveWebhookSubscription(_ context.Context, sub models.WebhookSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhookSubs[sub.ID] = cloneSubscription(sub)
+
+	return nil
+}
+
+func (s *Storage) WebhookSubscription(_ context.Context, id uuid.UUID) (models.WebhookSubscription, error) {
+	const op = "storage.memory.WebhookSubscription"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.webhookSubs[id]
+	if !ok {
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, storage.ErrWebhookSubscriptionNotFound)
+	}
+
+	return cloneSubscription(sub), nil
+}
+
+func (s *Storage) WebhookSubscriptions(_ context.Context) ([]models.WebhookSubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := make([]models.WebhookSubscription, 0, len(s.webhookSubs))
+	for _, sub := range s.webhookSubs {
+		subs = append(subs, cloneSubscription(sub))
+	}
+	slices.SortFunc(subs, func(a, b models.WebhookSubscription) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+
+	return subs, nil
+}
+
+func (s *Storage) DeleteWebhookSubscription(_ context.Context, id uuid.UUID) error {
+	const op = "storage.memory.DeleteWebhookSubscription"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhookSubs[id]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookSubscriptionNotFound)
+	}
+	delete(s.webhookSubs, id)
+	s.webhookDeliveries = slices.DeleteFunc(s.webhookDeliveries, func(d models.WebhookDelivery) bool {
+		return d.SubscriptionID == id
+	})
+
+	return nil
+}
+
+func (s *Storage) SaveWebhookDeliveries(_ context.Context, deliveries []models.WebhookDelivery) error {
+	const op = "storage.memory.SaveWebhookDeliveries"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range deliveries {
+		if _, ok := s.webhookSubs[d.SubscriptionID]; !ok {
+			return fmt.Errorf("%s: %w", op, storage.ErrWebhookSubscriptionNotFound)
+		}
+
+		duplicate := slices.ContainsFunc(s.webhookDeliveries, func(stored models.WebhookDelivery) bool {
+			return stored.SubscriptionID == d.SubscriptionID && stored.EventID == d.EventID
+		})
+		if !duplicate {
+			s.webhookDeliveries = append(s.webhookDeliveries, cloneDelivery(d))
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) ClaimWebhookDeliveries(_ context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// deliveries are appended in order of creation
+	var deliveries []models.WebhookDelivery
+	for i := range s.webhookDeliveries {
+		if len(deliveries) == limit {
+			break
+		}
+
+		d := &s.webhookDeliveries[i]
+		if d.Status != models.WebhookPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		d.NextAttemptAt = leaseUntil
+		deliveries = append(deliveries, cloneDelivery(*d))
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) UpdateWebhookDelivery(_ context.Context, d models.WebhookDelivery) error {
+	const op = "storage.memory.UpdateWebhookDelivery"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.webhookDelivery(d.ID)
+	if stored == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookDeliveryNotFound)
+	}
+	stored.Status = d.Status
+	stored.Attempts = d.Attempts
+	stored.NextAttemptAt = d.NextAttemptAt
+	stored.LastStatus = d.LastStatus
+	stored.LastError = d.LastError
+	stored.DeliveredAt = d.DeliveredAt
+
+	return nil
+}
+
+func (s *Storage) WebhookDelivery(_ context.Context, id uuid.UUID) (models.WebhookDelivery, error) {
+	const op = "storage.memory.WebhookDelivery"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d := s.webhookDelivery(id)
+	if d == nil {
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, storage.ErrWebhookDeliveryNotFound)
+	}
+
+	return cloneDelivery(*d), nil
+}
+
+// WebhookDeliveries mirrors storage.WebhookDeliveriesListSQL
+func (s *Storage) WebhookDeliveries(_ context.Context, q models.WebhookDeliveryQuery) ([]models.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, d := range s.webhookDeliveries {
+		if matchDelivery(q, d) {
+			deliveries = append(deliveries, cloneDelivery(d))
+		}
+	}
+
+	slices.SortFunc(deliveries, func(a, b models.WebhookDelivery) int {
+		return -compareDeliveries(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+	})
+	if uint(len(deliveries)) > q.Limit+1 {
+		deliveries = deliveries[:q.Limit+1]
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) webhookDelivery(id uuid.UUID) *models.WebhookDelivery {
+	for i := range s.webhookDeliveries {
+		if s.webhookDeliveries[i].ID == id {
+			return &s.webhookDeliveries[i]
+		}
+	}
+	return nil
+}
+
+func matchDelivery(q models.WebhookDeliveryQuery, d models.WebhookDelivery) bool {
+	f := q.Filter
+	if f.SubscriptionID != nil && d.SubscriptionID != *f.SubscriptionID {
+		return false
+	}
+	if f.EventID != nil && d.EventID != *f.EventID {
+		return false
+	}
+	if f.Status != "" && d.Status != f.Status {
+		return false
+	}
+	if q.After != nil && compareDeliveries(d.CreatedAt, d.ID, q.After.CreatedAt, q.After.ID) >= 0 {
+		return false
+	}
+
+	return true
+}
+
+func compareDeliveries(aCreatedAt time.Time, aID uuid.UUID, bCreatedAt time.Time, bID uuid.UUID) int {
+	if c := aCreatedAt.Compare(bCreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(aID[:], bID[:])
+}
+
+func cloneSubscription(sub models.WebhookSubscription) models.WebhookSubscription {
+	sub.EventTypes = slices.Clone(sub.EventTypes)
+	return sub
+}
+
+func cloneDelivery(d models.WebhookDelivery) models.WebhookDelivery {
+	d.Payload = bytes.Clone(d.Payload)
+	return d
+}
@@ -0,0 +1,131 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/babs-corp/babs-maps-auth/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+const (
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+
+	DefaultTable = "migrations"
+)
+
+var (
+	ErrUnsupportedDSN = errors.New("unsupported dsn: expected sqlite:// or postgres:// scheme")
+	ErrSchemaTooNew   = errors.New("database schema is newer than this binary supports")
+	ErrDirty          = errors.New("database schema is dirty, fix it and force version with cmd/migrator")
+)
+
+// DatabaseURL converts storage dsn into golang-migrate database url and
+// returns name of the backend, which is also the name of its migrations
+// directory.
+func DatabaseURL(dsn string, table string) (databaseURL string, backend string, err error) {
+	switch {
+	case strings.HasPrefix(dsn, "sqlite://"):
+		backend = BackendSQLite
+		databaseURL = "sqlite3://" + strings.TrimPrefix(dsn, "sqlite://")
+	case strings.HasPrefix(dsn, "postgres://"):
+		backend = BackendPostgres
+		databaseURL = "pgx5://" + strings.TrimPrefix(dsn, "postgres://")
+	case strings.HasPrefix(dsn, "postgresql://"):
+		backend = BackendPostgres
+		databaseURL = "pgx5://" + strings.TrimPrefix(dsn, "postgresql://")
+	default:
+		return "", "", ErrUnsupportedDSN
+	}
+
+	sep := "?"
+	if strings.Contains(databaseURL, "?") {
+		sep = "&"
+	}
+	databaseURL += sep + "x-migrations-table=" + url.QueryEscape(table)
+
+	return databaseURL, backend, nil
+}
+
+// Up applies embedded migrations to the database at dsn.
+//
+// golang-migrate holds a lock for the whole run: pg_advisory_lock on
+// Postgres, so instances starting together apply migrations once. It fails
+// if the database was migrated by a newer binary or is left dirty; both
+// are checked by m.Up under the lock, so that another instance cannot
+// change the version between the check and the migration.
+func Up(log *slog.Logger, dsn string) error {
+	const op = "migrator.Up"
+
+	log = log.With(slog.String("op", op))
+
+	databaseURL, backend, err := DatabaseURL(dsn, DefaultTable)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	src, err := iofs.New(migrations.FS, backend)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	latest, err := latestVersion(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, databaseURL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer m.Close()
+
+	var dirty migrate.ErrDirty
+	err = m.Up()
+	switch {
+	case errors.Is(err, migrate.ErrNoChange):
+		log.Info("schema is up to date", slog.Uint64("version", uint64(latest)))
+		return nil
+	case errors.As(err, &dirty):
+		return fmt.Errorf("%s: version %d: %w", op, dirty.Version, ErrDirty)
+	case errors.Is(err, fs.ErrNotExist):
+		// the database is at a version this binary has no migration for
+		if current, _, verr := m.Version(); verr == nil && current > latest {
+			return fmt.Errorf("%s: version %d, supported %d: %w", op, current, latest, ErrSchemaTooNew)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	case err != nil:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("migrations applied", slog.Uint64("version", uint64(latest)))
+
+	return nil
+}
+
+func latestVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
@@ -0,0 +1,114 @@
+package migrator
+
+import (
+	"database/sql"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/slogdiscard"
+	"github.com/babs-corp/babs-maps-auth/migrations"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// migrated applies embedded sqlite migrations to a new database and
+// returns its path and the latest version
+func migrated(t *testing.T) (string, uint) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+	require.NoError(t, Up(slog.New(slogdiscard.NewDiscardHandler()), "sqlite://"+path))
+
+	src, err := iofs.New(migrations.FS, BackendSQLite)
+	require.NoError(t, err)
+	latest, err := latestVersion(src)
+	require.NoError(t, err)
+
+	return path, latest
+}
+
+// setVersion writes the migrations table as a crashed or newer binary
+// would leave it
+func setVersion(t *testing.T, path string, version uint, dirty bool) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("UPDATE "+DefaultTable+" SET version = ?, dirty = ?", version, dirty)
+	require.NoError(t, err)
+}
+
+func version(t *testing.T, path string) (uint, bool) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var (
+		v     uint
+		dirty bool
+	)
+	require.NoError(t, db.QueryRow("SELECT version, dirty FROM "+DefaultTable).Scan(&v, &dirty))
+	return v, dirty
+}
+
+func TestUp(t *testing.T) {
+	path, latest := migrated(t)
+
+	v, dirty := version(t, path)
+	assert.Equal(t, latest, v)
+	assert.False(t, dirty)
+
+	// up to date
+	require.NoError(t, Up(slog.New(slogdiscard.NewDiscardHandler()), "sqlite://"+path))
+}
+
+func TestUp_SchemaTooNew(t *testing.T) {
+	path, latest := migrated(t)
+	setVersion(t, path, latest+1, false)
+
+	err := Up(slog.New(slogdiscard.NewDiscardHandler()), "sqlite://"+path)
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+
+	v, _ := version(t, path)
+	assert.Equal(t, latest+1, v)
+}
+
+func TestUp_Dirty(t *testing.T) {
+	path, latest := migrated(t)
+	setVersion(t, path, latest-1, true)
+
+	err := Up(slog.New(slogdiscard.NewDiscardHandler()), "sqlite://"+path)
+	assert.ErrorIs(t, err, ErrDirty)
+
+	// nothing is applied over a dirty schema
+	v, dirty := version(t, path)
+	assert.Equal(t, latest-1, v)
+	assert.True(t, dirty)
+}
+
+func TestUp_UnsupportedDSN(t *testing.T) {
+	err := Up(slog.New(slogdiscard.NewDiscardHandler()), "mysql://localhost/sso")
+	assert.ErrorIs(t, err, ErrUnsupportedDSN)
+}
+
+func TestLatestVersion(t *testing.T) {
+	src, err := iofs.New(fstest.MapFS{
+		"m/1_init.up.sql":      {Data: []byte("SELECT 1;")},
+		"m/1_init.down.sql":    {Data: []byte("SELECT 1;")},
+		"m/3_third.up.sql":     {Data: []byte("SELECT 1;")},
+		"m/20_twenty.up.sql":   {Data: []byte("SELECT 1;")},
+		"m/20_twenty.down.sql": {Data: []byte("SELECT 1;")},
+	}, "m")
+	require.NoError(t, err)
+
+	latest, err := latestVersion(src)
+	require.NoError(t, err)
+	assert.Equal(t, uint(20), latest)
+}
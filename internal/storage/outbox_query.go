@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+// OutboxColumns are columns scanned by ScanOutboxEvents
+const OutboxColumns = "id, type, subject_id, payload, created_at, attempts, next_attempt_at, last_error, published_at"
+
+// OutboxInsertSQL inserts models.Event with "?" placeholders. The first
+// attempt is due right away.
+const OutboxInsertSQL = "INSERT INTO outbox (id, type, subject_id, payload, created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?)"
+
+// OutboxInsertArgs returns arguments of OutboxInsertSQL
+func OutboxInsertArgs(e models.Event) []any {
+	return []any{e.ID, e.Type, e.SubjectID, string(e.Payload), e.CreatedAt, e.CreatedAt}
+}
+
+// OutboxClaimSQL moves next attempt of due events to the lease deadline
+// and returns them. Arguments are leaseUntil, now and limit. lock is
+// appended to the subquery, Postgres passes "FOR UPDATE SKIP LOCKED" so
+// that concurrent relays claim different events.
+func OutboxClaimSQL(lock string) string {
+	return `UPDATE outbox SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND next_attempt_at <= ?
+			ORDER BY created_at, id
+			LIMIT ? ` + lock + `
+		)
+		RETURNING ` + OutboxColumns
+}
+
+// ScanOutboxEvents reads rows selected with OutboxColumns
+func ScanOutboxEvents(rows *sql.Rows) ([]models.OutboxEvent, error) {
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var (
+			e       models.OutboxEvent
+			payload []byte
+		)
+		err := rows.Scan(&e.ID, &e.Type, &e.SubjectID, &payload, &e.CreatedAt,
+			&e.Attempts, &e.NextAttemptAt, &e.LastError, &e.PublishedAt)
+		if err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ auth.APIKeyStore = (*Storage)(nil)
+
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	const op = "storage.pgx.SaveAPIKey"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, s.db.Rebind(storage.APIKeyInsertSQL), storage.APIKeyArgs(key)...); err != nil {
+		var e *pgconn.PgError
+		if errors.As(err, &e) && e.Code == UniqueViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) APIKey(ctx context.Context, id uuid.UUID) (models.APIKey, error) {
+	const op = "storage.pgx.APIKey"
+
+	row := s.conn(ctx).QueryRowContext(ctx, "SELECT "+storage.APIKeyColumns+" FROM api_keys WHERE id = $1", id)
+
+	key, err := storage.ScanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+		}
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) APIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	const op = "storage.pgx.APIKeyByPrefix"
+
+	row := s.conn(ctx).QueryRowContext(ctx, "SELECT "+storage.APIKeyColumns+" FROM api_keys WHERE prefix = $1", prefix)
+
+	key, err := storage.ScanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+		}
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) APIKeys(ctx context.Context, userID uuid.UUID, orgID *uuid.UUID) ([]models.APIKey, error) {
+	const op = "storage.pgx.APIKeys"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, s.db.Rebind(storage.APIKeysListSQL), userID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := storage.ScanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.pgx.TouchAPIKey"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "UPDATE api_keys SET last_used_at = $1 WHERE id = $2", at, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.pgx.RevokeAPIKey"
+
+	res, err := s.conn(ctx).ExecContext(ctx, s.db.Rebind(storage.APIKeyRevokeSQL), at, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
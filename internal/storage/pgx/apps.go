@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ auth.AppStore = (*Storage)(nil)
+
+func (s *Storage) CreateApp(ctx context.Context, app models.App) (int, error) {
+	const op = "storage.pgx.CreateApp"
+
+	var id int
+	err := s.conn(ctx).GetContext(ctx, &id, s.db.Rebind(storage.AppInsertSQL+" RETURNING id"), storage.AppArgs(app)...)
+	if err != nil {
+		var e *pgconn.PgError
+		if errors.As(err, &e) && e.Code == UniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "storage.pgx.Apps"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, "SELECT "+storage.AppColumns+" FROM apps ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		app, err := storage.ScanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+func (s *Storage) UpdateApp(ctx context.Context, app models.App) error {
+	const op = "storage.pgx.UpdateApp"
+
+	res, err := s.conn(ctx).ExecContext(ctx, s.db.Rebind(storage.AppUpdateSQL), storage.AppUpdateArgs(app)...)
+	if err != nil {
+		var e *pgconn.PgError
+		if errors.As(err, &e) && e.Code == UniqueViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) UpdateAppSecret(ctx context.Context, appID int, secret string) error {
+	const op = "storage.pgx.UpdateAppSecret"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "UPDATE apps SET secret = $1 WHERE id = $2", secret, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteApp(ctx context.Context, appID int) error {
+	const op = "storage.pgx.DeleteApp"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM apps WHERE id = $1", appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+)
+
+var _ auth.AttemptStore = (*Storage)(nil)
+
+func (s *Storage) LoginAttempts(ctx context.Context, key string) (models.LoginAttempts, error) {
+	const op = "storage.pgx.LoginAttempts"
+
+	var attempts models.LoginAttempts
+	err := s.conn(ctx).GetContext(ctx, &attempts,
+		"SELECT key, failures, last_failed_at FROM login_attempts WHERE key = $1", key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginAttempts{Key: key}, nil
+		}
+
+		return models.LoginAttempts{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attempts, nil
+}
+
+func (s *Storage) RegisterFailedLogin(ctx context.Context, key string, at time.Time, since time.Time) (models.LoginAttempts, error) {
+	const op = "storage.pgx.RegisterFailedLogin"
+
+	var attempts models.LoginAttempts
+	err := s.conn(ctx).GetContext(ctx, &attempts, `
+		INSERT INTO login_attempts (key, failures, last_failed_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failed_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failed_at = EXCLUDED.last_failed_at
+		RETURNING key, failures, last_failed_at`,
+		key, at, since,
+	)
+	if err != nil {
+		return models.LoginAttempts{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attempts, nil
+}
+
+func (s *Storage) ResetLoginAttempts(ctx context.Context, key string) error {
+	const op = "storage.pgx.ResetLoginAttempts"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+)
+
+var _ auth.AuditLog = (*Storage)(nil)
+
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	const op = "storage.pgx.SaveAuditEvent"
+
+	_, err := s.conn(ctx).ExecContext(ctx, s.db.Rebind(storage.AuditInsertSQL), storage.AuditInsertArgs(event)...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) AuditEvents(ctx context.Context, q models.AuditQuery) ([]models.AuditEvent, error) {
+	const op = "storage.pgx.AuditEvents"
+
+	query, args := storage.AuditListSQL(q)
+
+	var events []models.AuditEvent
+	if err := s.conn(ctx).SelectContext(ctx, &events, s.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
@@ -2,4 +2,8 @@ package postgres
 
 const (
 	UniqueViolation = "23505"
-)
\ No newline at end of file
+)
+
+// statementCacheCapacity is the number of prepared statements cached
+// per connection
+const statementCacheCapacity = 256
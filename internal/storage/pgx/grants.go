@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.GrantStore = (*Storage)(nil)
+
+func (s *Storage) SaveGrant(ctx context.Context, grant models.Grant) error {
+	const op = "storage.pgx.SaveGrant"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, s.db.Rebind(storage.GrantUpsertSQL), storage.GrantArgs(grant)...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Grant(ctx context.Context, userID uuid.UUID, appID int) (models.Grant, error) {
+	const op = "storage.pgx.Grant"
+
+	row := s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+storage.GrantColumns+" FROM grants WHERE user_id = $1 AND app_id = $2", userID, appID)
+
+	grant, err := storage.ScanGrant(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Grant{}, fmt.Errorf("%s: %w", op, storage.ErrGrantNotFound)
+		}
+		return models.Grant{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return grant, nil
+}
+
+func (s *Storage) Grants(ctx context.Context, userID uuid.UUID) ([]models.Grant, error) {
+	const op = "storage.pgx.Grants"
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT "+storage.GrantColumns+" FROM grants WHERE user_id = $1 ORDER BY app_id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var grants []models.Grant
+	for rows.Next() {
+		grant, err := storage.ScanGrant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		grants = append(grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return grants, nil
+}
+
+func (s *Storage) DeleteGrant(ctx context.Context, userID uuid.UUID, appID int) error {
+	const op = "storage.pgx.DeleteGrant"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM grants WHERE user_id = $1 AND app_id = $2", userID, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrGrantNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/sl"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// usersChangedChannel is notified by trigger on users with id of the
+// updated or deleted user
+const usersChangedChannel = "users_changed"
+
+const listenRetryDelay = 5 * time.Second
+
+// UserChangeListener is notified about users changed by any instance
+type UserChangeListener interface {
+	Invalidate(id uuid.UUID)
+	// Purge is called after reconnect, when notifications could be missed
+	Purge()
+}
+
+// ListenUserChanges forwards notifications about changed users to l until
+// ctx is done. It holds a dedicated connection and reconnects on failures.
+func (s *Storage) ListenUserChanges(ctx context.Context, log *slog.Logger, l UserChangeListener) {
+	const op = "storage.pgx.ListenUserChanges"
+
+	log = log.With(slog.String("op", op))
+
+	for {
+		err := s.listenUserChanges(ctx, log, l)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error("listening for user changes failed", sl.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (s *Storage) listenUserChanges(ctx context.Context, log *slog.Logger, l UserChangeListener) error {
+	conn, err := pgx.ConnectConfig(ctx, s.cfg.Copy())
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+usersChangedChannel); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	// changes made while we were not listening are unknown
+	l.Purge()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+
+		id, err := uuid.Parse(n.Payload)
+		if err != nil {
+			log.Warn("malformed user change notification", slog.String("payload", n.Payload))
+			continue
+		}
+		l.Invalidate(id)
+	}
+}
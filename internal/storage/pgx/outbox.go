@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/services/outbox"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.EventOutbox = (*Storage)(nil)
+var _ outbox.Store = (*Storage)(nil)
+
+func (s *Storage) SaveEvent(ctx context.Context, event models.Event) error {
+	const op = "storage.pgx.SaveEvent"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, s.db.Rebind(storage.OutboxInsertSQL), storage.OutboxInsertArgs(event)...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ClaimEvents(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error) {
+	const op = "storage.pgx.ClaimEvents"
+
+	query := s.db.Rebind(storage.OutboxClaimSQL("FOR UPDATE SKIP LOCKED"))
+	rows, err := s.conn(ctx).QueryContext(ctx, query, leaseUntil, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	events, err := storage.ScanOutboxEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) MarkEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.pgx.MarkEventPublished"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "UPDATE outbox SET published_at = $1 WHERE id = $2", at, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrEventNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) MarkEventFailed(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, reason string) error {
+	const op = "storage.pgx.MarkEventFailed"
+
+	res, err := s.conn(ctx).ExecContext(ctx,
+		"UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2 WHERE id = $3",
+		nextAttemptAt, reason, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrEventNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeletePublishedEvents(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.pgx.DeletePublishedEvents"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM outbox WHERE published_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/migrations"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Local_OutboxClaim(t *testing.T) {
+	s := newLocalStorage(t)
+	ctx := context.Background()
+
+	schema, err := migrations.FS.ReadFile("postgres/5_outbox.up.sql")
+	require.NoError(t, err)
+	_, err = s.db.ExecContext(ctx, string(schema))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventUserCreated,
+		SubjectID: uuid.New(),
+		Payload:   json.RawMessage(`{"email":"a@example.com"}`),
+		CreatedAt: now,
+	}
+	require.NoError(t, s.SaveEvent(ctx, event))
+
+	// concurrent relays skip events locked by others
+	tx, err := s.db.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	var locked uuid.UUID
+	require.NoError(t, tx.GetContext(ctx, &locked, "SELECT id FROM outbox FOR UPDATE"))
+
+	claimed, err := s.ClaimEvents(ctx, now.Add(time.Second), now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+	require.NoError(t, tx.Rollback())
+
+	claimed, err = s.ClaimEvents(ctx, now.Add(time.Second), now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, event.ID, claimed[0].ID)
+	assert.JSONEq(t, string(event.Payload), string(claimed[0].Payload))
+	assert.True(t, claimed[0].NextAttemptAt.Equal(now.Add(time.Minute)))
+
+	require.NoError(t, s.MarkEventPublished(ctx, event.ID, now.Add(time.Second)))
+
+	claimed, err = s.ClaimEvents(ctx, now.Add(time.Hour), now.Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	n, err := s.DeletePublishedEvents(ctx, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+}
@@ -10,8 +10,9 @@ import (
 	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
 	"github.com/babs-corp/babs-maps-auth/internal/storage"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -20,33 +21,41 @@ var _ auth.UserSaver = (*Storage)(nil)
 var _ auth.AppProvider = (*Storage)(nil)
 
 type Storage struct {
-	db *sqlx.DB
+	db  *sqlx.DB
+	cfg *pgx.ConnConfig
 }
 
+// New connects to Postgres. All queries use bound parameters, statements
+// are prepared once per connection and cached.
 func New(ctx context.Context, storagePath string) (*Storage, error) {
 	const op = "storage.pgx"
 
-	db, err := sqlx.Connect("pgx", storagePath)
+	cfg, err := pgx.ParseConfig(storagePath)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	cfg.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	cfg.StatementCacheCapacity = statementCacheCapacity
 
-	return &Storage{db: db}, nil
+	db := sqlx.NewDb(stdlib.OpenDB(*cfg), "pgx")
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db: db, cfg: cfg}, nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
 }
 
 func (s *Storage) SaveUser(ctx context.Context, email string, passwordHash []byte) (userId uuid.UUID, err error) {
 	const op = "storage.pgx.SaveUser"
 
-	query := fmt.Sprintf("INSERT INTO users (email, pass_hash) VALUES ('%s', '%s') RETURNING id;", email, string(passwordHash))
-	stmt, err := s.db.PreparexContext(ctx, query)
-	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
-	}
-	defer stmt.Close()
-
-	res := stmt.QueryRowxContext(ctx)
 	var id uuid.UUID
-	err = res.Scan(&id)
+	err = s.conn(ctx).GetContext(ctx, &id,
+		"INSERT INTO users (email, pass_hash) VALUES ($1, $2) RETURNING id", email, passwordHash)
 	if err != nil {
 		var e *pgconn.PgError
 		if errors.As(err, &e) && e.Code == UniqueViolation {
@@ -62,16 +71,9 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passwordHash []byt
 func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.pgx.User"
 
-	query := fmt.Sprintf("SELECT id, email, pass_hash FROM users WHERE email = '%s'", email)
-	stmt, err := s.db.PreparexContext(ctx, query)
-	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
-	}
-
-	row := stmt.QueryRowContext(ctx)
-
 	var user models.User
-	err = row.Scan(&user.ID, &user.Email, &user.PassHash)
+	err := s.conn(ctx).GetContext(ctx, &user,
+		"SELECT "+storage.UserColumns+" FROM users WHERE email = $1", email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -86,16 +88,9 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 func (s *Storage) UserById(ctx context.Context, uid uuid.UUID) (models.User, error) {
 	const op = "storage.pgx.UserById"
 
-	query := fmt.Sprintf("SELECT id, email, pass_hash, created_at FROM users WHERE id = '%s'", uid)
-	stmt, err := s.db.PreparexContext(ctx, query)
-	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
-	}
-
-	row := stmt.QueryRowxContext(ctx)
-
 	var user models.User
-	err = row.StructScan(&user)
+	err := s.conn(ctx).GetContext(ctx, &user,
+		"SELECT "+storage.UserColumns+" FROM users WHERE id = $1", uid)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -107,48 +102,37 @@ func (s *Storage) UserById(ctx context.Context, uid uuid.UUID) (models.User, err
 	return user, nil
 }
 
-func (s *Storage) Users(ctx context.Context, limit uint) ([]models.User, error) {
-	const op = "storage.pgx.Users"
+func (s *Storage) ListUsers(ctx context.Context, q models.UserQuery) ([]models.User, error) {
+	const op = "storage.pgx.ListUsers"
 
-	query := fmt.Sprintf("SELECT * FROM users LIMIT '%d'", limit)
-	stmt, err := s.db.PreparexContext(ctx, query)
-	if err != nil {
-		return []models.User{}, fmt.Errorf("%s: %w", op, err)
-	}
+	query, args := storage.UsersListSQL(q)
 
-	rows, err := stmt.QueryxContext(ctx)
-	if err != nil {
-		return []models.User{}, fmt.Errorf("%s: %w", op, err)
+	users := []models.User{}
+	if err := s.conn(ctx).SelectContext(ctx, &users, s.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var users []models.User
+	return users, nil
+}
 
-	for rows.Next() {
-		var user models.User
-		err = rows.StructScan(&user)
-		if err != nil {
-			return []models.User{}, fmt.Errorf("%s: %w", op, err)
-		}
+func (s *Storage) CountUsers(ctx context.Context, f models.UserFilter) (int, error) {
+	const op = "storage.pgx.CountUsers"
 
-		users = append(users, user)
+	query, args := storage.UsersCountSQL(f)
+
+	var total int
+	if err := s.conn(ctx).GetContext(ctx, &total, s.db.Rebind(query), args...); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return users, nil
+	return total, nil
 }
 
 func (s *Storage) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
-	const op = "storage.pfx.IsAdmin"
-
-	stmt, err := s.db.PreparexContext(ctx, "SELECT is_admin FROM users WHERE id = ?")
-	if err != nil {
-		return false, fmt.Errorf("%s: %w", op, err)
-	}
-
-	row := stmt.QueryRowContext(ctx, userID)
+	const op = "storage.pgx.IsAdmin"
 
 	var isAdmin bool
-
-	err = row.Scan(&isAdmin)
+	err := s.conn(ctx).GetContext(ctx, &isAdmin, "SELECT is_admin FROM users WHERE id = $1", userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -162,15 +146,10 @@ func (s *Storage) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
 
 func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
 	const op = "storage.pgx.App"
-	stmt, err := s.db.PreparexContext(ctx, "SELECT * FROM apps WHERE id = ?")
-	if err != nil {
-		return models.App{}, fmt.Errorf("%s: %w", op, err)
-	}
 
-	row := stmt.QueryRowxContext(ctx, appID)
+	row := s.conn(ctx).QueryRowContext(ctx, "SELECT "+storage.AppColumns+" FROM apps WHERE id = $1", appID)
 
-	var app models.App
-	err = row.StructScan(&app)
+	app, err := storage.ScanApp(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
@@ -180,3 +159,18 @@ func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
 	}
 	return app, nil
 }
+
+func (s *Storage) UpdatePassHash(ctx context.Context, userID uuid.UUID, passHash []byte) error {
+	const op = "storage.pgx.UpdatePassHash"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "UPDATE users SET pass_hash = $1 WHERE id = $2", passHash, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
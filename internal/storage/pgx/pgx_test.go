@@ -0,0 +1,288 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPostgresDSNEnv points at a local Postgres server (no containers
+// needed). Tests talking to a real server are skipped when it is not set.
+const testPostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+var injectionPayloads = []string{
+	"' OR '1'='1",
+	"' OR 1=1 --",
+	"admin@example.com'--",
+	"'; DROP TABLE users; --",
+	"x' UNION SELECT id, email, pass_hash, is_admin, verified, disabled, org_id, created_at FROM users --",
+	`\'; SELECT pg_sleep(10); --`,
+	"$1",
+	"?",
+	"%",
+	"_",
+	"\x00",
+	"ünïcødé@exämple.com",
+	strings.Repeat("a", 10_000),
+}
+
+// assertBound checks that value did not get into SQL text of statements
+// sent to the driver and was passed as a parameter instead
+func assertBound(t *testing.T, value string) {
+	t.Helper()
+
+	calls := standin.recorded()
+	require.NotEmpty(t, calls)
+
+	// short values such as "?" or "$1" legitimately appear in SQL text
+	if len(value) > 2 {
+		for _, call := range calls {
+			assert.NotContains(t, call.query, value, "value leaked into SQL text")
+		}
+	}
+
+	found := false
+	for _, call := range calls {
+		for _, arg := range call.args {
+			switch v := arg.(type) {
+			case string:
+				found = found || strings.Contains(v, value)
+			case []byte:
+				found = found || strings.Contains(string(v), value)
+			}
+		}
+	}
+	assert.True(t, found, "value was not passed as parameter")
+}
+
+func TestStorage_BindsUserInput(t *testing.T) {
+	ctx := context.Background()
+	s := newStandinStorage()
+
+	for _, payload := range injectionPayloads {
+		t.Run(fmt.Sprintf("%.20q", payload), func(t *testing.T) {
+			standin.reset()
+			_, err := s.User(ctx, payload)
+			require.ErrorIs(t, err, storage.ErrUserNotFound)
+			assertBound(t, payload)
+
+			standin.reset()
+			_, err = s.SaveUser(ctx, payload, []byte(payload))
+			require.NoError(t, err)
+			assertBound(t, payload)
+
+			standin.reset()
+			_, err = s.ListUsers(ctx, models.UserQuery{
+				Limit:  10,
+				Filter: models.UserFilter{EmailPrefix: payload},
+			})
+			require.NoError(t, err)
+			assertBound(t, strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(payload))
+
+			standin.reset()
+			_, err = s.ListUsers(ctx, models.UserQuery{
+				Sort:  models.UserSortEmail,
+				Limit: 10,
+				After: &models.UserCursor{Sort: models.UserSortEmail, Email: payload},
+			})
+			require.NoError(t, err)
+			assertBound(t, payload)
+
+			standin.reset()
+			_, err = s.LoginAttempts(ctx, payload)
+			require.NoError(t, err)
+			assertBound(t, payload)
+		})
+	}
+}
+
+func TestStorage_UsesPostgresPlaceholders(t *testing.T) {
+	ctx := context.Background()
+	s := newStandinStorage()
+	standin.reset()
+
+	_, _ = s.IsAdmin(ctx, uuid.New())
+	_, _ = s.App(ctx, 1)
+	_, _ = s.UserById(ctx, uuid.New())
+	_ = s.UpdatePassHash(ctx, uuid.New(), []byte("hash"))
+	_, _ = s.CountUsers(ctx, models.UserFilter{Role: models.RoleAdmin, CreatedAfter: time.Now()})
+	_, _ = s.Session(ctx, uuid.New())
+	_, _ = s.Sessions(ctx, uuid.New(), time.Now())
+	_ = s.TouchSession(ctx, uuid.New(), time.Now())
+	_ = s.RevokeSession(ctx, uuid.New(), time.Now())
+	_ = s.RevokeAppSessions(ctx, uuid.New(), 1, time.Now())
+	_ = s.SaveGrant(ctx, models.Grant{UserID: uuid.New(), AppID: 1})
+	_, _ = s.Grant(ctx, uuid.New(), 1)
+	_, _ = s.Grants(ctx, uuid.New())
+	_ = s.DeleteGrant(ctx, uuid.New(), 1)
+	_ = s.RevokeAllAppSessions(ctx, 1, time.Now())
+	_, _ = s.CreateApp(ctx, models.App{Name: "maps"})
+	_, _ = s.Apps(ctx)
+	_ = s.UpdateApp(ctx, models.App{ID: 1, Name: "maps"})
+	_ = s.UpdateAppSecret(ctx, 1, "hash")
+	_ = s.DeleteApp(ctx, 1)
+	_ = s.SaveAPIKey(ctx, models.APIKey{ID: uuid.New(), Prefix: "bmk_000000000000", UserID: uuid.New()})
+	_, _ = s.APIKey(ctx, uuid.New())
+	_, _ = s.APIKeyByPrefix(ctx, "bmk_000000000000")
+	_, _ = s.APIKeys(ctx, uuid.New(), nil)
+	_ = s.TouchAPIKey(ctx, uuid.New(), time.Now())
+	_ = s.RevokeAPIKey(ctx, uuid.New(), time.Now())
+	_ = s.SaveQuota(ctx, models.Quota{QuotaSubject: models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "1"}, PerDay: 1})
+	_, _ = s.Quota(ctx, models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "1"})
+	_ = s.DeleteQuota(ctx, models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "1"})
+	_ = s.SaveQuotaBucket(ctx, models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "1"}, models.QuotaBucket{UpdatedAt: time.Now()})
+	_, _ = s.QuotaUsage(ctx, models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "1"}, models.QuotaPeriodDay, time.Now())
+	_ = s.AddQuotaUsage(ctx, models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "1"}, models.QuotaPeriodDay, time.Now(), 1)
+	_, _ = s.UsageReport(ctx, models.UsageFilter{Period: models.QuotaPeriodDay, Subject: &models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "1"}, From: time.Now()})
+
+	for _, call := range standin.recorded() {
+		assert.NotContains(t, call.query, "?", "Postgres does not accept ? placeholders: %s", call.query)
+	}
+}
+
+func FuzzStorage_UserEmailIsBound(f *testing.F) {
+	for _, payload := range injectionPayloads {
+		f.Add(payload)
+	}
+
+	ctx := context.Background()
+	s := newStandinStorage()
+
+	f.Fuzz(func(t *testing.T, email string) {
+		standin.reset()
+		_, err := s.User(ctx, email)
+		if !errors.Is(err, storage.ErrUserNotFound) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		calls := standin.recorded()
+		if len(calls) != 1 {
+			t.Fatalf("expected 1 statement, got %d", len(calls))
+		}
+		want := "SELECT " + storage.UserColumns + " FROM users WHERE email = $1"
+		if calls[0].query != want {
+			t.Fatalf("statement depends on input: %q", calls[0].query)
+		}
+		if len(calls[0].args) != 1 || calls[0].args[0] != email {
+			t.Fatalf("email was not bound as is: %#v", calls[0].args)
+		}
+	})
+}
+
+// newLocalStorage connects to a local Postgres in a fresh schema
+func newLocalStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	dsn := os.Getenv(testPostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testPostgresDSNEnv)
+	}
+	ctx := context.Background()
+
+	admin, err := New(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	_, err = admin.db.ExecContext(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = admin.db.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	s, err := New(ctx, dsn+sep+"search_path="+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	_, err = s.conn(ctx).ExecContext(ctx, `
+		CREATE TABLE users (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			email TEXT NOT NULL UNIQUE,
+			pass_hash BYTEA NOT NULL,
+			is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+			verified BOOLEAN NOT NULL DEFAULT FALSE,
+			disabled BOOLEAN NOT NULL DEFAULT FALSE,
+			org_id UUID,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`)
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestStorage_Local_InjectionRoundTrip(t *testing.T) {
+	s := newLocalStorage(t)
+	ctx := context.Background()
+
+	victim, err := s.SaveUser(ctx, "victim@example.com", []byte("hash"))
+	require.NoError(t, err)
+
+	for _, payload := range injectionPayloads {
+		if strings.ContainsRune(payload, 0) {
+			// Postgres text cannot hold NUL bytes
+			continue
+		}
+
+		_, err := s.User(ctx, payload)
+		require.ErrorIs(t, err, storage.ErrUserNotFound, payload)
+
+		id, err := s.SaveUser(ctx, payload, []byte(payload))
+		require.NoError(t, err, payload)
+
+		user, err := s.User(ctx, payload)
+		require.NoError(t, err, payload)
+		assert.Equal(t, id, user.ID)
+		assert.Equal(t, payload, user.Email)
+		assert.Equal(t, []byte(payload), user.PassHash)
+	}
+
+	user, err := s.UserById(ctx, victim)
+	require.NoError(t, err)
+	assert.Equal(t, "victim@example.com", user.Email)
+
+	total, err := s.CountUsers(ctx, models.UserFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, len(injectionPayloads), total) // payloads with NUL skipped, victim added
+
+	page, err := s.ListUsers(ctx, models.UserQuery{
+		Limit:  10,
+		Filter: models.UserFilter{EmailPrefix: "%"},
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "%", page[0].Email)
+}
+
+func TestStorage_Local_InTxRollback(t *testing.T) {
+	s := newLocalStorage(t)
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+
+	err := s.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.SaveUser(ctx, "a@example.com", []byte("hash")); err != nil {
+			return err
+		}
+		if _, err := s.User(ctx, "a@example.com"); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+
+	_, err = s.User(ctx, "a@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
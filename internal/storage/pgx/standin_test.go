@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// standinDriver is a database/sql driver standing in for Postgres in tests
+// which do not need a server. It records every statement with its
+// arguments, returns no rows for queries and a fresh uuid for
+// INSERT ... RETURNING id.
+type standinDriver struct {
+	mu    sync.Mutex
+	calls []standinCall
+}
+
+type standinCall struct {
+	query string
+	args  []driver.Value
+}
+
+var standin = &standinDriver{}
+
+// newStandinStorage returns Storage backed by the stand-in driver. It binds
+// parameters like the real pgx driver does.
+func newStandinStorage() *Storage {
+	db := sqlx.NewDb(sql.OpenDB(standinConnector{}), "pgx")
+	return &Storage{db: db}
+}
+
+func (d *standinDriver) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = nil
+}
+
+func (d *standinDriver) record(query string, args []driver.NamedValue) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	values := make([]driver.Value, 0, len(args))
+	for _, a := range args {
+		values = append(values, a.Value)
+	}
+	d.calls = append(d.calls, standinCall{query: query, args: values})
+}
+
+func (d *standinDriver) recorded() []standinCall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]standinCall(nil), d.calls...)
+}
+
+func (d *standinDriver) Open(string) (driver.Conn, error) {
+	return standinConn{}, nil
+}
+
+type standinConnector struct{}
+
+func (standinConnector) Connect(context.Context) (driver.Conn, error) {
+	return standinConn{}, nil
+}
+
+func (standinConnector) Driver() driver.Driver {
+	return standin
+}
+
+type standinConn struct{}
+
+func (standinConn) Prepare(query string) (driver.Stmt, error) {
+	return standinStmt{query: query}, nil
+}
+
+func (standinConn) Close() error {
+	return nil
+}
+
+func (standinConn) Begin() (driver.Tx, error) {
+	return standinTx{}, nil
+}
+
+func (standinConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	standin.record(query, args)
+	if isReturningID(query) {
+		return &standinRows{values: [][]driver.Value{{uuid.NewString()}}, columns: []string{"id"}}, nil
+	}
+	return &standinRows{}, nil
+}
+
+func (standinConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	standin.record(query, args)
+	return driver.RowsAffected(1), nil
+}
+
+type standinStmt struct {
+	query string
+}
+
+func (s standinStmt) Close() error {
+	return nil
+}
+
+func (s standinStmt) NumInput() int {
+	return -1
+}
+
+func (s standinStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return standinConn{}.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s standinStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return standinConn{}.QueryContext(context.Background(), s.query, named(args))
+}
+
+type standinTx struct{}
+
+func (standinTx) Commit() error {
+	return nil
+}
+
+func (standinTx) Rollback() error {
+	return nil
+}
+
+type standinRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *standinRows) Columns() []string {
+	return r.columns
+}
+
+func (r *standinRows) Close() error {
+	return nil
+}
+
+func (r *standinRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	res := make([]driver.NamedValue, 0, len(args))
+	for i, a := range args {
+		res = append(res, driver.NamedValue{Ordinal: i + 1, Value: a})
+	}
+	return res
+}
+
+func isReturningID(query string) bool {
+	const suffix = "RETURNING id"
+	return len(query) >= len(suffix) && query[len(query)-len(suffix):] == suffix
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/migrations"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Local_WebhookDeliveries(t *testing.T) {
+	s := newLocalStorage(t)
+	ctx := context.Background()
+
+	schema, err := migrations.FS.ReadFile("postgres/6_webhooks.up.sql")
+	require.NoError(t, err)
+	_, err = s.db.ExecContext(ctx, string(schema))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	orgID := uuid.New()
+	sub := models.WebhookSubscription{
+		ID:        uuid.New(),
+		OrgID:     &orgID,
+		URL:       "https://example.com/hook",
+		Secret:    "whsec_test",
+		CreatedAt: now,
+	}
+	require.NoError(t, s.SaveWebhookSubscription(ctx, sub))
+
+	// the payload is kept byte for byte, as it was signed
+	payload := json.RawMessage(`{"type":"user.created",  "id":"x"}`)
+	d := models.WebhookDelivery{
+		ID:             uuid.New(),
+		SubscriptionID: sub.ID,
+		EventID:        uuid.New(),
+		EventType:      models.EventUserCreated,
+		Payload:        payload,
+		Status:         models.WebhookPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+	require.NoError(t, s.SaveWebhookDeliveries(ctx, []models.WebhookDelivery{d, d}))
+
+	claimed, err := s.ClaimWebhookDeliveries(ctx, now, now.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, string(payload), string(claimed[0].Payload))
+
+	delivered := now.Add(time.Second)
+	d = claimed[0]
+	d.Status = models.WebhookDelivered
+	d.Attempts = 1
+	d.LastStatus = 200
+	d.DeliveredAt = &delivered
+	require.NoError(t, s.UpdateWebhookDelivery(ctx, d))
+
+	got, err := s.WebhookDelivery(ctx, d.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDelivered, got.Status)
+	require.NotNil(t, got.DeliveredAt)
+	assert.True(t, got.DeliveredAt.Equal(delivered))
+
+	require.NoError(t, s.DeleteWebhookSubscription(ctx, sub.ID))
+	page, err := s.WebhookDeliveries(ctx, models.WebhookDeliveryQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+}
@@ -0,0 +1,98 @@
+package storage
+
+import "github.com/babs-corp/babs-maps-auth/internal/domain/models"
+
+// QuotaColumns are columns scanned by ScanQuota
+const QuotaColumns = "subject_type, subject_id, per_minute, per_day, per_month, updated_at"
+
+// QuotaUpsertSQL saves models.Quota replacing limits of the subject.
+// Arguments are QuotaArgs.
+const QuotaUpsertSQL = "INSERT INTO quotas (" + QuotaColumns + ") VALUES (?, ?, ?, ?, ?, ?) " +
+	"ON CONFLICT (subject_type, subject_id) DO UPDATE SET per_minute = excluded.per_minute, " +
+	"per_day = excluded.per_day, per_month = excluded.per_month, updated_at = excluded.updated_at"
+
+// QuotaBucketCreateSQL creates the bucket of a subject unless it exists.
+// Arguments are subject type and id, tokens and the time.
+const QuotaBucketCreateSQL = "INSERT INTO quota_buckets (subject_type, subject_id, tokens, updated_at) " +
+	"VALUES (?, ?, ?, ?) ON CONFLICT (subject_type, subject_id) DO NOTHING"
+
+// QuotaBucketSQL selects tokens and update time of the bucket of a
+// subject. Arguments are subject type and id.
+const QuotaBucketSQL = "SELECT tokens, updated_at FROM quota_buckets WHERE subject_type = ? AND subject_id = ?"
+
+// QuotaBucketUpdateSQL saves a bucket. Arguments are tokens, the time,
+// subject type and id.
+const QuotaBucketUpdateSQL = "UPDATE quota_buckets SET tokens = ?, updated_at = ? " +
+	"WHERE subject_type = ? AND subject_id = ?"
+
+// QuotaUsageSQL selects the count of requests of a subject in a period.
+// Arguments are subject type and id, period and its start.
+const QuotaUsageSQL = "SELECT used FROM quota_usage " +
+	"WHERE subject_type = ? AND subject_id = ? AND period = ? AND period_start = ?"
+
+// QuotaUsageAddSQL adds to the count of requests of a subject in a
+// period. Arguments are subject type and id, period, its start and the
+// count to add.
+const QuotaUsageAddSQL = "INSERT INTO quota_usage (subject_type, subject_id, period, period_start, used) " +
+	"VALUES (?, ?, ?, ?, ?) ON CONFLICT (subject_type, subject_id, period, period_start) " +
+	"DO UPDATE SET used = quota_usage.used + excluded.used"
+
+// QuotaArgs returns arguments of QuotaUpsertSQL
+func QuotaArgs(q models.Quota) []any {
+	return []any{q.Type, q.ID, q.PerMinute, q.PerDay, q.PerMonth, q.UpdatedAt}
+}
+
+// ScanQuota reads a row selected with QuotaColumns
+func ScanQuota(row interface{ Scan(dest ...any) error }) (models.Quota, error) {
+	var q models.Quota
+	if err := row.Scan(&q.Type, &q.ID, &q.PerMinute, &q.PerDay, &q.PerMonth, &q.UpdatedAt); err != nil {
+		return models.Quota{}, err
+	}
+
+	return q, nil
+}
+
+// UsageReportSQL builds query selecting usage matching f, oldest period
+// first. Like AuditListSQL it uses "?" placeholders.
+func UsageReportSQL(f models.UsageFilter) (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+
+	if f.Period != "" {
+		where = append(where, "period = ?")
+		args = append(args, f.Period)
+	}
+	if f.Subject != nil {
+		where = append(where, "subject_type = ?")
+		args = append(args, f.Subject.Type)
+		if f.Subject.ID != "" {
+			where = append(where, "subject_id = ?")
+			args = append(args, f.Subject.ID)
+		}
+	}
+	if !f.From.IsZero() {
+		where = append(where, "period_start >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		where = append(where, "period_start < ?")
+		args = append(args, f.To)
+	}
+
+	query := "SELECT subject_type, subject_id, period, period_start, used FROM quota_usage" + whereClause(where) +
+		" ORDER BY period_start, subject_type, subject_id, period"
+
+	return query, args
+}
+
+// ScanQuotaUsage reads a row selected with UsageReportSQL
+func ScanQuotaUsage(row interface{ Scan(dest ...any) error }) (models.QuotaUsage, error) {
+	var u models.QuotaUsage
+	if err := row.Scan(&u.Type, &u.ID, &u.Period, &u.Start, &u.Used); err != nil {
+		return models.QuotaUsage{}, err
+	}
+
+	return u, nil
+}
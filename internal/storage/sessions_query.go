@@ -0,0 +1,48 @@
+package storage
+
+import "github.com/babs-corp/babs-maps-auth/internal/domain/models"
+
+// SessionColumns are columns scanned by ScanSession
+const SessionColumns = "id, user_id, app_id, device, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at, cookie_hash"
+
+// SessionInsertSQL inserts models.Session with "?" placeholders
+const SessionInsertSQL = "INSERT INTO sessions (" + SessionColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// SessionInsertArgs returns arguments of SessionInsertSQL
+func SessionInsertArgs(s models.Session) []any {
+	return []any{
+		s.ID, s.UserID, s.AppID, s.Device, s.UserAgent, s.IP,
+		s.CreatedAt, s.LastSeenAt, s.ExpiresAt, s.RevokedAt, s.CookieHash,
+	}
+}
+
+// SessionsListSQL selects sessions of a user active at a time, most
+// recently seen first. Arguments are user id and the time.
+const SessionsListSQL = "SELECT " + SessionColumns + " FROM sessions " +
+	"WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_seen_at DESC, id"
+
+// SessionRevokeSQL revokes a session keeping the time of the first
+// revocation. Arguments are the time and session id.
+const SessionRevokeSQL = "UPDATE sessions SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?"
+
+// SessionRevokeAppSQL revokes active token sessions of a user in an app;
+// browser sessions are kept. Arguments are the time, user id and app id.
+const SessionRevokeAppSQL = "UPDATE sessions SET revoked_at = ? " +
+	"WHERE user_id = ? AND app_id = ? AND revoked_at IS NULL AND cookie_hash IS NULL"
+
+// SessionRevokeAllAppSQL revokes active token sessions of every user in
+// an app. Arguments are the time and app id.
+const SessionRevokeAllAppSQL = "UPDATE sessions SET revoked_at = ? " +
+	"WHERE app_id = ? AND revoked_at IS NULL AND cookie_hash IS NULL"
+
+// ScanSession reads a row selected with SessionColumns
+func ScanSession(row interface{ Scan(dest ...any) error }) (models.Session, error) {
+	var s models.Session
+	err := row.Scan(&s.ID, &s.UserID, &s.AppID, &s.Device, &s.UserAgent, &s.IP,
+		&s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt, &s.CookieHash)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	return s, nil
+}
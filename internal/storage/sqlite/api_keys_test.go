@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_APIKeys(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, "a@example.com", []byte("hash"))
+	require.NoError(t, err)
+	otherID, err := s.SaveUser(ctx, "b@example.com", []byte("hash"))
+	require.NoError(t, err)
+	org := uuid.New()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	key := models.APIKey{
+		ID:        uuid.New(),
+		Prefix:    "bmk_000000000001",
+		Hash:      []byte("hash"),
+		Name:      "tiles",
+		UserID:    userID,
+		Scopes:    []string{"maps:read", "tiles:render"},
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	require.NoError(t, s.SaveAPIKey(ctx, key))
+	shared := models.APIKey{
+		ID:        uuid.New(),
+		Prefix:    "bmk_000000000002",
+		Hash:      []byte("other"),
+		Name:      "shared",
+		UserID:    otherID,
+		OrgID:     &org,
+		Scopes:    []string{},
+		CreatedAt: now.Add(time.Second),
+		ExpiresAt: now.Add(time.Hour),
+	}
+	require.NoError(t, s.SaveAPIKey(ctx, shared))
+
+	got, err := s.APIKeyByPrefix(ctx, key.Prefix)
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	err = s.SaveAPIKey(ctx, models.APIKey{ID: uuid.New(), Prefix: key.Prefix, Hash: []byte("x"), UserID: userID})
+	assert.ErrorIs(t, err, storage.ErrAPIKeyExists)
+
+	keys, err := s.APIKeys(ctx, userID, nil)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, key.ID, keys[0].ID)
+
+	keys, err = s.APIKeys(ctx, userID, &org)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, shared.ID, keys[0].ID, "newest first")
+
+	require.NoError(t, s.TouchAPIKey(ctx, key.ID, now))
+	got, err = s.APIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastUsedAt)
+	assert.True(t, now.Equal(*got.LastUsedAt))
+
+	require.NoError(t, s.RevokeAPIKey(ctx, key.ID, now))
+	require.NoError(t, s.RevokeAPIKey(ctx, key.ID, now.Add(time.Hour)))
+	got, err = s.APIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.RevokedAt)
+	assert.True(t, now.Equal(*got.RevokedAt), "first revocation is kept")
+
+	keys, err = s.APIKeys(ctx, userID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, err = s.APIKey(ctx, uuid.New())
+	assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+	assert.ErrorIs(t, s.RevokeAPIKey(ctx, uuid.New(), now), storage.ErrAPIKeyNotFound)
+	assert.ErrorIs(t, s.TouchAPIKey(ctx, uuid.New(), now), storage.ErrAPIKeyNotFound)
+}
@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Apps(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	app := models.App{
+		Name:         "partner",
+		Secret:       "hash",
+		Type:         models.AppTypePublic,
+		RedirectURIs: []string{"com.example.partner:/callback", "https://partner.example.com/callback"},
+		GrantTypes:   []string{models.GrantTypeAuthorizationCode},
+		Scopes:       []string{"maps:read"},
+		TokenTTL:     15 * time.Minute,
+		DisplayName:  "Partner",
+	}
+	id, err := s.CreateApp(ctx, app)
+	require.NoError(t, err)
+	app.ID = id
+
+	got, err := s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, app, got)
+
+	_, err = s.CreateApp(ctx, models.App{Name: "partner", Secret: "other"})
+	assert.ErrorIs(t, err, storage.ErrAppExists)
+
+	otherID, err := s.CreateApp(ctx, models.App{Name: "other", Secret: "other"})
+	require.NoError(t, err)
+
+	updated := app
+	updated.Secret = "ignored"
+	updated.Type = models.AppTypeConfidential
+	updated.RedirectURIs = nil
+	updated.TokenTTL = 0
+	require.NoError(t, s.UpdateApp(ctx, updated))
+	require.NoError(t, s.UpdateAppSecret(ctx, id, "new-hash"))
+
+	got, err = s.App(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.AppTypeConfidential, got.Type)
+	assert.Empty(t, got.RedirectURIs)
+	assert.Zero(t, got.TokenTTL)
+	assert.Equal(t, "new-hash", got.Secret, "secret is changed only by UpdateAppSecret")
+
+	updated.Name = "other"
+	assert.ErrorIs(t, s.UpdateApp(ctx, updated), storage.ErrAppExists)
+	assert.ErrorIs(t, s.UpdateApp(ctx, models.App{ID: 42, Name: "missing"}), storage.ErrAppNotFound)
+	assert.ErrorIs(t, s.UpdateAppSecret(ctx, 42, "hash"), storage.ErrAppNotFound)
+
+	apps, err := s.Apps(ctx)
+	require.NoError(t, err)
+	require.Len(t, apps, 2)
+	assert.Equal(t, id, apps[0].ID)
+	assert.Equal(t, otherID, apps[1].ID)
+
+	require.NoError(t, s.DeleteApp(ctx, id))
+	_, err = s.App(ctx, id)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+	assert.ErrorIs(t, s.DeleteApp(ctx, id), storage.ErrAppNotFound)
+}
@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+)
+
+var _ auth.AttemptStore = (*Storage)(nil)
+
+func (s *Storage) LoginAttempts(ctx context.Context, key string) (models.LoginAttempts, error) {
+	const op = "storage.sqlite.LoginAttempts"
+
+	row := s.conn(ctx).QueryRowContext(ctx,
+		"SELECT key, failures, last_failed_at FROM login_attempts WHERE key = ?", key)
+
+	var attempts models.LoginAttempts
+	err := row.Scan(&attempts.Key, &attempts.Failures, &attempts.LastFailedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginAttempts{Key: key}, nil
+		}
+
+		return models.LoginAttempts{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attempts, nil
+}
+
+func (s *Storage) RegisterFailedLogin(ctx context.Context, key string, at time.Time, since time.Time) (models.LoginAttempts, error) {
+	const op = "storage.sqlite.RegisterFailedLogin"
+
+	row := s.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO login_attempts (key, failures, last_failed_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failed_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failed_at = excluded.last_failed_at
+		RETURNING key, failures, last_failed_at`,
+		key, at.UTC(), since.UTC(),
+	)
+
+	var attempts models.LoginAttempts
+	err := row.Scan(&attempts.Key, &attempts.Failures, &attempts.LastFailedAt)
+	if err != nil {
+		return models.LoginAttempts{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attempts, nil
+}
+
+func (s *Storage) ResetLoginAttempts(ctx context.Context, key string) error {
+	const op = "storage.sqlite.ResetLoginAttempts"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM login_attempts WHERE key = ?", key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+)
+
+var _ auth.AuditLog = (*Storage)(nil)
+
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	const op = "storage.sqlite.SaveAuditEvent"
+
+	event.CreatedAt = event.CreatedAt.UTC()
+	if _, err := s.conn(ctx).ExecContext(ctx, storage.AuditInsertSQL, storage.AuditInsertArgs(event)...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) AuditEvents(ctx context.Context, q models.AuditQuery) ([]models.AuditEvent, error) {
+	const op = "storage.sqlite.AuditEvents"
+
+	q.Filter.From = q.Filter.From.UTC()
+	q.Filter.To = q.Filter.To.UTC()
+	if q.After != nil {
+		after := *q.After
+		after.CreatedAt = after.CreatedAt.UTC()
+		q.After = &after
+	}
+	query, args := storage.AuditListSQL(q)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var e models.AuditEvent
+		err := rows.Scan(&e.ID, &e.Type, &e.Outcome, &e.Reason, &e.ActorID, &e.SubjectID,
+			&e.Email, &e.IP, &e.UserAgent, &e.AppID, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
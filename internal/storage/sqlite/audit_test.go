@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_AuditEvents(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	subject := uuid.New()
+	start := time.Now().UTC().Truncate(time.Second)
+
+	for i := 0; i < 5; i++ {
+		event := models.AuditEvent{
+			ID:        uuid.New(),
+			Type:      models.AuditLogin,
+			Outcome:   models.AuditFailure,
+			Reason:    "invalid_credentials",
+			Email:     "a@example.com",
+			IP:        "192.0.2.1",
+			CreatedAt: start.Add(time.Duration(i) * time.Second),
+		}
+		if i%2 == 0 {
+			event.Outcome, event.Reason = models.AuditSuccess, ""
+			event.SubjectID = &subject
+		}
+		require.NoError(t, s.SaveAuditEvent(ctx, event))
+	}
+
+	events, err := s.AuditEvents(ctx, models.AuditQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	assert.True(t, events[0].CreatedAt.After(events[4].CreatedAt), "newest first")
+
+	events, err = s.AuditEvents(ctx, models.AuditQuery{
+		Limit:  10,
+		Filter: models.AuditFilter{SubjectID: &subject, From: start.Add(time.Second)},
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, &subject, events[0].SubjectID)
+
+	page, err := s.AuditEvents(ctx, models.AuditQuery{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 2, "one extra row tells there is a next page")
+
+	next, err := s.AuditEvents(ctx, models.AuditQuery{
+		Limit: 1,
+		After: &models.AuditCursor{CreatedAt: page[0].CreatedAt, ID: page[0].ID},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, page[1].ID, next[0].ID)
+}
+
+func TestStorage_AuditEventsAreAppendOnly(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveAuditEvent(ctx, models.AuditEvent{
+		ID:        uuid.New(),
+		Type:      models.AuditLogin,
+		Outcome:   models.AuditSuccess,
+		CreatedAt: time.Now(),
+	}))
+
+	_, err := s.db.ExecContext(ctx, "UPDATE audit_events SET outcome = 'failure'")
+	assert.ErrorContains(t, err, "append-only")
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM audit_events")
+	assert.ErrorContains(t, err, "append-only")
+}
@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package sqlite
+
+// isUniqueViolation always reports false: without cgo go-sqlite3 is a stub
+// which fails to open any database.
+func isUniqueViolation(err error) bool {
+	return false
+}
@@ -0,0 +1,133 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_App(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	_, err := s.db.ExecContext(ctx, "INSERT INTO apps (id, name, secret, scopes, display_name, logo_url, primary_color) "+
+		"VALUES (7, 'tiles', 's', 'maps:read tiles:render', 'Babs Tiles', 'https://cdn.example.com/tiles.svg', '#ff6600')")
+	require.NoError(t, err)
+
+	app, err := s.App(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, models.App{
+		ID:           7,
+		Name:         "tiles",
+		Secret:       "s",
+		Type:         models.AppTypeConfidential,
+		RedirectURIs: []string{},
+		GrantTypes:   []string{models.GrantTypePassword},
+		Scopes:       []string{"maps:read", "tiles:render"},
+		DisplayName:  "Babs Tiles",
+		LogoURL:      "https://cdn.example.com/tiles.svg",
+		PrimaryColor: "#ff6600",
+	}, app, "defaults of existing apps")
+
+	_, err = s.App(ctx, 8)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+}
+
+func TestStorage_Grants(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, "a@example.com", []byte("hash"))
+	require.NoError(t, err)
+	_, err = s.db.ExecContext(ctx, "INSERT INTO apps (id, name, secret) VALUES (1, 'a', 'a'), (2, 'b', 'b')")
+	require.NoError(t, err)
+
+	_, err = s.Grant(ctx, userID, 1)
+	assert.ErrorIs(t, err, storage.ErrGrantNotFound)
+
+	created := time.Now().UTC().Truncate(time.Millisecond)
+	grant := models.Grant{
+		UserID:    userID,
+		AppID:     2,
+		Scopes:    []string{"maps:read"},
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+	require.NoError(t, s.SaveGrant(ctx, grant))
+	require.NoError(t, s.SaveGrant(ctx, models.Grant{UserID: userID, AppID: 1, CreatedAt: created, UpdatedAt: created}))
+
+	updated := created.Add(time.Hour)
+	grant.Scopes = []string{"layers:write", "maps:read"}
+	grant.CreatedAt, grant.UpdatedAt = updated, updated
+	require.NoError(t, s.SaveGrant(ctx, grant))
+
+	got, err := s.Grant(ctx, userID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"layers:write", "maps:read"}, got.Scopes)
+	assert.True(t, created.Equal(got.CreatedAt), "creation time is kept")
+	assert.True(t, updated.Equal(got.UpdatedAt))
+
+	grants, err := s.Grants(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, grants, 2)
+	assert.Equal(t, 1, grants[0].AppID)
+	assert.Empty(t, grants[0].Scopes)
+	assert.Equal(t, 2, grants[1].AppID)
+
+	require.NoError(t, s.DeleteGrant(ctx, userID, 2))
+	assert.ErrorIs(t, s.DeleteGrant(ctx, userID, 2), storage.ErrGrantNotFound)
+
+	// grants go away with the app
+	_, err = s.db.ExecContext(ctx, "DELETE FROM apps WHERE id = 1")
+	require.NoError(t, err)
+	grants, err = s.Grants(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+}
+
+func TestStorage_RevokeAppSessions(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, "a@example.com", []byte("hash"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	newSession := func(appID int, cookieHash []byte) uuid.UUID {
+		session := models.Session{
+			ID:         uuid.New(),
+			UserID:     userID,
+			AppID:      appID,
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(time.Hour),
+			CookieHash: cookieHash,
+		}
+		require.NoError(t, s.SaveSession(ctx, session))
+		return session.ID
+	}
+	partner := newSession(2, nil)
+	browser := newSession(2, []byte("cookie-hash"))
+	maps := newSession(1, nil)
+
+	require.NoError(t, s.RevokeAppSessions(ctx, userID, 2, now))
+
+	sessions, err := s.Sessions(ctx, userID, now)
+	require.NoError(t, err)
+	ids := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+	assert.ElementsMatch(t, []uuid.UUID{browser, maps}, ids, "browser sessions are kept")
+
+	revoked, err := s.Session(ctx, partner)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	assert.True(t, now.Equal(*revoked.RevokedAt))
+}
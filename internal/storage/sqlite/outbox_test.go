@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Outbox(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		event := models.Event{
+			ID:        uuid.New(),
+			Type:      models.EventUserCreated,
+			SubjectID: uuid.New(),
+			Payload:   json.RawMessage(`{"email":"a@example.com"}`),
+			CreatedAt: now.Add(time.Duration(i) * time.Millisecond),
+		}
+		require.NoError(t, s.SaveEvent(ctx, event))
+		ids = append(ids, event.ID)
+	}
+
+	lease := now.Add(time.Minute)
+	claimed, err := s.ClaimEvents(ctx, now.Add(time.Second), lease, 2)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.ElementsMatch(t, ids[:2], []uuid.UUID{claimed[0].ID, claimed[1].ID})
+	assert.JSONEq(t, `{"email":"a@example.com"}`, string(claimed[0].Payload))
+	assert.True(t, claimed[0].NextAttemptAt.Equal(lease))
+	assert.Nil(t, claimed[0].PublishedAt)
+
+	// claimed events are leased
+	claimed, err = s.ClaimEvents(ctx, now.Add(time.Second), lease, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, ids[2], claimed[0].ID)
+
+	require.NoError(t, s.MarkEventPublished(ctx, ids[0], now.Add(time.Second)))
+	require.NoError(t, s.MarkEventFailed(ctx, ids[1], now.Add(2*time.Minute), "unexpected status 500"))
+
+	// after the lease only the failed and the unmarked events are due
+	claimed, err = s.ClaimEvents(ctx, now.Add(90*time.Second), now.Add(3*time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, ids[2], claimed[0].ID)
+
+	claimed, err = s.ClaimEvents(ctx, now.Add(4*time.Minute), now.Add(5*time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+
+	failed := claimed[0]
+	if failed.ID != ids[1] {
+		failed = claimed[1]
+	}
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, "unexpected status 500", failed.LastError)
+
+	n, err := s.DeletePublishedEvents(ctx, now.Add(2*time.Second))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	err = s.MarkEventPublished(ctx, ids[0], now)
+	assert.ErrorIs(t, err, storage.ErrEventNotFound)
+}
@@ -0,0 +1,180 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/quota"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+)
+
+var _ quota.Store = (*Storage)(nil)
+
+func (s *Storage) SaveQuota(ctx context.Context, q models.Quota) error {
+	const op = "storage.sqlite.SaveQuota"
+
+	q.UpdatedAt = q.UpdatedAt.UTC()
+	if _, err := s.conn(ctx).ExecContext(ctx, storage.QuotaUpsertSQL, storage.QuotaArgs(q)...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Quota(ctx context.Context, subject models.QuotaSubject) (models.Quota, error) {
+	const op = "storage.sqlite.Quota"
+
+	row := s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+storage.QuotaColumns+" FROM quotas WHERE subject_type = ? AND subject_id = ?", subject.Type, subject.ID)
+
+	q, err := storage.ScanQuota(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Quota{}, fmt.Errorf("%s: %w", op, storage.ErrQuotaNotFound)
+		}
+		return models.Quota{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return q, nil
+}
+
+func (s *Storage) Quotas(ctx context.Context) ([]models.Quota, error) {
+	const op = "storage.sqlite.Quotas"
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT "+storage.QuotaColumns+" FROM quotas ORDER BY subject_type, subject_id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var quotas []models.Quota
+	for rows.Next() {
+		q, err := storage.ScanQuota(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		quotas = append(quotas, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return quotas, nil
+}
+
+func (s *Storage) DeleteQuota(ctx context.Context, subject models.QuotaSubject) error {
+	const op = "storage.sqlite.DeleteQuota"
+
+	res, err := s.conn(ctx).ExecContext(ctx,
+		"DELETE FROM quotas WHERE subject_type = ? AND subject_id = ?", subject.Type, subject.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrQuotaNotFound)
+	}
+
+	return nil
+}
+
+// LockQuotaBucket relies on the single connection of the storage:
+// transactions do not run concurrently
+func (s *Storage) LockQuotaBucket(
+	ctx context.Context,
+	subject models.QuotaSubject,
+	tokens float64,
+	now time.Time,
+) (models.QuotaBucket, error) {
+	const op = "storage.sqlite.LockQuotaBucket"
+
+	conn := s.conn(ctx)
+	if _, err := conn.ExecContext(ctx, storage.QuotaBucketCreateSQL, subject.Type, subject.ID, tokens, now.UTC()); err != nil {
+		return models.QuotaBucket{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var b models.QuotaBucket
+	if err := conn.QueryRowContext(ctx, storage.QuotaBucketSQL, subject.Type, subject.ID).Scan(&b.Tokens, &b.UpdatedAt); err != nil {
+		return models.QuotaBucket{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return b, nil
+}
+
+func (s *Storage) SaveQuotaBucket(ctx context.Context, subject models.QuotaSubject, b models.QuotaBucket) error {
+	const op = "storage.sqlite.SaveQuotaBucket"
+
+	_, err := s.conn(ctx).ExecContext(ctx, storage.QuotaBucketUpdateSQL, b.Tokens, b.UpdatedAt.UTC(), subject.Type, subject.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) QuotaUsage(
+	ctx context.Context,
+	subject models.QuotaSubject,
+	period string,
+	start time.Time,
+) (int64, error) {
+	const op = "storage.sqlite.QuotaUsage"
+
+	var used int64
+	err := s.conn(ctx).QueryRowContext(ctx, storage.QuotaUsageSQL, subject.Type, subject.ID, period, start.UTC()).Scan(&used)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return used, nil
+}
+
+func (s *Storage) AddQuotaUsage(
+	ctx context.Context,
+	subject models.QuotaSubject,
+	period string,
+	start time.Time,
+	n int64,
+) error {
+	const op = "storage.sqlite.AddQuotaUsage"
+
+	_, err := s.conn(ctx).ExecContext(ctx, storage.QuotaUsageAddSQL, subject.Type, subject.ID, period, start.UTC(), n)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) UsageReport(ctx context.Context, filter models.UsageFilter) ([]models.QuotaUsage, error) {
+	const op = "storage.sqlite.UsageReport"
+
+	filter.From = filter.From.UTC()
+	filter.To = filter.To.UTC()
+	query, args := storage.UsageReportSQL(filter)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var report []models.QuotaUsage
+	for rows.Next() {
+		u, err := storage.ScanQuotaUsage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		report = append(report, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Quotas(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	app := models.QuotaSubject{Type: models.QuotaSubjectApp, ID: "7"}
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	_, err := s.Quota(ctx, app)
+	require.ErrorIs(t, err, storage.ErrQuotaNotFound)
+
+	q := models.Quota{QuotaSubject: app, PerMinute: 10, PerDay: 100, UpdatedAt: now}
+	require.NoError(t, s.SaveQuota(ctx, q))
+	q.PerDay = 200
+	require.NoError(t, s.SaveQuota(ctx, q))
+
+	got, err := s.Quota(ctx, app)
+	require.NoError(t, err)
+	assert.Equal(t, q, got)
+
+	list, err := s.Quotas(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Quota{q}, list)
+
+	err = s.InTx(ctx, func(ctx context.Context) error {
+		bucket, err := s.LockQuotaBucket(ctx, app, 10, now)
+		require.NoError(t, err)
+		assert.Equal(t, models.QuotaBucket{Tokens: 10, UpdatedAt: now}, bucket)
+
+		bucket.Tokens = 7.5
+		require.NoError(t, s.SaveQuotaBucket(ctx, app, bucket))
+
+		// the existing bucket is kept
+		bucket, err = s.LockQuotaBucket(ctx, app, 10, now.Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 7.5, bucket.Tokens)
+		assert.Equal(t, now, bucket.UpdatedAt)
+		return nil
+	})
+	require.NoError(t, err)
+
+	day := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	used, err := s.QuotaUsage(ctx, app, models.QuotaPeriodDay, day)
+	require.NoError(t, err)
+	assert.Zero(t, used)
+
+	require.NoError(t, s.AddQuotaUsage(ctx, app, models.QuotaPeriodDay, day, 2))
+	require.NoError(t, s.AddQuotaUsage(ctx, app, models.QuotaPeriodDay, day, 3))
+	require.NoError(t, s.AddQuotaUsage(ctx, app, models.QuotaPeriodDay, day.AddDate(0, 0, 1), 1))
+	require.NoError(t, s.AddQuotaUsage(ctx, app, models.QuotaPeriodMonth, day, 6))
+
+	used, err = s.QuotaUsage(ctx, app, models.QuotaPeriodDay, day)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), used)
+
+	report, err := s.UsageReport(ctx, models.UsageFilter{Period: models.QuotaPeriodDay, Subject: &app})
+	require.NoError(t, err)
+	require.Len(t, report, 2)
+	assert.Equal(t, models.QuotaUsage{QuotaSubject: app, Period: models.QuotaPeriodDay, Start: day, Used: 5}, report[0])
+	assert.Equal(t, int64(1), report[1].Used)
+
+	report, err = s.UsageReport(ctx, models.UsageFilter{Period: models.QuotaPeriodDay, To: day.AddDate(0, 0, 1)})
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+	assert.Equal(t, day, report[0].Start)
+
+	require.NoError(t, s.DeleteQuota(ctx, app))
+	require.ErrorIs(t, s.DeleteQuota(ctx, app), storage.ErrQuotaNotFound)
+}
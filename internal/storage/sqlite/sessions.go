@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ auth.SessionStore = (*Storage)(nil)
+
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) error {
+	const op = "storage.sqlite.SaveSession"
+
+	session.CreatedAt = session.CreatedAt.UTC()
+	session.LastSeenAt = session.LastSeenAt.UTC()
+	session.ExpiresAt = session.ExpiresAt.UTC()
+	if _, err := s.conn(ctx).ExecContext(ctx, storage.SessionInsertSQL, storage.SessionInsertArgs(session)...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Session(ctx context.Context, id uuid.UUID) (models.Session, error) {
+	const op = "storage.sqlite.Session"
+
+	row := s.conn(ctx).QueryRowContext(ctx, "SELECT "+storage.SessionColumns+" FROM sessions WHERE id = ?", id)
+
+	session, err := storage.ScanSession(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+func (s *Storage) SessionByCookie(ctx context.Context, cookieHash []byte) (models.Session, error) {
+	const op = "storage.sqlite.SessionByCookie"
+
+	row := s.conn(ctx).QueryRowContext(ctx, "SELECT "+storage.SessionColumns+" FROM sessions WHERE cookie_hash = ?", cookieHash)
+
+	session, err := storage.ScanSession(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+func (s *Storage) Sessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error) {
+	const op = "storage.sqlite.Sessions"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, storage.SessionsListSQL, userID, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := storage.ScanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+func (s *Storage) TouchSession(ctx context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.sqlite.TouchSession"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "UPDATE sessions SET last_seen_at = ? WHERE id = ?", at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeSession(ctx context.Context, id uuid.UUID, at time.Time) error {
+	const op = "storage.sqlite.RevokeSession"
+
+	res, err := s.conn(ctx).ExecContext(ctx, storage.SessionRevokeSQL, at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeAppSessions(ctx context.Context, userID uuid.UUID, appID int, at time.Time) error {
+	const op = "storage.sqlite.RevokeAppSessions"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, storage.SessionRevokeAppSQL, at.UTC(), userID, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeAllAppSessions(ctx context.Context, appID int, at time.Time) error {
+	const op = "storage.sqlite.RevokeAllAppSessions"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, storage.SessionRevokeAllAppSQL, at.UTC(), appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
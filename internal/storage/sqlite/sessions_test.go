@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Sessions(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	userID, err := s.SaveUser(ctx, "a@example.com", []byte("hash"))
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	newSession := func(lastSeen, expires time.Duration) models.Session {
+		session := models.Session{
+			ID:         uuid.New(),
+			UserID:     userID,
+			AppID:      1,
+			Device:     "Firefox on Linux",
+			UserAgent:  "Mozilla/5.0",
+			IP:         "192.0.2.1",
+			CreatedAt:  now.Add(-time.Hour),
+			LastSeenAt: now.Add(lastSeen),
+			ExpiresAt:  now.Add(expires),
+		}
+		require.NoError(t, s.SaveSession(ctx, session))
+		return session
+	}
+	older := newSession(-time.Minute, time.Hour)
+	newer := newSession(0, time.Hour)
+	newSession(0, -time.Second) // expired
+
+	got, err := s.Session(ctx, older.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Firefox on Linux", got.Device)
+	assert.True(t, got.ExpiresAt.Equal(older.ExpiresAt))
+	assert.Nil(t, got.RevokedAt)
+
+	_, err = s.Session(ctx, uuid.New())
+	assert.ErrorIs(t, err, storage.ErrSessionNotFound)
+
+	sessions, err := s.Sessions(ctx, userID, now)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, newer.ID, sessions[0].ID)
+	assert.Equal(t, older.ID, sessions[1].ID)
+
+	require.NoError(t, s.TouchSession(ctx, older.ID, now.Add(time.Minute)))
+	sessions, err = s.Sessions(ctx, userID, now)
+	require.NoError(t, err)
+	assert.Equal(t, older.ID, sessions[0].ID)
+
+	revokedAt := now.Add(2 * time.Minute)
+	require.NoError(t, s.RevokeSession(ctx, older.ID, revokedAt))
+	require.NoError(t, s.RevokeSession(ctx, older.ID, revokedAt.Add(time.Minute)))
+	got, err = s.Session(ctx, older.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.RevokedAt)
+	assert.True(t, got.RevokedAt.Equal(revokedAt), "the first revocation is kept")
+
+	sessions, err = s.Sessions(ctx, userID, now)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, newer.ID, sessions[0].ID)
+
+	browser := models.Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(time.Hour),
+		CookieHash: []byte("hash of cookie"),
+	}
+	require.NoError(t, s.SaveSession(ctx, browser))
+	got, err = s.SessionByCookie(ctx, []byte("hash of cookie"))
+	require.NoError(t, err)
+	assert.Equal(t, browser.ID, got.ID)
+	assert.True(t, got.IsBrowser())
+	assert.False(t, sessions[0].IsBrowser(), "NULL cookie hash is read as nil")
+
+	_, err = s.SessionByCookie(ctx, []byte("other"))
+	assert.ErrorIs(t, err, storage.ErrSessionNotFound)
+
+	assert.ErrorIs(t, s.RevokeSession(ctx, uuid.New(), now), storage.ErrSessionNotFound)
+	assert.ErrorIs(t, s.TouchSession(ctx, uuid.New(), now), storage.ErrSessionNotFound)
+}
@@ -5,16 +5,15 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
 	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
 	"github.com/babs-corp/babs-maps-auth/internal/storage"
 	"github.com/google/uuid"
-	"github.com/mattn/go-sqlite3"
-	_ "github.com/mattn/go-sqlite3"
 )
 
-// var _ auth.UserProvider = (*Storage)(nil)
+var _ auth.UserProvider = (*Storage)(nil)
 var _ auth.UserSaver = (*Storage)(nil)
 var _ auth.AppProvider = (*Storage)(nil)
 
@@ -22,57 +21,60 @@ type Storage struct {
 	db *sql.DB
 }
 
+// connParams are appended to the path of the database file
+const connParams = "_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL"
+
+// New opens SQLite database file at storagePath
 func New(storagePath string) (*Storage, error) {
 	const op = "storage.sqlite"
-	fmt.Println("storage path: ", storagePath)
-	db, err := sql.Open("sqlite3", storagePath)
+
+	db, err := sql.Open("sqlite3", "file:"+storagePath+"?"+connParams)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+
+	// SQLite allows a single writer, serializing access in the pool
+	// avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return &Storage{db: db}, nil
 }
 
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
 func (s *Storage) SaveUser(ctx context.Context, email string, passwordHash []byte) (userId uuid.UUID, err error) {
 	const op = "storage.sqlite.SaveUser"
 
-	stmt, err := s.db.Prepare("INSERT INTO users (email, pass_hash) VALUES (?, ?) RETURNING id")
+	id := uuid.New()
+	_, err = s.conn(ctx).ExecContext(ctx,
+		"INSERT INTO users (id, email, pass_hash, created_at) VALUES (?, ?, ?, ?)",
+		id, email, passwordHash, time.Now().UTC(),
+	)
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
-	}
-	defer stmt.Close()
-
-	res := stmt.QueryRowContext(ctx, email, passwordHash)
-	if err != nil {
-		var sqliteErr sqlite3.Error
-
-		if errors.Is(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		if isUniqueViolation(err) {
 			return uuid.UUID{}, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
 		}
 
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var id uuid.UUID
-	err = res.Scan(&id)
-	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
-	}
-
 	return id, nil
 }
 
 func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.sqlite.User"
 
-	stmt, err := s.db.Prepare("SELECT id, email, pass_hash FROM users WHERE email = ?")
-	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
-	}
+	row := s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+storage.UserColumns+" FROM users WHERE email = ?", email)
 
-	row := stmt.QueryRowContext(ctx, email)
-
-	var user models.User
-	err = row.Scan(&user.ID, &user.Email, &user.PassHash)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -87,15 +89,10 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 func (s *Storage) UserById(ctx context.Context, id uuid.UUID) (models.User, error) {
 	const op = "storage.sqlite.UserById"
 
-	stmt, err := s.db.Prepare("SELECT id, email, pass_hash FROM users WHERE id = ?")
-	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
-	}
-
-	row := stmt.QueryRowContext(ctx, id)
+	row := s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+storage.UserColumns+" FROM users WHERE id = ?", id)
 
-	var user models.User
-	err = row.Scan(&user.ID, &user.Email, &user.PassHash)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -110,16 +107,11 @@ func (s *Storage) UserById(ctx context.Context, id uuid.UUID) (models.User, erro
 func (s *Storage) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
 	const op = "storage.sqlite.IsAdmin"
 
-	stmt, err := s.db.Prepare("SELECT is_admin FROM users WHERE id = ?")
-	if err != nil {
-		return false, fmt.Errorf("%s: %w", op, err)
-	}
-
-	row := stmt.QueryRowContext(ctx, userID)
+	row := s.conn(ctx).QueryRowContext(ctx, "SELECT is_admin FROM users WHERE id = ?", userID)
 
 	var isAdmin bool
 
-	err = row.Scan(&isAdmin)
+	err := row.Scan(&isAdmin)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -133,15 +125,10 @@ func (s *Storage) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
 
 func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
 	const op = "storage.sqlite.App"
-	stmt, err := s.db.Prepare("SELECT * FROM apps WHERE id = ?")
-	if err != nil {
-		return models.App{}, fmt.Errorf("%s: %w", op, err)
-	}
 
-	row := stmt.QueryRowContext(ctx, appID)
+	row := s.conn(ctx).QueryRowContext(ctx, "SELECT "+storage.AppColumns+" FROM apps WHERE id = ?", appID)
 
-	var app models.App
-	err = row.Scan(&app.ID, &app.Name, &app.Secret)
+	app, err := storage.ScanApp(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
@@ -151,3 +138,29 @@ func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
 	}
 	return app, nil
 }
+
+func (s *Storage) UpdatePassHash(ctx context.Context, userID uuid.UUID, passHash []byte) error {
+	const op = "storage.sqlite.UpdatePassHash"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "UPDATE users SET pass_hash = ? WHERE id = ?", passHash, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser scans row selected with storage.UserColumns
+func scanUser(row scanner) (models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Email, &u.PassHash, &u.IsAdmin, &u.Verified, &u.Disabled, &u.OrgID, &u.CreatedAt)
+	return u, err
+}
@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/babs-corp/babs-maps-auth/internal/services/auth"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+)
+
+var _ auth.TxManager = (*Storage)(nil)
+
+// conn is implemented by both *sql.DB and *sql.Tx
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn returns transaction of ctx, if any, or the database
+func (s *Storage) conn(ctx context.Context) conn {
+	if tx, ok := storage.TxFromContext(ctx); ok {
+		if tx, ok := tx.(*sql.Tx); ok {
+			return tx
+		}
+	}
+
+	return s.db
+}
+
+// InTx runs fn in a transaction. Storage calls made with the ctx passed
+// to fn join it. The transaction is committed if fn returns nil and rolled
+// back otherwise. Nested calls join the outer transaction.
+//
+// The pool holds a single connection, so calls made inside fn without its
+// ctx block until the transaction ends.
+func (s *Storage) InTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	const op = "storage.sqlite.InTx"
+
+	if storage.InTx(ctx) {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(storage.ContextWithTx(ctx, tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/internal/lib/logger/handlers/slogdiscard"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/babs-corp/babs-maps-auth/internal/storage/migrator"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage opens a migrated database in a temporary file
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+	log := slog.New(slogdiscard.NewDiscardHandler())
+	require.NoError(t, migrator.Up(log, "sqlite://"+path))
+
+	s, err := New(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestStorage_InTx_Commit(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	var id uuid.UUID
+	err := s.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = s.SaveUser(ctx, "a@example.com", []byte("hash"))
+		if err != nil {
+			return err
+		}
+		return s.UpdatePassHash(ctx, id, []byte("new"))
+	})
+	require.NoError(t, err)
+
+	user, err := s.UserById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new"), user.PassHash)
+}
+
+func TestStorage_InTx_Rollback(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+
+	err := s.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.SaveUser(ctx, "a@example.com", []byte("hash")); err != nil {
+			return err
+		}
+
+		// nested call joins the outer transaction
+		return s.InTx(ctx, func(ctx context.Context) error {
+			_, err := s.User(ctx, "a@example.com")
+			require.NoError(t, err, "transaction must see its own writes")
+
+			return errFailed
+		})
+	})
+	require.ErrorIs(t, err, errFailed)
+
+	_, err = s.User(ctx, "a@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func TestStorage_InTx_RollbackOnPanic(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	assert.Panics(t, func() {
+		_ = s.InTx(ctx, func(ctx context.Context) error {
+			_, err := s.SaveUser(ctx, "a@example.com", []byte("hash"))
+			require.NoError(t, err)
+			panic("boom")
+		})
+	})
+
+	_, err := s.User(ctx, "a@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	// the connection was released
+	_, err = s.SaveUser(ctx, "a@example.com", []byte("hash"))
+	assert.NoError(t, err)
+}
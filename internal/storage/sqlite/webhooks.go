@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/services/webhooks"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+)
+
+var _ webhooks.Store = (*Storage)(nil)
+
+func (s *Storage) SaveWebhookSubscription(ctx context.Context, sub models.WebhookSubscription) error {
+	const op = "storage.sqlite.SaveWebhookSubscription"
+
+	sub.CreatedAt = sub.CreatedAt.UTC()
+	_, err := s.conn(ctx).ExecContext(ctx, storage.WebhookSubscriptionInsertSQL, storage.WebhookSubscriptionInsertArgs(sub)...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) WebhookSubscription(ctx context.Context, id uuid.UUID) (models.WebhookSubscription, error) {
+	const op = "storage.sqlite.WebhookSubscription"
+
+	row := s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+storage.WebhookSubscriptionColumns+" FROM webhook_subscriptions WHERE id = ?", id)
+
+	sub, err := storage.ScanWebhookSubscription(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, storage.ErrWebhookSubscriptionNotFound)
+		}
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sub, nil
+}
+
+func (s *Storage) WebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	const op = "storage.sqlite.WebhookSubscriptions"
+
+	rows, err := s.conn(ctx).QueryContext(ctx,
+		"SELECT "+storage.WebhookSubscriptionColumns+" FROM webhook_subscriptions ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		sub, err := storage.ScanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subs, nil
+}
+
+func (s *Storage) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	const op = "storage.sqlite.DeleteWebhookSubscription"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookSubscriptionNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveWebhookDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	const op = "storage.sqlite.SaveWebhookDeliveries"
+
+	err := s.InTx(ctx, func(ctx context.Context) error {
+		for _, d := range deliveries {
+			d = utcDelivery(d)
+			if _, err := s.conn(ctx).ExecContext(ctx, storage.WebhookDeliveryInsertSQL, storage.WebhookDeliveryInsertArgs(d)...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	const op = "storage.sqlite.ClaimWebhookDeliveries"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, storage.WebhookDeliveryClaimSQL(""), leaseUntil.UTC(), now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := storage.ScanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	const op = "storage.sqlite.UpdateWebhookDelivery"
+
+	d = utcDelivery(d)
+	res, err := s.conn(ctx).ExecContext(ctx, storage.WebhookDeliveryUpdateSQL, storage.WebhookDeliveryUpdateArgs(d)...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookDeliveryNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) WebhookDelivery(ctx context.Context, id uuid.UUID) (models.WebhookDelivery, error) {
+	const op = "storage.sqlite.WebhookDelivery"
+
+	row := s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+storage.WebhookDeliveryColumns+" FROM webhook_deliveries WHERE id = ?", id)
+
+	d, err := storage.ScanWebhookDelivery(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, storage.ErrWebhookDeliveryNotFound)
+		}
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return d, nil
+}
+
+func (s *Storage) WebhookDeliveries(ctx context.Context, q models.WebhookDeliveryQuery) ([]models.WebhookDelivery, error) {
+	const op = "storage.sqlite.WebhookDeliveries"
+
+	if q.After != nil {
+		after := *q.After
+		after.CreatedAt = after.CreatedAt.UTC()
+		q.After = &after
+	}
+	query, args := storage.WebhookDeliveriesListSQL(q)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := storage.ScanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// utcDelivery normalizes times of d, which are compared as text
+func utcDelivery(d models.WebhookDelivery) models.WebhookDelivery {
+	d.NextAttemptAt = d.NextAttemptAt.UTC()
+	d.CreatedAt = d.CreatedAt.UTC()
+	if d.DeliveredAt != nil {
+		at := d.DeliveredAt.UTC()
+		d.DeliveredAt = &at
+	}
+	return d
+}
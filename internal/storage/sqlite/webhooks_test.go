@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+	"github.com/babs-corp/babs-maps-auth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Webhooks(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	appID := 1
+	sub := models.WebhookSubscription{
+		ID:         uuid.New(),
+		AppID:      &appID,
+		URL:        "https://example.com/hook",
+		Secret:     "whsec_test",
+		EventTypes: []string{models.EventUserCreated},
+		CreatedAt:  now,
+	}
+	require.NoError(t, s.SaveWebhookSubscription(ctx, sub))
+
+	got, err := s.WebhookSubscription(ctx, sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sub.Secret, got.Secret)
+	assert.Equal(t, sub.EventTypes, got.EventTypes)
+	assert.Equal(t, &appID, got.AppID)
+	assert.Nil(t, got.OrgID)
+
+	var deliveries []models.WebhookDelivery
+	for i := 0; i < 3; i++ {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:             uuid.New(),
+			SubscriptionID: sub.ID,
+			EventID:        uuid.New(),
+			EventType:      models.EventUserCreated,
+			Payload:        json.RawMessage(`{"type":"user.created"}`),
+			Status:         models.WebhookPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+	require.NoError(t, s.SaveWebhookDeliveries(ctx, deliveries))
+
+	// a delivery of the same event to the same subscription is skipped
+	dup := deliveries[0]
+	dup.ID = uuid.New()
+	require.NoError(t, s.SaveWebhookDeliveries(ctx, []models.WebhookDelivery{dup}))
+
+	lease := now.Add(time.Minute)
+	claimed, err := s.ClaimWebhookDeliveries(ctx, now, lease, 2)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.JSONEq(t, `{"type":"user.created"}`, string(claimed[0].Payload))
+	assert.True(t, claimed[0].NextAttemptAt.Equal(lease))
+
+	claimed, err = s.ClaimWebhookDeliveries(ctx, now, lease, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	d := claimed[0]
+	d.Status = models.WebhookDead
+	d.Attempts = 8
+	d.LastStatus = 500
+	d.LastError = "unexpected status 500"
+	require.NoError(t, s.UpdateWebhookDelivery(ctx, d))
+
+	// dead deliveries are not claimed
+	claimed, err = s.ClaimWebhookDeliveries(ctx, lease, lease.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Len(t, claimed, 2)
+
+	got2, err := s.WebhookDelivery(ctx, d.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDead, got2.Status)
+	assert.Equal(t, "unexpected status 500", got2.LastError)
+
+	_, err = s.WebhookDelivery(ctx, uuid.New())
+	assert.ErrorIs(t, err, storage.ErrWebhookDeliveryNotFound)
+
+	page, err := s.WebhookDeliveries(ctx, models.WebhookDeliveryQuery{
+		Limit:  10,
+		Filter: models.WebhookDeliveryFilter{Status: models.WebhookDead},
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, d.ID, page[0].ID)
+
+	page, err = s.WebhookDeliveries(ctx, models.WebhookDeliveryQuery{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, deliveries[2].ID, page[0].ID)
+
+	page, err = s.WebhookDeliveries(ctx, models.WebhookDeliveryQuery{
+		Limit: 10,
+		After: &models.WebhookDeliveryCursor{CreatedAt: page[0].CreatedAt, ID: page[0].ID},
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, deliveries[1].ID, page[0].ID)
+
+	require.NoError(t, s.DeleteWebhookSubscription(ctx, sub.ID))
+	assert.ErrorIs(t, s.DeleteWebhookSubscription(ctx, sub.ID), storage.ErrWebhookSubscriptionNotFound)
+
+	page, err = s.WebhookDeliveries(ctx, models.WebhookDeliveryQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page)
+}
@@ -11,6 +11,21 @@ var (
 	ErrUserExists   = errors.New("user already exists")
 	ErrUserNotFound = errors.New("user not found")
 	ErrAppNotFound  = errors.New("app not found")
+	ErrAppExists    = errors.New("app already exists")
+
+	ErrEventNotFound = errors.New("event not found")
+
+	ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrWebhookDeliveryNotFound     = errors.New("webhook delivery not found")
+
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrGrantNotFound = errors.New("grant not found")
+
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyExists   = errors.New("api key already exists")
+
+	ErrQuotaNotFound = errors.New("quota not found")
 )
 
 type Storage interface {
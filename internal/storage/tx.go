@@ -0,0 +1,24 @@
+package storage
+
+import "context"
+
+type txKey struct{}
+
+// ContextWithTx returns ctx carrying backend specific transaction tx.
+// Storage methods called with such ctx run inside the transaction.
+func ContextWithTx(ctx context.Context, tx any) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns transaction started by any backend
+func TxFromContext(ctx context.Context) (any, bool) {
+	tx := ctx.Value(txKey{})
+	return tx, tx != nil
+}
+
+// InTx reports whether ctx carries a transaction. Changes seen inside
+// it may still be rolled back, so caches must not keep them.
+func InTx(ctx context.Context) bool {
+	_, ok := TxFromContext(ctx)
+	return ok
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+// UserColumns are columns scanned into models.User
+const UserColumns = "id, email, pass_hash, is_admin, verified, disabled, org_id, created_at"
+
+// UsersListSQL builds query selecting a page of users. It uses "?"
+// placeholders, so drivers with other bind styles must rebind it.
+// The query returns at most q.Limit+1 rows, the extra row tells that
+// there is a next page.
+func UsersListSQL(q models.UserQuery) (string, []any) {
+	where, args := usersWhere(q.Filter)
+
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	var order string
+	switch q.Sort {
+	case models.UserSortEmail:
+		order = fmt.Sprintf("email %s", dir)
+		if q.After != nil {
+			where = append(where, fmt.Sprintf("email %s ?", cmp))
+			args = append(args, q.After.Email)
+		}
+	default:
+		order = fmt.Sprintf("created_at %s, id %s", dir, dir)
+		if q.After != nil {
+			where = append(where, fmt.Sprintf("(created_at, id) %s (?, ?)", cmp))
+			args = append(args, q.After.CreatedAt, q.After.ID)
+		}
+	}
+
+	query := "SELECT " + UserColumns + " FROM users" + whereClause(where) +
+		" ORDER BY " + order + " LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	return query, args
+}
+
+// UsersCountSQL builds query counting users matching filter
+func UsersCountSQL(f models.UserFilter) (string, []any) {
+	where, args := usersWhere(f)
+
+	return "SELECT COUNT(*) FROM users" + whereClause(where), args
+}
+
+func usersWhere(f models.UserFilter) ([]string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+
+	if f.EmailPrefix != "" {
+		where = append(where, `email LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(f.EmailPrefix)+"%")
+	}
+	switch f.Role {
+	case models.RoleAdmin:
+		where = append(where, "is_admin = ?")
+		args = append(args, true)
+	case models.RoleUser:
+		where = append(where, "is_admin = ?")
+		args = append(args, false)
+	}
+	if f.Verified != nil {
+		where = append(where, "verified = ?")
+		args = append(args, *f.Verified)
+	}
+	if f.Disabled != nil {
+		where = append(where, "disabled = ?")
+		args = append(args, *f.Disabled)
+	}
+	if f.OrgID != nil {
+		where = append(where, "org_id = ?")
+		args = append(args, *f.OrgID)
+	}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.CreatedBefore)
+	}
+
+	return where, args
+}
+
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(where, " AND ")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
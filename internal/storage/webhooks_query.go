@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/babs-corp/babs-maps-auth/internal/domain/models"
+)
+
+// WebhookSubscriptionColumns are columns scanned by ScanWebhookSubscription
+const WebhookSubscriptionColumns = "id, app_id, org_id, url, secret, event_types, created_at"
+
+// WebhookSubscriptionInsertSQL inserts models.WebhookSubscription with "?"
+// placeholders
+const WebhookSubscriptionInsertSQL = "INSERT INTO webhook_subscriptions (" + WebhookSubscriptionColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?)"
+
+// WebhookSubscriptionInsertArgs returns arguments of WebhookSubscriptionInsertSQL
+func WebhookSubscriptionInsertArgs(s models.WebhookSubscription) []any {
+	return []any{s.ID, s.AppID, s.OrgID, s.URL, s.Secret, strings.Join(s.EventTypes, ","), s.CreatedAt}
+}
+
+// ScanWebhookSubscription reads a row selected with WebhookSubscriptionColumns
+func ScanWebhookSubscription(row interface{ Scan(dest ...any) error }) (models.WebhookSubscription, error) {
+	var (
+		s          models.WebhookSubscription
+		eventTypes string
+	)
+	if err := row.Scan(&s.ID, &s.AppID, &s.OrgID, &s.URL, &s.Secret, &eventTypes, &s.CreatedAt); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	if eventTypes != "" {
+		s.EventTypes = strings.Split(eventTypes, ",")
+	}
+
+	return s, nil
+}
+
+// WebhookDeliveryColumns are columns scanned by ScanWebhookDeliveries
+const WebhookDeliveryColumns = "id, subscription_id, event_id, event_type, payload, status, attempts, " +
+	"next_attempt_at, last_status, last_error, created_at, delivered_at"
+
+// WebhookDeliveryInsertSQL inserts models.WebhookDelivery with "?"
+// placeholders, skipping events already stored for the subscription
+const WebhookDeliveryInsertSQL = "INSERT INTO webhook_deliveries (" + WebhookDeliveryColumns + ") " +
+	"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (subscription_id, event_id) DO NOTHING"
+
+// WebhookDeliveryInsertArgs returns arguments of WebhookDeliveryInsertSQL
+func WebhookDeliveryInsertArgs(d models.WebhookDelivery) []any {
+	return []any{
+		d.ID, d.SubscriptionID, d.EventID, d.EventType, string(d.Payload), d.Status, d.Attempts,
+		d.NextAttemptAt, d.LastStatus, d.LastError, d.CreatedAt, d.DeliveredAt,
+	}
+}
+
+// WebhookDeliveryUpdateSQL saves the outcome of an attempt
+const WebhookDeliveryUpdateSQL = "UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, " +
+	"last_status = ?, last_error = ?, delivered_at = ? WHERE id = ?"
+
+// WebhookDeliveryUpdateArgs returns arguments of WebhookDeliveryUpdateSQL
+func WebhookDeliveryUpdateArgs(d models.WebhookDelivery) []any {
+	return []any{d.Status, d.Attempts, d.NextAttemptAt, d.LastStatus, d.LastError, d.DeliveredAt, d.ID}
+}
+
+// WebhookDeliveryClaimSQL is OutboxClaimSQL for pending webhook deliveries
+func WebhookDeliveryClaimSQL(lock string) string {
+	return `UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= ?
+			ORDER BY created_at, id
+			LIMIT ? ` + lock + `
+		)
+		RETURNING ` + WebhookDeliveryColumns
+}
+
+// WebhookDeliveriesListSQL builds query selecting a page of deliveries,
+// newest first. Like AuditListSQL it uses "?" placeholders and returns at
+// most q.Limit+1 rows.
+func WebhookDeliveriesListSQL(q models.WebhookDeliveryQuery) (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+
+	f := q.Filter
+	if f.SubscriptionID != nil {
+		where = append(where, "subscription_id = ?")
+		args = append(args, *f.SubscriptionID)
+	}
+	if f.EventID != nil {
+		where = append(where, "event_id = ?")
+		args = append(args, *f.EventID)
+	}
+	if f.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, f.Status)
+	}
+	if q.After != nil {
+		where = append(where, "(created_at, id) < (?, ?)")
+		args = append(args, q.After.CreatedAt, q.After.ID)
+	}
+
+	query := "SELECT " + WebhookDeliveryColumns + " FROM webhook_deliveries" + whereClause(where) +
+		" ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	return query, args
+}
+
+// ScanWebhookDeliveries reads rows selected with WebhookDeliveryColumns
+func ScanWebhookDeliveries(rows *sql.Rows) ([]models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		d, err := ScanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ScanWebhookDelivery reads a row selected with WebhookDeliveryColumns
+func ScanWebhookDelivery(row interface{ Scan(dest ...any) error }) (models.WebhookDelivery, error) {
+	var (
+		d       models.WebhookDelivery
+		payload []byte
+	)
+	err := row.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	d.Payload = payload
+
+	return d, nil
+}
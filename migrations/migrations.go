@@ -0,0 +1,8 @@
+// Package migrations embeds SQL migrations of every storage backend,
+// so the service binary can apply them without the source tree.
+package migrations
+
+import "embed"
+
+//go:embed sqlite/*.sql postgres/*.sql
+var FS embed.FS
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_user_id_app_id;
+DROP TABLE IF EXISTS grants;
+ALTER TABLE apps DROP COLUMN first_party;
@@ -0,0 +1,16 @@
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS first_party BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- apps added by hand before consent existed are our own
+UPDATE apps SET first_party = TRUE;
+
+CREATE TABLE IF NOT EXISTS grants (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    scopes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, app_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_grants_app_id ON grants (app_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id_app_id ON sessions (user_id, app_id) WHERE revoked_at IS NULL;
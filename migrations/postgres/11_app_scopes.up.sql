@@ -0,0 +1,2 @@
+-- space-separated scopes tokens of the app may carry, none until set
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS scopes TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+ALTER TABLE apps DROP COLUMN token_ttl;
+ALTER TABLE apps DROP COLUMN grant_types;
+ALTER TABLE apps DROP COLUMN redirect_uris;
+ALTER TABLE apps DROP COLUMN type;
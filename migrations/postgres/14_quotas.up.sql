@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS quotas (
+    subject_type TEXT NOT NULL,
+    subject_id TEXT NOT NULL,
+    per_minute BIGINT NOT NULL DEFAULT 0,
+    per_day BIGINT NOT NULL DEFAULT 0,
+    per_month BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (subject_type, subject_id)
+);
+
+CREATE TABLE IF NOT EXISTS quota_buckets (
+    subject_type TEXT NOT NULL,
+    subject_id TEXT NOT NULL,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (subject_type, subject_id)
+);
+
+CREATE TABLE IF NOT EXISTS quota_usage (
+    subject_type TEXT NOT NULL,
+    subject_id TEXT NOT NULL,
+    period TEXT NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    used BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (subject_type, subject_id, period, period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_usage_period_start ON quota_usage (period, period_start);
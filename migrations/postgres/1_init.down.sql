@@ -0,0 +1,9 @@
+-- users and apps may predate these migrations, see 1_init.up.sql, so the
+-- tables and their data are kept; only what version 1 adds is dropped
+DROP INDEX IF EXISTS idx_users_org_id;
+DROP INDEX IF EXISTS idx_users_created_at;
+DROP INDEX IF EXISTS idx_users_email_pattern;
+
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled;
+ALTER TABLE users DROP COLUMN IF EXISTS verified;
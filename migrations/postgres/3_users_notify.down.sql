@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS users_changed ON users;
+DROP FUNCTION IF EXISTS notify_user_changed();
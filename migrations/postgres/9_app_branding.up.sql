@@ -0,0 +1,5 @@
+-- branding of hosted login pages, empty values fall back to defaults
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS logo_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS primary_color TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS background_color TEXT NOT NULL DEFAULT '';
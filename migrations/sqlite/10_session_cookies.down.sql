@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_sessions_cookie_hash;
+ALTER TABLE sessions DROP COLUMN cookie_hash;
@@ -0,0 +1,4 @@
+-- SHA-256 of the cookie of a browser session, NULL for sessions of tokens
+ALTER TABLE sessions ADD COLUMN cookie_hash BLOB;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_cookie_hash ON sessions (cookie_hash) WHERE cookie_hash IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE apps DROP COLUMN background_color;
+ALTER TABLE apps DROP COLUMN primary_color;
+ALTER TABLE apps DROP COLUMN logo_url;
+ALTER TABLE apps DROP COLUMN display_name;
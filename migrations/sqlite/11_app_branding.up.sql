@@ -0,0 +1,5 @@
+-- branding of hosted login pages, empty values fall back to defaults
+ALTER TABLE apps ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN logo_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN primary_color TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN background_color TEXT NOT NULL DEFAULT '';
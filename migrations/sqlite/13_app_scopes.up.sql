@@ -0,0 +1,2 @@
+-- space-separated scopes tokens of the app may carry, none until set
+ALTER TABLE apps ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
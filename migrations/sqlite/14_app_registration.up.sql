@@ -0,0 +1,8 @@
+-- secret now keeps the SHA-256 hash of the secret, secrets of existing
+-- apps have to be rotated before apps can use them
+ALTER TABLE apps ADD COLUMN type TEXT NOT NULL DEFAULT 'confidential';
+ALTER TABLE apps ADD COLUMN redirect_uris TEXT NOT NULL DEFAULT '';
+-- existing apps sign users in with the login operation
+ALTER TABLE apps ADD COLUMN grant_types TEXT NOT NULL DEFAULT 'password';
+-- seconds, 0 for the default of the service
+ALTER TABLE apps ADD COLUMN token_ttl INTEGER NOT NULL DEFAULT 0;
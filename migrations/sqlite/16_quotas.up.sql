@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS quotas (
+    subject_type TEXT NOT NULL,
+    subject_id TEXT NOT NULL,
+    per_minute INTEGER NOT NULL DEFAULT 0,
+    per_day INTEGER NOT NULL DEFAULT 0,
+    per_month INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (subject_type, subject_id)
+);
+
+CREATE TABLE IF NOT EXISTS quota_buckets (
+    subject_type TEXT NOT NULL,
+    subject_id TEXT NOT NULL,
+    tokens REAL NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (subject_type, subject_id)
+);
+
+CREATE TABLE IF NOT EXISTS quota_usage (
+    subject_type TEXT NOT NULL,
+    subject_id TEXT NOT NULL,
+    period TEXT NOT NULL,
+    period_start TIMESTAMP NOT NULL,
+    used INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (subject_type, subject_id, period, period_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_usage_period_start ON quota_usage (period, period_start);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_users_org_id;
+DROP INDEX IF EXISTS idx_users_created_at;
+
+ALTER TABLE users DROP COLUMN created_at;
+ALTER TABLE users DROP COLUMN org_id;
+ALTER TABLE users DROP COLUMN disabled;
+ALTER TABLE users DROP COLUMN verified;
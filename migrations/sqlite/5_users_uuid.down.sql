@@ -0,0 +1,20 @@
+CREATE TABLE users_int (
+    id INTEGER PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    pass_hash BLOB NOT NULL,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    verified BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    org_id TEXT,
+    created_at TIMESTAMP
+);
+
+INSERT INTO users_int (email, pass_hash, is_admin, verified, disabled, org_id, created_at)
+SELECT email, pass_hash, is_admin, verified, disabled, org_id, created_at FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_int RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_org_id ON users (org_id);
@@ -0,0 +1,30 @@
+CREATE TABLE users_uuid (
+    id TEXT PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    pass_hash BLOB NOT NULL,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    verified BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    org_id TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- existing users get random version 4 UUIDs
+INSERT INTO users_uuid (id, email, pass_hash, is_admin, verified, disabled, org_id, created_at)
+SELECT
+    lower(
+        hex(randomblob(4)) || '-' ||
+        hex(randomblob(2)) || '-4' ||
+        substr(hex(randomblob(2)), 2) || '-' ||
+        substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' ||
+        hex(randomblob(6))
+    ),
+    email, pass_hash, is_admin, verified, disabled, org_id,
+    COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_uuid RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_org_id ON users (org_id);
@@ -0,0 +1,165 @@
+// Package authz lets babs-maps resource servers authorize requests with
+// tokens of the auth service.
+//
+// Tokens are JWTs signed by the service with its Ed25519 private key
+// (EdDSA) or, in deployments without one, HS256 JWTs signed with a secret
+// shared with the service. Besides the user they carry scopes the user
+// granted to the app in the space-separated "scope" claim:
+//
+//	{"uid": "…", "email": "…", "sid": "…", "app": 7, "scope": "maps:read tiles:render", "exp": 1700000000}
+//
+// A resource server validates the token with the public key of the
+// service and checks scopes of the operation:
+//
+//	key, err := authz.ParsePublicKey(pemBytes)
+//	...
+//	claims, err := authz.ValidateWithKey(token, key)
+//	if err != nil {
+//		// 401
+//	}
+//	if err := claims.Require(authz.TilesRender); err != nil {
+//		// 403
+//	}
+//
+// or wraps its handlers with MiddlewareWithKey. Validate and Middleware
+// check HS256 tokens; anyone holding the secret can also issue tokens
+// for any user, so the secret must not be given to resource servers
+// that are not trusted as much as the service. Tokens are checked locally, so
+// revoked sessions are accepted until the token expires; operations that
+// must see revocation should validate tokens with the auth service.
+// API keys, bearer tokens starting with "bmk_", are opaque and are only
+// validated by the auth service.
+package authz
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Claims of tokens
+const (
+	ClaimUserID    = "uid"
+	ClaimEmail     = "email"
+	ClaimSessionID = "sid"
+	ClaimAppID     = "app"
+	ClaimScope     = "scope"
+	ClaimExpires   = "exp"
+)
+
+var (
+	ErrInvalidToken      = errors.New("invalid token")
+	ErrInsufficientScope = errors.New("insufficient scope")
+	ErrUnsupportedKey    = errors.New("unsupported public key")
+)
+
+// Claims are claims of a valid token
+type Claims struct {
+	UserID uuid.UUID
+	Email  string
+	// SessionID is uuid.Nil for tokens issued without a session
+	SessionID uuid.UUID
+	// AppID is the app the token was issued to, 0 for tokens issued
+	// without one
+	AppID int
+	// Scopes are sorted
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// Validate checks signature of HS256 token made with secret and its
+// expiration and returns its claims. Errors wrap ErrInvalidToken.
+func Validate(token string, secret string) (Claims, error) {
+	return validate(token, []byte(secret), jwt.SigningMethodHS256)
+}
+
+// ValidateWithKey checks signature of token made with the private key of
+// the service and its expiration and returns its claims. key is
+// ed25519.PublicKey for EdDSA or *rsa.PublicKey for RS256 tokens; the
+// algorithm follows from the key, never from the token. Errors wrap
+// ErrInvalidToken, or ErrUnsupportedKey for keys of other types.
+func ValidateWithKey(token string, key crypto.PublicKey) (Claims, error) {
+	method, err := keyMethod(key)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	return validate(token, key, method)
+}
+
+func keyMethod(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key.(type) {
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
+
+func validate(token string, key any, method jwt.SigningMethod) (Claims, error) {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{method.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	mc, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return Claims{}, fmt.Errorf("%w: unexpected claims", ErrInvalidToken)
+	}
+
+	var claims Claims
+	uid, _ := mc[ClaimUserID].(string)
+	if claims.UserID, err = uuid.Parse(uid); err != nil {
+		return Claims{}, fmt.Errorf("%w: invalid %s claim", ErrInvalidToken, ClaimUserID)
+	}
+	if sid, ok := mc[ClaimSessionID].(string); ok {
+		if claims.SessionID, err = uuid.Parse(sid); err != nil {
+			return Claims{}, fmt.Errorf("%w: invalid %s claim", ErrInvalidToken, ClaimSessionID)
+		}
+	}
+	claims.Email, _ = mc[ClaimEmail].(string)
+	if app, ok := mc[ClaimAppID].(float64); ok {
+		claims.AppID = int(app)
+	}
+	if scope, ok := mc[ClaimScope].(string); ok {
+		claims.Scopes = ParseScope(scope)
+	}
+	if exp, err := mc.GetExpirationTime(); err == nil && exp != nil {
+		claims.ExpiresAt = exp.Time
+	}
+
+	return claims, nil
+}
+
+// Has reports whether the token carries scope
+func (c Claims) Has(scope string) bool {
+	_, found := slices.BinarySearch(c.Scopes, scope)
+	return found
+}
+
+// Require returns error wrapping ErrInsufficientScope if the token lacks
+// any of scopes
+func (c Claims) Require(scopes ...string) error {
+	var missing []string
+	for _, scope := range scopes {
+		if !c.Has(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s", ErrInsufficientScope, FormatScope(missing))
+	}
+
+	return nil
+}
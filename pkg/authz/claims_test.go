@@ -0,0 +1,154 @@
+package authz_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "test-secret"
+
+func newToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestValidate(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	token := newToken(t, jwt.MapClaims{
+		authz.ClaimUserID:    userID.String(),
+		authz.ClaimEmail:     "user@example.com",
+		authz.ClaimSessionID: sessionID.String(),
+		authz.ClaimAppID:     7,
+		authz.ClaimScope:     "tiles:render maps:read",
+		authz.ClaimExpires:   exp.Unix(),
+	})
+
+	claims, err := authz.Validate(token, secret)
+	require.NoError(t, err)
+	assert.Equal(t, authz.Claims{
+		UserID:    userID,
+		Email:     "user@example.com",
+		SessionID: sessionID,
+		AppID:     7,
+		Scopes:    []string{authz.MapsRead, authz.TilesRender},
+		ExpiresAt: exp,
+	}, claims)
+
+	assert.True(t, claims.Has(authz.MapsRead))
+	assert.False(t, claims.Has(authz.MapsWrite))
+	assert.NoError(t, claims.Require(authz.MapsRead, authz.TilesRender))
+	assert.ErrorIs(t, claims.Require(authz.MapsRead, authz.MapsWrite), authz.ErrInsufficientScope)
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	valid := jwt.MapClaims{
+		authz.ClaimUserID:  uuid.NewString(),
+		authz.ClaimExpires: time.Now().Add(time.Hour).Unix(),
+	}
+	with := func(key string, value any) jwt.MapClaims {
+		claims := jwt.MapClaims{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	otherSecret, err := jwt.NewWithClaims(jwt.SigningMethodHS256, valid).SignedString([]byte("other"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "garbage", token: "not-a-token"},
+		{name: "other secret", token: otherSecret},
+		{name: "expired", token: newToken(t, with(authz.ClaimExpires, time.Now().Add(-time.Minute).Unix()))},
+		{name: "no expiration", token: newToken(t, with(authz.ClaimExpires, nil))},
+		{name: "no user", token: newToken(t, with(authz.ClaimUserID, nil))},
+		{name: "invalid session", token: newToken(t, with(authz.ClaimSessionID, "42"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := authz.Validate(tt.token, secret)
+			assert.ErrorIs(t, err, authz.ErrInvalidToken)
+		})
+	}
+}
+
+func TestValidateWithKey(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPub, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	claims := jwt.MapClaims{
+		authz.ClaimUserID:  userID.String(),
+		authz.ClaimScope:   authz.MapsRead,
+		authz.ClaimExpires: time.Now().Add(time.Hour).Unix(),
+	}
+	sign := func(method jwt.SigningMethod, key any) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return token
+	}
+
+	edToken := sign(jwt.SigningMethodEdDSA, edPriv)
+	rsaToken := sign(jwt.SigningMethodRS256, rsaPriv)
+
+	got, err := authz.ValidateWithKey(edToken, edPub)
+	require.NoError(t, err)
+	assert.Equal(t, userID, got.UserID)
+	assert.True(t, got.Has(authz.MapsRead))
+
+	got, err = authz.ValidateWithKey(rsaToken, &rsaPriv.PublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, userID, got.UserID)
+
+	tests := []struct {
+		name  string
+		token string
+		key   any
+	}{
+		{name: "other key", token: sign(jwt.SigningMethodEdDSA, otherPriv), key: edPub},
+		{name: "rsa token, ed25519 key", token: rsaToken, key: edPub},
+		{name: "ed25519 token, rsa key", token: edToken, key: &rsaPriv.PublicKey},
+		// the public key is no secret, HS256 tokens made with it must fail
+		{name: "public key as hmac secret", token: sign(jwt.SigningMethodHS256, []byte(edPub)), key: edPub},
+		{name: "shared secret", token: newToken(t, claims), key: otherPub},
+		{name: "unsigned", token: sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType), key: edPub},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := authz.ValidateWithKey(tt.token, tt.key)
+			assert.ErrorIs(t, err, authz.ErrInvalidToken)
+		})
+	}
+
+	_, err = authz.ValidateWithKey(edToken, edPriv)
+	assert.ErrorIs(t, err, authz.ErrUnsupportedKey)
+	_, err = authz.ValidateWithKey(edToken, []byte(secret))
+	assert.ErrorIs(t, err, authz.ErrUnsupportedKey)
+}
@@ -0,0 +1,22 @@
+package authz
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ParsePublicKey parses PEM encoded public key of the service for
+// ValidateWithKey: an Ed25519 or RSA key in PKIX ("PUBLIC KEY") form, as
+// printed by "openssl pkey -pubout".
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%w: expected PEM encoded Ed25519 or RSA public key", ErrUnsupportedKey)
+}
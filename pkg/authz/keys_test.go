@@ -0,0 +1,52 @@
+package authz_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePEM(t *testing.T, typ string, key any) []byte {
+	t.Helper()
+
+	var (
+		der []byte
+		err error
+	)
+	if typ == "PRIVATE KEY" {
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+	} else {
+		der, err = x509.MarshalPKIXPublicKey(key)
+	}
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+func TestParsePublicKey(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	key, err := authz.ParsePublicKey(encodePEM(t, "PUBLIC KEY", edPub))
+	require.NoError(t, err)
+	assert.Equal(t, edPub, key)
+
+	key, err = authz.ParsePublicKey(encodePEM(t, "PUBLIC KEY", &rsaPriv.PublicKey))
+	require.NoError(t, err)
+	assert.Equal(t, &rsaPriv.PublicKey, key)
+
+	// private keys must not be handed to resource servers
+	_, err = authz.ParsePublicKey(encodePEM(t, "PRIVATE KEY", edPriv))
+	assert.ErrorIs(t, err, authz.ErrUnsupportedKey)
+	_, err = authz.ParsePublicKey([]byte("not a key"))
+	assert.ErrorIs(t, err, authz.ErrUnsupportedKey)
+}
@@ -0,0 +1,82 @@
+package authz
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type claimsKey struct{}
+
+// Middleware authorizes requests with HS256 bearer tokens carrying every
+// scope of required. Other requests are answered with 401 or 403 and
+// WWW-Authenticate header of RFC 6750; handlers get claims with
+// FromContext.
+func Middleware(secret string, required ...string) func(http.Handler) http.Handler {
+	return middleware(func(token string) (Claims, error) {
+		return Validate(token, secret)
+	}, required)
+}
+
+// MiddlewareWithKey is Middleware for tokens signed with the private key
+// of the service, see ValidateWithKey. It panics if key is of unsupported
+// type.
+func MiddlewareWithKey(key crypto.PublicKey, required ...string) func(http.Handler) http.Handler {
+	if _, err := keyMethod(key); err != nil {
+		panic(err)
+	}
+
+	return middleware(func(token string) (Claims, error) {
+		return ValidateWithKey(token, key)
+	}, required)
+}
+
+func middleware(validate func(token string) (Claims, error), required []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="babs-maps"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := validate(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="babs-maps", error="invalid_token"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if err := claims.Require(required...); err != nil {
+				w.Header().Set("WWW-Authenticate",
+					fmt.Sprintf(`Bearer realm="babs-maps", error="insufficient_scope", scope="%s"`, FormatScope(required)))
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// WithClaims returns ctx carrying claims
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns claims of the token authorized by Middleware
+func FromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
@@ -0,0 +1,111 @@
+package authz_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/babs-corp/babs-maps-auth/pkg/authz"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	userID := uuid.New()
+	token := newToken(t, jwt.MapClaims{
+		authz.ClaimUserID:  userID.String(),
+		authz.ClaimScope:   authz.TilesRender,
+		authz.ClaimExpires: time.Now().Add(time.Hour).Unix(),
+	})
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, found := authz.FromContext(r.Context())
+		assert.True(t, found)
+		assert.Equal(t, userID, claims.UserID)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		required      []string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{
+			name:          "authorized",
+			authorization: "Bearer " + token,
+			required:      []string{authz.TilesRender},
+			wantStatus:    http.StatusOK,
+		},
+		{
+			name:          "no token",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="babs-maps"`,
+		},
+		{
+			name:          "invalid token",
+			authorization: "Bearer " + token + "x",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="babs-maps", error="invalid_token"`,
+		},
+		{
+			name:          "insufficient scope",
+			authorization: "Bearer " + token,
+			required:      []string{authz.MapsWrite, authz.TilesRender},
+			wantStatus:    http.StatusForbidden,
+			wantChallenge: `Bearer realm="babs-maps", error="insufficient_scope", scope="maps:write tiles:render"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/tiles/1/2/3", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			authz.Middleware(secret, tt.required...)(ok).ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantChallenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestMiddlewareWithKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		authz.ClaimUserID:  uuid.NewString(),
+		authz.ClaimScope:   authz.TilesRender,
+		authz.ClaimExpires: time.Now().Add(time.Hour).Unix(),
+	}).SignedString(priv)
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := authz.MiddlewareWithKey(pub, authz.TilesRender)(ok)
+
+	for authorization, want := range map[string]int{
+		"Bearer " + token: http.StatusOK,
+		"Bearer " + newToken(t, jwt.MapClaims{
+			authz.ClaimUserID:  uuid.NewString(),
+			authz.ClaimScope:   authz.TilesRender,
+			authz.ClaimExpires: time.Now().Add(time.Hour).Unix(),
+		}): http.StatusUnauthorized,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/tiles/1/2/3", nil)
+		r.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, want, w.Code)
+	}
+
+	assert.Panics(t, func() { authz.MiddlewareWithKey([]byte(secret)) })
+}
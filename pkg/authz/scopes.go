@@ -0,0 +1,62 @@
+package authz
+
+import (
+	"slices"
+	"strings"
+)
+
+// Scopes of babs-maps APIs
+const (
+	MapsRead      = "maps:read"
+	MapsWrite     = "maps:write"
+	LayersRead    = "layers:read"
+	LayersWrite   = "layers:write"
+	TilesRender   = "tiles:render"
+	GeocodeSearch = "geocode:search"
+)
+
+// Scope is a permission an app may get to act on behalf of the user
+type Scope struct {
+	Name        string `json:"name"`
+	Description string `json:"description" doc:"shown to users on the consent page"`
+}
+
+// registry lists every scope tokens may carry
+var registry = []Scope{
+	{Name: MapsRead, Description: "View your maps"},
+	{Name: MapsWrite, Description: "Create, change and delete your maps"},
+	{Name: LayersRead, Description: "View layers of your maps"},
+	{Name: LayersWrite, Description: "Create, change and delete layers of your maps"},
+	{Name: TilesRender, Description: "Render map tiles for you"},
+	{Name: GeocodeSearch, Description: "Search addresses and places for you"},
+}
+
+// Scopes returns every known scope
+func Scopes() []Scope {
+	return slices.Clone(registry)
+}
+
+// Lookup returns the known scope of name
+func Lookup(name string) (Scope, bool) {
+	i := slices.IndexFunc(registry, func(s Scope) bool { return s.Name == name })
+	if i < 0 {
+		return Scope{}, false
+	}
+
+	return registry[i], true
+}
+
+// ParseScope splits space-separated scope claim or parameter. Scopes are
+// returned sorted and without duplicates; they are not checked against
+// the registry.
+func ParseScope(scope string) []string {
+	scopes := strings.Fields(scope)
+	slices.Sort(scopes)
+
+	return slices.Compact(scopes)
+}
+
+// FormatScope joins scopes into scope claim or parameter
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}